@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/facebookincubator/go-belt"
+	"github.com/facebookincubator/go-belt/tool/logger"
+	"github.com/facebookincubator/go-belt/tool/logger/implementation/logrus"
+	"github.com/spf13/pflag"
+	"github.com/xaionaro-go/audio/pkg/audio"
+	"github.com/xaionaro-go/audio/pkg/audio/audiod"
+	_ "github.com/xaionaro-go/audio/pkg/audio/backends/oto"
+	_ "github.com/xaionaro-go/audio/pkg/audio/backends/portaudio"
+	_ "github.com/xaionaro-go/audio/pkg/audio/backends/pulseaudio"
+)
+
+func main() {
+	loggerLevel := logger.LevelDebug
+	pflag.Var(&loggerLevel, "log-level", "Log level")
+	socketPath := pflag.String("socket", audiod.DefaultSocketPath(), "path to the Unix socket to listen")
+	pflag.Parse()
+
+	l := logrus.Default().WithLevel(loggerLevel)
+	ctx := logger.CtxWithLogger(context.Background(), l)
+	logger.Default = func() logger.Logger {
+		return l
+	}
+	defer belt.Flush(ctx)
+
+	ctx, cancelFn := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer cancelFn()
+
+	// the daemon backend is deliberately not imported here: otherwise
+	// the daemon would try to play through itself.
+	player := audio.NewPlayerAuto(ctx)
+	defer player.Close()
+	recorder := audio.NewRecorderAuto(ctx)
+	defer recorder.Close()
+	logger.Infof(ctx, "serving '%s' (player: %T, recorder: %T)", *socketPath, player.PlayerPCM, recorder.RecorderPCM)
+
+	srv := audiod.NewServer(player, recorder)
+	assertNoError(srv.ListenAndServe(ctx, *socketPath))
+}
+
+func assertNoError(err error) {
+	if err != nil {
+		panic(err)
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"io"
 	"net/http"
 	_ "net/http/pprof"
+	"sync"
 	"time"
 
 	"github.com/facebookincubator/go-belt"
@@ -17,6 +18,7 @@ import (
 	_ "github.com/xaionaro-go/audio/pkg/audio/backends/oto"
 	_ "github.com/xaionaro-go/audio/pkg/audio/backends/portaudio"
 	"github.com/xaionaro-go/audio/pkg/audio/backends/pulseaudio"
+	"github.com/xaionaro-go/audio/pkg/audio/resampler"
 	"github.com/xaionaro-go/audio/pkg/noisesuppression/implementations/rnnoise"
 	"github.com/xaionaro-go/audio/pkg/noisesuppressionstream"
 	"github.com/xaionaro-go/datacounter"
@@ -28,6 +30,7 @@ func main() {
 	pflag.Var(&loggerLevel, "log-level", "Log level")
 	netPprofAddr := pflag.String("net-pprof-listen-addr", "", "an address to listen for incoming net/pprof connections")
 	noiseSuppressionFlag := pflag.Bool("noise-suppression", false, "enable noise suppression using RNNoise")
+	driftCompensationFlag := pflag.Duration("drift-compensation", 0, "compensate the clock drift between the devices by resampling, keeping the given amount of audio buffered (0 disables)")
 	pflag.Parse()
 
 	l := logrus.Default().WithLevel(loggerLevel)
@@ -53,6 +56,9 @@ func main() {
 		w io.Writer
 	)
 	r, w = io.Pipe()
+	if *driftCompensationFlag > 0 {
+		r, w = newDriftCompensator(ctx, *driftCompensationFlag)
+	}
 	wc := datacounter.NewWriterCounter(w)
 
 	logger.Tracef(ctx, "recorder.RecordPCM")
@@ -115,6 +121,96 @@ func main() {
 	<-context.Background().Done()
 }
 
+const (
+	sampleRate = 48000
+	channels   = 2
+	frameSize  = channels * 4
+)
+
+// newDriftCompensator returns a buffer to put between the recorder and the
+// player, which is kept at the target fill level by resampling its output.
+func newDriftCompensator(ctx context.Context, target time.Duration) (io.Reader, io.Writer) {
+	targetFill := int(target.Seconds()*sampleRate) * frameSize
+	buf := newFIFO(targetFill*4, frameSize)
+	format := resampler.Format{
+		Channels:   channels,
+		SampleRate: sampleRate,
+		PCMFormat:  audio.PCMFormatFloat32LE,
+	}
+	r, err := resampler.NewResampler(format, buf, format, resampler.OptionVariableRatio(true))
+	assertNoError(err)
+
+	controller := resampler.NewRatioController(resampler.DefaultRatioControllerConfig(float64(targetFill)))
+	observability.Go(ctx, func(ctx context.Context) {
+		const interval = 100 * time.Millisecond
+		t := time.NewTicker(interval)
+		defer t.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-t.C:
+				fill := buf.Len()
+				ratio := controller.Update(float64(fill), interval)
+				assertNoError(r.SetRatio(ratio))
+				logger.Tracef(ctx, "drift compensation: fill:%d/%d, ratio:%f", fill, targetFill, ratio)
+			}
+		}
+	})
+	return r, buf
+}
+
+// fifo is a bounded buffer of whole frames; on overflow the oldest frames
+// are dropped.
+type fifo struct {
+	locker    sync.Mutex
+	cond      *sync.Cond
+	data      []byte
+	limit     int
+	frameSize int
+}
+
+func newFIFO(limit, frameSize int) *fifo {
+	f := &fifo{
+		limit:     limit,
+		frameSize: frameSize,
+	}
+	f.cond = sync.NewCond(&f.locker)
+	return f
+}
+
+func (f *fifo) Write(p []byte) (int, error) {
+	f.locker.Lock()
+	defer f.locker.Unlock()
+	f.data = append(f.data, p...)
+	if overflow := len(f.data) - f.limit; overflow > 0 {
+		overflow = min((overflow+f.frameSize-1)/f.frameSize*f.frameSize, len(f.data))
+		f.data = f.data[:copy(f.data, f.data[overflow:])]
+	}
+	f.cond.Broadcast()
+	return len(p), nil
+}
+
+func (f *fifo) Read(p []byte) (int, error) {
+	f.locker.Lock()
+	defer f.locker.Unlock()
+	for {
+		n := min(len(f.data), len(p)) / f.frameSize * f.frameSize
+		if n > 0 {
+			copy(p, f.data[:n])
+			f.data = f.data[:copy(f.data, f.data[n:])]
+			return n, nil
+		}
+		f.cond.Wait()
+	}
+}
+
+func (f *fifo) Len() int {
+	f.locker.Lock()
+	defer f.locker.Unlock()
+	return len(f.data)
+}
+
 func assertNoError(err error) {
 	if err != nil {
 		panic(err)
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/spf13/pflag"
+	"github.com/xaionaro-go/audio/pkg/audio/pcmsniff"
+	"github.com/xaionaro-go/audio/pkg/audio/types"
+)
+
+func syntaxExit(message string) {
+	fmt.Fprintf(os.Stderr, "syntax error: %s\n", message)
+	pflag.Usage()
+	os.Exit(2)
+}
+
+func main() {
+	cfg := pcmsniff.DefaultConfig()
+	formatsFlag := pflag.StringSlice("formats", nil, "candidate PCM formats (default: all the common linear formats)")
+	channelsFlag := pflag.UintSlice("channels", []uint{1, 2}, "candidate amounts of channels")
+	maxBytes := pflag.Int("max-bytes", cfg.MaxBytes, "analyze only the first N bytes (0 means everything)")
+	top := pflag.Int("top", 5, "amount of the candidates to print")
+	pflag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: %s [options] <file|->\n", os.Args[0])
+		pflag.PrintDefaults()
+	}
+	pflag.Parse()
+	if pflag.NArg() != 1 {
+		syntaxExit("expected one argument")
+	}
+
+	if len(*formatsFlag) > 0 {
+		cfg.PCMFormats = cfg.PCMFormats[:0]
+		for _, s := range *formatsFlag {
+			pcmFormat := types.PCMFormatFromString(s)
+			if pcmFormat == types.UndefinedPCMFormat {
+				syntaxExit(fmt.Sprintf("unknown PCM format '%s'", s))
+			}
+			cfg.PCMFormats = append(cfg.PCMFormats, pcmFormat)
+		}
+	}
+	cfg.Channels = cfg.Channels[:0]
+	for _, channels := range *channelsFlag {
+		cfg.Channels = append(cfg.Channels, types.Channel(channels))
+	}
+	cfg.MaxBytes = *maxBytes
+
+	var r io.Reader = os.Stdin
+	if filePath := pflag.Arg(0); filePath != "-" {
+		f, err := os.Open(filePath)
+		assertNoError(err)
+		defer f.Close()
+		r = f
+	}
+	if cfg.MaxBytes > 0 {
+		r = io.LimitReader(r, int64(cfg.MaxBytes))
+	}
+	data, err := io.ReadAll(r)
+	assertNoError(err)
+
+	candidates, err := pcmsniff.Analyze(data, cfg)
+	assertNoError(err)
+
+	fmt.Printf("%-10s %-8s %-6s %-6s %-8s %-6s %-6s %-6s\n", "FORMAT", "CHANNELS", "SCORE", "SMOOTH", "FLATNESS", "CORR", "CONC", "VALID")
+	for _, c := range candidates[:min(*top, len(candidates))] {
+		fmt.Printf("%-10s %-8d %-6.3f %-6.3f %-8.3f %-6.3f %-6.3f %-6.3f\n",
+			c.PCMFormat, c.Channels, c.Score, c.Smoothness, c.SpectralFlatness, c.ChannelCorrelation, c.Concentration, c.Validity)
+	}
+	best := candidates[0]
+	fmt.Printf("\nmost likely: %s, %d channel(s)\n", strings.ToUpper(best.PCMFormat.String()), best.Channels)
+}
+
+func assertNoError(err error) {
+	if err != nil {
+		panic(err)
+	}
+}
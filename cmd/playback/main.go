@@ -3,8 +3,6 @@ package main
 import (
 	"context"
 	_ "embed"
-	"os"
-	"time"
 
 	"github.com/facebookincubator/go-belt"
 	"github.com/facebookincubator/go-belt/tool/logger"
@@ -21,7 +19,7 @@ func main() {
 	pflag.Parse()
 
 	if pflag.NArg() != 1 {
-		panic("expected exactly one positional argument: path to the float32le 48K 2ch WAV file")
+		panic("expected exactly one positional argument: path to the audio file (WAV, Ogg Vorbis, ...)")
 	}
 	filePath := pflag.Arg(0)
 
@@ -33,15 +31,11 @@ func main() {
 	defer belt.Flush(ctx)
 
 	logger.Infof(ctx, "starting...")
-	file, err := os.Open(filePath)
-	assertNoError(err)
-	defer file.Close()
-
 	player := audio.NewPlayerAuto(ctx)
 	defer player.Close()
-	logger.Tracef(ctx, "player.PlayPCM")
-	streamPlay, err := player.PlayPCM(ctx, 48000, 2, audio.PCMFormatFloat32LE, 100*time.Millisecond, file)
-	logger.Tracef(ctx, "/player.PlayPCM: %v", err)
+	logger.Tracef(ctx, "player.PlayFile")
+	streamPlay, err := player.PlayFile(ctx, filePath)
+	logger.Tracef(ctx, "/player.PlayFile: %v", err)
 	assertNoError(err)
 	defer streamPlay.Close()
 	logger.Infof(ctx, "started (file -> %T)", player.PlayerPCM)
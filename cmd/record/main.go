@@ -3,7 +3,12 @@ package main
 import (
 	"context"
 	_ "embed"
+	"io"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
 	"time"
 
 	"github.com/facebookincubator/go-belt"
@@ -14,6 +19,8 @@ import (
 	_ "github.com/xaionaro-go/audio/pkg/audio/backends/oto"
 	_ "github.com/xaionaro-go/audio/pkg/audio/backends/portaudio"
 	"github.com/xaionaro-go/audio/pkg/audio/backends/pulseaudio"
+	"github.com/xaionaro-go/audio/pkg/audio/codec/opus"
+	"github.com/xaionaro-go/audio/pkg/audio/codec/wav"
 	"github.com/xaionaro-go/datacounter"
 	"github.com/xaionaro-go/observability"
 )
@@ -21,6 +28,7 @@ import (
 func main() {
 	loggerLevel := logger.LevelDebug
 	pflag.Var(&loggerLevel, "log-level", "Log level")
+	outputPath := pflag.String("output", "-", "path to the WAV (or Ogg Opus, if the extension is \".opus\") file to write (\"-\" means stdout)")
 	pflag.Parse()
 
 	l := logrus.Default().WithLevel(loggerLevel)
@@ -30,12 +38,42 @@ func main() {
 	}
 	defer belt.Flush(ctx)
 
+	ctx, cancelFn := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer cancelFn()
+
+	wavHeader := wav.Header{
+		Channels:   2,
+		SampleRate: 48000,
+		PCMFormat:  audio.PCMFormatFloat32LE,
+	}
+	var (
+		fileWriter io.WriteCloser
+		err        error
+	)
+	switch {
+	case *outputPath == "-":
+		// if stdout is redirected to a file, the header is finalized on exit;
+		// otherwise it is a valid streaming WAV anyway
+		fileWriter, err = wav.NewWriter(os.Stdout, wavHeader)
+	case strings.ToLower(filepath.Ext(*outputPath)) == ".opus":
+		opusConfig := opus.DefaultEncoderConfig(wavHeader.SampleRate, wavHeader.Channels)
+		opusConfig.PCMFormat = wavHeader.PCMFormat
+		opusConfig.Application = opus.ApplicationAudio
+		fileWriter, err = opus.Create(*outputPath, opusConfig)
+	default:
+		fileWriter, err = wav.Create(*outputPath, wavHeader)
+	}
+	assertNoError(err)
+	defer func() {
+		assertNoError(fileWriter.Close())
+	}()
+
 	logger.Infof(ctx, "starting...")
 	recorder := audio.NewRecorderAuto(ctx)
 	defer recorder.Close()
-	wc := datacounter.NewWriterCounter(os.Stdout)
+	wc := datacounter.NewWriterCounter(fileWriter)
 	logger.Tracef(ctx, "recorder.RecordPCM")
-	streamRecord, err := recorder.RecordPCM(ctx, 48000, 2, audio.PCMFormatFloat32LE, wc)
+	streamRecord, err := recorder.RecordPCM(ctx, wavHeader.SampleRate, wavHeader.Channels, wavHeader.PCMFormat, wc)
 	logger.Tracef(ctx, "/recorder.RecordPCM: %v", err)
 	assertNoError(err)
 	defer func() {
@@ -56,7 +94,8 @@ func main() {
 			}
 		}
 	})
-	<-context.Background().Done()
+	<-ctx.Done()
+	logger.Infof(ctx, "finishing...")
 }
 
 func assertNoError(err error) {
@@ -0,0 +1,84 @@
+package audiod
+
+import (
+	"bytes"
+	"context"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/xaionaro-go/audio/pkg/audio/backends/virtual"
+	"github.com/xaionaro-go/audio/pkg/audio/types"
+)
+
+type syncBuffer struct {
+	locker sync.Mutex
+	buffer bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.locker.Lock()
+	defer b.locker.Unlock()
+	return b.buffer.Write(p)
+}
+
+func (b *syncBuffer) Bytes() []byte {
+	b.locker.Lock()
+	defer b.locker.Unlock()
+	return bytes.Clone(b.buffer.Bytes())
+}
+
+func TestDaemon(t *testing.T) {
+	ctx, cancelFn := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancelFn()
+
+	d, err := virtual.NewDevice(ctx, t.Name(), virtual.DeviceConfig{
+		SampleRate:      1000,
+		Channels:        1,
+		Period:          time.Millisecond,
+		BlockOnUnderrun: true,
+	})
+	require.NoError(t, err)
+	defer d.Close()
+
+	socketPath := filepath.Join(t.TempDir(), DefaultSocketName)
+	srv := NewServer(virtual.NewPlayerPCMForDevice(d), virtual.NewRecorderPCMForDevice(d))
+	serveCtx, serveCancelFn := context.WithCancel(ctx)
+	serveErrCh := make(chan error, 1)
+	go func() { serveErrCh <- srv.ListenAndServe(serveCtx, socketPath) }()
+
+	player := NewPlayerPCM(socketPath)
+	recorder := NewRecorderPCM(socketPath)
+	require.Eventually(t, func() bool { return player.Ping(ctx) == nil }, time.Second, time.Millisecond)
+	require.NoError(t, recorder.Ping(ctx))
+
+	var recorded syncBuffer
+	recordStream, err := recorder.RecordPCM(ctx, 1000, 1, types.PCMFormatS16LE, &recorded)
+	require.NoError(t, err)
+
+	playStream, err := player.PlayPCM(ctx, 1000, 1, types.PCMFormatS16LE, 2*time.Millisecond, bytes.NewReader([]byte{
+		0x00, 0x40, 0x00, 0xc0, 0x00, 0x20,
+	}))
+	require.NoError(t, err)
+	require.NoError(t, d.Advance(10*time.Millisecond))
+	require.NoError(t, playStream.Drain())
+	require.NoError(t, playStream.Close())
+
+	require.Eventually(t, func() bool { return len(recorded.Bytes()) >= 20 }, time.Second, time.Millisecond)
+	require.Equal(t, append([]byte{0x00, 0x40, 0x00, 0xc0, 0x00, 0x20}, make([]byte, 14)...), recorded.Bytes())
+	require.NoError(t, recordStream.Close())
+	require.NoError(t, recordStream.(*RecordStream).Err())
+
+	_, err = player.PlayPCM(ctx, 1000, 1, types.UndefinedPCMFormat, time.Millisecond, bytes.NewReader(nil))
+	require.Error(t, err)
+
+	require.NoError(t, d.Close())
+	var remoteErr RemoteError
+	require.ErrorAs(t, player.Ping(ctx), &remoteErr)
+
+	serveCancelFn()
+	require.NoError(t, <-serveErrCh)
+	require.Error(t, player.Ping(ctx))
+}
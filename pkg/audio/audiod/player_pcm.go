@@ -0,0 +1,97 @@
+package audiod
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"time"
+
+	"github.com/xaionaro-go/audio/pkg/audio/backends/ipc"
+	"github.com/xaionaro-go/audio/pkg/audio/types"
+)
+
+// PlayerPCM plays the audio through the daemon listening SocketPath.
+type PlayerPCM struct {
+	SocketPath string
+}
+
+var _ types.PlayerPCM = (*PlayerPCM)(nil)
+
+func NewPlayerPCM(socketPath string) *PlayerPCM {
+	return &PlayerPCM{
+		SocketPath: socketPath,
+	}
+}
+
+func (p *PlayerPCM) Close() error {
+	return nil
+}
+
+func (p *PlayerPCM) Ping(ctx context.Context) error {
+	conn, err := sendRequest(ctx, p.SocketPath, request{Op: OpPingPlayer})
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
+
+func (p *PlayerPCM) PlayPCM(
+	ctx context.Context,
+	sampleRate types.SampleRate,
+	channels types.Channel,
+	format types.PCMFormat,
+	bufferSize time.Duration,
+	reader io.Reader,
+) (types.PlayStream, error) {
+	conn, err := sendRequest(ctx, p.SocketPath, request{
+		Op:         OpPlay,
+		BufferSize: bufferSize,
+		Header: ipc.StreamHeader{
+			PCMFormat:  format,
+			SampleRate: sampleRate,
+			Channels:   channels,
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	chunkSize := int(types.EncodingPCM{
+		PCMFormat:  format,
+		SampleRate: sampleRate,
+	}.BytesForDuration(bufferSize)) * int(channels)
+	frameSize := int(channels) * int(format.Size())
+	chunkSize -= chunkSize % frameSize
+	if chunkSize < frameSize {
+		chunkSize = frameSize
+	}
+	return newPlayStream(ctx, conn, reader, chunkSize, frameSize), nil
+}
+
+// sendRequest connects to the daemon, sends the request and
+// waits for the (first) result.
+func sendRequest(
+	ctx context.Context,
+	socketPath string,
+	req request,
+) (net.Conn, error) {
+	reqBytes, err := req.marshal()
+	if err != nil {
+		return nil, err
+	}
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "unix", socketPath)
+	if err != nil {
+		return nil, fmt.Errorf("unable to connect to the daemon at '%s': %w", socketPath, err)
+	}
+	if _, err := ipc.WriteFrame(conn, nil, ipc.FrameTypeRequest, reqBytes); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if err := readResult(conn); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return conn, nil
+}
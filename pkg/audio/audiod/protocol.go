@@ -0,0 +1,122 @@
+package audiod
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/xaionaro-go/audio/pkg/audio/backends/ipc"
+)
+
+// A connection serves exactly one request, using the framing of package
+// ipc (see ipc.WriteFrame). The client sends ipc.FrameTypeRequest and the
+// server replies with ipc.FrameTypeResult. Then:
+//
+//   - OpPlay: the client sends ipc.FrameTypeData frames (with raw PCM
+//     as the body) followed by ipc.FrameTypeEnd; the server replies with
+//     one more ipc.FrameTypeResult when the data is drained.
+//   - OpRecord: the server sends ipc.FrameTypeData frames until the
+//     client closes the connection.
+const (
+	requestSize = 9
+)
+
+type Op uint8
+
+const (
+	UndefinedOp = Op(iota)
+	OpPingPlayer
+	OpPingRecorder
+	OpPlay
+	OpRecord
+	EndOfOp
+)
+
+func (op Op) String() string {
+	switch op {
+	case UndefinedOp:
+		return "<undefined>"
+	case OpPingPlayer:
+		return "ping_player"
+	case OpPingRecorder:
+		return "ping_recorder"
+	case OpPlay:
+		return "play"
+	case OpRecord:
+		return "record"
+	default:
+		return fmt.Sprintf("<unexpected_value_%d>", uint8(op))
+	}
+}
+
+type request struct {
+	Op         Op
+	BufferSize time.Duration
+	Header     ipc.StreamHeader
+}
+
+func (r request) marshal() ([]byte, error) {
+	b := make([]byte, 0, requestSize)
+	b = append(b, byte(r.Op))
+	b = binary.BigEndian.AppendUint64(b, uint64(r.BufferSize))
+	if r.Op != OpPlay && r.Op != OpRecord {
+		return b, nil
+	}
+	header, err := r.Header.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	return append(b, header...), nil
+}
+
+func (r *request) unmarshal(b []byte) error {
+	if len(b) < requestSize {
+		return fmt.Errorf("the request is too short: %d < %d", len(b), requestSize)
+	}
+	r.Op = Op(b[0])
+	if r.Op == UndefinedOp || r.Op >= EndOfOp {
+		return fmt.Errorf("unknown operation: %v", r.Op)
+	}
+	r.BufferSize = time.Duration(binary.BigEndian.Uint64(b[1:]))
+	if r.Op != OpPlay && r.Op != OpRecord {
+		return nil
+	}
+	return r.Header.UnmarshalBinary(b[requestSize:])
+}
+
+func writeResult(w io.Writer, err error) error {
+	var body []byte
+	if err != nil {
+		body = []byte(err.Error())
+		if len(body) == 0 {
+			body = []byte("unknown error")
+		}
+	}
+	_, err = ipc.WriteFrame(w, nil, ipc.FrameTypeResult, body)
+	return err
+}
+
+// readResult returns the error reported by the server (if any) as RemoteError.
+func readResult(r io.Reader) error {
+	frameType, body, err := ipc.ReadFrame(r, nil)
+	if err != nil {
+		return fmt.Errorf("unable to read the result: %w", err)
+	}
+	if frameType != ipc.FrameTypeResult {
+		return fmt.Errorf("expected a %v frame, but received %v", ipc.FrameTypeResult, frameType)
+	}
+	if len(body) == 0 {
+		return nil
+	}
+	return RemoteError{Message: string(body)}
+}
+
+// RemoteError is an error returned by the daemon.
+type RemoteError struct {
+	Message string
+}
+
+func (e RemoteError) Error() string {
+	return fmt.Sprintf("audiod: %s", e.Message)
+}
@@ -0,0 +1,55 @@
+package audiod
+
+import (
+	"context"
+	"io"
+
+	"github.com/xaionaro-go/audio/pkg/audio/backends/ipc"
+	"github.com/xaionaro-go/audio/pkg/audio/types"
+)
+
+// RecorderPCM records the audio through the daemon listening SocketPath.
+type RecorderPCM struct {
+	SocketPath string
+}
+
+var _ types.RecorderPCM = (*RecorderPCM)(nil)
+
+func NewRecorderPCM(socketPath string) *RecorderPCM {
+	return &RecorderPCM{
+		SocketPath: socketPath,
+	}
+}
+
+func (r *RecorderPCM) Close() error {
+	return nil
+}
+
+func (r *RecorderPCM) Ping(ctx context.Context) error {
+	conn, err := sendRequest(ctx, r.SocketPath, request{Op: OpPingRecorder})
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
+
+func (r *RecorderPCM) RecordPCM(
+	ctx context.Context,
+	sampleRate types.SampleRate,
+	channels types.Channel,
+	format types.PCMFormat,
+	writer io.Writer,
+) (types.RecordStream, error) {
+	conn, err := sendRequest(ctx, r.SocketPath, request{
+		Op: OpRecord,
+		Header: ipc.StreamHeader{
+			PCMFormat:  format,
+			SampleRate: sampleRate,
+			Channels:   channels,
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return newRecordStream(ctx, conn, writer), nil
+}
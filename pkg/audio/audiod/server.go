@@ -0,0 +1,257 @@
+package audiod
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sync"
+
+	"github.com/facebookincubator/go-belt/tool/logger"
+	"github.com/xaionaro-go/audio/pkg/audio/backends/ipc"
+	"github.com/xaionaro-go/audio/pkg/audio/types"
+	"github.com/xaionaro-go/observability"
+)
+
+var errStreamFinished = errors.New("the stream is finished")
+
+// Server serves the play and record requests of the clients using
+// the given (real) player and recorder.
+type Server struct {
+	PlayerPCM   types.PlayerPCM
+	RecorderPCM types.RecorderPCM
+
+	waitGroup sync.WaitGroup
+}
+
+func NewServer(
+	playerPCM types.PlayerPCM,
+	recorderPCM types.RecorderPCM,
+) *Server {
+	return &Server{
+		PlayerPCM:   playerPCM,
+		RecorderPCM: recorderPCM,
+	}
+}
+
+// ListenAndServe listens the Unix socket and serves it until the context
+// is cancelled. A stale socket file (left by a crashed daemon) is replaced.
+func (s *Server) ListenAndServe(
+	ctx context.Context,
+	socketPath string,
+) error {
+	if _, err := os.Stat(socketPath); err == nil {
+		conn, err := net.Dial("unix", socketPath)
+		if err == nil {
+			conn.Close()
+			return fmt.Errorf("another daemon is already listening '%s'", socketPath)
+		}
+		logger.Debugf(ctx, "removing stale socket '%s'", socketPath)
+		if err := os.Remove(socketPath); err != nil {
+			return fmt.Errorf("unable to remove the stale socket '%s': %w", socketPath, err)
+		}
+	}
+	listener, err := net.Listen("unix", socketPath)
+	if err != nil {
+		return fmt.Errorf("unable to listen '%s': %w", socketPath, err)
+	}
+	return s.Serve(ctx, listener)
+}
+
+// Serve accepts the connections until the context is cancelled
+// (and then closes the listener and all the connections).
+func (s *Server) Serve(
+	ctx context.Context,
+	listener net.Listener,
+) (_err error) {
+	logger.Debugf(ctx, "Serve(%s)", listener.Addr())
+	defer func() { logger.Debugf(ctx, "/Serve(%s): %v", listener.Addr(), _err) }()
+
+	ctx, cancelFn := context.WithCancel(ctx)
+	defer cancelFn()
+	observability.Go(ctx, func(ctx context.Context) {
+		<-ctx.Done()
+		listener.Close()
+	})
+	defer s.waitGroup.Wait()
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return fmt.Errorf("unable to accept a connection: %w", err)
+		}
+		s.waitGroup.Add(1)
+		observability.Go(ctx, func(ctx context.Context) {
+			defer s.waitGroup.Done()
+			connCtx, cancelFn := context.WithCancel(ctx)
+			defer cancelFn()
+			observability.Go(connCtx, func(ctx context.Context) {
+				<-ctx.Done()
+				conn.Close()
+			})
+			if err := s.serveConn(connCtx, conn); err != nil {
+				logger.Debugf(ctx, "unable to serve a connection: %v", err)
+			}
+		})
+	}
+}
+
+func (s *Server) serveConn(
+	ctx context.Context,
+	conn net.Conn,
+) error {
+	frameType, body, err := ipc.ReadFrame(conn, nil)
+	if err != nil {
+		return fmt.Errorf("unable to read the request: %w", err)
+	}
+	if frameType != ipc.FrameTypeRequest {
+		return fmt.Errorf("expected a %v frame, but received %v", ipc.FrameTypeRequest, frameType)
+	}
+	var req request
+	if err := req.unmarshal(body); err != nil {
+		writeResult(conn, err)
+		return fmt.Errorf("unable to parse the request: %w", err)
+	}
+	logger.Debugf(ctx, "request: %#+v", req)
+
+	switch req.Op {
+	case OpPingPlayer:
+		return writeResult(conn, s.PlayerPCM.Ping(ctx))
+	case OpPingRecorder:
+		return writeResult(conn, s.RecorderPCM.Ping(ctx))
+	case OpPlay:
+		return s.servePlay(ctx, conn, req)
+	case OpRecord:
+		return s.serveRecord(ctx, conn, req)
+	default:
+		err := fmt.Errorf("unknown operation: %v", req.Op)
+		writeResult(conn, err)
+		return err
+	}
+}
+
+func (s *Server) servePlay(
+	ctx context.Context,
+	conn net.Conn,
+	req request,
+) error {
+	pipeReader, pipeWriter := io.Pipe()
+	stream, err := s.PlayerPCM.PlayPCM(
+		ctx,
+		req.Header.SampleRate,
+		req.Header.Channels,
+		req.Header.PCMFormat,
+		req.BufferSize,
+		pipeReader,
+	)
+	if err := writeResult(conn, err); err != nil {
+		if stream != nil {
+			stream.Close()
+		}
+		return err
+	}
+	if err != nil {
+		return err
+	}
+	defer stream.Close()
+
+	drainedCh := make(chan error, 1)
+	observability.Go(ctx, func(ctx context.Context) {
+		err := stream.Drain()
+		// if the stream finished prematurely, nobody reads the pipe anymore:
+		pipeReader.CloseWithError(errStreamFinished)
+		drainedCh <- err
+	})
+
+	var buf []byte
+	for {
+		var frameType ipc.FrameType
+		frameType, buf, err = ipc.ReadFrame(conn, buf)
+		if err != nil {
+			pipeWriter.CloseWithError(err)
+			<-drainedCh
+			return fmt.Errorf("unable to read a message: %w", err)
+		}
+		if frameType == ipc.FrameTypeEnd {
+			pipeWriter.Close()
+			break
+		}
+		if frameType != ipc.FrameTypeData {
+			err := fmt.Errorf("unexpected frame type: %v", frameType)
+			pipeWriter.CloseWithError(err)
+			<-drainedCh
+			return err
+		}
+		if _, err := pipeWriter.Write(buf); err != nil {
+			// the stream is finished, the result will be reported below
+			break
+		}
+	}
+
+	return writeResult(conn, <-drainedCh)
+}
+
+type recordWriter struct {
+	conn    net.Conn
+	locker  sync.Mutex
+	buf     []byte
+	started chan struct{}
+	failed  bool
+}
+
+func (w *recordWriter) Write(b []byte) (int, error) {
+	<-w.started
+	w.locker.Lock()
+	defer w.locker.Unlock()
+	if w.failed {
+		return 0, errStreamFinished
+	}
+	var err error
+	w.buf, err = ipc.WriteFrame(w.conn, w.buf, ipc.FrameTypeData, b)
+	if err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
+
+func (s *Server) serveRecord(
+	ctx context.Context,
+	conn net.Conn,
+	req request,
+) error {
+	w := &recordWriter{
+		conn:    conn,
+		started: make(chan struct{}),
+	}
+	stream, err := s.RecorderPCM.RecordPCM(
+		ctx,
+		req.Header.SampleRate,
+		req.Header.Channels,
+		req.Header.PCMFormat,
+		w,
+	)
+	w.locker.Lock()
+	resultErr := writeResult(conn, err)
+	w.failed = err != nil || resultErr != nil
+	close(w.started)
+	w.locker.Unlock()
+	if stream != nil {
+		defer stream.Close()
+	}
+	if resultErr != nil {
+		return resultErr
+	}
+	if err != nil {
+		return err
+	}
+
+	// the client does not send anything after the request, so this
+	// just waits until the connection is closed
+	_, err = io.Copy(io.Discard, conn)
+	return err
+}
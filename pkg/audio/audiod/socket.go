@@ -0,0 +1,24 @@
+package audiod
+
+import (
+	"os"
+	"path/filepath"
+)
+
+const (
+	EnvSocketPath     = "AUDIOD_SOCKET"
+	DefaultSocketName = "audiod.sock"
+)
+
+// DefaultSocketPath returns the path from environment variable
+// EnvSocketPath, or DefaultSocketName in the runtime directory of the user.
+func DefaultSocketPath() string {
+	if path := os.Getenv(EnvSocketPath); path != "" {
+		return path
+	}
+	dir := os.Getenv("XDG_RUNTIME_DIR")
+	if dir == "" {
+		dir = os.TempDir()
+	}
+	return filepath.Join(dir, DefaultSocketName)
+}
@@ -0,0 +1,108 @@
+package audiod
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+
+	"github.com/facebookincubator/go-belt/tool/logger"
+	"github.com/xaionaro-go/audio/pkg/audio/backends/ipc"
+	"github.com/xaionaro-go/audio/pkg/audio/types"
+	"github.com/xaionaro-go/observability"
+)
+
+type PlayStream struct {
+	CancelFunc context.CancelFunc
+	WaitGroup  sync.WaitGroup
+
+	conn      net.Conn
+	locker    sync.Mutex
+	closed    bool
+	resultErr error
+}
+
+var _ types.PlayStream = (*PlayStream)(nil)
+
+func newPlayStream(
+	ctx context.Context,
+	conn net.Conn,
+	reader io.Reader,
+	chunkSize int,
+	frameSize int,
+) *PlayStream {
+	s := &PlayStream{
+		conn: conn,
+	}
+	ctx, s.CancelFunc = context.WithCancel(ctx)
+	s.WaitGroup.Add(1)
+	observability.Go(ctx, func(ctx context.Context) {
+		defer s.WaitGroup.Done()
+		defer conn.Close()
+		err := s.senderLoop(ctx, reader, chunkSize, frameSize)
+		s.locker.Lock()
+		defer s.locker.Unlock()
+		if err != nil && !s.closed {
+			s.resultErr = err
+		}
+	})
+	return s
+}
+
+func (s *PlayStream) senderLoop(
+	ctx context.Context,
+	reader io.Reader,
+	chunkSize int,
+	frameSize int,
+) (_err error) {
+	logger.Debugf(ctx, "senderLoop")
+	defer func() { logger.Debugf(ctx, "/senderLoop: %v", _err) }()
+
+	var buf []byte
+	chunk := make([]byte, chunkSize)
+	var pending int
+	for {
+		n, err := reader.Read(chunk[pending:])
+		pending += n
+		complete := pending - pending%frameSize
+		if complete > 0 && (complete == len(chunk) || err != nil) {
+			var writeErr error
+			buf, writeErr = ipc.WriteFrame(s.conn, buf, ipc.FrameTypeData, chunk[:complete])
+			if writeErr != nil {
+				return writeErr
+			}
+			copy(chunk, chunk[complete:pending])
+			pending -= complete
+		}
+		if err != nil {
+			if !errors.Is(err, io.EOF) {
+				return fmt.Errorf("unable to read: %w", err)
+			}
+			break
+		}
+	}
+	if _, err := ipc.WriteFrame(s.conn, buf, ipc.FrameTypeEnd, nil); err != nil {
+		return err
+	}
+	return readResult(s.conn)
+}
+
+// Drain waits until the daemon played all the data from the reader.
+func (s *PlayStream) Drain() error {
+	s.WaitGroup.Wait()
+	s.locker.Lock()
+	defer s.locker.Unlock()
+	return s.resultErr
+}
+
+func (s *PlayStream) Close() error {
+	s.locker.Lock()
+	s.closed = true
+	s.locker.Unlock()
+	s.CancelFunc()
+	s.conn.Close()
+	s.WaitGroup.Wait()
+	return nil
+}
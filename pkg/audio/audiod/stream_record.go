@@ -0,0 +1,96 @@
+package audiod
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+
+	"github.com/facebookincubator/go-belt/tool/logger"
+	"github.com/xaionaro-go/audio/pkg/audio/backends/ipc"
+	"github.com/xaionaro-go/audio/pkg/audio/types"
+	"github.com/xaionaro-go/observability"
+)
+
+type RecordStream struct {
+	CancelFunc context.CancelFunc
+	WaitGroup  sync.WaitGroup
+
+	conn      net.Conn
+	locker    sync.Mutex
+	closed    bool
+	resultErr error
+}
+
+var _ types.RecordStream = (*RecordStream)(nil)
+
+func newRecordStream(
+	ctx context.Context,
+	conn net.Conn,
+	writer io.Writer,
+) *RecordStream {
+	s := &RecordStream{
+		conn: conn,
+	}
+	ctx, s.CancelFunc = context.WithCancel(ctx)
+	s.WaitGroup.Add(1)
+	observability.Go(ctx, func(ctx context.Context) {
+		defer s.WaitGroup.Done()
+		defer conn.Close()
+		err := s.receiverLoop(ctx, writer)
+		s.locker.Lock()
+		defer s.locker.Unlock()
+		if err != nil && !s.closed {
+			s.resultErr = err
+		}
+	})
+	return s
+}
+
+func (s *RecordStream) receiverLoop(
+	ctx context.Context,
+	writer io.Writer,
+) (_err error) {
+	logger.Debugf(ctx, "receiverLoop")
+	defer func() { logger.Debugf(ctx, "/receiverLoop: %v", _err) }()
+
+	var buf []byte
+	for {
+		var (
+			frameType ipc.FrameType
+			err       error
+		)
+		frameType, buf, err = ipc.ReadFrame(s.conn, buf)
+		if err != nil {
+			return fmt.Errorf("unable to read a message: %w", err)
+		}
+		if frameType != ipc.FrameTypeData {
+			return fmt.Errorf("unexpected frame type: %v", frameType)
+		}
+		n, err := writer.Write(buf)
+		if err != nil {
+			return fmt.Errorf("unable to write: %w", err)
+		}
+		if n != len(buf) {
+			return fmt.Errorf("invalid write length: %d != %d", n, len(buf))
+		}
+	}
+}
+
+// Err returns the error which stopped the stream (if any).
+func (s *RecordStream) Err() error {
+	s.locker.Lock()
+	defer s.locker.Unlock()
+	return s.resultErr
+}
+
+func (s *RecordStream) Close() error {
+	s.locker.Lock()
+	s.closed = true
+	s.locker.Unlock()
+	s.CancelFunc()
+	s.conn.Close()
+	s.WaitGroup.Wait()
+	return nil
+}
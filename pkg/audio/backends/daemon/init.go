@@ -0,0 +1,31 @@
+package daemon
+
+import (
+	"github.com/xaionaro-go/audio/pkg/audio/audiod"
+	"github.com/xaionaro-go/audio/pkg/audio/registry"
+	"github.com/xaionaro-go/audio/pkg/audio/types"
+)
+
+const (
+	// Priority is higher than the priority of any local backend: if
+	// the daemon is running, it owns the devices, so it should be used.
+	// Otherwise the ping fails and the next backend is tried.
+	Priority = 200
+)
+
+func init() {
+	registry.RegisterPlayerFactory(Priority, PlayerPCMFactory{})
+	registry.RegisterRecorderFactory(Priority, RecorderPCMFactory{})
+}
+
+type PlayerPCMFactory struct{}
+
+func (PlayerPCMFactory) NewPlayerPCM() (types.PlayerPCM, error) {
+	return audiod.NewPlayerPCM(audiod.DefaultSocketPath()), nil
+}
+
+type RecorderPCMFactory struct{}
+
+func (RecorderPCMFactory) NewRecorderPCM() (types.RecorderPCM, error) {
+	return audiod.NewRecorderPCM(audiod.DefaultSocketPath()), nil
+}
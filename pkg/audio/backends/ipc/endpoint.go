@@ -0,0 +1,123 @@
+package ipc
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"os"
+
+	"github.com/xaionaro-go/observability"
+)
+
+type Transport uint
+
+const (
+	UndefinedTransport = Transport(iota)
+	TransportUnixSocket
+	TransportFIFO
+	EndOfTransport
+)
+
+func (t Transport) String() string {
+	switch t {
+	case UndefinedTransport:
+		return "<undefined>"
+	case TransportUnixSocket:
+		return "unix"
+	case TransportFIFO:
+		return "fifo"
+	default:
+		return fmt.Sprintf("<unexpected_value_%d>", uint(t))
+	}
+}
+
+// Endpoint is the path to connect to. With Listen set, the endpoint
+// creates the socket (or the FIFO) and waits for the peer instead of
+// connecting to an existing one.
+type Endpoint struct {
+	Transport Transport
+	Path      string
+	Listen    bool
+}
+
+func (e Endpoint) ping() error {
+	if e.Listen {
+		return nil
+	}
+	if _, err := os.Stat(e.Path); err != nil {
+		return fmt.Errorf("unable to stat '%s': %w", e.Path, err)
+	}
+	return nil
+}
+
+// connector establishes the connection; it is split from the connecting
+// itself, so that a listening socket exists right after PlayPCM/RecordPCM
+// returns (even though nobody connected, yet).
+type connector func(ctx context.Context) (io.ReadWriteCloser, error)
+
+func (e Endpoint) prepare(forWriting bool) (connector, error) {
+	switch e.Transport {
+	case TransportUnixSocket:
+		if !e.Listen {
+			return func(ctx context.Context) (io.ReadWriteCloser, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, "unix", e.Path)
+			}, nil
+		}
+		listener, err := net.Listen("unix", e.Path)
+		if err != nil {
+			return nil, fmt.Errorf("unable to listen '%s': %w", e.Path, err)
+		}
+		return func(ctx context.Context) (io.ReadWriteCloser, error) {
+			ctx, cancelFn := context.WithCancel(ctx)
+			defer cancelFn()
+			observability.Go(ctx, func(ctx context.Context) {
+				<-ctx.Done()
+				listener.Close()
+			})
+			return listener.Accept()
+		}, nil
+	case TransportFIFO:
+		if e.Listen {
+			if err := mkfifo(e.Path); err != nil {
+				return nil, fmt.Errorf("unable to create a FIFO '%s': %w", e.Path, err)
+			}
+		}
+		flag := os.O_RDONLY
+		if forWriting {
+			flag = os.O_WRONLY
+		}
+		return func(ctx context.Context) (io.ReadWriteCloser, error) {
+			ctx, cancelFn := context.WithCancel(ctx)
+			defer cancelFn()
+			opened := make(chan struct{})
+			defer close(opened)
+			observability.Go(ctx, func(ctx context.Context) {
+				select {
+				case <-opened:
+				case <-ctx.Done():
+					select {
+					case <-opened:
+						return
+					default:
+					}
+					// opening a FIFO blocks until the peer opens it, so
+					// pretending to be the peer to unblock it
+					unblockFIFOOpen(e.Path)
+				}
+			})
+			f, err := os.OpenFile(e.Path, flag, 0)
+			if err != nil {
+				return nil, err
+			}
+			if err := ctx.Err(); err != nil {
+				f.Close()
+				return nil, err
+			}
+			return f, nil
+		}, nil
+	default:
+		return nil, fmt.Errorf("unknown transport: %v", e.Transport)
+	}
+}
@@ -0,0 +1,14 @@
+//go:build !unix
+// +build !unix
+
+package ipc
+
+import (
+	"fmt"
+)
+
+func mkfifo(path string) error {
+	return fmt.Errorf("FIFOs are not supported on this platform")
+}
+
+func unblockFIFOOpen(path string) {}
@@ -0,0 +1,25 @@
+//go:build unix
+// +build unix
+
+package ipc
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+func mkfifo(path string) error {
+	err := syscall.Mkfifo(path, 0600)
+	if errors.Is(err, os.ErrExist) {
+		return nil
+	}
+	return err
+}
+
+func unblockFIFOOpen(path string) {
+	f, err := os.OpenFile(path, os.O_RDWR|syscall.O_NONBLOCK, 0)
+	if err == nil {
+		f.Close()
+	}
+}
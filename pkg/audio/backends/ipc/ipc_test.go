@@ -0,0 +1,92 @@
+package ipc
+
+import (
+	"bytes"
+	"context"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/xaionaro-go/audio/pkg/audio/types"
+)
+
+func TestStreamHeaderMarshal(t *testing.T) {
+	header := StreamHeader{
+		PCMFormat:  types.PCMFormatS24BE,
+		SampleRate: 44100,
+		Channels:   6,
+	}
+	b, err := header.MarshalBinary()
+	require.NoError(t, err)
+
+	var parsed StreamHeader
+	require.NoError(t, parsed.UnmarshalBinary(b))
+	require.Equal(t, header, parsed)
+
+	b[0] = 'Y'
+	require.Error(t, parsed.UnmarshalBinary(b))
+}
+
+func TestUnixSocketConversion(t *testing.T) {
+	ctx, cancelFn := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancelFn()
+	path := filepath.Join(t.TempDir(), "audio.sock")
+
+	var recorded bytes.Buffer
+	recorder := NewRecorderPCM(Endpoint{Transport: TransportUnixSocket, Path: path, Listen: true})
+	recordStream, err := recorder.RecordPCM(ctx, 1000, 1, types.PCMFormatS16LE, &recorded)
+	require.NoError(t, err)
+	defer recordStream.Close()
+
+	player := NewPlayerPCM(Endpoint{Transport: TransportUnixSocket, Path: path})
+	require.NoError(t, player.Ping(ctx))
+	playStream, err := player.PlayPCM(ctx, 1000, 1, types.PCMFormatS16BE, 2*time.Millisecond, bytes.NewReader([]byte{
+		0x40, 0x00, 0xc0, 0x00, 0x01, 0x02,
+	}))
+	require.NoError(t, err)
+	require.NoError(t, playStream.Drain())
+	require.NoError(t, playStream.Close())
+
+	s := recordStream.(*RecordStream)
+	require.NoError(t, s.Wait(ctx))
+	require.Equal(t, []byte{0x00, 0x40, 0x00, 0xc0, 0x02, 0x01}, recorded.Bytes())
+	require.Equal(t, &StreamHeader{
+		PCMFormat:  types.PCMFormatS16BE,
+		SampleRate: 1000,
+		Channels:   1,
+	}, s.Header())
+	require.False(t, s.LastTimestamp().IsZero())
+}
+
+func TestFIFO(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("FIFOs are not supported")
+	}
+	ctx, cancelFn := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancelFn()
+	path := filepath.Join(t.TempDir(), "audio.fifo")
+
+	player := NewPlayerPCM(Endpoint{Transport: TransportFIFO, Path: path, Listen: true})
+	playStream, err := player.PlayPCM(ctx, 48000, 2, types.PCMFormatFloat32LE, time.Millisecond, bytes.NewReader(make([]byte, 8*100)))
+	require.NoError(t, err)
+
+	var recorded bytes.Buffer
+	recorder := NewRecorderPCM(Endpoint{Transport: TransportFIFO, Path: path})
+	require.NoError(t, recorder.Ping(ctx))
+	recordStream, err := recorder.RecordPCM(ctx, 48000, 2, types.PCMFormatFloat32LE, &recorded)
+	require.NoError(t, err)
+
+	require.NoError(t, playStream.Drain())
+	require.NoError(t, recordStream.(*RecordStream).Wait(ctx))
+	require.Equal(t, make([]byte, 8*100), recorded.Bytes())
+}
+
+func TestCancelWaitingForPeer(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audio.fifo")
+	recorder := NewRecorderPCM(Endpoint{Transport: TransportFIFO, Path: path, Listen: true})
+	recordStream, err := recorder.RecordPCM(context.Background(), 48000, 2, types.PCMFormatFloat32LE, &bytes.Buffer{})
+	require.NoError(t, err)
+	require.NoError(t, recordStream.Close())
+}
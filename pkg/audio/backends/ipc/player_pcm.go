@@ -0,0 +1,64 @@
+package ipc
+
+import (
+	"context"
+	"io"
+	"time"
+
+	"github.com/xaionaro-go/audio/pkg/audio/types"
+)
+
+// PlayerPCM "plays" the audio by sending it (with its format) to the Endpoint.
+type PlayerPCM struct {
+	Endpoint Endpoint
+}
+
+var _ types.PlayerPCM = (*PlayerPCM)(nil)
+
+func NewPlayerPCM(endpoint Endpoint) *PlayerPCM {
+	return &PlayerPCM{
+		Endpoint: endpoint,
+	}
+}
+
+func (p *PlayerPCM) Close() error {
+	return nil
+}
+
+func (p *PlayerPCM) Ping(context.Context) error {
+	return p.Endpoint.ping()
+}
+
+func (p *PlayerPCM) PlayPCM(
+	ctx context.Context,
+	sampleRate types.SampleRate,
+	channels types.Channel,
+	format types.PCMFormat,
+	bufferSize time.Duration,
+	reader io.Reader,
+) (types.PlayStream, error) {
+	header := StreamHeader{
+		PCMFormat:  format,
+		SampleRate: sampleRate,
+		Channels:   channels,
+	}
+	headerBytes, err := header.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	connect, err := p.Endpoint.prepare(true)
+	if err != nil {
+		return nil, err
+	}
+
+	chunkSize := int(types.EncodingPCM{
+		PCMFormat:  format,
+		SampleRate: sampleRate,
+	}.BytesForDuration(bufferSize)) * int(channels)
+	frameSize := int(channels) * int(format.Size())
+	chunkSize -= chunkSize % frameSize
+	if chunkSize < frameSize {
+		chunkSize = frameSize
+	}
+	return newPlayStream(ctx, connect, reader, headerBytes, chunkSize, frameSize), nil
+}
@@ -0,0 +1,197 @@
+package ipc
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/xaionaro-go/audio/pkg/audio/types"
+)
+
+// The protocol is a sequence of frames: [type:1][length:4, big-endian][body].
+//
+// The first frame is always FrameTypeHeader; it is followed by any amount of
+// FrameTypeData frames and optionally by FrameTypeEnd.
+const (
+	protocolMagic   = "XAPC"
+	protocolVersion = 1
+
+	frameHeaderSize = 5
+
+	// MaxFrameSize is the maximal size of a frame body accepted by ReadFrame.
+	MaxFrameSize = 16 << 20
+)
+
+type FrameType uint8
+
+const (
+	UndefinedFrameType = FrameType(iota)
+	FrameTypeHeader
+	FrameTypeData
+	FrameTypeEnd
+
+	// FrameTypeRequest and FrameTypeResult are not used by this package,
+	// but by the request/response protocols built on top of the same
+	// framing (see package audiod).
+	FrameTypeRequest
+	FrameTypeResult
+
+	EndOfFrameType
+)
+
+func (t FrameType) String() string {
+	switch t {
+	case UndefinedFrameType:
+		return "<undefined>"
+	case FrameTypeHeader:
+		return "header"
+	case FrameTypeData:
+		return "data"
+	case FrameTypeEnd:
+		return "end"
+	case FrameTypeRequest:
+		return "request"
+	case FrameTypeResult:
+		return "result"
+	default:
+		return fmt.Sprintf("<unexpected_value_%d>", uint8(t))
+	}
+}
+
+// StreamHeader describes the PCM data in the stream.
+type StreamHeader struct {
+	PCMFormat  types.PCMFormat
+	SampleRate types.SampleRate
+	Channels   types.Channel
+}
+
+// wirePCMFormats maps the formats to codes which are stable across
+// versions of this package (unlike the values of types.PCMFormat).
+var wirePCMFormats = map[types.PCMFormat]uint16{
+	types.PCMFormatU8:        1,
+	types.PCMFormatS16LE:     2,
+	types.PCMFormatS16BE:     3,
+	types.PCMFormatS24LE:     4,
+	types.PCMFormatS24BE:     5,
+	types.PCMFormatS32LE:     6,
+	types.PCMFormatS32BE:     7,
+	types.PCMFormatS64LE:     8,
+	types.PCMFormatS64BE:     9,
+	types.PCMFormatFloat32LE: 10,
+	types.PCMFormatFloat32BE: 11,
+	types.PCMFormatFloat64LE: 12,
+	types.PCMFormatFloat64BE: 13,
+	types.PCMFormatMuLaw:     14,
+	types.PCMFormatALaw:      15,
+	types.PCMFormatIMAADPCM:  16,
+	types.PCMFormatS8:        17,
+	types.PCMFormatU16LE:     18,
+	types.PCMFormatU16BE:     19,
+	types.PCMFormatU24LE:     20,
+	types.PCMFormatU24BE:     21,
+	types.PCMFormatS24_32LE:  22,
+	types.PCMFormatS24_32BE:  23,
+	types.PCMFormatFloat16LE: 24,
+	types.PCMFormatFloat16BE: 25,
+}
+
+func pcmFormatFromWire(code uint16) types.PCMFormat {
+	for format, c := range wirePCMFormats {
+		if c == code {
+			return format
+		}
+	}
+	return types.UndefinedPCMFormat
+}
+
+func (h StreamHeader) MarshalBinary() ([]byte, error) {
+	code, ok := wirePCMFormats[h.PCMFormat]
+	if !ok {
+		return nil, fmt.Errorf("PCM format %v is not supported by the protocol", h.PCMFormat)
+	}
+	b := make([]byte, 0, 15)
+	b = append(b, protocolMagic...)
+	b = append(b, protocolVersion)
+	b = binary.BigEndian.AppendUint16(b, code)
+	b = binary.BigEndian.AppendUint32(b, uint32(h.SampleRate))
+	b = binary.BigEndian.AppendUint32(b, uint32(h.Channels))
+	return b, nil
+}
+
+func (h *StreamHeader) UnmarshalBinary(b []byte) error {
+	if len(b) < 15 {
+		return fmt.Errorf("the header is too short: %d < 15", len(b))
+	}
+	if string(b[:4]) != protocolMagic {
+		return fmt.Errorf("invalid magic: %q", b[:4])
+	}
+	if b[4] != protocolVersion {
+		return fmt.Errorf("unsupported protocol version: %d", b[4])
+	}
+	h.PCMFormat = pcmFormatFromWire(binary.BigEndian.Uint16(b[5:]))
+	if h.PCMFormat == types.UndefinedPCMFormat {
+		return fmt.Errorf("unknown PCM format code: %d", binary.BigEndian.Uint16(b[5:]))
+	}
+	h.SampleRate = types.SampleRate(binary.BigEndian.Uint32(b[7:]))
+	h.Channels = types.Channel(binary.BigEndian.Uint32(b[11:]))
+	if h.SampleRate == 0 || h.Channels == 0 {
+		return fmt.Errorf("invalid header: %#+v", *h)
+	}
+	return nil
+}
+
+// WriteFrame writes a frame with the concatenation of "bodyParts" as
+// the body, using "buf" as the scratch buffer (which is returned for reuse).
+func WriteFrame(w io.Writer, buf []byte, frameType FrameType, bodyParts ...[]byte) ([]byte, error) {
+	var size int
+	for _, part := range bodyParts {
+		size += len(part)
+	}
+	buf = append(buf[:0], byte(frameType))
+	buf = binary.BigEndian.AppendUint32(buf, uint32(size))
+	for _, part := range bodyParts {
+		buf = append(buf, part...)
+	}
+	n, err := w.Write(buf)
+	if err != nil {
+		return buf, fmt.Errorf("unable to write a %v frame: %w", frameType, err)
+	}
+	if n != len(buf) {
+		return buf, fmt.Errorf("invalid write length: %d != %d", n, len(buf))
+	}
+	return buf, nil
+}
+
+func writeDataFrame(w io.Writer, buf []byte, ts time.Time, pcm []byte) ([]byte, error) {
+	var tsBytes [8]byte
+	binary.BigEndian.PutUint64(tsBytes[:], uint64(ts.UnixNano()))
+	return WriteFrame(w, buf, FrameTypeData, tsBytes[:], pcm)
+}
+
+// ReadFrame reads the next frame; the body references "buf".
+func ReadFrame(r io.Reader, buf []byte) (FrameType, []byte, error) {
+	var hdr [frameHeaderSize]byte
+	if _, err := io.ReadFull(r, hdr[:]); err != nil {
+		return UndefinedFrameType, buf, err
+	}
+	size := binary.BigEndian.Uint32(hdr[1:])
+	if size > MaxFrameSize {
+		return UndefinedFrameType, buf, fmt.Errorf("the frame is too large: %d > %d", size, MaxFrameSize)
+	}
+	if cap(buf) < int(size) {
+		buf = make([]byte, size)
+	}
+	buf = buf[:size]
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return UndefinedFrameType, buf, fmt.Errorf("unable to read the frame body: %w", err)
+	}
+	return FrameType(hdr[0]), buf, nil
+}
+
+func parseDataFrame(body []byte) (time.Time, []byte, error) {
+	if len(body) < 8 {
+		return time.Time{}, nil, fmt.Errorf("the data frame is too short: %d < 8", len(body))
+	}
+	return time.Unix(0, int64(binary.BigEndian.Uint64(body))), body[8:], nil
+}
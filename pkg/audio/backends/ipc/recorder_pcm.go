@@ -0,0 +1,50 @@
+package ipc
+
+import (
+	"context"
+	"io"
+
+	"github.com/xaionaro-go/audio/pkg/audio/resampler"
+	"github.com/xaionaro-go/audio/pkg/audio/types"
+)
+
+// RecorderPCM "records" the audio by receiving it from the Endpoint. The
+// received audio is converted to the requested format if the sender
+// uses a different one.
+type RecorderPCM struct {
+	Endpoint Endpoint
+}
+
+var _ types.RecorderPCM = (*RecorderPCM)(nil)
+
+func NewRecorderPCM(endpoint Endpoint) *RecorderPCM {
+	return &RecorderPCM{
+		Endpoint: endpoint,
+	}
+}
+
+func (r *RecorderPCM) Close() error {
+	return nil
+}
+
+func (r *RecorderPCM) Ping(context.Context) error {
+	return r.Endpoint.ping()
+}
+
+func (r *RecorderPCM) RecordPCM(
+	ctx context.Context,
+	sampleRate types.SampleRate,
+	channels types.Channel,
+	format types.PCMFormat,
+	writer io.Writer,
+) (types.RecordStream, error) {
+	connect, err := r.Endpoint.prepare(false)
+	if err != nil {
+		return nil, err
+	}
+	return newRecordStream(ctx, connect, resampler.Format{
+		Channels:   channels,
+		SampleRate: sampleRate,
+		PCMFormat:  format,
+	}, writer), nil
+}
@@ -0,0 +1,125 @@
+package ipc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/facebookincubator/go-belt/tool/logger"
+	"github.com/xaionaro-go/audio/pkg/audio/types"
+	"github.com/xaionaro-go/observability"
+)
+
+type PlayStream struct {
+	CancelFunc context.CancelFunc
+	WaitGroup  sync.WaitGroup
+
+	locker    sync.Mutex
+	conn      io.Closer
+	closed    bool
+	resultErr error
+}
+
+var _ types.PlayStream = (*PlayStream)(nil)
+
+func newPlayStream(
+	ctx context.Context,
+	connect connector,
+	reader io.Reader,
+	header []byte,
+	chunkSize int,
+	frameSize int,
+) *PlayStream {
+	s := &PlayStream{}
+	ctx, s.CancelFunc = context.WithCancel(ctx)
+	s.WaitGroup.Add(1)
+	observability.Go(ctx, func(ctx context.Context) {
+		defer s.WaitGroup.Done()
+		err := s.senderLoop(ctx, connect, reader, header, chunkSize, frameSize)
+		s.locker.Lock()
+		defer s.locker.Unlock()
+		if err != nil && !s.closed {
+			s.resultErr = err
+		}
+	})
+	return s
+}
+
+func (s *PlayStream) senderLoop(
+	ctx context.Context,
+	connect connector,
+	reader io.Reader,
+	header []byte,
+	chunkSize int,
+	frameSize int,
+) (_err error) {
+	logger.Debugf(ctx, "senderLoop")
+	defer func() { logger.Debugf(ctx, "/senderLoop: %v", _err) }()
+
+	conn, err := connect(ctx)
+	if err != nil {
+		return fmt.Errorf("unable to connect: %w", err)
+	}
+	s.locker.Lock()
+	if s.closed {
+		s.locker.Unlock()
+		conn.Close()
+		return nil
+	}
+	s.conn = conn
+	s.locker.Unlock()
+	defer conn.Close()
+
+	buf, err := WriteFrame(conn, nil, FrameTypeHeader, header)
+	if err != nil {
+		return err
+	}
+
+	chunk := make([]byte, chunkSize)
+	var pending int
+	for {
+		n, err := reader.Read(chunk[pending:])
+		pending += n
+		complete := pending - pending%frameSize
+		if complete > 0 && (complete == len(chunk) || err != nil) {
+			var writeErr error
+			buf, writeErr = writeDataFrame(conn, buf, time.Now(), chunk[:complete])
+			if writeErr != nil {
+				return writeErr
+			}
+			copy(chunk, chunk[complete:pending])
+			pending -= complete
+		}
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				_, err := WriteFrame(conn, buf, FrameTypeEnd)
+				return err
+			}
+			return fmt.Errorf("unable to read: %w", err)
+		}
+	}
+}
+
+// Drain waits until all the data from the reader is sent to the peer.
+func (s *PlayStream) Drain() error {
+	s.WaitGroup.Wait()
+	s.locker.Lock()
+	defer s.locker.Unlock()
+	return s.resultErr
+}
+
+func (s *PlayStream) Close() error {
+	s.locker.Lock()
+	s.closed = true
+	conn := s.conn
+	s.locker.Unlock()
+	s.CancelFunc()
+	if conn != nil {
+		conn.Close()
+	}
+	s.WaitGroup.Wait()
+	return nil
+}
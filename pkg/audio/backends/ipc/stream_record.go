@@ -0,0 +1,199 @@
+package ipc
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/facebookincubator/go-belt/tool/logger"
+	"github.com/xaionaro-go/audio/pkg/audio/resampler"
+	"github.com/xaionaro-go/audio/pkg/audio/types"
+	"github.com/xaionaro-go/observability"
+)
+
+type RecordStream struct {
+	CancelFunc context.CancelFunc
+	WaitGroup  sync.WaitGroup
+
+	outFormat resampler.Format
+	writer    io.Writer
+
+	locker        sync.Mutex
+	conn          io.Closer
+	closed        bool
+	header        *StreamHeader
+	lastTimestamp time.Time
+	ended         bool
+	resultErr     error
+	doneCh        chan struct{}
+}
+
+var _ types.RecordStream = (*RecordStream)(nil)
+
+func newRecordStream(
+	ctx context.Context,
+	connect connector,
+	outFormat resampler.Format,
+	writer io.Writer,
+) *RecordStream {
+	s := &RecordStream{
+		outFormat: outFormat,
+		writer:    writer,
+		doneCh:    make(chan struct{}),
+	}
+	ctx, s.CancelFunc = context.WithCancel(ctx)
+	s.WaitGroup.Add(1)
+	observability.Go(ctx, func(ctx context.Context) {
+		defer s.WaitGroup.Done()
+		defer close(s.doneCh)
+		err := s.receiverLoop(ctx, connect)
+		s.locker.Lock()
+		defer s.locker.Unlock()
+		if err != nil && !s.closed {
+			s.resultErr = err
+		}
+	})
+	return s
+}
+
+func (s *RecordStream) receiverLoop(
+	ctx context.Context,
+	connect connector,
+) (_err error) {
+	logger.Debugf(ctx, "receiverLoop")
+	defer func() { logger.Debugf(ctx, "/receiverLoop: %v", _err) }()
+
+	conn, err := connect(ctx)
+	if err != nil {
+		return fmt.Errorf("unable to connect: %w", err)
+	}
+	s.locker.Lock()
+	if s.closed {
+		s.locker.Unlock()
+		conn.Close()
+		return nil
+	}
+	s.conn = conn
+	s.locker.Unlock()
+	defer conn.Close()
+
+	frameType, body, err := ReadFrame(conn, nil)
+	if err != nil {
+		return fmt.Errorf("unable to read the header frame: %w", err)
+	}
+	if frameType != FrameTypeHeader {
+		return fmt.Errorf("expected a %v frame, but received %v", FrameTypeHeader, frameType)
+	}
+	var header StreamHeader
+	if err := header.UnmarshalBinary(body); err != nil {
+		return fmt.Errorf("unable to parse the header: %w", err)
+	}
+	logger.Debugf(ctx, "received the stream header: %#+v", header)
+	s.locker.Lock()
+	s.header = &header
+	s.locker.Unlock()
+
+	inFormat := resampler.Format{
+		Channels:   header.Channels,
+		SampleRate: header.SampleRate,
+		PCMFormat:  header.PCMFormat,
+	}
+	write, flush := s.writeAll, func() error { return nil }
+	if inFormat != s.outFormat {
+		conv, err := resampler.NewWriter(inFormat, s.writer, s.outFormat)
+		if err != nil {
+			return fmt.Errorf("unable to initialize a resampler from %#+v to %#+v: %w", inFormat, s.outFormat, err)
+		}
+		write = func(b []byte) error {
+			if _, err := conv.Write(b); err != nil {
+				return fmt.Errorf("unable to convert: %w", err)
+			}
+			return nil
+		}
+		flush = func() error {
+			if err := conv.Close(); err != nil {
+				return fmt.Errorf("unable to convert: %w", err)
+			}
+			return nil
+		}
+	}
+
+	for {
+		frameType, body, err = ReadFrame(conn, body)
+		if err != nil {
+			return fmt.Errorf("unable to read a frame: %w", err)
+		}
+		switch frameType {
+		case FrameTypeData:
+			ts, pcm, err := parseDataFrame(body)
+			if err != nil {
+				return err
+			}
+			s.locker.Lock()
+			s.lastTimestamp = ts
+			s.locker.Unlock()
+			if err := write(pcm); err != nil {
+				return err
+			}
+		case FrameTypeEnd:
+			s.locker.Lock()
+			s.ended = true
+			s.locker.Unlock()
+			return flush()
+		default:
+			logger.Debugf(ctx, "skipping an unknown frame type: %v", frameType)
+		}
+	}
+}
+
+func (s *RecordStream) writeAll(b []byte) error {
+	n, err := s.writer.Write(b)
+	if err != nil {
+		return fmt.Errorf("unable to write: %w", err)
+	}
+	if n != len(b) {
+		return fmt.Errorf("invalid write length: %d != %d", n, len(b))
+	}
+	return nil
+}
+
+// Header returns the format announced by the sender (or nil if it is not received, yet).
+func (s *RecordStream) Header() *StreamHeader {
+	s.locker.Lock()
+	defer s.locker.Unlock()
+	return s.header
+}
+
+// LastTimestamp returns the time when the last received chunk was sent.
+func (s *RecordStream) LastTimestamp() time.Time {
+	s.locker.Lock()
+	defer s.locker.Unlock()
+	return s.lastTimestamp
+}
+
+// Wait waits until the sender finishes the stream (or the stream breaks).
+func (s *RecordStream) Wait(ctx context.Context) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-s.doneCh:
+	}
+	s.locker.Lock()
+	defer s.locker.Unlock()
+	return s.resultErr
+}
+
+func (s *RecordStream) Close() error {
+	s.locker.Lock()
+	s.closed = true
+	conn := s.conn
+	s.locker.Unlock()
+	s.CancelFunc()
+	if conn != nil {
+		conn.Close()
+	}
+	s.WaitGroup.Wait()
+	return nil
+}
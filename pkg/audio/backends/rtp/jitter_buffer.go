@@ -0,0 +1,109 @@
+package rtp
+
+type JitterBufferStats struct {
+	Received   uint64
+	Lost       uint64
+	Late       uint64
+	Duplicated uint64
+	Reordered  uint64
+}
+
+// jitterBuffer reorders the packets by their sequence numbers. It holds
+// up to "depth" packets while waiting for a missing one; after that the
+// missing packet is considered lost and is replaced with silence.
+type jitterBuffer struct {
+	depth        int
+	frameSize    int
+	maxGapFrames uint32
+
+	packets          map[uint16]Packet
+	started          bool
+	nextSeq          uint16
+	nextTimestamp    uint32
+	hasNextTimestamp bool
+	highestSeq       uint16
+	stats            JitterBufferStats
+}
+
+func newJitterBuffer(
+	depth int,
+	frameSize int,
+	maxGapFrames uint32,
+) *jitterBuffer {
+	return &jitterBuffer{
+		depth:        depth,
+		frameSize:    frameSize,
+		maxGapFrames: maxGapFrames,
+		packets:      map[uint16]Packet{},
+	}
+}
+
+func (jb *jitterBuffer) reset() {
+	*jb = *newJitterBuffer(jb.depth, jb.frameSize, jb.maxGapFrames)
+}
+
+func (jb *jitterBuffer) push(pkt Packet) {
+	if jb.started && seqLess(pkt.SequenceNumber, jb.nextSeq) {
+		jb.stats.Late++
+		return
+	}
+	if _, ok := jb.packets[pkt.SequenceNumber]; ok {
+		jb.stats.Duplicated++
+		return
+	}
+	jb.stats.Received++
+	if !jb.started {
+		jb.started = true
+		jb.nextSeq = pkt.SequenceNumber
+		jb.highestSeq = pkt.SequenceNumber
+	}
+	if seqLess(pkt.SequenceNumber, jb.highestSeq) {
+		jb.stats.Reordered++
+	} else {
+		jb.highestSeq = pkt.SequenceNumber
+	}
+	pkt.Payload = append([]byte(nil), pkt.Payload...)
+	jb.packets[pkt.SequenceNumber] = pkt
+}
+
+// pop appends the next in-order payload (prepended with silence for
+// the lost packets, if any) to "dst"; returns false if it is still
+// required to wait for more packets.
+func (jb *jitterBuffer) pop(dst []byte, flush bool) ([]byte, bool) {
+	if len(jb.packets) == 0 {
+		return dst, false
+	}
+	pkt, ok := jb.packets[jb.nextSeq]
+	if !ok {
+		if !flush && len(jb.packets) < jb.depth {
+			return dst, false
+		}
+		pkt = jb.earliest()
+		jb.stats.Lost += uint64(pkt.SequenceNumber - jb.nextSeq)
+		if jb.hasNextTimestamp {
+			gap := pkt.Timestamp - jb.nextTimestamp
+			if gap > 0 && gap <= jb.maxGapFrames {
+				dst = append(dst, make([]byte, int(gap)*jb.frameSize)...)
+			}
+		}
+	}
+	delete(jb.packets, pkt.SequenceNumber)
+	jb.nextSeq = pkt.SequenceNumber + 1
+	jb.nextTimestamp = pkt.Timestamp + uint32(len(pkt.Payload)/jb.frameSize)
+	jb.hasNextTimestamp = true
+	return append(dst, pkt.Payload...), true
+}
+
+func (jb *jitterBuffer) earliest() Packet {
+	var (
+		result Packet
+		found  bool
+	)
+	for _, pkt := range jb.packets {
+		if !found || seqLess(pkt.SequenceNumber, result.SequenceNumber) {
+			result = pkt
+			found = true
+		}
+	}
+	return result
+}
@@ -0,0 +1,82 @@
+package rtp
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+const (
+	rtpVersion      = 2
+	headerSize      = 12
+	maxSequenceSkew = 1 << 15
+)
+
+type Header struct {
+	Marker         bool
+	PayloadType    uint8
+	SequenceNumber uint16
+	Timestamp      uint32
+	SSRC           uint32
+}
+
+type Packet struct {
+	Header
+	Payload []byte
+}
+
+func (p *Packet) Marshal(dst []byte) []byte {
+	dst = append(dst, rtpVersion<<6)
+	b := p.PayloadType & 0x7f
+	if p.Marker {
+		b |= 0x80
+	}
+	dst = append(dst, b)
+	dst = binary.BigEndian.AppendUint16(dst, p.SequenceNumber)
+	dst = binary.BigEndian.AppendUint32(dst, p.Timestamp)
+	dst = binary.BigEndian.AppendUint32(dst, p.SSRC)
+	return append(dst, p.Payload...)
+}
+
+// Unmarshal parses the packet; the payload references "b".
+func (p *Packet) Unmarshal(b []byte) error {
+	if len(b) < headerSize {
+		return fmt.Errorf("the packet is too short: %d < %d", len(b), headerSize)
+	}
+	if version := b[0] >> 6; version != rtpVersion {
+		return fmt.Errorf("unexpected RTP version: %d", version)
+	}
+	hasPadding := b[0]&0x20 != 0
+	hasExtension := b[0]&0x10 != 0
+	csrcCount := int(b[0] & 0x0f)
+
+	p.Marker = b[1]&0x80 != 0
+	p.PayloadType = b[1] & 0x7f
+	p.SequenceNumber = binary.BigEndian.Uint16(b[2:])
+	p.Timestamp = binary.BigEndian.Uint32(b[4:])
+	p.SSRC = binary.BigEndian.Uint32(b[8:])
+
+	offset := headerSize + csrcCount*4
+	if hasExtension {
+		if len(b) < offset+4 {
+			return fmt.Errorf("the packet is too short for the extension header: %d < %d", len(b), offset+4)
+		}
+		offset += 4 + int(binary.BigEndian.Uint16(b[offset+2:]))*4
+	}
+	end := len(b)
+	if hasPadding {
+		if end == 0 {
+			return fmt.Errorf("padding flag is set on an empty packet")
+		}
+		end -= int(b[end-1])
+	}
+	if offset > end {
+		return fmt.Errorf("invalid packet: header size %d exceeds the payload end %d", offset, end)
+	}
+	p.Payload = b[offset:end]
+	return nil
+}
+
+// seqLess returns true if sequence number "a" precedes "b", taking wrap-around into account.
+func seqLess(a, b uint16) bool {
+	return a != b && b-a < maxSequenceSkew
+}
@@ -0,0 +1,78 @@
+package rtp
+
+import (
+	"fmt"
+
+	"github.com/xaionaro-go/audio/pkg/audio/types"
+)
+
+// PayloadEncoding is an RTP audio payload format (RFC 3551).
+type PayloadEncoding uint
+
+const (
+	UndefinedPayloadEncoding = PayloadEncoding(iota)
+	PayloadEncodingL16
+	PayloadEncodingL24
+	EndOfPayloadEncoding
+)
+
+const (
+	// PayloadTypeL16Stereo is the static payload type for 44100Hz stereo L16.
+	PayloadTypeL16Stereo = 10
+	// PayloadTypeL16Mono is the static payload type for 44100Hz mono L16.
+	PayloadTypeL16Mono = 11
+	// DefaultPayloadType is the first dynamic payload type.
+	DefaultPayloadType = 96
+)
+
+func (e PayloadEncoding) String() string {
+	switch e {
+	case UndefinedPayloadEncoding:
+		return "<undefined>"
+	case PayloadEncodingL16:
+		return "L16"
+	case PayloadEncodingL24:
+		return "L24"
+	default:
+		return fmt.Sprintf("<unexpected_value_%d>", uint(e))
+	}
+}
+
+// PCMFormat returns the sample format carried in the payload (network byte order).
+func (e PayloadEncoding) PCMFormat() types.PCMFormat {
+	switch e {
+	case PayloadEncodingL16:
+		return types.PCMFormatS16BE
+	case PayloadEncodingL24:
+		return types.PCMFormatS24BE
+	default:
+		return types.UndefinedPCMFormat
+	}
+}
+
+func checkPayloadType(
+	payloadType uint8,
+	encoding PayloadEncoding,
+	sampleRate types.SampleRate,
+	channels types.Channel,
+) error {
+	if payloadType > 127 {
+		return fmt.Errorf("invalid payload type %d", payloadType)
+	}
+	if encoding.PCMFormat() == types.UndefinedPCMFormat {
+		return fmt.Errorf("unsupported payload encoding %v", encoding)
+	}
+	var expectedChannels types.Channel
+	switch payloadType {
+	case PayloadTypeL16Stereo:
+		expectedChannels = 2
+	case PayloadTypeL16Mono:
+		expectedChannels = 1
+	default:
+		return nil
+	}
+	if encoding != PayloadEncodingL16 || sampleRate != 44100 || channels != expectedChannels {
+		return fmt.Errorf("static payload type %d implies L16 44100Hz %dch, but %v %dHz %dch is requested", payloadType, expectedChannels, encoding, sampleRate, channels)
+	}
+	return nil
+}
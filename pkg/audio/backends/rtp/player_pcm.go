@@ -0,0 +1,118 @@
+package rtp
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"time"
+
+	"github.com/xaionaro-go/audio/pkg/audio/resampler"
+	"github.com/xaionaro-go/audio/pkg/audio/types"
+)
+
+const (
+	DefaultPacketDuration = 20 * time.Millisecond
+	DefaultMaxPayloadSize = 1200
+)
+
+// PlayerPCM "plays" the audio by sending it as an RTP stream to RemoteAddr.
+type PlayerPCM struct {
+	RemoteAddr  string
+	Encoding    PayloadEncoding
+	PayloadType uint8
+
+	// SSRC is the synchronization source identifier; zero means a random one.
+	SSRC uint32
+
+	PacketDuration time.Duration
+	MaxPayloadSize int
+
+	// Pacing makes the sender send the packets in real time instead
+	// of as fast as the reader provides the data.
+	Pacing bool
+}
+
+var _ types.PlayerPCM = (*PlayerPCM)(nil)
+
+func NewPlayerPCM(remoteAddr string) *PlayerPCM {
+	return &PlayerPCM{
+		RemoteAddr:     remoteAddr,
+		Encoding:       PayloadEncodingL16,
+		PayloadType:    DefaultPayloadType,
+		PacketDuration: DefaultPacketDuration,
+		MaxPayloadSize: DefaultMaxPayloadSize,
+		Pacing:         true,
+	}
+}
+
+func (p *PlayerPCM) Close() error {
+	return nil
+}
+
+func (p *PlayerPCM) Ping(context.Context) error {
+	_, err := net.ResolveUDPAddr("udp", p.RemoteAddr)
+	return err
+}
+
+func (p *PlayerPCM) PlayPCM(
+	ctx context.Context,
+	sampleRate types.SampleRate,
+	channels types.Channel,
+	format types.PCMFormat,
+	bufferSize time.Duration,
+	reader io.Reader,
+) (types.PlayStream, error) {
+	if err := checkPayloadType(p.PayloadType, p.Encoding, sampleRate, channels); err != nil {
+		return nil, err
+	}
+
+	inFmt := resampler.Format{
+		Channels:   channels,
+		SampleRate: sampleRate,
+		PCMFormat:  format,
+	}
+	outFmt := inFmt
+	outFmt.PCMFormat = p.Encoding.PCMFormat()
+	if inFmt != outFmt {
+		var err error
+		reader, err = resampler.NewResampler(inFmt, reader, outFmt)
+		if err != nil {
+			return nil, fmt.Errorf("unable to initialize a resampler from %#+v to %#+v: %w", inFmt, outFmt, err)
+		}
+	}
+
+	frameSize := int(channels) * int(outFmt.PCMFormat.Size())
+	packetFrames := int(uint64(p.PacketDuration) * uint64(sampleRate) / uint64(time.Second))
+	if maxFrames := p.MaxPayloadSize / frameSize; packetFrames > maxFrames {
+		packetFrames = maxFrames
+	}
+	if packetFrames < 1 {
+		return nil, fmt.Errorf("a single frame (%d bytes) does not fit into the max payload size %d", frameSize, p.MaxPayloadSize)
+	}
+
+	addr, err := net.ResolveUDPAddr("udp", p.RemoteAddr)
+	if err != nil {
+		return nil, fmt.Errorf("unable to resolve '%s': %w", p.RemoteAddr, err)
+	}
+	conn, err := net.DialUDP("udp", nil, addr)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open an UDP socket to %s: %w", addr, err)
+	}
+
+	ssrc := p.SSRC
+	if ssrc == 0 {
+		ssrc = rand.Uint32()
+	}
+	return newPlayStream(ctx, playStreamConfig{
+		Conn:         conn,
+		Reader:       reader,
+		PayloadType:  p.PayloadType,
+		SSRC:         ssrc,
+		SampleRate:   sampleRate,
+		FrameSize:    frameSize,
+		PacketFrames: packetFrames,
+		Pacing:       p.Pacing,
+	}), nil
+}
@@ -0,0 +1,121 @@
+package rtp
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/xaionaro-go/audio/pkg/audio/resampler"
+	"github.com/xaionaro-go/audio/pkg/audio/types"
+)
+
+const (
+	DefaultJitterBufferDepth = 4
+	DefaultSSRCTimeout       = time.Second
+)
+
+// RecorderPCM "records" the audio by receiving an RTP stream.
+type RecorderPCM struct {
+	Conn        *net.UDPConn
+	Encoding    PayloadEncoding
+	PayloadType uint8
+
+	// SSRC is the synchronization source to accept; zero means to lock
+	// onto the first one received, and to switch to another one only
+	// if the current one stays silent for longer than SSRCTimeout.
+	SSRC        uint32
+	SSRCTimeout time.Duration
+
+	// JitterBufferDepth is the amount of packets to hold while waiting
+	// for a reordered packet before considering it lost.
+	JitterBufferDepth int
+
+	locker sync.Mutex
+	stream *RecordStream
+}
+
+var _ types.RecorderPCM = (*RecorderPCM)(nil)
+
+func NewRecorderPCM(listenAddr string) (*RecorderPCM, error) {
+	addr, err := net.ResolveUDPAddr("udp", listenAddr)
+	if err != nil {
+		return nil, fmt.Errorf("unable to resolve '%s': %w", listenAddr, err)
+	}
+	conn, err := net.ListenUDP("udp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("unable to listen %s: %w", addr, err)
+	}
+	return &RecorderPCM{
+		Conn:              conn,
+		Encoding:          PayloadEncodingL16,
+		PayloadType:       DefaultPayloadType,
+		SSRCTimeout:       DefaultSSRCTimeout,
+		JitterBufferDepth: DefaultJitterBufferDepth,
+	}, nil
+}
+
+// LocalAddr returns the address the RTP stream should be sent to.
+func (r *RecorderPCM) LocalAddr() net.Addr {
+	return r.Conn.LocalAddr()
+}
+
+func (r *RecorderPCM) Close() error {
+	return r.Conn.Close()
+}
+
+func (r *RecorderPCM) Ping(context.Context) error {
+	return nil
+}
+
+func (r *RecorderPCM) RecordPCM(
+	ctx context.Context,
+	sampleRate types.SampleRate,
+	channels types.Channel,
+	format types.PCMFormat,
+	writer io.Writer,
+) (types.RecordStream, error) {
+	if err := checkPayloadType(r.PayloadType, r.Encoding, sampleRate, channels); err != nil {
+		return nil, err
+	}
+
+	r.locker.Lock()
+	defer r.locker.Unlock()
+	if r.stream != nil && !r.stream.isClosed() {
+		return nil, fmt.Errorf("the recorder is already in use by another stream")
+	}
+
+	inFmt := resampler.Format{
+		Channels:   channels,
+		SampleRate: sampleRate,
+		PCMFormat:  r.Encoding.PCMFormat(),
+	}
+	outFmt := inFmt
+	outFmt.PCMFormat = format
+	output := writer
+	if inFmt != outFmt {
+		conv, err := resampler.NewWriter(inFmt, writer, outFmt)
+		if err != nil {
+			return nil, fmt.Errorf("unable to initialize a resampler from %#+v to %#+v: %w", inFmt, outFmt, err)
+		}
+		output = conv
+	}
+
+	frameSize := int(channels) * int(inFmt.PCMFormat.Size())
+	depth := r.JitterBufferDepth
+	if depth < 1 {
+		depth = 1
+	}
+	r.stream = newRecordStream(ctx, recordStreamConfig{
+		Conn:         r.Conn,
+		Output:       output,
+		PayloadType:  r.PayloadType,
+		SSRC:         r.SSRC,
+		SSRCTimeout:  r.SSRCTimeout,
+		FrameSize:    frameSize,
+		JitterBuffer: newJitterBuffer(depth, frameSize, uint32(sampleRate)),
+	})
+	return r.stream, nil
+}
@@ -0,0 +1,131 @@
+package rtp
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/xaionaro-go/audio/pkg/audio/types"
+)
+
+func TestPacketMarshalUnmarshal(t *testing.T) {
+	pkt := Packet{
+		Header: Header{
+			Marker:         true,
+			PayloadType:    DefaultPayloadType,
+			SequenceNumber: 0xfffe,
+			Timestamp:      123456,
+			SSRC:           0xdeadbeef,
+		},
+		Payload: []byte{1, 2, 3, 4},
+	}
+	b := pkt.Marshal(nil)
+	var parsed Packet
+	require.NoError(t, parsed.Unmarshal(b))
+	require.Equal(t, pkt, parsed)
+
+	// the same packet with a CSRC, an extension and padding
+	b = pkt.Marshal(nil)
+	raw := append([]byte{}, b[:headerSize]...)
+	raw[0] |= 0x20 | 0x10 | 0x01
+	raw = append(raw, 0, 0, 0, 1)       // CSRC
+	raw = append(raw, 0xbe, 0xde, 0, 1) // extension header
+	raw = append(raw, 9, 9, 9, 9)       // extension data
+	raw = append(raw, pkt.Payload...)
+	raw = append(raw, 0, 0, 3) // padding
+	require.NoError(t, parsed.Unmarshal(raw))
+	require.Equal(t, pkt, parsed)
+}
+
+func TestJitterBuffer(t *testing.T) {
+	jb := newJitterBuffer(2, 1, 100)
+	push := func(seq uint16, ts uint32, payload ...byte) {
+		jb.push(Packet{Header: Header{SequenceNumber: seq, Timestamp: ts}, Payload: payload})
+	}
+	var out []byte
+	popAll := func(flush bool) {
+		for {
+			var ok bool
+			out, ok = jb.pop(out, flush)
+			if !ok {
+				return
+			}
+		}
+	}
+
+	push(0xffff, 10, 1, 2)
+	push(1, 14, 5, 6) // reordered: 0 comes later
+	popAll(false)
+	require.Equal(t, []byte{1, 2}, out)
+	push(0, 12, 3, 4)
+	push(0, 12, 3, 4) // duplicate
+	popAll(false)
+	require.Equal(t, []byte{1, 2, 3, 4, 5, 6}, out)
+	push(0xfffe, 8, 0, 0) // late
+
+	push(4, 20, 9) // 2 and 3 are lost
+	push(5, 21, 10)
+	popAll(false)
+	require.Equal(t, []byte{1, 2, 3, 4, 5, 6, 0, 0, 0, 0, 9, 10}, out)
+
+	push(7, 23, 11)
+	popAll(false)
+	popAll(true)
+	require.Equal(t, []byte{1, 2, 3, 4, 5, 6, 0, 0, 0, 0, 9, 10, 0, 11}, out)
+	require.Equal(t, JitterBufferStats{
+		Received:   6,
+		Lost:       3,
+		Late:       1,
+		Duplicated: 1,
+		Reordered:  1,
+	}, jb.stats)
+}
+
+func TestLoopbackOverLocalhost(t *testing.T) {
+	for _, encoding := range []PayloadEncoding{PayloadEncodingL16, PayloadEncodingL24} {
+		t.Run(encoding.String(), func(t *testing.T) {
+			ctx := context.Background()
+			recorder, err := NewRecorderPCM("127.0.0.1:0")
+			require.NoError(t, err)
+			defer recorder.Close()
+			recorder.Encoding = encoding
+
+			var recorded bytes.Buffer
+			recordStream, err := recorder.RecordPCM(ctx, 48000, 2, types.PCMFormatS16LE, &recorded)
+			require.NoError(t, err)
+
+			input := make([]byte, 4800*2*2)
+			for idx := 0; idx < len(input); idx += 2 {
+				binary.LittleEndian.PutUint16(input[idx:], uint16(idx*7))
+			}
+			player := NewPlayerPCM(recorder.LocalAddr().String())
+			player.Encoding = encoding
+			player.Pacing = false
+			playStream, err := player.PlayPCM(ctx, 48000, 2, types.PCMFormatS16LE, time.Second, bytes.NewReader(input))
+			require.NoError(t, err)
+			require.NoError(t, playStream.Drain())
+			packetsSent := playStream.(*PlayStream).PacketsSent()
+			require.NoError(t, playStream.Close())
+
+			s := recordStream.(*RecordStream)
+			require.Eventually(t, func() bool {
+				return s.JitterBufferStats().Received == packetsSent
+			}, 5*time.Second, 10*time.Millisecond)
+			require.NoError(t, recordStream.Close())
+			require.Equal(t, input, recorded.Bytes())
+
+			_, locked := s.LockedSSRC()
+			require.True(t, locked)
+		})
+	}
+}
+
+func TestStaticPayloadTypeMismatch(t *testing.T) {
+	player := NewPlayerPCM("127.0.0.1:9")
+	player.PayloadType = PayloadTypeL16Mono
+	_, err := player.PlayPCM(context.Background(), 48000, 1, types.PCMFormatS16BE, time.Second, bytes.NewReader(nil))
+	require.Error(t, err)
+}
@@ -0,0 +1,143 @@
+package rtp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/facebookincubator/go-belt/tool/logger"
+	"github.com/xaionaro-go/audio/pkg/audio/types"
+	"github.com/xaionaro-go/observability"
+)
+
+type playStreamConfig struct {
+	Conn         *net.UDPConn
+	Reader       io.Reader
+	PayloadType  uint8
+	SSRC         uint32
+	SampleRate   types.SampleRate
+	FrameSize    int
+	PacketFrames int
+	Pacing       bool
+}
+
+type PlayStream struct {
+	playStreamConfig
+	CancelFunc context.CancelFunc
+	WaitGroup  sync.WaitGroup
+
+	locker      sync.Mutex
+	packetsSent uint64
+	resultErr   error
+}
+
+var _ types.PlayStream = (*PlayStream)(nil)
+
+func newPlayStream(
+	ctx context.Context,
+	cfg playStreamConfig,
+) *PlayStream {
+	s := &PlayStream{
+		playStreamConfig: cfg,
+	}
+	ctx, s.CancelFunc = context.WithCancel(ctx)
+	s.WaitGroup.Add(1)
+	observability.Go(ctx, func(ctx context.Context) {
+		defer s.WaitGroup.Done()
+		err := s.senderLoop(ctx)
+		s.locker.Lock()
+		defer s.locker.Unlock()
+		s.resultErr = err
+	})
+	return s
+}
+
+func (s *PlayStream) senderLoop(
+	ctx context.Context,
+) (_err error) {
+	logger.Debugf(ctx, "senderLoop")
+	defer func() { logger.Debugf(ctx, "/senderLoop: %v", _err) }()
+
+	payload := make([]byte, s.PacketFrames*s.FrameSize)
+	pkt := Packet{
+		Header: Header{
+			Marker:         true,
+			PayloadType:    s.PayloadType,
+			SequenceNumber: uint16(rand.Uint32()),
+			Timestamp:      rand.Uint32(),
+			SSRC:           s.SSRC,
+		},
+	}
+	var (
+		buf        []byte
+		framesSent uint64
+	)
+	startTS := time.Now()
+	for {
+		n, err := io.ReadFull(s.Reader, payload)
+		n -= n % s.FrameSize
+		if n > 0 {
+			if s.Pacing {
+				sendAt := startTS.Add(time.Duration(framesSent * uint64(time.Second) / uint64(s.SampleRate)))
+				if d := time.Until(sendAt); d > 0 {
+					t := time.NewTimer(d)
+					select {
+					case <-ctx.Done():
+						t.Stop()
+						return ctx.Err()
+					case <-t.C:
+					}
+				}
+			}
+
+			pkt.Payload = payload[:n]
+			buf = pkt.Marshal(buf[:0])
+			if _, err := s.Conn.Write(buf); err != nil {
+				return fmt.Errorf("unable to send a packet: %w", err)
+			}
+			s.locker.Lock()
+			s.packetsSent++
+			s.locker.Unlock()
+
+			frames := n / s.FrameSize
+			framesSent += uint64(frames)
+			pkt.Marker = false
+			pkt.SequenceNumber++
+			pkt.Timestamp += uint32(frames)
+		}
+		if err != nil {
+			if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+				return nil
+			}
+			return fmt.Errorf("unable to read: %w", err)
+		}
+	}
+}
+
+// PacketsSent returns the amount of RTP packets sent so far.
+func (s *PlayStream) PacketsSent() uint64 {
+	s.locker.Lock()
+	defer s.locker.Unlock()
+	return s.packetsSent
+}
+
+// Drain waits until all the data from the reader is sent.
+func (s *PlayStream) Drain() error {
+	s.WaitGroup.Wait()
+	s.locker.Lock()
+	defer s.locker.Unlock()
+	if errors.Is(s.resultErr, context.Canceled) {
+		return nil
+	}
+	return s.resultErr
+}
+
+func (s *PlayStream) Close() error {
+	s.CancelFunc()
+	return s.Conn.Close()
+}
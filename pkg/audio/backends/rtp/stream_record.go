@@ -0,0 +1,223 @@
+package rtp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/facebookincubator/go-belt/tool/logger"
+	"github.com/xaionaro-go/audio/pkg/audio/types"
+	"github.com/xaionaro-go/observability"
+)
+
+const (
+	readPollInterval = 100 * time.Millisecond
+)
+
+type recordStreamConfig struct {
+	Conn         *net.UDPConn
+	Output       io.Writer
+	PayloadType  uint8
+	SSRC         uint32
+	SSRCTimeout  time.Duration
+	FrameSize    int
+	JitterBuffer *jitterBuffer
+}
+
+type RecordStream struct {
+	recordStreamConfig
+	CancelFunc context.CancelFunc
+	WaitGroup  sync.WaitGroup
+
+	locker         sync.Mutex
+	closed         bool
+	lockedSSRC     uint32
+	isSSRCLocked   bool
+	lastPacketTS   time.Time
+	foreignPackets uint64
+	invalidPackets uint64
+	resultErr      error
+}
+
+var _ types.RecordStream = (*RecordStream)(nil)
+
+func newRecordStream(
+	ctx context.Context,
+	cfg recordStreamConfig,
+) *RecordStream {
+	s := &RecordStream{
+		recordStreamConfig: cfg,
+		lockedSSRC:         cfg.SSRC,
+		isSSRCLocked:       cfg.SSRC != 0,
+	}
+	ctx, s.CancelFunc = context.WithCancel(ctx)
+	s.WaitGroup.Add(1)
+	observability.Go(ctx, func(ctx context.Context) {
+		defer s.WaitGroup.Done()
+		err := s.receiverLoop(ctx)
+		s.locker.Lock()
+		defer s.locker.Unlock()
+		if err != nil && !errors.Is(err, context.Canceled) {
+			s.resultErr = err
+		}
+	})
+	return s
+}
+
+func (s *RecordStream) receiverLoop(
+	ctx context.Context,
+) (_err error) {
+	logger.Debugf(ctx, "receiverLoop")
+	defer func() { logger.Debugf(ctx, "/receiverLoop: %v", _err) }()
+
+	buf := make([]byte, 65536)
+	var out []byte
+	for {
+		select {
+		case <-ctx.Done():
+			return s.flush(out)
+		default:
+		}
+
+		if err := s.Conn.SetReadDeadline(time.Now().Add(readPollInterval)); err != nil {
+			return fmt.Errorf("unable to set the read deadline: %w", err)
+		}
+		n, _, err := s.Conn.ReadFromUDP(buf)
+		if err != nil {
+			if errors.Is(err, os.ErrDeadlineExceeded) {
+				continue
+			}
+			if errors.Is(err, net.ErrClosed) {
+				return s.flush(out)
+			}
+			return fmt.Errorf("unable to receive a packet: %w", err)
+		}
+
+		var pkt Packet
+		if err := pkt.Unmarshal(buf[:n]); err != nil {
+			logger.Tracef(ctx, "invalid packet: %v", err)
+			s.count(&s.invalidPackets)
+			continue
+		}
+		if pkt.PayloadType != s.PayloadType || len(pkt.Payload)%s.FrameSize != 0 {
+			s.count(&s.invalidPackets)
+			continue
+		}
+		if !s.acceptSSRC(ctx, pkt.SSRC) {
+			s.count(&s.foreignPackets)
+			continue
+		}
+
+		s.locker.Lock()
+		s.JitterBuffer.push(pkt)
+		s.locker.Unlock()
+		for {
+			var ok bool
+			s.locker.Lock()
+			out, ok = s.JitterBuffer.pop(out[:0], false)
+			s.locker.Unlock()
+			if !ok {
+				break
+			}
+			if err := s.writeAll(out); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (s *RecordStream) acceptSSRC(
+	ctx context.Context,
+	ssrc uint32,
+) bool {
+	s.locker.Lock()
+	defer s.locker.Unlock()
+	now := time.Now()
+	switch {
+	case !s.isSSRCLocked:
+		logger.Debugf(ctx, "locking onto SSRC 0x%08X", ssrc)
+	case s.lockedSSRC == ssrc:
+	case s.SSRC == 0 && now.Sub(s.lastPacketTS) > s.SSRCTimeout:
+		logger.Debugf(ctx, "switching from SSRC 0x%08X to 0x%08X", s.lockedSSRC, ssrc)
+		s.JitterBuffer.reset()
+	default:
+		return false
+	}
+	s.lockedSSRC = ssrc
+	s.isSSRCLocked = true
+	s.lastPacketTS = now
+	return true
+}
+
+func (s *RecordStream) flush(out []byte) error {
+	for {
+		var ok bool
+		s.locker.Lock()
+		out, ok = s.JitterBuffer.pop(out[:0], true)
+		s.locker.Unlock()
+		if !ok {
+			return nil
+		}
+		if err := s.writeAll(out); err != nil {
+			return err
+		}
+	}
+}
+
+func (s *RecordStream) writeAll(b []byte) error {
+	n, err := s.Output.Write(b)
+	if err != nil {
+		return fmt.Errorf("unable to write: %w", err)
+	}
+	if n != len(b) {
+		return fmt.Errorf("invalid write length: %d != %d", n, len(b))
+	}
+	return nil
+}
+
+func (s *RecordStream) count(counter *uint64) {
+	s.locker.Lock()
+	defer s.locker.Unlock()
+	*counter++
+}
+
+// LockedSSRC returns the synchronization source the stream is locked onto.
+func (s *RecordStream) LockedSSRC() (uint32, bool) {
+	s.locker.Lock()
+	defer s.locker.Unlock()
+	return s.lockedSSRC, s.isSSRCLocked
+}
+
+func (s *RecordStream) JitterBufferStats() JitterBufferStats {
+	s.locker.Lock()
+	defer s.locker.Unlock()
+	return s.JitterBuffer.stats
+}
+
+// Err returns the error that stopped the receiving, if any.
+func (s *RecordStream) Err() error {
+	s.locker.Lock()
+	defer s.locker.Unlock()
+	return s.resultErr
+}
+
+func (s *RecordStream) isClosed() bool {
+	s.locker.Lock()
+	defer s.locker.Unlock()
+	return s.closed
+}
+
+// Close stops receiving and writes out the packets left in the jitter buffer.
+func (s *RecordStream) Close() error {
+	s.locker.Lock()
+	s.closed = true
+	s.locker.Unlock()
+	s.CancelFunc()
+	s.WaitGroup.Wait()
+	return s.Err()
+}
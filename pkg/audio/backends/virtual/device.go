@@ -0,0 +1,374 @@
+package virtual
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/facebookincubator/go-belt/tool/logger"
+	"github.com/xaionaro-go/audio/pkg/audio/types"
+	"github.com/xaionaro-go/observability"
+)
+
+const (
+	DefaultDeviceName = "default"
+	DefaultSampleRate = 48000
+	DefaultChannels   = 2
+	DefaultPeriod     = 10 * time.Millisecond
+)
+
+type DeviceConfig struct {
+	SampleRate types.SampleRate
+	Channels   types.Channel
+
+	// Latency is the delay between a sample being played on the device
+	// and the same sample being recorded from it.
+	Latency time.Duration
+
+	// Period is the amount of audio the device processes per tick.
+	Period time.Duration
+
+	// Realtime makes the device clock follow the wall clock. Otherwise
+	// the clock moves only when Advance is called, which is what makes
+	// tests deterministic.
+	Realtime bool
+
+	// BlockOnUnderrun makes the device wait for the play streams to
+	// provide the data, instead of substituting the missing data with silence.
+	BlockOnUnderrun bool
+}
+
+func DefaultDeviceConfig() DeviceConfig {
+	return DeviceConfig{
+		SampleRate: DefaultSampleRate,
+		Channels:   DefaultChannels,
+		Period:     DefaultPeriod,
+		Realtime:   true,
+	}
+}
+
+// Device is an in-memory sink, which is also its own monitor source:
+// everything played on it (mixed together) is delivered to every
+// recording stream after Config.Latency.
+type Device struct {
+	Name   string
+	Config DeviceConfig
+
+	locker        sync.Mutex
+	closed        bool
+	elapsed       time.Duration
+	position      uint64
+	playStreams   []*PlayStream
+	recordStreams []*RecordStream
+	cancelFunc    context.CancelFunc
+	waitGroup     sync.WaitGroup
+
+	// advanceLocker serializes the processing, which is done without
+	// holding "locker" (as a play stream may block it on underrun).
+	advanceLocker sync.Mutex
+	delayLine     []float64
+	mixBuffer     []float64
+
+	closeCh chan struct{}
+	ctxDone <-chan struct{}
+}
+
+var (
+	devices       = map[string]*Device{}
+	devicesLocker sync.Mutex
+)
+
+// NewDevice creates and registers a virtual device with the given name.
+func NewDevice(
+	ctx context.Context,
+	name string,
+	cfg DeviceConfig,
+) (*Device, error) {
+	if cfg.SampleRate == 0 {
+		return nil, fmt.Errorf("sample rate is not set")
+	}
+	if cfg.Channels == 0 {
+		return nil, fmt.Errorf("the amount of channels is not set")
+	}
+	if cfg.Period <= 0 {
+		cfg.Period = DefaultPeriod
+	}
+	if cfg.Latency < 0 {
+		return nil, fmt.Errorf("the latency is negative: %v", cfg.Latency)
+	}
+
+	devicesLocker.Lock()
+	defer devicesLocker.Unlock()
+	if _, ok := devices[name]; ok {
+		return nil, fmt.Errorf("there is already a virtual device with name '%s'", name)
+	}
+
+	d := &Device{
+		Name:    name,
+		Config:  cfg,
+		closeCh: make(chan struct{}),
+	}
+	d.delayLine = make([]float64, d.durationToFrames(cfg.Latency)*uint64(cfg.Channels))
+	devices[name] = d
+
+	if cfg.Realtime {
+		ctx, d.cancelFunc = context.WithCancel(ctx)
+		d.ctxDone = ctx.Done()
+		context.AfterFunc(ctx, d.wakeUpPlayStreams)
+		d.waitGroup.Add(1)
+		observability.Go(ctx, func(ctx context.Context) {
+			defer d.waitGroup.Done()
+			d.realtimeLoop(ctx)
+		})
+	}
+	return d, nil
+}
+
+// GetDevice returns the virtual device with the given name, or nil if there is no such device.
+func GetDevice(name string) *Device {
+	devicesLocker.Lock()
+	defer devicesLocker.Unlock()
+	return devices[name]
+}
+
+// DefaultDevice returns the device named DefaultDeviceName, creating
+// it with DefaultDeviceConfig if it does not exist yet.
+func DefaultDevice() (*Device, error) {
+	if d := GetDevice(DefaultDeviceName); d != nil {
+		return d, nil
+	}
+	d, err := NewDevice(context.Background(), DefaultDeviceName, DefaultDeviceConfig())
+	if err != nil {
+		if d := GetDevice(DefaultDeviceName); d != nil {
+			return d, nil
+		}
+		return nil, err
+	}
+	return d, nil
+}
+
+func (d *Device) durationToFrames(duration time.Duration) uint64 {
+	return uint64(duration) * uint64(d.Config.SampleRate) / uint64(time.Second)
+}
+
+// stopped returns true if the device is closed (or its context is
+// cancelled), so nothing should wait for the play streams anymore.
+func (d *Device) stopped() bool {
+	select {
+	case <-d.closeCh:
+		return true
+	case <-d.ctxDone:
+		return true
+	default:
+		return false
+	}
+}
+
+func (d *Device) wakeUpPlayStreams() {
+	d.locker.Lock()
+	playStreams := slices.Clone(d.playStreams)
+	d.locker.Unlock()
+	for _, s := range playStreams {
+		s.wakeUp()
+	}
+}
+
+func (d *Device) realtimeLoop(ctx context.Context) {
+	logger.Debugf(ctx, "realtimeLoop[%s]", d.Name)
+	defer func() { logger.Debugf(ctx, "/realtimeLoop[%s]", d.Name) }()
+
+	t := time.NewTicker(d.Config.Period)
+	defer t.Stop()
+	prevTS := time.Now()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-t.C:
+			if err := d.Advance(now.Sub(prevTS)); err != nil {
+				logger.Errorf(ctx, "unable to advance the virtual device '%s': %v", d.Name, err)
+				return
+			}
+			prevTS = now
+		}
+	}
+}
+
+// Now returns the current position of the device clock.
+func (d *Device) Now() time.Duration {
+	d.locker.Lock()
+	defer d.locker.Unlock()
+	return d.elapsed
+}
+
+// Position returns the amount of frames processed by the device so far.
+func (d *Device) Position() uint64 {
+	d.locker.Lock()
+	defer d.locker.Unlock()
+	return d.position
+}
+
+// Advance moves the device clock forward by the given duration,
+// processing all the audio that corresponds to it.
+func (d *Device) Advance(duration time.Duration) error {
+	if duration < 0 {
+		return fmt.Errorf("cannot move the clock backwards: %v", duration)
+	}
+
+	d.advanceLocker.Lock()
+	defer d.advanceLocker.Unlock()
+
+	d.locker.Lock()
+	if d.closed {
+		d.locker.Unlock()
+		return fmt.Errorf("the device '%s' is closed", d.Name)
+	}
+	d.elapsed += duration
+	targetPosition := d.durationToFrames(d.elapsed)
+	d.locker.Unlock()
+
+	periodFrames := d.durationToFrames(d.Config.Period)
+	if periodFrames == 0 {
+		periodFrames = 1
+	}
+	for d.position < targetPosition {
+		frames := targetPosition - d.position
+		if frames > periodFrames {
+			frames = periodFrames
+		}
+		d.tick(frames)
+	}
+	return nil
+}
+
+func (d *Device) tick(frames uint64) {
+	channels := uint64(d.Config.Channels)
+	samples := int(frames * channels)
+	if cap(d.mixBuffer) < samples {
+		d.mixBuffer = make([]float64, samples)
+	}
+	mix := d.mixBuffer[:samples]
+	for idx := range mix {
+		mix[idx] = 0
+	}
+
+	// the streams are accessed without holding d.locker, since they
+	// may block (waiting for the data or writing it)
+	d.locker.Lock()
+	playStreams := slices.Clone(d.playStreams)
+	recordStreams := slices.Clone(d.recordStreams)
+	d.locker.Unlock()
+
+	detached := map[any]struct{}{}
+	drainAt := d.position + frames + d.durationToFrames(d.Config.Latency)
+	for _, s := range playStreams {
+		if !s.pull(mix, d.Config.BlockOnUnderrun, d.stopped, drainAt) {
+			detached[s] = struct{}{}
+		}
+	}
+
+	out := mix
+	if len(d.delayLine) > 0 {
+		d.delayLine = append(d.delayLine, mix...)
+		out = d.delayLine[:samples]
+	}
+
+	for _, s := range recordStreams {
+		if !s.push(out) {
+			detached[s] = struct{}{}
+		}
+	}
+
+	if len(d.delayLine) > 0 {
+		d.delayLine = append(d.delayLine[:0], d.delayLine[samples:]...)
+	}
+
+	d.locker.Lock()
+	if len(detached) > 0 {
+		d.playStreams = slices.DeleteFunc(d.playStreams, func(s *PlayStream) bool {
+			_, ok := detached[s]
+			return ok
+		})
+		d.recordStreams = slices.DeleteFunc(d.recordStreams, func(s *RecordStream) bool {
+			_, ok := detached[s]
+			return ok
+		})
+	}
+	d.position += frames
+	position := d.position
+	playStreams = slices.Clone(d.playStreams)
+	d.locker.Unlock()
+
+	for _, s := range playStreams {
+		s.updatePosition(position)
+	}
+}
+
+func (d *Device) addPlayStream(s *PlayStream) error {
+	d.locker.Lock()
+	defer d.locker.Unlock()
+	if d.closed {
+		return fmt.Errorf("the device '%s' is closed", d.Name)
+	}
+	d.playStreams = append(d.playStreams, s)
+	return nil
+}
+
+func (d *Device) addRecordStream(s *RecordStream) error {
+	d.locker.Lock()
+	defer d.locker.Unlock()
+	if d.closed {
+		return fmt.Errorf("the device '%s' is closed", d.Name)
+	}
+	d.recordStreams = append(d.recordStreams, s)
+	return nil
+}
+
+func (d *Device) Ping(context.Context) error {
+	d.locker.Lock()
+	defer d.locker.Unlock()
+	if d.closed {
+		return fmt.Errorf("the device '%s' is closed", d.Name)
+	}
+	return nil
+}
+
+// Close stops the device, finishes all its streams and unregisters it,
+// so that the name can be reused.
+func (d *Device) Close() error {
+	d.locker.Lock()
+	if d.closed {
+		d.locker.Unlock()
+		return nil
+	}
+	d.closed = true
+	close(d.closeCh)
+	d.locker.Unlock()
+
+	// unblocking the processing (if it waits for a play stream) before
+	// waiting for the realtime loop to finish
+	d.wakeUpPlayStreams()
+	if d.cancelFunc != nil {
+		d.cancelFunc()
+	}
+	d.waitGroup.Wait()
+
+	d.locker.Lock()
+	playStreams := d.playStreams
+	d.playStreams = nil
+	d.recordStreams = nil
+	d.locker.Unlock()
+
+	for _, s := range playStreams {
+		s.finish(fmt.Errorf("the device '%s' is closed", d.Name))
+	}
+
+	devicesLocker.Lock()
+	defer devicesLocker.Unlock()
+	if devices[d.Name] == d {
+		delete(devices, d.Name)
+	}
+	return nil
+}
@@ -0,0 +1,164 @@
+package virtual
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/xaionaro-go/audio/pkg/audio"
+	"github.com/xaionaro-go/audio/pkg/audio/types"
+)
+
+func float32LE(values ...float32) []byte {
+	var b []byte
+	for _, v := range values {
+		b = binary.LittleEndian.AppendUint32(b, math.Float32bits(v))
+	}
+	return b
+}
+
+func parseFloat32LE(b []byte) []float32 {
+	var r []float32
+	for idx := 0; idx+4 <= len(b); idx += 4 {
+		r = append(r, math.Float32frombits(binary.LittleEndian.Uint32(b[idx:])))
+	}
+	return r
+}
+
+func newTestDevice(t *testing.T, name string, latency time.Duration) *Device {
+	d, err := NewDevice(context.Background(), name, DeviceConfig{
+		SampleRate:      1000,
+		Channels:        1,
+		Latency:         latency,
+		Period:          10 * time.Millisecond,
+		BlockOnUnderrun: true,
+	})
+	require.NoError(t, err)
+	t.Cleanup(func() { d.Close() })
+	return d
+}
+
+func TestDeviceLoopback(t *testing.T) {
+	ctx := context.Background()
+	d := newTestDevice(t, t.Name(), 5*time.Millisecond)
+
+	var recorded bytes.Buffer
+	recordStream, err := NewRecorderPCMForDevice(d).RecordPCM(ctx, 1000, 1, types.PCMFormatFloat32LE, &recorded)
+	require.NoError(t, err)
+	defer recordStream.Close()
+
+	input := make([]float32, 20)
+	for idx := range input {
+		input[idx] = float32(idx+1) / 32
+	}
+	playStream, err := NewPlayerPCMForDevice(d).PlayPCM(ctx, 1000, 1, types.PCMFormatFloat32LE, 100*time.Millisecond, bytes.NewReader(float32LE(input...)))
+	require.NoError(t, err)
+	defer playStream.Close()
+
+	require.NoError(t, d.Advance(30*time.Millisecond))
+	require.NoError(t, playStream.Drain())
+	require.Equal(t, 30*time.Millisecond, d.Now())
+
+	expected := make([]float32, 30)
+	copy(expected[5:], input)
+	require.Equal(t, expected, parseFloat32LE(recorded.Bytes()))
+}
+
+func TestDeviceMixingAndIsolation(t *testing.T) {
+	ctx := context.Background()
+	a := newTestDevice(t, t.Name()+"_a", 0)
+	b := newTestDevice(t, t.Name()+"_b", 0)
+
+	var recordedA, recordedB bytes.Buffer
+	_, err := NewRecorderPCMForDevice(a).RecordPCM(ctx, 1000, 1, types.PCMFormatFloat32LE, &recordedA)
+	require.NoError(t, err)
+	_, err = NewRecorderPCMForDevice(b).RecordPCM(ctx, 1000, 1, types.PCMFormatFloat32LE, &recordedB)
+	require.NoError(t, err)
+
+	for _, v := range []float32{0.25, 0.5} {
+		_, err := NewPlayerPCMForDevice(a).PlayPCM(ctx, 1000, 1, types.PCMFormatFloat32LE, time.Second, bytes.NewReader(float32LE(v, v)))
+		require.NoError(t, err)
+	}
+
+	require.NoError(t, a.Advance(2*time.Millisecond))
+	require.NoError(t, b.Advance(2*time.Millisecond))
+	require.Equal(t, []float32{0.75, 0.75}, parseFloat32LE(recordedA.Bytes()))
+	require.Equal(t, []float32{0, 0}, parseFloat32LE(recordedB.Bytes()))
+}
+
+func TestDeviceAuto(t *testing.T) {
+	ctx := context.Background()
+	d := newTestDevice(t, DefaultDeviceName, 0)
+
+	var recorded bytes.Buffer
+	recorder := audio.NewRecorderAuto(ctx)
+	require.IsType(t, &RecorderPCM{}, recorder.RecorderPCM)
+	_, err := recorder.RecordPCM(ctx, 1000, 1, types.PCMFormatS16LE, &recorded)
+	require.NoError(t, err)
+
+	player := audio.NewPlayerAuto(ctx)
+	require.IsType(t, &PlayerPCM{}, player.PlayerPCM)
+	playStream, err := player.PlayPCM(ctx, 1000, 1, types.PCMFormatS16LE, time.Second, bytes.NewReader([]byte{0x00, 0x40, 0x00, 0xc0}))
+	require.NoError(t, err)
+
+	require.NoError(t, d.Advance(2*time.Millisecond))
+	require.NoError(t, playStream.Drain())
+	require.Equal(t, []byte{0x00, 0x40, 0x00, 0xc0}, recorded.Bytes())
+
+	require.NoError(t, d.Close())
+	require.Error(t, NewPlayerPCMForDevice(d).Ping(ctx))
+}
+
+func TestDeviceStalledSource(t *testing.T) {
+	ctx := context.Background()
+	d, err := NewDevice(ctx, t.Name(), DeviceConfig{
+		SampleRate:      1000,
+		Channels:        1,
+		Period:          time.Millisecond,
+		Realtime:        true,
+		BlockOnUnderrun: true,
+	})
+	require.NoError(t, err)
+
+	// the source never provides any data
+	pr, pw := io.Pipe()
+	defer pw.Close()
+	_, err = NewPlayerPCMForDevice(d).PlayPCM(ctx, 1000, 1, types.PCMFormatFloat32LE, time.Second, pr)
+	require.NoError(t, err)
+	time.Sleep(20 * time.Millisecond)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		d.Now()
+		d.Position()
+		assert.NoError(t, d.Ping(ctx))
+		assert.NoError(t, d.Close())
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the device is blocked by the stalled play stream")
+	}
+}
+
+func TestDeviceRecordNonlinear(t *testing.T) {
+	ctx := context.Background()
+	d := newTestDevice(t, t.Name(), 0)
+
+	var recorded bytes.Buffer
+	recordStream, err := audio.NewRecorder(NewRecorderPCMForDevice(d)).RecordPCM(ctx, 1000, 1, types.PCMFormatIMAADPCM, &recorded)
+	require.NoError(t, err)
+
+	// an odd amount of samples, so the last nibble is written only on Close
+	require.NoError(t, d.Advance(3*time.Millisecond))
+	require.Equal(t, 1, recorded.Len())
+	require.NoError(t, recordStream.Close())
+	require.Equal(t, 2, recorded.Len())
+}
@@ -0,0 +1,29 @@
+package virtual
+
+import (
+	"github.com/xaionaro-go/audio/pkg/audio/registry"
+	"github.com/xaionaro-go/audio/pkg/audio/types"
+)
+
+const (
+	// Priority is higher than of any real backend, so importing this package
+	// redirects audio.NewPlayerAuto and audio.NewRecorderAuto to the virtual devices.
+	Priority = 1000
+)
+
+func init() {
+	registry.RegisterPlayerFactory(Priority, PlayerPCMFactory{})
+	registry.RegisterRecorderFactory(Priority, RecorderPCMFactory{})
+}
+
+type PlayerPCMFactory struct{}
+
+func (PlayerPCMFactory) NewPlayerPCM() (types.PlayerPCM, error) {
+	return NewPlayerPCM()
+}
+
+type RecorderPCMFactory struct{}
+
+func (RecorderPCMFactory) NewRecorderPCM() (types.RecorderPCM, error) {
+	return NewRecorderPCM()
+}
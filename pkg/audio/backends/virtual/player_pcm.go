@@ -0,0 +1,70 @@
+package virtual
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/xaionaro-go/audio/pkg/audio/resampler"
+	"github.com/xaionaro-go/audio/pkg/audio/types"
+)
+
+type PlayerPCM struct {
+	Device *Device
+}
+
+var _ types.PlayerPCM = (*PlayerPCM)(nil)
+
+// NewPlayerPCM returns a player of the default virtual device.
+func NewPlayerPCM() (*PlayerPCM, error) {
+	d, err := DefaultDevice()
+	if err != nil {
+		return nil, fmt.Errorf("unable to get the default virtual device: %w", err)
+	}
+	return NewPlayerPCMForDevice(d), nil
+}
+
+func NewPlayerPCMForDevice(d *Device) *PlayerPCM {
+	return &PlayerPCM{
+		Device: d,
+	}
+}
+
+func (p *PlayerPCM) Close() error {
+	return nil
+}
+
+func (p *PlayerPCM) Ping(ctx context.Context) error {
+	return p.Device.Ping(ctx)
+}
+
+func (p *PlayerPCM) PlayPCM(
+	ctx context.Context,
+	sampleRate types.SampleRate,
+	channels types.Channel,
+	format types.PCMFormat,
+	bufferSize time.Duration,
+	reader io.Reader,
+) (types.PlayStream, error) {
+	inFmt := resampler.Format{
+		Channels:   channels,
+		SampleRate: sampleRate,
+		PCMFormat:  format,
+	}
+	outFmt := p.Device.internalFormat()
+	if inFmt != outFmt {
+		var err error
+		reader, err = resampler.NewResampler(inFmt, reader, outFmt)
+		if err != nil {
+			return nil, fmt.Errorf("unable to initialize a resampler from %#+v to %#+v: %w", inFmt, outFmt, err)
+		}
+	}
+
+	s := newPlayStream(ctx, p.Device, reader, p.Device.durationToFrames(bufferSize))
+	if err := p.Device.addPlayStream(s); err != nil {
+		s.Close()
+		return nil, fmt.Errorf("unable to attach the stream to the device: %w", err)
+	}
+	return s, nil
+}
@@ -0,0 +1,60 @@
+package virtual
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/xaionaro-go/audio/pkg/audio/resampler"
+	"github.com/xaionaro-go/audio/pkg/audio/types"
+)
+
+type RecorderPCM struct {
+	Device *Device
+}
+
+var _ types.RecorderPCM = (*RecorderPCM)(nil)
+
+// NewRecorderPCM returns a recorder of the default virtual device.
+func NewRecorderPCM() (*RecorderPCM, error) {
+	d, err := DefaultDevice()
+	if err != nil {
+		return nil, fmt.Errorf("unable to get the default virtual device: %w", err)
+	}
+	return NewRecorderPCMForDevice(d), nil
+}
+
+func NewRecorderPCMForDevice(d *Device) *RecorderPCM {
+	return &RecorderPCM{
+		Device: d,
+	}
+}
+
+func (r *RecorderPCM) Close() error {
+	return nil
+}
+
+func (r *RecorderPCM) Ping(ctx context.Context) error {
+	return r.Device.Ping(ctx)
+}
+
+func (r *RecorderPCM) RecordPCM(
+	ctx context.Context,
+	sampleRate types.SampleRate,
+	channels types.Channel,
+	format types.PCMFormat,
+	writer io.Writer,
+) (types.RecordStream, error) {
+	s, err := newRecordStream(r.Device, resampler.Format{
+		Channels:   channels,
+		SampleRate: sampleRate,
+		PCMFormat:  format,
+	}, writer)
+	if err != nil {
+		return nil, fmt.Errorf("unable to initialize a record stream: %w", err)
+	}
+	if err := r.Device.addRecordStream(s); err != nil {
+		return nil, fmt.Errorf("unable to attach the stream to the device: %w", err)
+	}
+	return s, nil
+}
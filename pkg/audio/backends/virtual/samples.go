@@ -0,0 +1,38 @@
+package virtual
+
+import (
+	"encoding/binary"
+	"math"
+
+	"github.com/xaionaro-go/audio/pkg/audio/resampler"
+	"github.com/xaionaro-go/audio/pkg/audio/types"
+)
+
+// internally devices keep samples as float64, so this format is what
+// the streams are converted to/from.
+const (
+	internalPCMFormat = types.PCMFormatFloat64LE
+	sampleSize        = 8
+)
+
+func (d *Device) internalFormat() resampler.Format {
+	return resampler.Format{
+		Channels:   d.Config.Channels,
+		SampleRate: d.Config.SampleRate,
+		PCMFormat:  internalPCMFormat,
+	}
+}
+
+func bytesToFloat64s(dst []float64, src []byte) []float64 {
+	for idx := 0; idx+sampleSize <= len(src); idx += sampleSize {
+		dst = append(dst, math.Float64frombits(binary.LittleEndian.Uint64(src[idx:])))
+	}
+	return dst
+}
+
+func float64sToBytes(dst []byte, src []float64) []byte {
+	for _, v := range src {
+		dst = binary.LittleEndian.AppendUint64(dst, math.Float64bits(v))
+	}
+	return dst
+}
@@ -0,0 +1,214 @@
+package virtual
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/facebookincubator/go-belt/tool/logger"
+	"github.com/xaionaro-go/audio/pkg/audio/types"
+	"github.com/xaionaro-go/observability"
+)
+
+type PlayStream struct {
+	device      *Device
+	reader      io.Reader
+	chunkSize   int
+	maxBuffered int
+
+	locker       sync.Mutex
+	cond         *sync.Cond
+	buffer       []float64
+	readErr      error
+	eof          bool
+	closed       bool
+	drainAt      uint64
+	drainAtIsSet bool
+	underruns    uint64
+	drainedCh    chan struct{}
+	resultErr    error
+	cancelFunc   context.CancelFunc
+}
+
+var _ types.PlayStream = (*PlayStream)(nil)
+
+func newPlayStream(
+	ctx context.Context,
+	device *Device,
+	reader io.Reader,
+	bufferFrames uint64,
+) *PlayStream {
+	channels := int(device.Config.Channels)
+	periodFrames := int(device.durationToFrames(device.Config.Period))
+	if periodFrames == 0 {
+		periodFrames = 1
+	}
+	if bufferFrames < uint64(periodFrames) {
+		bufferFrames = uint64(periodFrames)
+	}
+	s := &PlayStream{
+		device:      device,
+		reader:      reader,
+		chunkSize:   periodFrames * channels * sampleSize,
+		maxBuffered: int(bufferFrames) * channels,
+		drainedCh:   make(chan struct{}),
+	}
+	s.cond = sync.NewCond(&s.locker)
+
+	ctx, s.cancelFunc = context.WithCancel(ctx)
+	observability.Go(ctx, func(ctx context.Context) {
+		s.readerLoop(ctx)
+	})
+	return s
+}
+
+func (s *PlayStream) readerLoop(
+	ctx context.Context,
+) (_err error) {
+	logger.Debugf(ctx, "readerLoop")
+	defer func() { logger.Debugf(ctx, "/readerLoop: %v", _err) }()
+	defer func() {
+		s.locker.Lock()
+		defer s.locker.Unlock()
+		s.eof = true
+		if _err != nil && !errors.Is(_err, io.EOF) {
+			s.readErr = _err
+		}
+		s.cond.Broadcast()
+	}()
+
+	frameSize := int(s.device.Config.Channels) * sampleSize
+	buf := make([]byte, s.chunkSize)
+	var pending int
+	for {
+		n, err := s.reader.Read(buf[pending:])
+		pending += n
+		complete := pending - pending%frameSize
+		if complete > 0 {
+			samples := bytesToFloat64s(nil, buf[:complete])
+			copy(buf, buf[complete:pending])
+			pending -= complete
+			if !s.enqueue(samples) {
+				return ctx.Err()
+			}
+		}
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return fmt.Errorf("unable to read: %w", err)
+		}
+	}
+}
+
+func (s *PlayStream) enqueue(samples []float64) bool {
+	s.locker.Lock()
+	defer s.locker.Unlock()
+	for len(s.buffer) >= s.maxBuffered && !s.closed {
+		s.cond.Wait()
+	}
+	if s.closed {
+		return false
+	}
+	s.buffer = append(s.buffer, samples...)
+	s.cond.Broadcast()
+	return true
+}
+
+// pull mixes the buffered samples into "mix" and returns false if the
+// stream should be detached from the device. The waiting for the data
+// (if blockOnUnderrun) is interrupted when "stopped" returns true (see wakeUp).
+func (s *PlayStream) pull(
+	mix []float64,
+	blockOnUnderrun bool,
+	stopped func() bool,
+	drainAt uint64,
+) bool {
+	s.locker.Lock()
+	defer s.locker.Unlock()
+	if s.closed {
+		return false
+	}
+	if s.drainAtIsSet {
+		return true
+	}
+	for blockOnUnderrun && len(s.buffer) < len(mix) && !s.eof && !s.closed && !stopped() {
+		s.cond.Wait()
+	}
+	if s.closed {
+		return false
+	}
+
+	n := len(s.buffer)
+	if n > len(mix) {
+		n = len(mix)
+	}
+	for idx := 0; idx < n; idx++ {
+		mix[idx] += s.buffer[idx]
+	}
+	s.buffer = append(s.buffer[:0], s.buffer[n:]...)
+	s.cond.Broadcast()
+
+	if n < len(mix) && !s.eof {
+		s.underruns++
+	}
+	if s.eof && len(s.buffer) == 0 {
+		s.drainAt = drainAt
+		s.drainAtIsSet = true
+	}
+	return true
+}
+
+func (s *PlayStream) wakeUp() {
+	s.locker.Lock()
+	defer s.locker.Unlock()
+	s.cond.Broadcast()
+}
+
+func (s *PlayStream) updatePosition(position uint64) {
+	s.locker.Lock()
+	if !s.drainAtIsSet || position < s.drainAt {
+		s.locker.Unlock()
+		return
+	}
+	err := s.readErr
+	s.locker.Unlock()
+	s.finish(err)
+}
+
+func (s *PlayStream) finish(err error) {
+	s.locker.Lock()
+	defer s.locker.Unlock()
+	if s.closed {
+		return
+	}
+	s.closed = true
+	s.resultErr = err
+	close(s.drainedCh)
+	s.cond.Broadcast()
+	s.cancelFunc()
+}
+
+// Underruns returns how many times the device had to substitute
+// missing data with silence.
+func (s *PlayStream) Underruns() uint64 {
+	s.locker.Lock()
+	defer s.locker.Unlock()
+	return s.underruns
+}
+
+// Drain waits until all the data from the reader has been played
+// (including the device latency).
+func (s *PlayStream) Drain() error {
+	<-s.drainedCh
+	s.locker.Lock()
+	defer s.locker.Unlock()
+	return s.resultErr
+}
+
+func (s *PlayStream) Close() error {
+	s.finish(nil)
+	return nil
+}
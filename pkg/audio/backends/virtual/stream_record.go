@@ -0,0 +1,81 @@
+package virtual
+
+import (
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/xaionaro-go/audio/pkg/audio/resampler"
+	"github.com/xaionaro-go/audio/pkg/audio/types"
+)
+
+type RecordStream struct {
+	writer  io.Writer
+	encoded []byte
+
+	locker sync.Mutex
+	closed bool
+	err    error
+}
+
+var _ types.RecordStream = (*RecordStream)(nil)
+
+func newRecordStream(
+	device *Device,
+	format resampler.Format,
+	writer io.Writer,
+) (*RecordStream, error) {
+	s := &RecordStream{
+		writer: writer,
+	}
+	if format != device.internalFormat() {
+		conv, err := resampler.NewWriter(device.internalFormat(), writer, format)
+		if err != nil {
+			return nil, fmt.Errorf("unable to initialize a resampler: %w", err)
+		}
+		s.writer = conv
+	}
+	return s, nil
+}
+
+// push writes the samples to the writer and returns false if the
+// stream should be detached from the device.
+func (s *RecordStream) push(samples []float64) bool {
+	s.locker.Lock()
+	defer s.locker.Unlock()
+	if s.closed {
+		return false
+	}
+	if err := s.write(samples); err != nil {
+		s.err = err
+		s.closed = true
+		return false
+	}
+	return true
+}
+
+func (s *RecordStream) write(samples []float64) error {
+	s.encoded = float64sToBytes(s.encoded[:0], samples)
+	n, err := s.writer.Write(s.encoded)
+	if err != nil {
+		return fmt.Errorf("unable to write: %w", err)
+	}
+	if n != len(s.encoded) {
+		return fmt.Errorf("invalid write length: %d != %d", n, len(s.encoded))
+	}
+	return nil
+}
+
+// Err returns the error that stopped the recording, if any.
+func (s *RecordStream) Err() error {
+	s.locker.Lock()
+	defer s.locker.Unlock()
+	return s.err
+}
+
+func (s *RecordStream) Close() error {
+	s.locker.Lock()
+	defer s.locker.Unlock()
+	s.closed = true
+	return nil
+}
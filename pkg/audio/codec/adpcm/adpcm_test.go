@@ -0,0 +1,97 @@
+package adpcm
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"math"
+	"testing"
+	"testing/iotest"
+
+	"github.com/stretchr/testify/require"
+)
+
+func sine(frames, channels int) []int16 {
+	samples := make([]int16, frames*channels)
+	for idx := range frames {
+		for ch := range channels {
+			samples[idx*channels+ch] = int16(10000 * math.Sin(float64(idx)/float64(8+ch*4)))
+		}
+	}
+	return samples
+}
+
+func requireClose(t *testing.T, expected, actual []int16, skip int) {
+	for idx := skip; idx < len(expected); idx++ {
+		require.InDelta(t, expected[idx], actual[idx], 1000, "sample %d", idx)
+	}
+}
+
+func TestStream(t *testing.T) {
+	for _, channels := range []int{1, 2} {
+		samples := sine(1001, channels)
+		encoder := NewStreamEncoder(channels)
+		encoded := make([]byte, len(samples)/2+1)
+		n := encoder.Encode(encoded, samples[:3])
+		n += encoder.Encode(encoded[n:], samples[3:])
+		n += encoder.Flush(encoded[n:])
+		require.Equal(t, (len(samples)+1)/2, n)
+
+		r := NewReader(bytes.NewReader(encoded[:n]), channels)
+		// a short buffer is supported as well
+		head := make([]byte, 2)
+		_, err := io.ReadFull(r, head)
+		require.NoError(t, err)
+		rest, err := io.ReadAll(r)
+		require.NoError(t, err)
+		decodedBytes := append(head, rest...)
+		require.Len(t, decodedBytes, n*4)
+
+		decoded := make([]int16, len(decodedBytes)/2)
+		for idx := range decoded {
+			decoded[idx] = int16(binary.LittleEndian.Uint16(decodedBytes[idx*2:]))
+		}
+		requireClose(t, samples, decoded, 50*channels)
+
+		// the decoded data is not lost if EOF is returned together with
+		// the last byte, while the buffer is too short for it
+		r = NewReader(iotest.DataErrReader(bytes.NewReader(encoded[:n])), channels)
+		var total int
+		for {
+			m, err := r.Read(head)
+			total += m
+			if err == io.EOF {
+				break
+			}
+			require.NoError(t, err)
+		}
+		require.Equal(t, n*4, total)
+	}
+}
+
+func TestBlock(t *testing.T) {
+	require.Equal(t, 505, SamplesPerBlock(256, 1))
+	require.Equal(t, 505, SamplesPerBlock(512, 2))
+	require.Equal(t, 1024, BlockAlignFor(2041, 1))
+
+	for _, channels := range []int{1, 2} {
+		samplesPerBlock := 505
+		samples := sine(samplesPerBlock*2, channels)
+		states := make([]State, channels)
+		block := make([]byte, BlockAlignFor(samplesPerBlock, channels))
+		decoded := make([]int16, len(samples))
+		for blockIdx := range 2 {
+			in := samples[blockIdx*samplesPerBlock*channels : (blockIdx+1)*samplesPerBlock*channels]
+			EncodeBlock(block, in, channels, states)
+			frames, err := DecodeBlock(decoded[blockIdx*samplesPerBlock*channels:], block, channels)
+			require.NoError(t, err)
+			require.Equal(t, samplesPerBlock, frames)
+			// the first sample of a block is stored as is
+			require.Equal(t, in[:channels], decoded[blockIdx*samplesPerBlock*channels:][:channels])
+		}
+		requireClose(t, samples, decoded, 50*channels)
+
+		_, err := DecodeBlock(decoded, block[:len(block)-1], channels)
+		require.Error(t, err)
+	}
+}
@@ -0,0 +1,91 @@
+package adpcm
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// blockHeaderSize is the size of the per-channel header of a WAV IMA ADPCM block.
+const blockHeaderSize = 4
+
+// SamplesPerBlock returns the amount of samples (per channel) in a WAV IMA
+// ADPCM block of the given size.
+func SamplesPerBlock(blockAlign int, channels int) int {
+	return (blockAlign-blockHeaderSize*channels)*8/(4*channels) + 1
+}
+
+// BlockAlignFor returns the size of a WAV IMA ADPCM block containing the
+// given amount of samples per channel (which has to be 8*N+1).
+func BlockAlignFor(samplesPerBlock int, channels int) int {
+	return (samplesPerBlock-1)*4*channels/8 + blockHeaderSize*channels
+}
+
+// DecodeBlock decodes a WAV IMA ADPCM block into interleaved samples and
+// returns the amount of samples per channel. "dst" has to fit
+// SamplesPerBlock(len(block), channels)*channels samples.
+func DecodeBlock(dst []int16, block []byte, channels int) (int, error) {
+	if len(block) < blockHeaderSize*channels || (len(block)-blockHeaderSize*channels)%(4*channels) != 0 {
+		return 0, fmt.Errorf("invalid size of an IMA ADPCM block for %d channels: %d", channels, len(block))
+	}
+	states := make([]State, channels)
+	for ch := range states {
+		header := block[ch*blockHeaderSize:]
+		states[ch].Predictor = int16(binary.LittleEndian.Uint16(header))
+		if header[2] >= uint8(len(stepTable)) {
+			return 0, fmt.Errorf("invalid step index %d", header[2])
+		}
+		states[ch].Index = header[2]
+		dst[ch] = states[ch].Predictor
+	}
+	data := block[blockHeaderSize*channels:]
+
+	// the data consists of groups of 4 bytes (8 samples) per channel
+	samples := 1
+	for offset := 0; offset < len(data); offset += 4 * channels {
+		for ch := range states {
+			group := data[offset+4*ch : offset+4*ch+4]
+			for idx, v := range group {
+				dst[(samples+idx*2)*channels+ch] = states[ch].Decode(v & 0x0f)
+				dst[(samples+idx*2+1)*channels+ch] = states[ch].Decode(v >> 4)
+			}
+		}
+		samples += 8
+	}
+	return samples, nil
+}
+
+// EncodeBlock encodes interleaved samples (SamplesPerBlock per channel,
+// less samples are padded with the last value) into a WAV IMA ADPCM block.
+// The states are carried over between the blocks to keep the step sizes.
+func EncodeBlock(block []byte, samples []int16, channels int, states []State) {
+	samplesPerBlock := SamplesPerBlock(len(block), channels)
+	sample := func(idx, ch int) int16 {
+		if idx*channels+ch >= len(samples) {
+			idx = len(samples)/channels - 1
+			if idx < 0 {
+				return 0
+			}
+		}
+		return samples[idx*channels+ch]
+	}
+	for ch := range states {
+		// the first sample is stored as is
+		states[ch].Predictor = sample(0, ch)
+		header := block[ch*blockHeaderSize:]
+		binary.LittleEndian.PutUint16(header, uint16(states[ch].Predictor))
+		header[2] = states[ch].Index
+		header[3] = 0
+	}
+	data := block[blockHeaderSize*channels:]
+	for idx := 1; idx < samplesPerBlock; idx += 8 {
+		offset := (idx - 1) / 8 * 4 * channels
+		for ch := range states {
+			group := data[offset+4*ch : offset+4*ch+4]
+			for b := range group {
+				lo := states[ch].Encode(sample(idx+b*2, ch))
+				hi := states[ch].Encode(sample(idx+b*2+1, ch))
+				group[b] = lo | hi<<4
+			}
+		}
+	}
+}
@@ -0,0 +1,85 @@
+// Package adpcm implements the IMA ADPCM (DVI4) codec, which encodes 16-bit
+// samples into 4 bits.
+//
+// Two layouts are supported: a raw stream (see Reader and StreamEncoder),
+// where the state of each channel starts from zero and the nibbles of the
+// interleaved samples are packed low nibble first; and the blocks of the
+// WAV format 0x0011 (see DecodeBlock and EncodeBlock).
+package adpcm
+
+var indexTable = [16]int{
+	-1, -1, -1, -1, 2, 4, 6, 8,
+	-1, -1, -1, -1, 2, 4, 6, 8,
+}
+
+var stepTable = [89]int32{
+	7, 8, 9, 10, 11, 12, 13, 14, 16, 17,
+	19, 21, 23, 25, 28, 31, 34, 37, 41, 45,
+	50, 55, 60, 66, 73, 80, 88, 97, 107, 118,
+	130, 143, 157, 173, 190, 209, 230, 253, 279, 307,
+	337, 371, 408, 449, 494, 544, 598, 658, 724, 796,
+	876, 963, 1060, 1166, 1282, 1411, 1552, 1707, 1878, 2066,
+	2272, 2499, 2749, 3024, 3327, 3660, 4026, 4428, 4871, 5358,
+	5894, 6484, 7132, 7845, 8630, 9493, 10442, 11487, 12635, 13899,
+	15289, 16818, 18500, 20350, 22385, 24623, 27086, 29794, 32767,
+}
+
+// State is the state of the codec of a single channel.
+type State struct {
+	Predictor int16
+	Index     uint8
+}
+
+// Decode decodes a single 4-bit code.
+func (s *State) Decode(nibble byte) int16 {
+	step := stepTable[s.Index]
+	diff := step >> 3
+	if nibble&4 != 0 {
+		diff += step
+	}
+	if nibble&2 != 0 {
+		diff += step >> 1
+	}
+	if nibble&1 != 0 {
+		diff += step >> 2
+	}
+	predictor := int32(s.Predictor)
+	if nibble&8 != 0 {
+		predictor -= diff
+	} else {
+		predictor += diff
+	}
+	s.Predictor = int16(min(max(predictor, -32768), 32767))
+	s.updateIndex(nibble)
+	return s.Predictor
+}
+
+// Encode encodes a single sample into a 4-bit code.
+func (s *State) Encode(sample int16) byte {
+	step := stepTable[s.Index]
+	diff := int32(sample) - int32(s.Predictor)
+	var nibble byte
+	if diff < 0 {
+		nibble = 8
+		diff = -diff
+	}
+	if diff >= step {
+		nibble |= 4
+		diff -= step
+	}
+	if diff >= step>>1 {
+		nibble |= 2
+		diff -= step >> 1
+	}
+	if diff >= step>>2 {
+		nibble |= 1
+	}
+	// the predictor has to follow exactly what the decoder will get
+	s.Decode(nibble)
+	return nibble
+}
+
+func (s *State) updateIndex(nibble byte) {
+	index := int(s.Index) + indexTable[nibble&0x0f]
+	s.Index = uint8(min(max(index, 0), len(stepTable)-1))
+}
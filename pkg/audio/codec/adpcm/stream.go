@@ -0,0 +1,115 @@
+package adpcm
+
+import (
+	"encoding/binary"
+	"io"
+)
+
+// Reader decodes a raw IMA ADPCM stream into PCMFormatS16LE.
+type Reader struct {
+	reader   io.Reader
+	states   []State
+	position int
+	buf      []byte
+	pending  []byte
+	err      error
+}
+
+var _ io.Reader = (*Reader)(nil)
+
+func NewReader(r io.Reader, channels int) *Reader {
+	return &Reader{
+		reader: r,
+		states: make([]State, channels),
+	}
+}
+
+// Read decodes the data into "b" (one input byte is decoded into two
+// samples, so if "b" is shorter than 4 bytes, the rest is kept until
+// the next call, and so is the error of the underlying reader).
+func (r *Reader) Read(b []byte) (int, error) {
+	if len(r.pending) > 0 {
+		n := copy(b, r.pending)
+		r.pending = r.pending[n:]
+		return n, nil
+	}
+	if r.err != nil {
+		return 0, r.err
+	}
+	if len(b) < 4 {
+		var decoded [4]byte
+		n, err := r.decode(decoded[:])
+		if n == 0 {
+			return 0, err
+		}
+		copied := copy(b, decoded[:])
+		r.pending = append(r.pending[:0], decoded[copied:]...)
+		r.err = err
+		return copied, nil
+	}
+	return r.decode(b)
+}
+
+func (r *Reader) decode(b []byte) (int, error) {
+	size := len(b) / 4
+	if cap(r.buf) < size {
+		r.buf = make([]byte, size)
+	}
+	n, err := r.reader.Read(r.buf[:size])
+	for idx, v := range r.buf[:n] {
+		binary.LittleEndian.PutUint16(b[idx*4:], uint16(r.decodeNext(v&0x0f)))
+		binary.LittleEndian.PutUint16(b[idx*4+2:], uint16(r.decodeNext(v>>4)))
+	}
+	return n * 4, err
+}
+
+func (r *Reader) decodeNext(nibble byte) int16 {
+	v := r.states[r.position].Decode(nibble)
+	r.position = (r.position + 1) % len(r.states)
+	return v
+}
+
+// StreamEncoder encodes 16-bit samples into a raw IMA ADPCM stream.
+type StreamEncoder struct {
+	states     []State
+	position   int
+	pending    byte
+	hasPending bool
+}
+
+func NewStreamEncoder(channels int) *StreamEncoder {
+	return &StreamEncoder{
+		states: make([]State, channels),
+	}
+}
+
+// Encode encodes the samples into "dst" and returns the amount of bytes
+// written, which is (len(samples)+1)/2 at most: if the total amount of
+// samples is odd, the last nibble is kept until the next call (or Flush).
+func (e *StreamEncoder) Encode(dst []byte, samples []int16) int {
+	var n int
+	for _, sample := range samples {
+		nibble := e.states[e.position].Encode(sample)
+		e.position = (e.position + 1) % len(e.states)
+		if !e.hasPending {
+			e.pending = nibble
+			e.hasPending = true
+			continue
+		}
+		dst[n] = e.pending | nibble<<4
+		e.hasPending = false
+		n++
+	}
+	return n
+}
+
+// Flush writes the pending nibble (if any) padded with a zero nibble,
+// and returns the amount of bytes written (0 or 1).
+func (e *StreamEncoder) Flush(dst []byte) int {
+	if !e.hasPending {
+		return 0
+	}
+	dst[0] = e.pending
+	e.hasPending = false
+	return 1
+}
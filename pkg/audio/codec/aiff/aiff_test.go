@@ -0,0 +1,86 @@
+package aiff
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/xaionaro-go/audio/pkg/audio/codec"
+	"github.com/xaionaro-go/audio/pkg/audio/types"
+)
+
+func TestRoundTrip(t *testing.T) {
+	for _, tc := range []struct {
+		header Header
+		isAIFC bool
+	}{
+		{Header{Channels: 1, SampleRate: 8000, PCMFormat: types.PCMFormatS8}, false},
+		{Header{Channels: 2, SampleRate: 44100, PCMFormat: types.PCMFormatS16BE}, false},
+		{Header{Channels: 2, SampleRate: 48000, PCMFormat: types.PCMFormatS24BE}, false},
+		{Header{Channels: 1, SampleRate: 96000, PCMFormat: types.PCMFormatS32BE}, false},
+		{Header{Channels: 2, SampleRate: 44100, PCMFormat: types.PCMFormatS16LE}, true},
+		{Header{Channels: 2, SampleRate: 48000, PCMFormat: types.PCMFormatFloat32BE}, true},
+		{Header{Channels: 6, SampleRate: 22050, PCMFormat: types.PCMFormatFloat64BE}, true},
+		{Header{Channels: 1, SampleRate: 8000, PCMFormat: types.PCMFormatMuLaw}, true},
+		{Header{Channels: 1, SampleRate: 8000, PCMFormat: types.PCMFormatALaw}, true},
+	} {
+		t.Run(fmt.Sprintf("%v_%dch", tc.header.PCMFormat, tc.header.Channels), func(t *testing.T) {
+			filePath := filepath.Join(t.TempDir(), "test.aiff")
+			w, err := Create(filePath, tc.header)
+			require.NoError(t, err)
+			// an odd size to check the padding
+			data := make([]byte, int(tc.header.BlockAlign())*3)
+			for idx := range data {
+				data[idx] = byte(idx)
+			}
+			_, err = w.Write(data)
+			require.NoError(t, err)
+			require.NoError(t, w.Close())
+
+			f, err := os.Open(filePath)
+			require.NoError(t, err)
+			defer f.Close()
+			decoder, err := codec.NewDecoder(f)
+			require.NoError(t, err)
+			r := decoder.(*Reader)
+
+			require.Equal(t, tc.isAIFC, r.IsAIFC())
+			expected := tc.header
+			expected.DataSize = uint64(len(data))
+			require.Equal(t, expected, r.Header())
+			read, err := io.ReadAll(r)
+			require.NoError(t, err)
+			require.Equal(t, data, read)
+		})
+	}
+}
+
+func TestStreaming(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, Header{Channels: 1, SampleRate: 8000, PCMFormat: types.PCMFormatS16BE})
+	require.NoError(t, err)
+	_, err = w.Write([]byte{1, 2, 3, 4})
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	r, err := NewReader(bytes.NewReader(buf.Bytes()))
+	require.NoError(t, err)
+	require.Equal(t, UnknownDataSize, r.Header().DataSize)
+	read, err := io.ReadAll(r)
+	require.NoError(t, err)
+	require.Equal(t, []byte{1, 2, 3, 4}, read)
+}
+
+func TestExtendedFloat(t *testing.T) {
+	// 44100 as it is written by the Apple tools
+	b := []byte{0x40, 0x0E, 0xAC, 0x44, 0, 0, 0, 0, 0, 0}
+	require.Equal(t, 44100.0, parseExtendedFloat(b))
+	require.Equal(t, b, appendExtendedFloat(nil, 44100))
+	for _, v := range []float64{1, 8000, 22050, 48000, 192000, 11025.5} {
+		require.Equal(t, v, parseExtendedFloat(appendExtendedFloat(nil, v)))
+	}
+}
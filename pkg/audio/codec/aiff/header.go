@@ -0,0 +1,248 @@
+package aiff
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"math/bits"
+	"time"
+
+	"github.com/xaionaro-go/audio/pkg/audio/codec"
+	"github.com/xaionaro-go/audio/pkg/audio/codec/internal/container"
+	"github.com/xaionaro-go/audio/pkg/audio/types"
+)
+
+// CompressionType is the four-character code of the AIFF-C encoding.
+type CompressionType [4]byte
+
+var (
+	CompressionNone    = CompressionType{'N', 'O', 'N', 'E'}
+	CompressionSowt    = CompressionType{'s', 'o', 'w', 't'}
+	CompressionFloat32 = CompressionType{'f', 'l', '3', '2'}
+	CompressionFloat64 = CompressionType{'f', 'l', '6', '4'}
+	CompressionMuLaw   = CompressionType{'u', 'l', 'a', 'w'}
+	CompressionALaw    = CompressionType{'a', 'l', 'a', 'w'}
+)
+
+func (c CompressionType) String() string {
+	return string(c[:])
+}
+
+// name is the human-readable name written into the AIFF-C "COMM" chunk.
+func (c CompressionType) name() string {
+	switch c {
+	case CompressionNone:
+		return "not compressed"
+	case CompressionSowt:
+		return ""
+	case CompressionFloat32, CompressionFloat64:
+		return "IEEE floating point"
+	case CompressionMuLaw:
+		return "uLaw 2:1"
+	case CompressionALaw:
+		return "ALaw 2:1"
+	default:
+		return ""
+	}
+}
+
+// UnknownDataSize is the value of Header.DataSize if the size is not
+// known (the file was written into a non-seekable writer and was not finalized).
+const UnknownDataSize = container.UnknownDataSize
+
+const (
+	chunkHeaderSize   = 8
+	commBodySize      = 18
+	ssndHeaderSize    = 8
+	fverBodySize      = 4
+	aifcVersion1      = 0xA2805140
+	sizePlaceholder   = 0xFFFFFFFF
+	extendedFloatSize = 10
+)
+
+type Header struct {
+	Channels   types.Channel
+	SampleRate types.SampleRate
+
+	// PCMFormat is one of the big-endian formats (S8 for 8 bits), or a
+	// format of an AIFF-C compression type (see CompressionType).
+	PCMFormat types.PCMFormat
+
+	DataSize uint64
+}
+
+func (h Header) Format() codec.Format {
+	return codec.Format{
+		Channels:   h.Channels,
+		SampleRate: h.SampleRate,
+		PCMFormat:  h.PCMFormat,
+	}
+}
+
+func (h Header) BlockAlign() uint32 {
+	return uint32(h.Channels) * h.PCMFormat.Size()
+}
+
+// Duration returns the duration of the data, or zero if it is unknown.
+func (h Header) Duration() time.Duration {
+	if h.DataSize == UnknownDataSize || h.BlockAlign() == 0 || h.SampleRate == 0 {
+		return 0
+	}
+	frames := h.DataSize / uint64(h.BlockAlign())
+	return time.Duration(frames) * time.Second / time.Duration(h.SampleRate)
+}
+
+// CompressionType returns the AIFF-C compression type of the format.
+func (h Header) CompressionType() (CompressionType, error) {
+	switch h.PCMFormat {
+	case types.PCMFormatS8, types.PCMFormatS16BE, types.PCMFormatS24BE, types.PCMFormatS32BE:
+		return CompressionNone, nil
+	case types.PCMFormatS16LE, types.PCMFormatS24LE, types.PCMFormatS32LE:
+		return CompressionSowt, nil
+	case types.PCMFormatFloat32BE:
+		return CompressionFloat32, nil
+	case types.PCMFormatFloat64BE:
+		return CompressionFloat64, nil
+	case types.PCMFormatMuLaw:
+		return CompressionMuLaw, nil
+	case types.PCMFormatALaw:
+		return CompressionALaw, nil
+	default:
+		return CompressionType{}, container.ErrUnsupportedPCMFormat(h.PCMFormat, "AIFF")
+	}
+}
+
+// IsAIFC returns true if the format requires AIFF-C (is not a plain big-endian integer format).
+func (h Header) IsAIFC() bool {
+	compression, err := h.CompressionType()
+	return err == nil && compression != CompressionNone
+}
+
+func pcmFormatFor(compression CompressionType, sampleSize uint16) (types.PCMFormat, error) {
+	switch compression {
+	case CompressionNone, CompressionType{'t', 'w', 'o', 's'}:
+		switch (sampleSize + 7) / 8 {
+		case 1:
+			return types.PCMFormatS8, nil
+		case 2:
+			return types.PCMFormatS16BE, nil
+		case 3:
+			return types.PCMFormatS24BE, nil
+		case 4:
+			return types.PCMFormatS32BE, nil
+		}
+	case CompressionSowt:
+		switch (sampleSize + 7) / 8 {
+		case 2:
+			return types.PCMFormatS16LE, nil
+		case 3:
+			return types.PCMFormatS24LE, nil
+		case 4:
+			return types.PCMFormatS32LE, nil
+		}
+	case CompressionFloat32, CompressionType{'F', 'L', '3', '2'}:
+		return types.PCMFormatFloat32BE, nil
+	case CompressionFloat64, CompressionType{'F', 'L', '6', '4'}:
+		return types.PCMFormatFloat64BE, nil
+	case CompressionMuLaw, CompressionType{'U', 'L', 'A', 'W'}:
+		return types.PCMFormatMuLaw, nil
+	case CompressionALaw, CompressionType{'A', 'L', 'A', 'W'}:
+		return types.PCMFormatALaw, nil
+	default:
+		return types.UndefinedPCMFormat, fmt.Errorf("unsupported compression type: %q", compression[:])
+	}
+	return types.UndefinedPCMFormat, fmt.Errorf("unsupported sample size for compression type %v: %d", compression, sampleSize)
+}
+
+// marshalComm returns the body of the "COMM" chunk; the amount of frames
+// is at offset 2.
+func (h Header) marshalComm(frames uint32) ([]byte, error) {
+	if h.Channels == 0 || h.SampleRate == 0 {
+		return nil, fmt.Errorf("invalid header: %#+v", h)
+	}
+	compression, err := h.CompressionType()
+	if err != nil {
+		return nil, err
+	}
+	sampleSize := uint16(h.PCMFormat.Size() * 8)
+	if compression == CompressionMuLaw || compression == CompressionALaw {
+		// the size of the decoded samples
+		sampleSize = 16
+	}
+
+	b := make([]byte, 0, commBodySize+4+64)
+	b = binary.BigEndian.AppendUint16(b, uint16(h.Channels))
+	b = binary.BigEndian.AppendUint32(b, frames)
+	b = binary.BigEndian.AppendUint16(b, sampleSize)
+	b = appendExtendedFloat(b, float64(h.SampleRate))
+	if compression != CompressionNone {
+		b = append(b, compression[:]...)
+		b = appendPascalString(b, compression.name())
+	}
+	return b, nil
+}
+
+func (h *Header) unmarshalComm(b []byte, isAIFC bool) (uint32, error) {
+	if len(b) < commBodySize {
+		return 0, fmt.Errorf("the 'COMM' chunk is too short: %d < %d", len(b), commBodySize)
+	}
+	h.Channels = types.Channel(binary.BigEndian.Uint16(b[0:]))
+	frames := binary.BigEndian.Uint32(b[2:])
+	sampleSize := binary.BigEndian.Uint16(b[6:])
+	sampleRate := parseExtendedFloat(b[8:18])
+	compression := CompressionNone
+	if isAIFC {
+		if len(b) < commBodySize+4 {
+			return 0, fmt.Errorf("the AIFF-C 'COMM' chunk is too short: %d < %d", len(b), commBodySize+4)
+		}
+		copy(compression[:], b[18:])
+	}
+	if h.Channels == 0 || sampleRate < 1 || sampleRate > math.MaxUint32 {
+		return 0, fmt.Errorf("invalid format: %d channels, sample rate %f", h.Channels, sampleRate)
+	}
+	h.SampleRate = types.SampleRate(math.Round(sampleRate))
+	var err error
+	h.PCMFormat, err = pcmFormatFor(compression, sampleSize)
+	if err != nil {
+		return 0, err
+	}
+	return frames, nil
+}
+
+// appendExtendedFloat appends a positive number as an 80-bit IEEE 754
+// extended precision float (which is how AIFF stores the sample rate).
+func appendExtendedFloat(b []byte, v float64) []byte {
+	if v < 1 {
+		return append(b, make([]byte, extendedFloatSize)...)
+	}
+	mantissa := uint64(v)
+	shift := bits.LeadingZeros64(mantissa)
+	exponent := uint16(16383 + 63 - shift)
+	// the fraction part of the value
+	mantissa = mantissa<<shift | uint64((v-math.Floor(v))*math.Exp2(float64(shift)))
+	b = binary.BigEndian.AppendUint16(b, exponent)
+	return binary.BigEndian.AppendUint64(b, mantissa)
+}
+
+func parseExtendedFloat(b []byte) float64 {
+	exponent := int(binary.BigEndian.Uint16(b) & 0x7fff)
+	mantissa := binary.BigEndian.Uint64(b[2:])
+	if exponent == 0 && mantissa == 0 {
+		return 0
+	}
+	v := math.Ldexp(float64(mantissa), exponent-16383-63)
+	if b[0]&0x80 != 0 {
+		v = -v
+	}
+	return v
+}
+
+// appendPascalString appends a string prefixed with its length, padded to an even size.
+func appendPascalString(b []byte, s string) []byte {
+	b = append(b, byte(len(s)))
+	b = append(b, s...)
+	if len(s)%2 == 0 {
+		b = append(b, 0)
+	}
+	return b
+}
@@ -0,0 +1,164 @@
+package aiff
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/xaionaro-go/audio/pkg/audio/codec"
+	"github.com/xaionaro-go/audio/pkg/audio/codec/internal/container"
+)
+
+const (
+	Priority = 100
+)
+
+func init() {
+	codec.RegisterDecoderFactory(Priority, DecoderFactory{})
+}
+
+type DecoderFactory struct{}
+
+var _ codec.DecoderFactory = DecoderFactory{}
+
+func (DecoderFactory) Name() string {
+	return "aiff"
+}
+
+func (DecoderFactory) Sniff(head []byte) bool {
+	if len(head) < 12 || string(head[:4]) != "FORM" {
+		return false
+	}
+	switch string(head[8:12]) {
+	case "AIFF", "AIFC":
+		return true
+	}
+	return false
+}
+
+func (DecoderFactory) NewDecoder(r io.Reader) (codec.Decoder, error) {
+	return NewReader(r)
+}
+
+// Reader reads the PCM data from an AIFF or AIFF-C file.
+type Reader struct {
+	reader io.Reader
+	header Header
+	isAIFC bool
+	data   container.DataReader
+}
+
+var _ codec.Decoder = (*Reader)(nil)
+
+func NewReader(r io.Reader) (*Reader, error) {
+	ar := &Reader{
+		reader: r,
+	}
+	if err := ar.readHeader(); err != nil {
+		return nil, err
+	}
+	ar.data = container.DataReader{Reader: r, Remaining: ar.header.DataSize}
+	return ar, nil
+}
+
+func (r *Reader) readHeader() error {
+	var offset int64
+	readFull := func(b []byte) error {
+		n, err := io.ReadFull(r.reader, b)
+		offset += int64(n)
+		return err
+	}
+	skip := func(size int64) error {
+		n, err := io.CopyN(io.Discard, r.reader, size)
+		offset += n
+		return err
+	}
+
+	var formHeader [12]byte
+	if err := readFull(formHeader[:]); err != nil {
+		return fmt.Errorf("unable to read the FORM header: %w", err)
+	}
+	if !(DecoderFactory{}).Sniff(formHeader[:]) {
+		return fmt.Errorf("not an AIFF file: %q", formHeader[:])
+	}
+	r.isAIFC = string(formHeader[8:12]) == "AIFC"
+
+	var (
+		frames      uint32
+		commIsRead  bool
+		chunkHeader [chunkHeaderSize]byte
+	)
+	for {
+		if err := readFull(chunkHeader[:]); err != nil {
+			if errors.Is(err, io.EOF) {
+				return fmt.Errorf("there is no 'SSND' chunk")
+			}
+			return fmt.Errorf("unable to read a chunk header: %w", err)
+		}
+		chunkID := string(chunkHeader[:4])
+		chunkSize := binary.BigEndian.Uint32(chunkHeader[4:])
+
+		switch chunkID {
+		case "SSND":
+			if !commIsRead {
+				return fmt.Errorf("the 'SSND' chunk precedes the 'COMM' chunk")
+			}
+			var ssndHeader [ssndHeaderSize]byte
+			if err := readFull(ssndHeader[:]); err != nil {
+				return fmt.Errorf("unable to read the 'SSND' chunk header: %w", err)
+			}
+			dataOffset := binary.BigEndian.Uint32(ssndHeader[:])
+			if err := skip(int64(dataOffset)); err != nil {
+				return fmt.Errorf("unable to skip to the sound data: %w", err)
+			}
+			switch {
+			case chunkSize == sizePlaceholder:
+				r.header.DataSize = UnknownDataSize
+			case uint64(chunkSize) < ssndHeaderSize+uint64(dataOffset):
+				return fmt.Errorf("the 'SSND' chunk is too short: %d", chunkSize)
+			default:
+				r.header.DataSize = uint64(chunkSize) - ssndHeaderSize - uint64(dataOffset)
+				// the "COMM" chunk is authoritative
+				r.header.DataSize = min(r.header.DataSize, uint64(frames)*uint64(r.header.BlockAlign()))
+			}
+			return nil
+		case "COMM":
+			if chunkSize > 1<<16 {
+				return fmt.Errorf("the '%s' chunk is too large: %d", chunkID, chunkSize)
+			}
+			body := make([]byte, chunkSize+chunkSize%2)
+			if err := readFull(body); err != nil {
+				return fmt.Errorf("unable to read the '%s' chunk: %w", chunkID, err)
+			}
+			var err error
+			frames, err = r.header.unmarshalComm(body[:chunkSize], r.isAIFC)
+			if err != nil {
+				return err
+			}
+			commIsRead = true
+		default:
+			if err := skip(int64(chunkSize) + int64(chunkSize%2)); err != nil {
+				return fmt.Errorf("unable to skip the '%s' chunk: %w", chunkID, err)
+			}
+		}
+	}
+}
+
+func (r *Reader) Header() Header {
+	return r.header
+}
+
+func (r *Reader) Format() codec.Format {
+	return r.header.Format()
+}
+
+// IsAIFC returns true if the file is AIFF-C.
+func (r *Reader) IsAIFC() bool {
+	return r.isAIFC
+}
+
+// Read reads the PCM data (in Header().PCMFormat).
+func (r *Reader) Read(b []byte) (int, error) {
+	return r.data.Read(b)
+}
@@ -0,0 +1,183 @@
+package aiff
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"os"
+
+	"github.com/hashicorp/go-multierror"
+	"github.com/xaionaro-go/audio/pkg/audio/codec/internal/container"
+)
+
+// layout is where the fields to be patched are located (relative to the
+// beginning of the file).
+type layout struct {
+	framesOffset int64 // the offset of the amount of frames in the "COMM" chunk
+	dataOffset   int64 // the offset of the data (right after the "SSND" chunk header)
+}
+
+// Writer writes an AIFF file (or AIFF-C, if the format requires it, see
+// Header.IsAIFC). The sizes in the header are written as "unknown" first,
+// and are patched on Flush and Close if the underlying writer is an
+// io.WriteSeeker.
+type Writer struct {
+	writer      io.Writer
+	header      Header
+	layout      layout
+	startOffset int64
+	seekable    bool
+	dataSize    uint64
+	closer      io.Closer
+	closed      bool
+}
+
+var _ io.WriteCloser = (*Writer)(nil)
+
+// NewWriter writes the header into "w" and returns a writer of the data.
+// Close does not close "w".
+func NewWriter(w io.Writer, header Header) (*Writer, error) {
+	commBody, err := header.marshalComm(sizePlaceholder)
+	if err != nil {
+		return nil, err
+	}
+	header.DataSize = UnknownDataSize
+	isAIFC := header.IsAIFC()
+
+	b := make([]byte, 0, 12+chunkHeaderSize+fverBodySize+chunkHeaderSize+len(commBody)+chunkHeaderSize+ssndHeaderSize)
+	b = append(b, "FORM"...)
+	b = binary.BigEndian.AppendUint32(b, sizePlaceholder)
+	if isAIFC {
+		b = append(b, "AIFC"...)
+		b = append(b, "FVER"...)
+		b = binary.BigEndian.AppendUint32(b, fverBodySize)
+		b = binary.BigEndian.AppendUint32(b, aifcVersion1)
+	} else {
+		b = append(b, "AIFF"...)
+	}
+	b = append(b, "COMM"...)
+	b = binary.BigEndian.AppendUint32(b, uint32(len(commBody)))
+	framesOffset := int64(len(b)) + 2
+	b = append(b, commBody...)
+	b = append(b, "SSND"...)
+	b = binary.BigEndian.AppendUint32(b, sizePlaceholder)
+	b = binary.BigEndian.AppendUint32(b, 0) // offset
+	b = binary.BigEndian.AppendUint32(b, 0) // block size
+
+	wr := &Writer{
+		writer: w,
+		header: header,
+		layout: layout{
+			framesOffset: framesOffset,
+			dataOffset:   int64(len(b)),
+		},
+	}
+	if seeker, ok := w.(io.Seeker); ok {
+		if offset, err := seeker.Seek(0, io.SeekCurrent); err == nil {
+			wr.startOffset = offset
+			wr.seekable = true
+		}
+	}
+	if err := container.WriteAll(w, b); err != nil {
+		return nil, fmt.Errorf("unable to write the header: %w", err)
+	}
+	return wr, nil
+}
+
+// Create creates the file and returns a writer, which closes the file on Close.
+func Create(filePath string, header Header) (*Writer, error) {
+	f, err := os.Create(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create '%s': %w", filePath, err)
+	}
+	w, err := NewWriter(f, header)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	w.closer = f
+	return w, nil
+}
+
+func (w *Writer) Header() Header {
+	return w.header
+}
+
+// DataSize returns the amount of PCM bytes written so far.
+func (w *Writer) DataSize() uint64 {
+	return w.dataSize
+}
+
+func (w *Writer) Write(b []byte) (int, error) {
+	if w.closed {
+		return 0, fmt.Errorf("the writer is closed")
+	}
+	n, err := w.writer.Write(b)
+	w.dataSize += uint64(n)
+	return n, err
+}
+
+// Flush patches the sizes in the header to match the data written so
+// far (if the underlying writer is seekable).
+func (w *Writer) Flush() error {
+	if !w.seekable {
+		return nil
+	}
+	ws := w.writer.(io.WriteSeeker)
+	dataSize := w.dataSize
+	formSize := uint64(w.layout.dataOffset) - 8 + dataSize + dataSize%2
+	if formSize > math.MaxUint32 {
+		return fmt.Errorf("the data is too large for AIFF: %d bytes", dataSize)
+	}
+	writeAt := func(offset int64, v uint32) error {
+		if _, err := ws.Seek(w.startOffset+offset, io.SeekStart); err != nil {
+			return fmt.Errorf("unable to seek: %w", err)
+		}
+		return container.WriteAll(ws, binary.BigEndian.AppendUint32(nil, v))
+	}
+	for _, field := range []struct {
+		offset int64
+		value  uint32
+	}{
+		{4, uint32(formSize)},
+		{w.layout.framesOffset, uint32(dataSize / uint64(w.header.BlockAlign()))},
+		{w.layout.dataOffset - ssndHeaderSize - 4, uint32(dataSize + ssndHeaderSize)},
+	} {
+		if err := writeAt(field.offset, field.value); err != nil {
+			return fmt.Errorf("unable to patch the header: %w", err)
+		}
+	}
+	if _, err := ws.Seek(w.startOffset+w.layout.dataOffset+int64(dataSize+dataSize%2), io.SeekStart); err != nil {
+		return fmt.Errorf("unable to seek to the end of the data: %w", err)
+	}
+	return nil
+}
+
+// Close writes the padding byte (if required) and patches the sizes in the header.
+func (w *Writer) Close() (_err error) {
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+	var mErr *multierror.Error
+	defer func() {
+		if w.closer != nil {
+			if err := w.closer.Close(); err != nil {
+				mErr = multierror.Append(mErr, err)
+			}
+		}
+		_err = mErr.ErrorOrNil()
+	}()
+
+	if w.dataSize%2 != 0 {
+		if err := container.WriteAll(w.writer, []byte{0}); err != nil {
+			mErr = multierror.Append(mErr, fmt.Errorf("unable to write the padding byte: %w", err))
+			return
+		}
+	}
+	if err := w.Flush(); err != nil {
+		mErr = multierror.Append(mErr, err)
+	}
+	return
+}
@@ -0,0 +1,15 @@
+// Package all registers all the decoders of package codec (see
+// codec.NewDecoder). It is imported by package audio, so Player.PlayFile
+// and Player.PlayReader recognize every supported format.
+package all
+
+import (
+	_ "github.com/xaionaro-go/audio/pkg/audio/codec/aiff"
+	_ "github.com/xaionaro-go/audio/pkg/audio/codec/au"
+	_ "github.com/xaionaro-go/audio/pkg/audio/codec/flac"
+	_ "github.com/xaionaro-go/audio/pkg/audio/codec/matroska"
+	_ "github.com/xaionaro-go/audio/pkg/audio/codec/mp3"
+	_ "github.com/xaionaro-go/audio/pkg/audio/codec/opus"
+	_ "github.com/xaionaro-go/audio/pkg/audio/codec/vorbis"
+	_ "github.com/xaionaro-go/audio/pkg/audio/codec/wav"
+)
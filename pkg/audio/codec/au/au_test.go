@@ -0,0 +1,77 @@
+package au
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/xaionaro-go/audio/pkg/audio/codec"
+	"github.com/xaionaro-go/audio/pkg/audio/types"
+)
+
+func TestRoundTrip(t *testing.T) {
+	for _, header := range []Header{
+		{Channels: 1, SampleRate: 8000, PCMFormat: types.PCMFormatMuLaw},
+		{Channels: 1, SampleRate: 8000, PCMFormat: types.PCMFormatALaw, Annotation: "telephony"},
+		{Channels: 1, SampleRate: 11025, PCMFormat: types.PCMFormatS8},
+		{Channels: 2, SampleRate: 44100, PCMFormat: types.PCMFormatS16BE},
+		{Channels: 2, SampleRate: 48000, PCMFormat: types.PCMFormatS24BE},
+		{Channels: 2, SampleRate: 48000, PCMFormat: types.PCMFormatS32BE},
+		{Channels: 2, SampleRate: 48000, PCMFormat: types.PCMFormatFloat32BE},
+		{Channels: 6, SampleRate: 96000, PCMFormat: types.PCMFormatFloat64BE},
+	} {
+		t.Run(fmt.Sprintf("%v_%dch", header.PCMFormat, header.Channels), func(t *testing.T) {
+			filePath := filepath.Join(t.TempDir(), "test.au")
+			w, err := Create(filePath, header)
+			require.NoError(t, err)
+			data := make([]byte, int(header.BlockAlign())*3)
+			for idx := range data {
+				data[idx] = byte(idx)
+			}
+			_, err = w.Write(data)
+			require.NoError(t, err)
+			require.NoError(t, w.Close())
+
+			f, err := os.Open(filePath)
+			require.NoError(t, err)
+			defer f.Close()
+			decoder, err := codec.NewDecoder(f)
+			require.NoError(t, err)
+			r := decoder.(*Reader)
+
+			expected := header
+			expected.DataSize = uint64(len(data))
+			require.Equal(t, expected, r.Header())
+			read, err := io.ReadAll(r)
+			require.NoError(t, err)
+			require.Equal(t, data, read)
+		})
+	}
+}
+
+func TestStreaming(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, Header{Channels: 1, SampleRate: 8000, PCMFormat: types.PCMFormatMuLaw})
+	require.NoError(t, err)
+	b := buf.Bytes()
+	require.Equal(t, ".snd", string(b[:4]))
+	require.Equal(t, []byte{0, 0, 0, 32}, b[4:8])
+	require.Equal(t, []byte{0, 0, 0, 1}, b[12:16])
+
+	_, err = w.Write([]byte{1, 2, 3})
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	r, err := NewReader(bytes.NewReader(buf.Bytes()))
+	require.NoError(t, err)
+	require.Equal(t, UnknownDataSize, r.Header().DataSize)
+	read, err := io.ReadAll(r)
+	require.NoError(t, err)
+	require.Equal(t, []byte{1, 2, 3}, read)
+
+	_, err = NewWriter(&buf, Header{Channels: 1, SampleRate: 8000, PCMFormat: types.PCMFormatS16LE})
+	require.Error(t, err)
+}
@@ -0,0 +1,197 @@
+package au
+
+import (
+	"encoding/binary"
+	"fmt"
+	"time"
+
+	"github.com/xaionaro-go/audio/pkg/audio/codec"
+	"github.com/xaionaro-go/audio/pkg/audio/codec/internal/container"
+	"github.com/xaionaro-go/audio/pkg/audio/types"
+)
+
+// Encoding is the encoding code of a Sun AU file.
+type Encoding uint32
+
+const (
+	EncodingMuLaw    = Encoding(1)
+	EncodingLinear8  = Encoding(2)
+	EncodingLinear16 = Encoding(3)
+	EncodingLinear24 = Encoding(4)
+	EncodingLinear32 = Encoding(5)
+	EncodingFloat    = Encoding(6)
+	EncodingDouble   = Encoding(7)
+	EncodingALaw     = Encoding(27)
+)
+
+func (e Encoding) String() string {
+	switch e {
+	case EncodingMuLaw:
+		return "mulaw"
+	case EncodingLinear8:
+		return "linear8"
+	case EncodingLinear16:
+		return "linear16"
+	case EncodingLinear24:
+		return "linear24"
+	case EncodingLinear32:
+		return "linear32"
+	case EncodingFloat:
+		return "float"
+	case EncodingDouble:
+		return "double"
+	case EncodingALaw:
+		return "alaw"
+	default:
+		return fmt.Sprintf("<unexpected_value_%d>", uint32(e))
+	}
+}
+
+// UnknownDataSize is the value of Header.DataSize if the size is not
+// known (which is a valid value in Sun AU, e.g. for streams).
+const UnknownDataSize = container.UnknownDataSize
+
+const (
+	headerSize = 24
+
+	// minAnnotationSize is the minimal size of the annotation field
+	// following the header.
+	minAnnotationSize = 4
+
+	sizePlaceholder = 0xFFFFFFFF
+)
+
+type Header struct {
+	Channels   types.Channel
+	SampleRate types.SampleRate
+
+	// PCMFormat is one of the big-endian formats (S8 for 8 bits), PCMFormatMuLaw or PCMFormatALaw.
+	PCMFormat types.PCMFormat
+
+	// Annotation is the free-form text following the header.
+	Annotation string
+
+	DataSize uint64
+}
+
+func (h Header) Format() codec.Format {
+	return codec.Format{
+		Channels:   h.Channels,
+		SampleRate: h.SampleRate,
+		PCMFormat:  h.PCMFormat,
+	}
+}
+
+func (h Header) BlockAlign() uint32 {
+	return uint32(h.Channels) * h.PCMFormat.Size()
+}
+
+// Duration returns the duration of the data, or zero if it is unknown.
+func (h Header) Duration() time.Duration {
+	if h.DataSize == UnknownDataSize || h.BlockAlign() == 0 || h.SampleRate == 0 {
+		return 0
+	}
+	frames := h.DataSize / uint64(h.BlockAlign())
+	return time.Duration(frames) * time.Second / time.Duration(h.SampleRate)
+}
+
+func encodingFor(pcmFormat types.PCMFormat) (Encoding, error) {
+	switch pcmFormat {
+	case types.PCMFormatMuLaw:
+		return EncodingMuLaw, nil
+	case types.PCMFormatS8:
+		return EncodingLinear8, nil
+	case types.PCMFormatS16BE:
+		return EncodingLinear16, nil
+	case types.PCMFormatS24BE:
+		return EncodingLinear24, nil
+	case types.PCMFormatS32BE:
+		return EncodingLinear32, nil
+	case types.PCMFormatFloat32BE:
+		return EncodingFloat, nil
+	case types.PCMFormatFloat64BE:
+		return EncodingDouble, nil
+	case types.PCMFormatALaw:
+		return EncodingALaw, nil
+	default:
+		return 0, container.ErrUnsupportedPCMFormat(pcmFormat, "Sun AU")
+	}
+}
+
+func pcmFormatFor(encoding Encoding) (types.PCMFormat, error) {
+	switch encoding {
+	case EncodingMuLaw:
+		return types.PCMFormatMuLaw, nil
+	case EncodingLinear8:
+		return types.PCMFormatS8, nil
+	case EncodingLinear16:
+		return types.PCMFormatS16BE, nil
+	case EncodingLinear24:
+		return types.PCMFormatS24BE, nil
+	case EncodingLinear32:
+		return types.PCMFormatS32BE, nil
+	case EncodingFloat:
+		return types.PCMFormatFloat32BE, nil
+	case EncodingDouble:
+		return types.PCMFormatFloat64BE, nil
+	case EncodingALaw:
+		return types.PCMFormatALaw, nil
+	default:
+		return types.UndefinedPCMFormat, fmt.Errorf("unsupported encoding: %v", encoding)
+	}
+}
+
+// marshal returns the header followed by the annotation (NUL-terminated
+// and padded to a multiple of 8 bytes).
+func (h Header) marshal() ([]byte, error) {
+	if h.Channels == 0 || h.SampleRate == 0 {
+		return nil, fmt.Errorf("invalid header: %#+v", h)
+	}
+	encoding, err := encodingFor(h.PCMFormat)
+	if err != nil {
+		return nil, err
+	}
+	annotationSize := max((len(h.Annotation)+1+7)/8*8, minAnnotationSize)
+	dataSize := uint32(sizePlaceholder)
+	if h.DataSize < sizePlaceholder {
+		dataSize = uint32(h.DataSize)
+	}
+
+	b := make([]byte, 0, headerSize+annotationSize)
+	b = append(b, ".snd"...)
+	b = binary.BigEndian.AppendUint32(b, uint32(headerSize+annotationSize))
+	b = binary.BigEndian.AppendUint32(b, dataSize)
+	b = binary.BigEndian.AppendUint32(b, uint32(encoding))
+	b = binary.BigEndian.AppendUint32(b, uint32(h.SampleRate))
+	b = binary.BigEndian.AppendUint32(b, uint32(h.Channels))
+	b = append(b, h.Annotation...)
+	return append(b, make([]byte, headerSize+annotationSize-len(b))...), nil
+}
+
+// unmarshal parses the fixed part of the header and returns the offset of the data.
+func (h *Header) unmarshal(b []byte) (uint32, error) {
+	if string(b[:4]) != ".snd" {
+		return 0, fmt.Errorf("not a Sun AU file: %q", b[:4])
+	}
+	dataOffset := binary.BigEndian.Uint32(b[4:])
+	if dataOffset < headerSize {
+		return 0, fmt.Errorf("invalid data offset: %d", dataOffset)
+	}
+	dataSize := binary.BigEndian.Uint32(b[8:])
+	if dataSize == sizePlaceholder {
+		h.DataSize = UnknownDataSize
+	} else {
+		h.DataSize = uint64(dataSize)
+	}
+	var err error
+	h.PCMFormat, err = pcmFormatFor(Encoding(binary.BigEndian.Uint32(b[12:])))
+	if err != nil {
+		return 0, err
+	}
+	h.SampleRate = types.SampleRate(binary.BigEndian.Uint32(b[16:]))
+	h.Channels = types.Channel(binary.BigEndian.Uint32(b[20:]))
+	if h.Channels == 0 || h.SampleRate == 0 {
+		return 0, fmt.Errorf("invalid format: %d channels, sample rate %d", h.Channels, h.SampleRate)
+	}
+	return dataOffset, nil
+}
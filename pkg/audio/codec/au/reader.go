@@ -0,0 +1,87 @@
+package au
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+
+	"github.com/xaionaro-go/audio/pkg/audio/codec"
+	"github.com/xaionaro-go/audio/pkg/audio/codec/internal/container"
+)
+
+const (
+	Priority = 100
+)
+
+func init() {
+	codec.RegisterDecoderFactory(Priority, DecoderFactory{})
+}
+
+type DecoderFactory struct{}
+
+var _ codec.DecoderFactory = DecoderFactory{}
+
+func (DecoderFactory) Name() string {
+	return "au"
+}
+
+func (DecoderFactory) Sniff(head []byte) bool {
+	return bytes.HasPrefix(head, []byte(".snd"))
+}
+
+func (DecoderFactory) NewDecoder(r io.Reader) (codec.Decoder, error) {
+	return NewReader(r)
+}
+
+// Reader reads the PCM data from a Sun AU file.
+type Reader struct {
+	reader io.Reader
+	header Header
+	data   container.DataReader
+}
+
+var _ codec.Decoder = (*Reader)(nil)
+
+func NewReader(r io.Reader) (*Reader, error) {
+	b := make([]byte, headerSize)
+	if _, err := io.ReadFull(r, b); err != nil {
+		return nil, fmt.Errorf("unable to read the header: %w", err)
+	}
+	ar := &Reader{
+		reader: r,
+	}
+	dataOffset, err := ar.header.unmarshal(b)
+	if err != nil {
+		return nil, err
+	}
+	if dataOffset > headerSize {
+		if dataOffset > 1<<20 {
+			return nil, fmt.Errorf("the annotation is too large: %d", dataOffset-headerSize)
+		}
+		annotation := make([]byte, dataOffset-headerSize)
+		if _, err := io.ReadFull(r, annotation); err != nil {
+			return nil, fmt.Errorf("unable to read the annotation: %w", err)
+		}
+		if idx := bytes.IndexByte(annotation, 0); idx >= 0 {
+			annotation = annotation[:idx]
+		}
+		ar.header.Annotation = string(annotation)
+	}
+	ar.data = container.DataReader{Reader: r, Remaining: ar.header.DataSize}
+	return ar, nil
+}
+
+// Header returns the header of the file. Header().DataSize is
+// UnknownDataSize if the size was not specified.
+func (r *Reader) Header() Header {
+	return r.header
+}
+
+func (r *Reader) Format() codec.Format {
+	return r.header.Format()
+}
+
+// Read reads the PCM data (in Header().PCMFormat).
+func (r *Reader) Read(b []byte) (int, error) {
+	return r.data.Read(b)
+}
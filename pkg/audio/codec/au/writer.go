@@ -0,0 +1,126 @@
+package au
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/hashicorp/go-multierror"
+	"github.com/xaionaro-go/audio/pkg/audio/codec/internal/container"
+)
+
+// dataSizeOffset is the offset of the data size field in the header.
+const dataSizeOffset = 8
+
+// Writer writes a Sun AU file. The data size is written as "unknown"
+// first (which is valid in Sun AU), and is patched on Flush and Close if
+// the underlying writer is an io.WriteSeeker.
+type Writer struct {
+	writer      io.Writer
+	header      Header
+	dataOffset  int64
+	startOffset int64
+	seekable    bool
+	dataSize    uint64
+	closer      io.Closer
+	closed      bool
+}
+
+var _ io.WriteCloser = (*Writer)(nil)
+
+// NewWriter writes the header into "w" and returns a writer of the data.
+// Close does not close "w".
+func NewWriter(w io.Writer, header Header) (*Writer, error) {
+	header.DataSize = UnknownDataSize
+	b, err := header.marshal()
+	if err != nil {
+		return nil, err
+	}
+	wr := &Writer{
+		writer:     w,
+		header:     header,
+		dataOffset: int64(len(b)),
+	}
+	if seeker, ok := w.(io.Seeker); ok {
+		if offset, err := seeker.Seek(0, io.SeekCurrent); err == nil {
+			wr.startOffset = offset
+			wr.seekable = true
+		}
+	}
+	if err := container.WriteAll(w, b); err != nil {
+		return nil, fmt.Errorf("unable to write the header: %w", err)
+	}
+	return wr, nil
+}
+
+// Create creates the file and returns a writer, which closes the file on Close.
+func Create(filePath string, header Header) (*Writer, error) {
+	f, err := os.Create(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create '%s': %w", filePath, err)
+	}
+	w, err := NewWriter(f, header)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	w.closer = f
+	return w, nil
+}
+
+func (w *Writer) Header() Header {
+	return w.header
+}
+
+// DataSize returns the amount of PCM bytes written so far.
+func (w *Writer) DataSize() uint64 {
+	return w.dataSize
+}
+
+func (w *Writer) Write(b []byte) (int, error) {
+	if w.closed {
+		return 0, fmt.Errorf("the writer is closed")
+	}
+	n, err := w.writer.Write(b)
+	w.dataSize += uint64(n)
+	return n, err
+}
+
+// Flush patches the data size in the header to match the data written
+// so far (if the underlying writer is seekable). If the data exceeds
+// 4GiB, the size is left unknown.
+func (w *Writer) Flush() error {
+	if !w.seekable || w.dataSize >= sizePlaceholder {
+		return nil
+	}
+	ws := w.writer.(io.WriteSeeker)
+	if _, err := ws.Seek(w.startOffset+dataSizeOffset, io.SeekStart); err != nil {
+		return fmt.Errorf("unable to seek: %w", err)
+	}
+	if err := container.WriteAll(ws, binary.BigEndian.AppendUint32(nil, uint32(w.dataSize))); err != nil {
+		return fmt.Errorf("unable to patch the header: %w", err)
+	}
+	if _, err := ws.Seek(w.startOffset+w.dataOffset+int64(w.dataSize), io.SeekStart); err != nil {
+		return fmt.Errorf("unable to seek to the end of the data: %w", err)
+	}
+	return nil
+}
+
+// Close patches the data size in the header.
+func (w *Writer) Close() (_err error) {
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+	var mErr *multierror.Error
+	if err := w.Flush(); err != nil {
+		mErr = multierror.Append(mErr, err)
+	}
+	if w.closer != nil {
+		if err := w.closer.Close(); err != nil {
+			mErr = multierror.Append(mErr, err)
+		}
+	}
+	return mErr.ErrorOrNil()
+}
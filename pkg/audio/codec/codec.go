@@ -0,0 +1,32 @@
+package codec
+
+import (
+	"io"
+
+	"github.com/xaionaro-go/audio/pkg/audio/types"
+)
+
+// Format is the format of the PCM data produced by a Decoder.
+type Format struct {
+	Channels   types.Channel
+	SampleRate types.SampleRate
+	PCMFormat  types.PCMFormat
+}
+
+// Decoder is a decoded stream: reading it returns PCM data in Format().
+type Decoder interface {
+	io.Reader
+	Format() Format
+}
+
+type DecoderFactory interface {
+	// Name is a short name of the format, like "vorbis".
+	Name() string
+
+	// Sniff reports if the data starting with "head" is in the format of
+	// the decoder. "head" is at most SniffLength bytes long (and might be
+	// shorter if the data is shorter).
+	Sniff(head []byte) bool
+
+	NewDecoder(r io.Reader) (Decoder, error)
+}
@@ -0,0 +1,91 @@
+package codec_test
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/xaionaro-go/audio/pkg/audio/codec"
+	"github.com/xaionaro-go/audio/pkg/audio/codec/vorbis"
+	"github.com/xaionaro-go/audio/pkg/audio/types"
+)
+
+type testDecoder struct {
+	io.Reader
+}
+
+func (testDecoder) Format() codec.Format {
+	return codec.Format{Channels: 1, SampleRate: 8000, PCMFormat: types.PCMFormatU8}
+}
+
+type testDecoderFactory struct{}
+
+func (testDecoderFactory) Name() string { return "test" }
+
+func (testDecoderFactory) Sniff(head []byte) bool {
+	return bytes.HasPrefix(head, []byte("TEST"))
+}
+
+func (testDecoderFactory) NewDecoder(r io.Reader) (codec.Decoder, error) {
+	if _, err := io.ReadFull(r, make([]byte, 4)); err != nil {
+		return nil, err
+	}
+	return testDecoder{Reader: r}, nil
+}
+
+func init() {
+	codec.RegisterDecoderFactory(0, testDecoderFactory{})
+}
+
+func TestNewDecoder(t *testing.T) {
+	decoder, err := codec.NewDecoder(bytes.NewReader([]byte("TEST\x01\x02\x03")))
+	require.NoError(t, err)
+	require.Equal(t, types.PCMFormatU8, decoder.Format().PCMFormat)
+	pcm, err := io.ReadAll(decoder)
+	require.NoError(t, err)
+	require.Equal(t, []byte{1, 2, 3}, pcm)
+
+	_, err = codec.NewDecoder(bytes.NewReader([]byte("UNKNOWN")))
+	require.Error(t, err)
+
+	require.Panics(t, func() {
+		codec.RegisterDecoderFactory(1, &testDecoderFactory{})
+	})
+}
+
+func TestNewDecoderReadSeeker(t *testing.T) {
+	r := bytes.NewReader([]byte("xxTEST\x01\x02\x03"))
+	_, err := r.Seek(2, io.SeekStart)
+	require.NoError(t, err)
+
+	decoder, err := codec.NewDecoder(r)
+	require.NoError(t, err)
+	require.Same(t, r, decoder.(testDecoder).Reader)
+	pcm, err := io.ReadAll(decoder)
+	require.NoError(t, err)
+	require.Equal(t, []byte{1, 2, 3}, pcm)
+}
+
+func TestVorbis(t *testing.T) {
+	b, err := os.ReadFile("../../../cmd/beep/resources/long_audio.ogg")
+	require.NoError(t, err)
+
+	factory, err := codec.Sniff(b)
+	require.NoError(t, err)
+	require.IsType(t, vorbis.DecoderFactory{}, factory)
+
+	decoder, err := codec.NewDecoder(bytes.NewReader(b))
+	require.NoError(t, err)
+	require.NotZero(t, decoder.(*vorbis.Decoder).Duration())
+	format := decoder.Format()
+	require.Equal(t, types.PCMFormatFloat32LE, format.PCMFormat)
+	require.NotZero(t, format.SampleRate)
+	require.NotZero(t, format.Channels)
+
+	pcm, err := io.ReadAll(decoder)
+	require.NoError(t, err)
+	require.NotZero(t, len(pcm))
+	require.Zero(t, len(pcm)%(4*int(format.Channels)))
+}
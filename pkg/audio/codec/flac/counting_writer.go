@@ -0,0 +1,74 @@
+package flac
+
+import (
+	"fmt"
+	"io"
+)
+
+// countingWriter counts the written bytes, and hides the io.Closer of the
+// underlying writer (flac.Encoder closes the writer if it can).
+type countingWriter struct {
+	writer io.Writer
+	size   int64
+}
+
+func (w *countingWriter) Write(b []byte) (int, error) {
+	n, err := w.writer.Write(b)
+	w.size += int64(n)
+	return n, err
+}
+
+func (w *countingWriter) Size() int64 {
+	return w.size
+}
+
+// countingWriteSeeker is countingWriter for seekable writers; the offsets
+// are relative to the position of the writer at the beginning.
+type countingWriteSeeker struct {
+	writer   io.WriteSeeker
+	start    int64
+	position int64
+	size     int64
+}
+
+func (w *countingWriteSeeker) Write(b []byte) (int, error) {
+	n, err := w.writer.Write(b)
+	w.position += int64(n)
+	w.size = max(w.size, w.position)
+	return n, err
+}
+
+func (w *countingWriteSeeker) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += w.position
+	case io.SeekEnd:
+		offset += w.size
+	default:
+		return 0, fmt.Errorf("invalid whence: %d", whence)
+	}
+	if _, err := w.writer.Seek(w.start+offset, io.SeekStart); err != nil {
+		return 0, err
+	}
+	w.position = offset
+	return offset, nil
+}
+
+func (w *countingWriteSeeker) Size() int64 {
+	return w.size
+}
+
+type sizeWriter interface {
+	io.Writer
+	Size() int64
+}
+
+func newCountingWriter(w io.Writer) sizeWriter {
+	if seeker, ok := w.(io.WriteSeeker); ok {
+		if offset, err := seeker.Seek(0, io.SeekCurrent); err == nil {
+			return &countingWriteSeeker{writer: seeker, start: offset}
+		}
+	}
+	return &countingWriter{writer: w}
+}
@@ -0,0 +1,197 @@
+package flac
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/mewkiz/flac"
+	"github.com/mewkiz/flac/frame"
+	"github.com/mewkiz/flac/meta"
+	"github.com/xaionaro-go/audio/pkg/audio/codec"
+	"github.com/xaionaro-go/audio/pkg/audio/types"
+)
+
+const (
+	Priority = 100
+)
+
+func init() {
+	codec.RegisterDecoderFactory(Priority, DecoderFactory{})
+}
+
+type DecoderFactory struct{}
+
+var _ codec.DecoderFactory = DecoderFactory{}
+
+func (DecoderFactory) Name() string {
+	return "flac"
+}
+
+func (DecoderFactory) Sniff(head []byte) bool {
+	return bytes.HasPrefix(head, []byte("fLaC"))
+}
+
+func (DecoderFactory) NewDecoder(r io.Reader) (codec.Decoder, error) {
+	return NewDecoder(r)
+}
+
+// Decoder decodes FLAC into PCM of the bit depth of the stream rounded
+// up to a whole amount of bytes (U8, S16LE, S24LE or S32LE).
+type Decoder struct {
+	stream    *flac.Stream
+	pcmFormat types.PCMFormat
+	closer    io.Closer
+
+	pending  []byte
+	position uint64
+	skip     uint64
+}
+
+var _ codec.Decoder = (*Decoder)(nil)
+
+// NewDecoder parses the metadata of the stream. Seeking is supported only
+// if "r" is an io.ReadSeeker.
+func NewDecoder(r io.Reader) (*Decoder, error) {
+	var (
+		stream *flac.Stream
+		err    error
+	)
+	if rs, ok := r.(io.ReadSeeker); ok {
+		stream, err = flac.NewSeek(rs)
+	} else {
+		stream, err = flac.New(r)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse the FLAC stream: %w", err)
+	}
+	pcmFormat, err := pcmFormatForBitsPerSample(stream.Info.BitsPerSample)
+	if err != nil {
+		return nil, err
+	}
+	return &Decoder{
+		stream:    stream,
+		pcmFormat: pcmFormat,
+	}, nil
+}
+
+// Open opens a FLAC file for decoding (with seeking enabled).
+func Open(filePath string) (*Decoder, error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open '%s': %w", filePath, err)
+	}
+	d, err := NewDecoder(f)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	d.closer = f
+	return d, nil
+}
+
+// StreamInfo returns the STREAMINFO metadata block.
+func (d *Decoder) StreamInfo() meta.StreamInfo {
+	return *d.stream.Info
+}
+
+func (d *Decoder) Format() codec.Format {
+	return codec.Format{
+		Channels:   types.Channel(d.stream.Info.NChannels),
+		SampleRate: types.SampleRate(d.stream.Info.SampleRate),
+		PCMFormat:  d.pcmFormat,
+	}
+}
+
+// Duration returns the duration of the stream, or zero if it is unknown.
+func (d *Decoder) Duration() time.Duration {
+	return d.samplesToDuration(d.stream.Info.NSamples)
+}
+
+// Position returns the position of the next sample to be read.
+func (d *Decoder) Position() time.Duration {
+	return d.samplesToDuration(d.position)
+}
+
+func (d *Decoder) samplesToDuration(samples uint64) time.Duration {
+	if d.stream.Info.SampleRate == 0 {
+		return 0
+	}
+	return time.Duration(samples) * time.Second / time.Duration(d.stream.Info.SampleRate)
+}
+
+// SeekSample moves the position to the given sample (per channel). It uses
+// the SEEKTABLE if the stream has it, and scans the stream otherwise.
+func (d *Decoder) SeekSample(sample uint64) error {
+	frameStart, err := d.stream.Seek(sample)
+	if err != nil {
+		if errors.Is(err, flac.ErrNoSeeker) {
+			return fmt.Errorf("the stream is not seekable: %w", err)
+		}
+		return fmt.Errorf("unable to seek to sample %d: %w", sample, err)
+	}
+	d.pending = d.pending[:0]
+	d.position = sample
+	d.skip = sample - frameStart
+	return nil
+}
+
+func (d *Decoder) Read(b []byte) (int, error) {
+	for len(d.pending) == 0 {
+		if err := d.decodeFrame(); err != nil {
+			return 0, err
+		}
+	}
+	frameSize := int(d.stream.Info.NChannels) * int(d.pcmFormat.Size())
+	n := copy(b, d.pending)
+	d.pending = d.pending[n:]
+	d.position += uint64(n / frameSize)
+	return n, nil
+}
+
+func (d *Decoder) decodeFrame() error {
+	f, err := d.stream.ParseNext()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return io.EOF
+		}
+		return fmt.Errorf("unable to decode a frame: %w", err)
+	}
+
+	bitsPerSample := f.BitsPerSample
+	if bitsPerSample == 0 {
+		bitsPerSample = d.stream.Info.BitsPerSample
+	}
+	sampleSize := int(d.pcmFormat.Size())
+	blockSize := int(f.BlockSize)
+	skip := int(min(d.skip, uint64(blockSize)))
+	d.skip -= uint64(skip)
+
+	size := (blockSize - skip) * len(f.Subframes) * sampleSize
+	if cap(d.pending) < size {
+		d.pending = make([]byte, size)
+	}
+	d.pending = d.pending[:size]
+	d.writeSamples(f, skip, bitsPerSample)
+	return nil
+}
+
+func (d *Decoder) writeSamples(f *frame.Frame, skip int, bitsPerSample uint8) {
+	var offset int
+	for idx := skip; idx < int(f.BlockSize); idx++ {
+		for _, subframe := range f.Subframes {
+			offset += putSample(d.pending[offset:], subframe.Samples[idx], bitsPerSample)
+		}
+	}
+}
+
+// Close closes the file if the decoder was created by Open.
+func (d *Decoder) Close() error {
+	if d.closer == nil {
+		return nil
+	}
+	return d.closer.Close()
+}
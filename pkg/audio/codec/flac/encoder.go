@@ -0,0 +1,335 @@
+package flac
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/hashicorp/go-multierror"
+	"github.com/mewkiz/flac"
+	"github.com/mewkiz/flac/frame"
+	"github.com/mewkiz/flac/meta"
+	"github.com/xaionaro-go/audio/pkg/audio/codec"
+)
+
+const (
+	DefaultBlockSize  = 4096
+	DefaultSeekPoints = 100
+
+	seekPointSize = 18
+	// "fLaC" + the header and the body of STREAMINFO
+	seekTableOffset = 4 + 4 + 34
+)
+
+type EncoderConfig struct {
+	// BlockSize is the amount of samples (per channel) in a frame.
+	BlockSize uint16
+
+	// SeekPoints is the size of the SEEKTABLE; it is filled on Close
+	// if the writer is seekable.
+	SeekPoints uint
+}
+
+func DefaultEncoderConfig() EncoderConfig {
+	return EncoderConfig{
+		BlockSize:  DefaultBlockSize,
+		SeekPoints: DefaultSeekPoints,
+	}
+}
+
+type frameInfo struct {
+	sampleNumber uint64
+	offset       uint64
+	blockSize    uint16
+}
+
+// Encoder encodes PCM (written into it) as FLAC. Each channel is encoded
+// with the best of the fixed predictors, and stereo is additionally
+// decorrelated (left/side, side/right or mid/side) where it helps.
+type Encoder struct {
+	format        codec.Format
+	config        EncoderConfig
+	bitsPerSample uint8
+
+	encoder *flac.Encoder
+	output  sizeWriter
+	closer  io.Closer
+
+	pending    []byte
+	frames     []frameInfo
+	dataOffset uint64
+	samples    uint64
+	closed     bool
+}
+
+var _ io.WriteCloser = (*Encoder)(nil)
+
+// NewEncoder writes the metadata into "w" and returns an encoder of
+// the data. STREAMINFO and SEEKTABLE are finalized on Close if "w"
+// is an io.WriteSeeker. Close does not close "w".
+func NewEncoder(
+	w io.Writer,
+	format codec.Format,
+	cfg EncoderConfig,
+) (*Encoder, error) {
+	bitsPerSample, err := bitsPerSampleForPCMFormat(format.PCMFormat)
+	if err != nil {
+		return nil, err
+	}
+	if format.Channels < 1 || format.Channels > 8 {
+		return nil, fmt.Errorf("FLAC supports 1-8 channels, but %d requested", format.Channels)
+	}
+	if format.SampleRate == 0 || format.SampleRate > 655350 {
+		return nil, fmt.Errorf("invalid sample rate: %d", format.SampleRate)
+	}
+	if cfg.BlockSize == 0 {
+		cfg.BlockSize = DefaultBlockSize
+	}
+	if cfg.BlockSize < 16 {
+		return nil, fmt.Errorf("the block size is too small: %d < 16", cfg.BlockSize)
+	}
+
+	var blocks []*meta.Block
+	if cfg.SeekPoints > 0 {
+		points := make([]meta.SeekPoint, cfg.SeekPoints)
+		for idx := range points {
+			points[idx].SampleNum = meta.PlaceholderPoint
+		}
+		blocks = append(blocks, &meta.Block{
+			Header: meta.Header{
+				Type:   meta.TypeSeekTable,
+				Length: int64(len(points) * seekPointSize),
+			},
+			Body: &meta.SeekTable{Points: points},
+		})
+	}
+
+	output := newCountingWriter(w)
+	encoder, err := flac.NewEncoder(output, &meta.StreamInfo{
+		BlockSizeMin:  cfg.BlockSize,
+		BlockSizeMax:  cfg.BlockSize,
+		SampleRate:    uint32(format.SampleRate),
+		NChannels:     uint8(format.Channels),
+		BitsPerSample: bitsPerSample,
+	}, blocks...)
+	if err != nil {
+		return nil, fmt.Errorf("unable to initialize the encoder: %w", err)
+	}
+	return &Encoder{
+		format:        format,
+		config:        cfg,
+		bitsPerSample: bitsPerSample,
+		encoder:       encoder,
+		output:        output,
+		dataOffset:    uint64(output.Size()),
+	}, nil
+}
+
+// Create creates the file and returns an encoder, which closes the file on Close.
+func Create(
+	filePath string,
+	format codec.Format,
+	cfg EncoderConfig,
+) (*Encoder, error) {
+	f, err := os.Create(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create '%s': %w", filePath, err)
+	}
+	e, err := NewEncoder(f, format, cfg)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	e.closer = f
+	return e, nil
+}
+
+func (e *Encoder) frameSize() int {
+	return int(e.format.Channels) * int(e.format.PCMFormat.Size())
+}
+
+// Write accepts PCM in the format passed to NewEncoder.
+func (e *Encoder) Write(b []byte) (int, error) {
+	if e.closed {
+		return 0, fmt.Errorf("the encoder is closed")
+	}
+	blockBytes := int(e.config.BlockSize) * e.frameSize()
+	written := len(b)
+	for len(e.pending)+len(b) >= blockBytes {
+		var block []byte
+		if len(e.pending) > 0 {
+			n := blockBytes - len(e.pending)
+			e.pending = append(e.pending, b[:n]...)
+			b = b[n:]
+			block = e.pending
+		} else {
+			block = b[:blockBytes]
+			b = b[blockBytes:]
+		}
+		if err := e.encodeBlock(block); err != nil {
+			return 0, err
+		}
+		e.pending = e.pending[:0]
+	}
+	e.pending = append(e.pending, b...)
+	return written, nil
+}
+
+func (e *Encoder) encodeBlock(block []byte) error {
+	channels := int(e.format.Channels)
+	sampleSize := int(e.format.PCMFormat.Size())
+	blockSize := len(block) / e.frameSize()
+
+	subframes := make([]*frame.Subframe, channels)
+	for ch := range subframes {
+		samples := make([]int32, blockSize)
+		for idx := range samples {
+			samples[idx] = getSample(block[(idx*channels+ch)*sampleSize:], e.format.PCMFormat)
+		}
+		subframes[ch] = &frame.Subframe{
+			SubHeader: frame.SubHeader{Pred: frame.PredVerbatim},
+			Samples:   samples,
+			NSamples:  blockSize,
+		}
+	}
+	channelAssignment := frame.Channels(channels - 1)
+	if channels == 2 {
+		channelAssignment = chooseStereoDecorrelation(subframes[0].Samples, subframes[1].Samples)
+	}
+
+	e.frames = append(e.frames, frameInfo{
+		sampleNumber: e.samples,
+		offset:       uint64(e.output.Size()) - e.dataOffset,
+		blockSize:    uint16(blockSize),
+	})
+	err := e.encoder.WriteFrame(&frame.Frame{
+		Header: frame.Header{
+			HasFixedBlockSize: true,
+			BlockSize:         uint16(blockSize),
+			SampleRate:        uint32(e.format.SampleRate),
+			Channels:          channelAssignment,
+			BitsPerSample:     e.bitsPerSample,
+		},
+		Subframes: subframes,
+	})
+	if err != nil {
+		return fmt.Errorf("unable to encode a frame: %w", err)
+	}
+	e.samples += uint64(blockSize)
+	return nil
+}
+
+// chooseStereoDecorrelation estimates the cost of each channel
+// assignment with the sum of absolute second-order residuals.
+func chooseStereoDecorrelation(left, right []int32) frame.Channels {
+	var costL, costR, costM, costS uint64
+	for idx := 2; idx < len(left); idx++ {
+		l := int64(left[idx]) - 2*int64(left[idx-1]) + int64(left[idx-2])
+		r := int64(right[idx]) - 2*int64(right[idx-1]) + int64(right[idx-2])
+		costL += abs(l)
+		costR += abs(r)
+		costM += abs((l + r) >> 1)
+		costS += abs(l - r)
+	}
+	best, bestCost := frame.ChannelsLR, costL+costR
+	for _, c := range []struct {
+		channels frame.Channels
+		cost     uint64
+	}{
+		{frame.ChannelsLeftSide, costL + costS},
+		{frame.ChannelsSideRight, costS + costR},
+		{frame.ChannelsMidSide, costM + costS},
+	} {
+		if c.cost < bestCost {
+			best, bestCost = c.channels, c.cost
+		}
+	}
+	return best
+}
+
+func abs(v int64) uint64 {
+	if v < 0 {
+		return uint64(-v)
+	}
+	return uint64(v)
+}
+
+// Close encodes the remaining data and finalizes the metadata (if the
+// writer is seekable). An incomplete trailing frame is dropped.
+func (e *Encoder) Close() (_err error) {
+	if e.closed {
+		return nil
+	}
+	e.closed = true
+	var mErr *multierror.Error
+	defer func() {
+		if e.closer != nil {
+			if err := e.closer.Close(); err != nil {
+				mErr = multierror.Append(mErr, err)
+			}
+		}
+		_err = mErr.ErrorOrNil()
+	}()
+
+	if complete := len(e.pending) - len(e.pending)%e.frameSize(); complete > 0 {
+		if err := e.encodeBlock(e.pending[:complete]); err != nil {
+			mErr = multierror.Append(mErr, err)
+			return
+		}
+	}
+	if err := e.encoder.Close(); err != nil {
+		mErr = multierror.Append(mErr, fmt.Errorf("unable to finalize the stream: %w", err))
+		return
+	}
+	if err := e.writeSeekTable(); err != nil {
+		mErr = multierror.Append(mErr, err)
+	}
+	return
+}
+
+func (e *Encoder) writeSeekTable() error {
+	ws, ok := e.output.(io.WriteSeeker)
+	if !ok {
+		return nil
+	}
+	if e.config.SeekPoints > 0 {
+		if _, err := ws.Seek(seekTableOffset+4, io.SeekStart); err != nil {
+			return fmt.Errorf("unable to seek to the SEEKTABLE: %w", err)
+		}
+		if _, err := ws.Write(e.seekPoints()); err != nil {
+			return fmt.Errorf("unable to write the SEEKTABLE: %w", err)
+		}
+	}
+	if _, err := ws.Seek(0, io.SeekEnd); err != nil {
+		return fmt.Errorf("unable to seek to the end: %w", err)
+	}
+	return nil
+}
+
+// seekPoints returns the SEEKTABLE body with points evenly distributed
+// over the stream (or placeholders, if there are not enough frames).
+func (e *Encoder) seekPoints() []byte {
+	points := make([]byte, 0, int(e.config.SeekPoints)*seekPointSize)
+	lastFrame := -1
+	for idx := uint(0); idx < e.config.SeekPoints; idx++ {
+		target := e.samples * uint64(idx) / uint64(e.config.SeekPoints)
+		frameIdx := lastFrame + 1
+		for frameIdx+1 < len(e.frames) && e.frames[frameIdx+1].sampleNumber <= target {
+			frameIdx++
+		}
+		if frameIdx >= len(e.frames) {
+			break
+		}
+		lastFrame = frameIdx
+		f := e.frames[frameIdx]
+		points = binary.BigEndian.AppendUint64(points, f.sampleNumber)
+		points = binary.BigEndian.AppendUint64(points, f.offset)
+		points = binary.BigEndian.AppendUint16(points, f.blockSize)
+	}
+	for len(points) < cap(points) {
+		points = binary.BigEndian.AppendUint64(points, meta.PlaceholderPoint)
+		points = append(points, make([]byte, seekPointSize-8)...)
+	}
+	return points
+}
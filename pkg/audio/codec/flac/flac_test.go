@@ -0,0 +1,103 @@
+package flac
+
+import (
+	"bytes"
+	"io"
+	"math"
+	"math/rand"
+	"path/filepath"
+	"testing"
+
+	"github.com/mewkiz/flac/frame"
+	"github.com/stretchr/testify/require"
+	"github.com/xaionaro-go/audio/pkg/audio/codec"
+	"github.com/xaionaro-go/audio/pkg/audio/types"
+)
+
+func generatePCM(format codec.Format, frames int) []byte {
+	rng := rand.New(rand.NewSource(0))
+	sampleSize := int(format.PCMFormat.Size())
+	amplitude := float64(int32(1)<<(8*sampleSize-1)) * 0.5
+	b := make([]byte, frames*int(format.Channels)*sampleSize)
+	var offset int
+	for idx := 0; idx < frames; idx++ {
+		for ch := 0; ch < int(format.Channels); ch++ {
+			v := amplitude * math.Sin(2*math.Pi*float64(idx)*float64(220*(ch+1))/float64(format.SampleRate))
+			v += float64(rng.Intn(16))
+			offset += putSample(b[offset:], int32(v), uint8(sampleSize*8))
+		}
+	}
+	return b
+}
+
+func TestEncodeDecode(t *testing.T) {
+	for _, format := range []codec.Format{
+		{Channels: 1, SampleRate: 8000, PCMFormat: types.PCMFormatU8},
+		{Channels: 2, SampleRate: 44100, PCMFormat: types.PCMFormatS16LE},
+		{Channels: 6, SampleRate: 48000, PCMFormat: types.PCMFormatS24LE},
+		{Channels: 8, SampleRate: 96000, PCMFormat: types.PCMFormatS16LE},
+	} {
+		t.Run(format.PCMFormat.String(), func(t *testing.T) {
+			pcm := generatePCM(format, 10000)
+			filePath := filepath.Join(t.TempDir(), "test.flac")
+			e, err := Create(filePath, format, EncoderConfig{BlockSize: 1024, SeekPoints: 4})
+			require.NoError(t, err)
+			_, err = e.Write(pcm[:1000])
+			require.NoError(t, err)
+			_, err = e.Write(pcm[1000:])
+			require.NoError(t, err)
+			require.NoError(t, e.Close())
+
+			d, err := Open(filePath)
+			require.NoError(t, err)
+			defer d.Close()
+			require.Equal(t, format, d.Format())
+			info := d.StreamInfo()
+			require.Equal(t, uint64(10000), info.NSamples)
+			require.NotZero(t, info.MD5sum)
+
+			decoded, err := io.ReadAll(d)
+			require.NoError(t, err)
+			require.Equal(t, pcm, decoded)
+
+			frameSize := int(format.Channels) * int(format.PCMFormat.Size())
+			for _, sample := range []uint64{7777, 0, 1024, 3000} {
+				require.NoError(t, d.SeekSample(sample))
+				b := make([]byte, frameSize*100)
+				_, err = io.ReadFull(d, b)
+				require.NoError(t, err)
+				require.Equal(t, pcm[int(sample)*frameSize:][:len(b)], b, sample)
+			}
+		})
+	}
+}
+
+func TestEncodeNonSeekable(t *testing.T) {
+	format := codec.Format{Channels: 2, SampleRate: 48000, PCMFormat: types.PCMFormatS16LE}
+	pcm := generatePCM(format, 48000)
+
+	var buf bytes.Buffer
+	e, err := NewEncoder(&buf, format, DefaultEncoderConfig())
+	require.NoError(t, err)
+	_, err = e.Write(pcm)
+	require.NoError(t, err)
+	require.NoError(t, e.Close())
+	require.Less(t, buf.Len(), len(pcm)/2)
+
+	d, err := codec.NewDecoder(bytes.NewReader(buf.Bytes()))
+	require.NoError(t, err)
+	require.IsType(t, &Decoder{}, d)
+	decoded, err := io.ReadAll(d)
+	require.NoError(t, err)
+	require.Equal(t, pcm, decoded)
+}
+
+func TestChooseStereoDecorrelation(t *testing.T) {
+	samples := make([]int32, 100)
+	for idx := range samples {
+		samples[idx] = int32(idx * idx)
+	}
+	silence := make([]int32, 100)
+	require.NotEqual(t, frame.ChannelsLR, chooseStereoDecorrelation(samples, samples))
+	require.Equal(t, frame.ChannelsLR, chooseStereoDecorrelation(silence, samples))
+}
@@ -0,0 +1,72 @@
+package flac
+
+import (
+	"fmt"
+
+	"github.com/xaionaro-go/audio/pkg/audio/types"
+)
+
+// pcmFormatForBitsPerSample returns the PCM format which fits the samples
+// of the given bit depth (samples are aligned to the most significant bit).
+func pcmFormatForBitsPerSample(bitsPerSample uint8) (types.PCMFormat, error) {
+	switch {
+	case bitsPerSample == 0 || bitsPerSample > 32:
+		return types.UndefinedPCMFormat, fmt.Errorf("unsupported bits per sample: %d", bitsPerSample)
+	case bitsPerSample <= 8:
+		return types.PCMFormatU8, nil
+	case bitsPerSample <= 16:
+		return types.PCMFormatS16LE, nil
+	case bitsPerSample <= 24:
+		return types.PCMFormatS24LE, nil
+	default:
+		return types.PCMFormatS32LE, nil
+	}
+}
+
+func bitsPerSampleForPCMFormat(pcmFormat types.PCMFormat) (uint8, error) {
+	switch pcmFormat {
+	case types.PCMFormatU8:
+		return 8, nil
+	case types.PCMFormatS16LE:
+		return 16, nil
+	case types.PCMFormatS24LE:
+		return 24, nil
+	default:
+		return 0, fmt.Errorf("PCM format %v is not supported by the FLAC encoder (use U8, S16LE or S24LE)", pcmFormat)
+	}
+}
+
+// putSample writes the sample (of the given bit depth) into "b" in the format
+// returned by pcmFormatForBitsPerSample, and returns the amount of written bytes.
+func putSample(b []byte, sample int32, bitsPerSample uint8) int {
+	switch {
+	case bitsPerSample <= 8:
+		b[0] = byte(sample<<(8-bitsPerSample)) ^ 0x80
+		return 1
+	case bitsPerSample <= 16:
+		v := uint16(sample << (16 - bitsPerSample))
+		b[0], b[1] = byte(v), byte(v>>8)
+		return 2
+	case bitsPerSample <= 24:
+		v := uint32(sample << (24 - bitsPerSample))
+		b[0], b[1], b[2] = byte(v), byte(v>>8), byte(v>>16)
+		return 3
+	default:
+		v := uint32(sample << (32 - bitsPerSample))
+		b[0], b[1], b[2], b[3] = byte(v), byte(v>>8), byte(v>>16), byte(v>>24)
+		return 4
+	}
+}
+
+func getSample(b []byte, pcmFormat types.PCMFormat) int32 {
+	switch pcmFormat {
+	case types.PCMFormatU8:
+		return int32(int8(b[0] ^ 0x80))
+	case types.PCMFormatS16LE:
+		return int32(int16(uint16(b[0]) | uint16(b[1])<<8))
+	case types.PCMFormatS24LE:
+		return int32(uint32(b[0])<<8|uint32(b[1])<<16|uint32(b[2])<<24) >> 8
+	default:
+		panic(fmt.Errorf("unexpected PCM format: %v", pcmFormat))
+	}
+}
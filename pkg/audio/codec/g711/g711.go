@@ -0,0 +1,98 @@
+// Package g711 implements the ITU-T G.711 companding (μ-law and A-law),
+// which encodes 14/13-bit linear samples into 8 bits.
+package g711
+
+const (
+	muLawBias = 0x84
+	muLawClip = 32635
+)
+
+var (
+	muLawDecodeTable [256]int16
+	aLawDecodeTable  [256]int16
+)
+
+func init() {
+	for idx := range 256 {
+		muLawDecodeTable[idx] = decodeMuLaw(byte(idx))
+		aLawDecodeTable[idx] = decodeALaw(byte(idx))
+	}
+}
+
+// EncodeMuLaw converts a 16-bit linear sample into μ-law.
+func EncodeMuLaw(sample int16) byte {
+	v := int32(sample)
+	sign := byte(0)
+	if v < 0 {
+		v = -v
+		sign = 0x80
+	}
+	v = min(v, muLawClip) + muLawBias
+	exponent := byte(7)
+	for mask := int32(0x4000); v&mask == 0 && exponent > 0; mask >>= 1 {
+		exponent--
+	}
+	mantissa := byte(v>>(exponent+3)) & 0x0f
+	return ^(sign | exponent<<4 | mantissa)
+}
+
+// DecodeMuLaw converts a μ-law sample into 16-bit linear.
+func DecodeMuLaw(b byte) int16 {
+	return muLawDecodeTable[b]
+}
+
+func decodeMuLaw(b byte) int16 {
+	b = ^b
+	exponent := (b >> 4) & 0x07
+	mantissa := int32(b & 0x0f)
+	v := ((mantissa << 3) + muLawBias) << exponent
+	v -= muLawBias
+	if b&0x80 != 0 {
+		return int16(-v)
+	}
+	return int16(v)
+}
+
+// EncodeALaw converts a 16-bit linear sample into A-law.
+func EncodeALaw(sample int16) byte {
+	v := int32(sample) >> 3 // 13-bit
+	sign := byte(0x80)
+	if v < 0 {
+		v = -v - 1
+		sign = 0
+	}
+	v = min(v, 0xfff)
+	var b byte
+	if v < 32 {
+		b = byte(v >> 1)
+	} else {
+		exponent := byte(1)
+		for v >= 64 {
+			v >>= 1
+			exponent++
+		}
+		b = exponent<<4 | byte(v>>1)&0x0f
+	}
+	return (sign | b) ^ 0x55
+}
+
+// DecodeALaw converts an A-law sample into 16-bit linear.
+func DecodeALaw(b byte) int16 {
+	return aLawDecodeTable[b]
+}
+
+func decodeALaw(b byte) int16 {
+	b ^= 0x55
+	exponent := (b >> 4) & 0x07
+	mantissa := int32(b & 0x0f)
+	var v int32
+	if exponent == 0 {
+		v = mantissa<<4 + 8
+	} else {
+		v = (mantissa<<4 + 0x108) << (exponent - 1)
+	}
+	if b&0x80 == 0 {
+		return int16(-v)
+	}
+	return int16(v)
+}
@@ -0,0 +1,45 @@
+package g711
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestKnownValues(t *testing.T) {
+	require.Equal(t, byte(0xFF), EncodeMuLaw(0))
+	require.Equal(t, byte(0x80), EncodeMuLaw(32767))
+	require.Equal(t, byte(0x00), EncodeMuLaw(-32768))
+	require.Equal(t, int16(0), DecodeMuLaw(0xFF))
+	require.Equal(t, int16(32124), DecodeMuLaw(0x80))
+	require.Equal(t, int16(-32124), DecodeMuLaw(0x00))
+
+	require.Equal(t, byte(0xD5), EncodeALaw(0))
+	require.Equal(t, int16(8), DecodeALaw(0xD5))
+	require.Equal(t, int16(32256), DecodeALaw(0xAA))
+	require.Equal(t, int16(-32256), DecodeALaw(0x2A))
+}
+
+func TestRoundTrip(t *testing.T) {
+	for b := range 256 {
+		// every code is decoded into a value, which is encoded back into the same code
+		// (except for the negative zero of μ-law)
+		if b != 0x7F {
+			require.Equal(t, byte(b), EncodeMuLaw(DecodeMuLaw(byte(b))), "μ-law 0x%02X", b)
+		}
+		require.Equal(t, byte(b), EncodeALaw(DecodeALaw(byte(b))), "A-law 0x%02X", b)
+	}
+	for sample := -32768; sample <= 32767; sample += 7 {
+		// the quantization error is proportional to the magnitude
+		tolerance := float64(abs(sample))/16 + 16
+		require.InDelta(t, sample, DecodeMuLaw(EncodeMuLaw(int16(sample))), tolerance)
+		require.InDelta(t, sample, DecodeALaw(EncodeALaw(int16(sample))), tolerance)
+	}
+}
+
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
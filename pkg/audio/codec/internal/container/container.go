@@ -0,0 +1,60 @@
+// Package container contains the helpers shared by the readers and
+// writers of the uncompressed PCM containers (WAV, AIFF, Sun AU).
+package container
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/xaionaro-go/audio/pkg/audio/types"
+)
+
+// UnknownDataSize is the size of the data if it is not specified in the
+// header (e.g. a stream or a file which was not finalized).
+const UnknownDataSize = ^uint64(0)
+
+// DataReader reads the data chunk: it stops after Remaining bytes (or at
+// the end of Reader if Remaining is UnknownDataSize) and reports
+// io.ErrUnexpectedEOF if Reader ends earlier.
+type DataReader struct {
+	Reader    io.Reader
+	Remaining uint64
+}
+
+var _ io.Reader = (*DataReader)(nil)
+
+func (r *DataReader) Read(b []byte) (int, error) {
+	if r.Remaining == 0 {
+		return 0, io.EOF
+	}
+	if r.Remaining != UnknownDataSize && uint64(len(b)) > r.Remaining {
+		b = b[:r.Remaining]
+	}
+	n, err := r.Reader.Read(b)
+	if r.Remaining != UnknownDataSize {
+		r.Remaining -= uint64(n)
+		if r.Remaining > 0 && errors.Is(err, io.EOF) {
+			err = io.ErrUnexpectedEOF
+		}
+	}
+	return n, err
+}
+
+// WriteAll writes "b" and returns an error on a short write.
+func WriteAll(w io.Writer, b []byte) error {
+	n, err := w.Write(b)
+	if err != nil {
+		return err
+	}
+	if n != len(b) {
+		return fmt.Errorf("invalid write length: %d != %d", n, len(b))
+	}
+	return nil
+}
+
+// ErrUnsupportedPCMFormat returns the error about a PCM format which
+// cannot be stored in the container (named "containerName").
+func ErrUnsupportedPCMFormat(pcmFormat types.PCMFormat, containerName string) error {
+	return fmt.Errorf("PCM format %v cannot be represented in %s (convert it with package resampler first)", pcmFormat, containerName)
+}
@@ -0,0 +1,26 @@
+package container
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDataReader(t *testing.T) {
+	r := &DataReader{Reader: bytes.NewReader([]byte{1, 2, 3, 4}), Remaining: 3}
+	b, err := io.ReadAll(r)
+	require.NoError(t, err)
+	require.Equal(t, []byte{1, 2, 3}, b)
+
+	r = &DataReader{Reader: bytes.NewReader([]byte{1, 2, 3, 4}), Remaining: UnknownDataSize}
+	b, err = io.ReadAll(r)
+	require.NoError(t, err)
+	require.Equal(t, []byte{1, 2, 3, 4}, b)
+
+	r = &DataReader{Reader: bytes.NewReader([]byte{1, 2}), Remaining: 3}
+	b, err = io.ReadAll(r)
+	require.ErrorIs(t, err, io.ErrUnexpectedEOF)
+	require.Equal(t, []byte{1, 2}, b)
+}
@@ -0,0 +1,220 @@
+package matroska
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/xaionaro-go/audio/pkg/audio/codec"
+	"github.com/xaionaro-go/audio/pkg/audio/codec/opus"
+	"github.com/xaionaro-go/audio/pkg/audio/codec/vorbis"
+	"github.com/xaionaro-go/audio/pkg/audio/types"
+)
+
+const (
+	Priority = 100
+)
+
+func init() {
+	codec.RegisterDecoderFactory(Priority, DecoderFactory{})
+}
+
+type DecoderFactory struct{}
+
+var _ codec.DecoderFactory = DecoderFactory{}
+
+func (DecoderFactory) Name() string {
+	return "matroska"
+}
+
+func (DecoderFactory) Sniff(head []byte) bool {
+	return bytes.HasPrefix(head, []byte{0x1A, 0x45, 0xDF, 0xA3})
+}
+
+func (DecoderFactory) NewDecoder(r io.Reader) (codec.Decoder, error) {
+	return NewDecoder(r)
+}
+
+type packetDecoder interface {
+	Decode(packet []byte, pcm []byte) (int, error)
+	MaxChunkSize() uint
+}
+
+// Decoder decodes the first Opus or Vorbis audio track of a Matroska (or
+// WebM) stream: Opus into PCMFormatS16LE at opus.SampleRateOgg (which
+// requires the build tag 'opus'), and Vorbis into PCMFormatFloat32LE.
+type Decoder struct {
+	demuxer       *Demuxer
+	track         Track
+	format        codec.Format
+	packetDecoder packetDecoder
+	closers       []io.Closer
+
+	pcm     []byte
+	pending []byte
+
+	// skip is the amount of frames to be discarded (CodecDelay).
+	skip     uint64
+	position uint64
+}
+
+var _ codec.Decoder = (*Decoder)(nil)
+
+func NewDecoder(r io.Reader) (*Decoder, error) {
+	demuxer, err := NewDemuxer(r)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read the Matroska headers: %w", err)
+	}
+	track, ok := demuxer.AudioTrack(CodecIDOpus, CodecIDVorbis)
+	if !ok {
+		return nil, fmt.Errorf("there are no Opus or Vorbis audio tracks")
+	}
+	d := &Decoder{
+		demuxer: demuxer,
+		track:   track,
+	}
+	switch track.CodecID {
+	case CodecIDOpus:
+		err = d.initOpus()
+	case CodecIDVorbis:
+		err = d.initVorbis()
+	}
+	if err != nil {
+		return nil, err
+	}
+	d.pcm = make([]byte, d.packetDecoder.MaxChunkSize())
+	return d, nil
+}
+
+func (d *Decoder) initOpus() error {
+	var head opus.Head
+	if err := head.UnmarshalBinary(d.track.CodecPrivate); err != nil {
+		return fmt.Errorf("unable to parse the OpusHead in CodecPrivate: %w", err)
+	}
+	d.format = codec.Format{
+		Channels:   types.Channel(head.Channels),
+		SampleRate: opus.SampleRateOgg,
+		PCMFormat:  types.PCMFormatS16LE,
+	}
+	packetDecoder, err := opus.NewPacketDecoder(d.format.SampleRate, d.format.Channels, d.format.PCMFormat)
+	if err != nil {
+		return err
+	}
+	d.packetDecoder = packetDecoder
+	d.closers = append(d.closers, packetDecoder)
+	d.skip = uint64(head.PreSkip)
+	if d.track.CodecDelay != 0 {
+		d.skip = d.durationToFrames(d.track.CodecDelay)
+	}
+	return nil
+}
+
+func (d *Decoder) initVorbis() error {
+	headers, err := SplitXiphLacing(d.track.CodecPrivate)
+	if err != nil {
+		return fmt.Errorf("unable to split the Vorbis headers in CodecPrivate: %w", err)
+	}
+	packetDecoder, err := vorbis.NewPacketDecoder(headers...)
+	if err != nil {
+		return err
+	}
+	d.packetDecoder = packetDecoder
+	d.format = packetDecoder.Format()
+	return nil
+}
+
+// Open opens a Matroska/WebM file for decoding.
+func Open(filePath string) (*Decoder, error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open '%s': %w", filePath, err)
+	}
+	d, err := NewDecoder(f)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	d.closers = append(d.closers, f)
+	return d, nil
+}
+
+func (d *Decoder) Demuxer() *Demuxer {
+	return d.demuxer
+}
+
+// Track returns the track being decoded.
+func (d *Decoder) Track() Track {
+	return d.track
+}
+
+func (d *Decoder) Format() codec.Format {
+	return d.format
+}
+
+// Duration returns the duration of the stream, or zero if it is unknown.
+func (d *Decoder) Duration() time.Duration {
+	return d.demuxer.Duration()
+}
+
+// Position returns the position of the next sample to be read.
+func (d *Decoder) Position() time.Duration {
+	return time.Duration(d.position) * time.Second / time.Duration(d.format.SampleRate)
+}
+
+func (d *Decoder) durationToFrames(duration time.Duration) uint64 {
+	return uint64(duration) * uint64(d.format.SampleRate) / uint64(time.Second)
+}
+
+func (d *Decoder) Read(b []byte) (int, error) {
+	for len(d.pending) == 0 {
+		if err := d.decodePacket(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(b, d.pending)
+	d.pending = d.pending[n:]
+	d.position += uint64(n / d.frameSize())
+	return n, nil
+}
+
+func (d *Decoder) frameSize() int {
+	return int(d.format.Channels) * int(d.format.PCMFormat.Size())
+}
+
+func (d *Decoder) decodePacket() error {
+	packet, err := d.demuxer.ReadPacket()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return io.EOF
+		}
+		return fmt.Errorf("unable to read a packet: %w", err)
+	}
+	if packet.TrackNumber != d.track.Number || len(packet.Data) == 0 {
+		return nil
+	}
+	n, err := d.packetDecoder.Decode(packet.Data, d.pcm)
+	if err != nil {
+		return err
+	}
+	frameSize := d.frameSize()
+	frames := uint64(n / frameSize)
+	end := frames - min(frames, d.durationToFrames(packet.DiscardPadding))
+	start := min(d.skip, end)
+	d.skip -= start
+	d.pending = d.pcm[int(start)*frameSize : int(end)*frameSize]
+	return nil
+}
+
+// Close releases the decoder (and closes the file if the decoder was created by Open).
+func (d *Decoder) Close() error {
+	var result error
+	for _, closer := range d.closers {
+		if err := closer.Close(); err != nil && result == nil {
+			result = err
+		}
+	}
+	return result
+}
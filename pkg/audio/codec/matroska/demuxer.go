@@ -0,0 +1,368 @@
+package matroska
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+const defaultTimecodeScale = 1000000
+
+// Packet is a frame of a track.
+type Packet struct {
+	TrackNumber uint64
+
+	// Timestamp is the presentation time of the block the packet belongs
+	// to; frames laced into one block have the same timestamp.
+	Timestamp time.Duration
+	Keyframe  bool
+
+	// DiscardPadding is the duration to be discarded from the end of
+	// the decoded packet (used by Opus to trim the end of the stream).
+	DiscardPadding time.Duration
+
+	Data []byte
+}
+
+// Demuxer reads the packets from a Matroska (or WebM) stream. The stream
+// is read sequentially (seeking is not supported), and the elements of an
+// unknown size (e.g. produced by MediaRecorder in browsers) are supported.
+type Demuxer struct {
+	reader          *bufio.Reader
+	docType         string
+	timecodeScale   uint64
+	duration        float64
+	tracks          []Track
+	clusterTimecode uint64
+	pending         []Packet
+}
+
+// NewDemuxer reads the headers of the stream up to the track descriptions.
+func NewDemuxer(r io.Reader) (*Demuxer, error) {
+	d := &Demuxer{
+		reader:        bufio.NewReader(r),
+		timecodeScale: defaultTimecodeScale,
+	}
+	if err := d.readEBMLHeader(); err != nil {
+		return nil, err
+	}
+	for d.tracks == nil {
+		header, err := d.readHeader()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil, fmt.Errorf("there are no tracks")
+			}
+			return nil, err
+		}
+		if header.ID == idCluster {
+			return nil, fmt.Errorf("a cluster precedes the tracks")
+		}
+		if err := d.handleElement(header); err != nil {
+			return nil, err
+		}
+	}
+	return d, nil
+}
+
+func (d *Demuxer) readEBMLHeader() error {
+	header, err := d.readHeader()
+	if err != nil {
+		return fmt.Errorf("unable to read the EBML header: %w", err)
+	}
+	if header.ID != idEBML {
+		return fmt.Errorf("not an EBML stream: the first element is 0x%X", header.ID)
+	}
+	body, err := d.readBody(header)
+	if err != nil {
+		return fmt.Errorf("unable to read the EBML header: %w", err)
+	}
+	children, err := parseChildren(body)
+	if err != nil {
+		return fmt.Errorf("unable to parse the EBML header: %w", err)
+	}
+	for _, child := range children {
+		if child.ID == idDocType {
+			d.docType = child.String()
+		}
+	}
+	switch d.docType {
+	case "matroska", "webm":
+		return nil
+	default:
+		return fmt.Errorf("unsupported document type: '%s'", d.docType)
+	}
+}
+
+func (d *Demuxer) readHeader() (elementHeader, error) {
+	header, _, err := readElementHeader(d.reader)
+	return header, err
+}
+
+func (d *Demuxer) readBody(header elementHeader) ([]byte, error) {
+	if header.Size == unknownSize || header.Size > maxElementSize {
+		return nil, fmt.Errorf("invalid size of element 0x%X: %d", header.ID, header.Size)
+	}
+	b := make([]byte, header.Size)
+	if _, err := io.ReadFull(d.reader, b); err != nil {
+		if errors.Is(err, io.EOF) {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, fmt.Errorf("unable to read element 0x%X: %w", header.ID, err)
+	}
+	return b, nil
+}
+
+// handleElement processes a top-level element (or a child of a cluster).
+func (d *Demuxer) handleElement(header elementHeader) error {
+	switch header.ID {
+	case idSegment, idCluster:
+		// the children follow (the elements are processed as a flat
+		// sequence, so that unknown sizes do not matter)
+		return nil
+	case idInfo:
+		body, err := d.readBody(header)
+		if err != nil {
+			return err
+		}
+		return d.parseInfo(body)
+	case idTracks:
+		body, err := d.readBody(header)
+		if err != nil {
+			return err
+		}
+		return d.parseTracks(body)
+	case idTimecode:
+		body, err := d.readBody(header)
+		if err != nil {
+			return err
+		}
+		d.clusterTimecode = element{Data: body}.Uint()
+		return nil
+	case idSimpleBlock:
+		body, err := d.readBody(header)
+		if err != nil {
+			return err
+		}
+		return d.parseBlock(body, true, 0)
+	case idBlockGroup:
+		body, err := d.readBody(header)
+		if err != nil {
+			return err
+		}
+		return d.parseBlockGroup(body)
+	default:
+		if header.Size == unknownSize {
+			return fmt.Errorf("element 0x%X of an unknown size is not supported", header.ID)
+		}
+		if _, err := io.CopyN(io.Discard, d.reader, int64(header.Size)); err != nil {
+			if errors.Is(err, io.EOF) {
+				err = io.ErrUnexpectedEOF
+			}
+			return fmt.Errorf("unable to skip element 0x%X: %w", header.ID, err)
+		}
+		return nil
+	}
+}
+
+func (d *Demuxer) parseInfo(b []byte) error {
+	children, err := parseChildren(b)
+	if err != nil {
+		return fmt.Errorf("unable to parse the segment info: %w", err)
+	}
+	for _, child := range children {
+		switch child.ID {
+		case idTimecodeScale:
+			if v := child.Uint(); v != 0 {
+				d.timecodeScale = v
+			}
+		case idDuration:
+			d.duration = child.Float()
+		}
+	}
+	return nil
+}
+
+func (d *Demuxer) parseTracks(b []byte) error {
+	children, err := parseChildren(b)
+	if err != nil {
+		return fmt.Errorf("unable to parse the tracks: %w", err)
+	}
+	tracks := []Track{}
+	for _, child := range children {
+		if child.ID != idTrackEntry {
+			continue
+		}
+		track, err := parseTrackEntry(child.Data)
+		if err != nil {
+			return err
+		}
+		tracks = append(tracks, track)
+	}
+	d.tracks = tracks
+	return nil
+}
+
+func (d *Demuxer) parseBlockGroup(b []byte) error {
+	children, err := parseChildren(b)
+	if err != nil {
+		return fmt.Errorf("unable to parse a block group: %w", err)
+	}
+	var (
+		block          []byte
+		discardPadding int64
+	)
+	for _, child := range children {
+		switch child.ID {
+		case idBlock:
+			block = child.Data
+		case idDiscardPadding:
+			discardPadding = child.Int()
+		}
+	}
+	if block == nil {
+		return nil
+	}
+	return d.parseBlock(block, false, time.Duration(max(discardPadding, 0)))
+}
+
+const (
+	lacingNone  = 0
+	lacingXiph  = 1
+	lacingFixed = 2
+	lacingEBML  = 3
+)
+
+func (d *Demuxer) parseBlock(b []byte, isSimple bool, discardPadding time.Duration) error {
+	r := byteSliceReader{b: b}
+	trackNumber, n, err := readVarInt(&r, false)
+	if err != nil {
+		return fmt.Errorf("unable to parse the track number of a block: %w", err)
+	}
+	b = b[n:]
+	if len(b) < 3 {
+		return fmt.Errorf("the block is too short")
+	}
+	relativeTimecode := int16(uint16(b[0])<<8 | uint16(b[1]))
+	flags := b[2]
+	b = b[3:]
+
+	frames, err := splitLacing(b, (flags>>1)&0x03)
+	if err != nil {
+		return fmt.Errorf("unable to parse the lacing of a block: %w", err)
+	}
+	timecode := int64(d.clusterTimecode) + int64(relativeTimecode)
+	for idx, frame := range frames {
+		packet := Packet{
+			TrackNumber: trackNumber,
+			Timestamp:   time.Duration(timecode * int64(d.timecodeScale)),
+			Keyframe:    isSimple && flags&0x80 != 0,
+			Data:        frame,
+		}
+		if idx == len(frames)-1 {
+			packet.DiscardPadding = discardPadding
+		}
+		d.pending = append(d.pending, packet)
+	}
+	return nil
+}
+
+func splitLacing(b []byte, lacing byte) ([][]byte, error) {
+	if lacing == lacingNone {
+		return [][]byte{b}, nil
+	}
+	if len(b) == 0 {
+		return nil, fmt.Errorf("empty laced data")
+	}
+	switch lacing {
+	case lacingXiph:
+		return SplitXiphLacing(b)
+	case lacingFixed:
+		count := int(b[0]) + 1
+		b = b[1:]
+		if len(b)%count != 0 {
+			return nil, fmt.Errorf("the size %d is not a multiple of the amount of frames %d", len(b), count)
+		}
+		sizes := make([]int, count-1)
+		for idx := range sizes {
+			sizes[idx] = len(b) / count
+		}
+		return splitBySizes(b, sizes)
+	default:
+		count := int(b[0]) + 1
+		r := byteSliceReader{b: b[1:]}
+		sizes := make([]int, count-1)
+		var prev int64
+		for idx := range sizes {
+			v, n, err := readVarInt(&r, false)
+			if err != nil {
+				return nil, err
+			}
+			size := int64(v)
+			if idx > 0 {
+				// the differences are signed
+				size = prev + int64(v) - (1<<(7*n-1) - 1)
+			}
+			if size < 0 {
+				return nil, fmt.Errorf("negative frame size %d", size)
+			}
+			sizes[idx] = int(size)
+			prev = size
+		}
+		return splitBySizes(r.b, sizes)
+	}
+}
+
+// DocType returns "webm" or "matroska".
+func (d *Demuxer) DocType() string {
+	return d.docType
+}
+
+func (d *Demuxer) Tracks() []Track {
+	return d.tracks
+}
+
+// AudioTrack returns the first audio track with the given codec ID (or
+// with any codec ID, if none is given).
+func (d *Demuxer) AudioTrack(codecIDs ...string) (Track, bool) {
+	for _, track := range d.tracks {
+		if track.Type != TrackTypeAudio {
+			continue
+		}
+		if len(codecIDs) == 0 {
+			return track, true
+		}
+		for _, codecID := range codecIDs {
+			if track.CodecID == codecID {
+				return track, true
+			}
+		}
+	}
+	return Track{}, false
+}
+
+// Duration returns the duration of the segment, or zero if it is unknown
+// (e.g. the stream was recorded live).
+func (d *Demuxer) Duration() time.Duration {
+	return time.Duration(d.duration * float64(d.timecodeScale))
+}
+
+// ReadPacket returns the next packet of any track.
+func (d *Demuxer) ReadPacket() (Packet, error) {
+	for len(d.pending) == 0 {
+		header, err := d.readHeader()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return Packet{}, io.EOF
+			}
+			return Packet{}, fmt.Errorf("unable to read an element header: %w", err)
+		}
+		if err := d.handleElement(header); err != nil {
+			return Packet{}, err
+		}
+	}
+	packet := d.pending[0]
+	d.pending = d.pending[1:]
+	return packet, nil
+}
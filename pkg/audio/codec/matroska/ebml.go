@@ -0,0 +1,172 @@
+package matroska
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/bits"
+)
+
+// the EBML/Matroska element IDs used by the demuxer
+const (
+	idEBML              = 0x1A45DFA3
+	idDocType           = 0x4282
+	idSegment           = 0x18538067
+	idInfo              = 0x1549A966
+	idTimecodeScale     = 0x2AD7B1
+	idDuration          = 0x4489
+	idTracks            = 0x1654AE6B
+	idTrackEntry        = 0xAE
+	idTrackNumber       = 0xD7
+	idTrackType         = 0x83
+	idCodecID           = 0x86
+	idCodecPrivate      = 0x63A2
+	idCodecDelay        = 0x56AA
+	idSeekPreRoll       = 0x56BB
+	idAudio             = 0xE1
+	idSamplingFrequency = 0xB5
+	idChannels          = 0x9F
+	idBitDepth          = 0x6264
+	idCluster           = 0x1F43B675
+	idTimecode          = 0xE7
+	idSimpleBlock       = 0xA3
+	idBlockGroup        = 0xA0
+	idBlock             = 0xA1
+	idDiscardPadding    = 0x75A2
+)
+
+// unknownSize is the size of an element, which lasts until an element of
+// a higher (or the same) level (e.g. live streams).
+const unknownSize = ^uint64(0)
+
+// maxElementSize is the limit for the elements read into memory.
+const maxElementSize = 64 << 20
+
+// readVarInt reads an EBML variable-size integer; if "keepMarker" is true
+// the length marker is kept (as in element IDs).
+func readVarInt(r io.ByteReader, keepMarker bool) (uint64, int, error) {
+	first, err := r.ReadByte()
+	if err != nil {
+		return 0, 0, err
+	}
+	length := bits.LeadingZeros8(first) + 1
+	if length > 8 {
+		return 0, 1, fmt.Errorf("invalid variable-size integer with the first byte 0x00")
+	}
+	v := uint64(first)
+	if !keepMarker {
+		v &= 0xff >> length
+	}
+	allOnes := v == 0xff>>length
+	for idx := 1; idx < length; idx++ {
+		b, err := r.ReadByte()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				err = io.ErrUnexpectedEOF
+			}
+			return 0, idx, err
+		}
+		v = v<<8 | uint64(b)
+		allOnes = allOnes && b == 0xff
+	}
+	if !keepMarker && allOnes {
+		return unknownSize, length, nil
+	}
+	return v, length, nil
+}
+
+// elementHeader is the ID and the size of an element.
+type elementHeader struct {
+	ID   uint64
+	Size uint64
+}
+
+func readElementHeader(r io.ByteReader) (elementHeader, int, error) {
+	id, idLength, err := readVarInt(r, true)
+	if err != nil {
+		return elementHeader{}, idLength, err
+	}
+	size, sizeLength, err := readVarInt(r, false)
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			err = io.ErrUnexpectedEOF
+		}
+		return elementHeader{}, idLength + sizeLength, fmt.Errorf("unable to read the size of element 0x%X: %w", id, err)
+	}
+	return elementHeader{ID: id, Size: size}, idLength + sizeLength, nil
+}
+
+// element is an element read into memory.
+type element struct {
+	ID   uint64
+	Data []byte
+}
+
+// parseChildren splits the body of a master element into its children.
+func parseChildren(b []byte) ([]element, error) {
+	var result []element
+	for len(b) > 0 {
+		r := byteSliceReader{b: b}
+		header, n, err := readElementHeader(&r)
+		if err != nil {
+			return nil, err
+		}
+		b = b[n:]
+		if header.Size == unknownSize || header.Size > uint64(len(b)) {
+			return nil, fmt.Errorf("element 0x%X is truncated: %d > %d", header.ID, header.Size, len(b))
+		}
+		result = append(result, element{ID: header.ID, Data: b[:header.Size]})
+		b = b[header.Size:]
+	}
+	return result, nil
+}
+
+type byteSliceReader struct {
+	b []byte
+}
+
+func (r *byteSliceReader) ReadByte() (byte, error) {
+	if len(r.b) == 0 {
+		return 0, io.EOF
+	}
+	v := r.b[0]
+	r.b = r.b[1:]
+	return v, nil
+}
+
+func (e element) Uint() uint64 {
+	var v uint64
+	for _, b := range e.Data {
+		v = v<<8 | uint64(b)
+	}
+	return v
+}
+
+func (e element) Int() int64 {
+	if len(e.Data) == 0 || len(e.Data) > 8 {
+		return 0
+	}
+	return int64(e.Uint()<<(64-8*len(e.Data))) >> (64 - 8*len(e.Data))
+}
+
+func (e element) Float() float64 {
+	switch len(e.Data) {
+	case 4:
+		return float64(math.Float32frombits(binary.BigEndian.Uint32(e.Data)))
+	case 8:
+		return math.Float64frombits(binary.BigEndian.Uint64(e.Data))
+	default:
+		return 0
+	}
+}
+
+func (e element) String() string {
+	// strings may be padded with zeros
+	s := e.Data
+	for len(s) > 0 && s[len(s)-1] == 0 {
+		s = s[:len(s)-1]
+	}
+	return string(s)
+}
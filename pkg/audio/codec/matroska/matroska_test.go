@@ -0,0 +1,223 @@
+package matroska
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/xaionaro-go/audio/pkg/audio/codec"
+	"github.com/xaionaro-go/audio/pkg/audio/codec/vorbis"
+	"github.com/xaionaro-go/audio/pkg/audio/types"
+)
+
+func ebmlID(id uint64) []byte {
+	var b []byte
+	for ; id > 0; id >>= 8 {
+		b = append([]byte{byte(id)}, b...)
+	}
+	return b
+}
+
+func ebmlSize(size uint64) []byte {
+	if size == unknownSize {
+		return []byte{0x01, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF}
+	}
+	// always 4 bytes for simplicity
+	return binary.BigEndian.AppendUint32(nil, uint32(size)|0x10000000)
+}
+
+func el(id uint64, children ...[]byte) []byte {
+	body := bytes.Join(children, nil)
+	return append(append(ebmlID(id), ebmlSize(uint64(len(body)))...), body...)
+}
+
+func elUnknownSize(id uint64) []byte {
+	return append(ebmlID(id), ebmlSize(unknownSize)...)
+}
+
+func uintData(v uint64) []byte {
+	return binary.BigEndian.AppendUint64(nil, v)
+}
+
+func floatData(v float64) []byte {
+	return binary.BigEndian.AppendUint64(nil, math.Float64bits(v))
+}
+
+func block(track byte, relativeTimecode int16, flags byte, data ...byte) []byte {
+	b := []byte{0x80 | track}
+	b = binary.BigEndian.AppendUint16(b, uint16(relativeTimecode))
+	return append(append(b, flags), data...)
+}
+
+func xiphLacing(frames ...[]byte) []byte {
+	b := []byte{byte(len(frames) - 1)}
+	for _, frame := range frames[:len(frames)-1] {
+		size := len(frame)
+		for ; size >= 255; size -= 255 {
+			b = append(b, 255)
+		}
+		b = append(b, byte(size))
+	}
+	return append(b, bytes.Join(frames, nil)...)
+}
+
+func ebmlHeader(docType string) []byte {
+	return el(idEBML, el(idDocType, []byte(docType)))
+}
+
+func TestDemuxer(t *testing.T) {
+	long := bytes.Repeat([]byte{7}, 300)
+	stream := bytes.Join([][]byte{
+		ebmlHeader("webm"),
+		elUnknownSize(idSegment),
+		el(idInfo, el(idTimecodeScale, uintData(1000000)), el(idDuration, floatData(1500))),
+		el(0xEC, make([]byte, 10)), // Void
+		el(idTracks,
+			el(idTrackEntry, el(idTrackNumber, uintData(1)), el(idTrackType, uintData(1)), el(idCodecID, []byte("V_VP8"))),
+			el(idTrackEntry,
+				el(idTrackNumber, uintData(2)),
+				el(idTrackType, uintData(2)),
+				el(idCodecID, []byte("A_OPUS\x00")),
+				el(idCodecPrivate, []byte("OpusHead")),
+				el(idCodecDelay, uintData(6500000)),
+				el(idAudio, el(idSamplingFrequency, floatData(48000)), el(idChannels, uintData(2))),
+			),
+		),
+		elUnknownSize(idCluster),
+		el(idTimecode, uintData(1000)),
+		el(idSimpleBlock, block(1, 0, 0x80, 1, 2, 3)),
+		el(idSimpleBlock, block(2, 20, 0x80, 4, 5)),
+		el(idSimpleBlock, block(2, -10, 0x80|lacingXiph<<1, xiphLacing([]byte{1}, long, []byte{2, 3})...)),
+		el(idSimpleBlock, block(2, 40, 0x80|lacingFixed<<1, 1, 1, 2, 3, 4)),
+		// EBML lacing: 3 frames of sizes 2, 1 (a difference of -1) and the rest (3)
+		el(idSimpleBlock, block(2, 60, 0x80|lacingEBML<<1, 2, 0x82, 0xBE, 1, 1, 2, 3, 3, 3)),
+		elUnknownSize(idCluster),
+		el(idTimecode, uintData(2000)),
+		el(idBlockGroup, el(idBlock, block(2, 0, 0, 9)), el(idDiscardPadding, uintData(2500000))),
+	}, nil)
+
+	d, err := NewDemuxer(bytes.NewReader(stream))
+	require.NoError(t, err)
+	require.Equal(t, "webm", d.DocType())
+	require.Equal(t, 1500*time.Millisecond, d.Duration())
+	require.Len(t, d.Tracks(), 2)
+	track, ok := d.AudioTrack()
+	require.True(t, ok)
+	require.Equal(t, Track{
+		Number:       2,
+		Type:         TrackTypeAudio,
+		CodecID:      CodecIDOpus,
+		CodecPrivate: []byte("OpusHead"),
+		CodecDelay:   6500 * time.Microsecond,
+		SampleRate:   48000,
+		Channels:     2,
+	}, track)
+	_, ok = d.AudioTrack(CodecIDVorbis)
+	require.False(t, ok)
+
+	var packets []Packet
+	for {
+		packet, err := d.ReadPacket()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		require.NoError(t, err)
+		packets = append(packets, packet)
+	}
+	ms := time.Millisecond
+	require.Equal(t, []Packet{
+		{TrackNumber: 1, Timestamp: 1000 * ms, Keyframe: true, Data: []byte{1, 2, 3}},
+		{TrackNumber: 2, Timestamp: 1020 * ms, Keyframe: true, Data: []byte{4, 5}},
+		{TrackNumber: 2, Timestamp: 990 * ms, Keyframe: true, Data: []byte{1}},
+		{TrackNumber: 2, Timestamp: 990 * ms, Keyframe: true, Data: long},
+		{TrackNumber: 2, Timestamp: 990 * ms, Keyframe: true, Data: []byte{2, 3}},
+		{TrackNumber: 2, Timestamp: 1040 * ms, Keyframe: true, Data: []byte{1, 2}},
+		{TrackNumber: 2, Timestamp: 1040 * ms, Keyframe: true, Data: []byte{3, 4}},
+		{TrackNumber: 2, Timestamp: 1060 * ms, Keyframe: true, Data: []byte{1, 1}},
+		{TrackNumber: 2, Timestamp: 1060 * ms, Keyframe: true, Data: []byte{2}},
+		{TrackNumber: 2, Timestamp: 1060 * ms, Keyframe: true, Data: []byte{3, 3, 3}},
+		{TrackNumber: 2, Timestamp: 2000 * ms, DiscardPadding: 2500 * time.Microsecond, Data: []byte{9}},
+	}, packets)
+}
+
+func TestDemuxerInvalid(t *testing.T) {
+	_, err := NewDemuxer(bytes.NewReader(ebmlHeader("mkv")))
+	require.Error(t, err)
+	_, err = NewDemuxer(bytes.NewReader(append(ebmlHeader("matroska"), elUnknownSize(idSegment)...)))
+	require.Error(t, err)
+	_, err = NewDemuxer(bytes.NewReader(append(ebmlHeader("matroska"), elUnknownSize(idCluster)...)))
+	require.Error(t, err)
+}
+
+// readOggPackets extracts the packets of the first logical stream of an Ogg file.
+func readOggPackets(t *testing.T, b []byte) [][]byte {
+	var (
+		packets [][]byte
+		packet  []byte
+	)
+	for len(b) > 0 {
+		require.Equal(t, "OggS", string(b[:4]))
+		segments := b[27 : 27+int(b[26])]
+		data := b[27+len(segments):]
+		for _, size := range segments {
+			packet = append(packet, data[:size]...)
+			data = data[size:]
+			if size < 255 {
+				packets = append(packets, packet)
+				packet = nil
+			}
+		}
+		b = data
+	}
+	return packets
+}
+
+func TestVorbis(t *testing.T) {
+	oggData, err := os.ReadFile("../../../../cmd/beep/resources/long_audio.ogg")
+	require.NoError(t, err)
+	oggDecoder, err := vorbis.NewDecoder(bytes.NewReader(oggData))
+	require.NoError(t, err)
+	expected := make([]byte, 48000*4*int(oggDecoder.Format().Channels))
+	_, err = io.ReadFull(oggDecoder, expected)
+	require.NoError(t, err)
+
+	packets := readOggPackets(t, oggData)
+	var blocks [][]byte
+	for idx, packet := range packets[3:] {
+		blocks = append(blocks, el(idSimpleBlock, block(1, int16(idx), 0x80, packet...)))
+		if len(blocks) > 1000 {
+			break
+		}
+	}
+	stream := bytes.Join([][]byte{
+		ebmlHeader("webm"),
+		elUnknownSize(idSegment),
+		el(idTracks, el(idTrackEntry,
+			el(idTrackNumber, uintData(1)),
+			el(idTrackType, uintData(2)),
+			el(idCodecID, []byte(CodecIDVorbis)),
+			el(idCodecPrivate, xiphLacing(packets[:3]...)),
+		)),
+		elUnknownSize(idCluster),
+		el(idTimecode, uintData(0)),
+		bytes.Join(blocks, nil),
+	}, nil)
+
+	decoder, err := codec.NewDecoder(bytes.NewReader(stream))
+	require.NoError(t, err)
+	d := decoder.(*Decoder)
+	require.Equal(t, oggDecoder.Format(), d.Format())
+	require.Equal(t, types.PCMFormatFloat32LE, d.Format().PCMFormat)
+	decoded := make([]byte, len(expected))
+	_, err = io.ReadFull(d, decoded)
+	require.NoError(t, err)
+	require.Equal(t, expected, decoded)
+	require.Equal(t, time.Second*48000/time.Duration(d.Format().SampleRate), d.Position())
+	require.NoError(t, d.Close())
+}
@@ -0,0 +1,154 @@
+package matroska
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/xaionaro-go/audio/pkg/audio/types"
+)
+
+type TrackType uint64
+
+const (
+	TrackTypeVideo    = TrackType(1)
+	TrackTypeAudio    = TrackType(2)
+	TrackTypeComplex  = TrackType(3)
+	TrackTypeLogo     = TrackType(0x10)
+	TrackTypeSubtitle = TrackType(0x11)
+	TrackTypeButtons  = TrackType(0x12)
+	TrackTypeControl  = TrackType(0x20)
+	TrackTypeMetadata = TrackType(0x21)
+)
+
+func (t TrackType) String() string {
+	switch t {
+	case TrackTypeVideo:
+		return "video"
+	case TrackTypeAudio:
+		return "audio"
+	case TrackTypeComplex:
+		return "complex"
+	case TrackTypeLogo:
+		return "logo"
+	case TrackTypeSubtitle:
+		return "subtitle"
+	case TrackTypeButtons:
+		return "buttons"
+	case TrackTypeControl:
+		return "control"
+	case TrackTypeMetadata:
+		return "metadata"
+	default:
+		return fmt.Sprintf("<unexpected_value_%d>", uint64(t))
+	}
+}
+
+// the codec IDs of the supported audio codecs
+const (
+	CodecIDOpus   = "A_OPUS"
+	CodecIDVorbis = "A_VORBIS"
+)
+
+type Track struct {
+	Number  uint64
+	Type    TrackType
+	CodecID string
+
+	// CodecPrivate is the codec-specific initialization data: OpusHead for
+	// Opus, the Xiph-laced headers for Vorbis (see SplitXiphLacing).
+	CodecPrivate []byte
+
+	// CodecDelay is the duration to be discarded from the beginning of
+	// the decoded audio (e.g. the Opus pre-skip).
+	CodecDelay  time.Duration
+	SeekPreRoll time.Duration
+
+	// SampleRate, Channels and BitDepth are set for audio tracks.
+	SampleRate float64
+	Channels   types.Channel
+	BitDepth   uint
+}
+
+func parseTrackEntry(b []byte) (Track, error) {
+	children, err := parseChildren(b)
+	if err != nil {
+		return Track{}, fmt.Errorf("unable to parse a track entry: %w", err)
+	}
+	track := Track{
+		SampleRate: 8000,
+		Channels:   1,
+	}
+	for _, child := range children {
+		switch child.ID {
+		case idTrackNumber:
+			track.Number = child.Uint()
+		case idTrackType:
+			track.Type = TrackType(child.Uint())
+		case idCodecID:
+			track.CodecID = child.String()
+		case idCodecPrivate:
+			track.CodecPrivate = child.Data
+		case idCodecDelay:
+			track.CodecDelay = time.Duration(child.Uint())
+		case idSeekPreRoll:
+			track.SeekPreRoll = time.Duration(child.Uint())
+		case idAudio:
+			audioChildren, err := parseChildren(child.Data)
+			if err != nil {
+				return Track{}, fmt.Errorf("unable to parse the audio settings of a track: %w", err)
+			}
+			for _, audioChild := range audioChildren {
+				switch audioChild.ID {
+				case idSamplingFrequency:
+					track.SampleRate = audioChild.Float()
+				case idChannels:
+					track.Channels = types.Channel(audioChild.Uint())
+				case idBitDepth:
+					track.BitDepth = uint(audioChild.Uint())
+				}
+			}
+		}
+	}
+	if track.Number == 0 {
+		return Track{}, fmt.Errorf("a track without a number")
+	}
+	return track, nil
+}
+
+// SplitXiphLacing splits the data laced in the Xiph style (as the Vorbis
+// headers in CodecPrivate) into the frames.
+func SplitXiphLacing(b []byte) ([][]byte, error) {
+	if len(b) == 0 {
+		return nil, fmt.Errorf("empty laced data")
+	}
+	count := int(b[0]) + 1
+	b = b[1:]
+	sizes := make([]int, count-1)
+	for idx := range sizes {
+		for {
+			if len(b) == 0 {
+				return nil, fmt.Errorf("the lacing is truncated")
+			}
+			v := b[0]
+			b = b[1:]
+			sizes[idx] += int(v)
+			if v != 255 {
+				break
+			}
+		}
+	}
+	return splitBySizes(b, sizes)
+}
+
+// splitBySizes splits "b" into frames of the given sizes, the rest is the last frame.
+func splitBySizes(b []byte, sizes []int) ([][]byte, error) {
+	frames := make([][]byte, 0, len(sizes)+1)
+	for _, size := range sizes {
+		if size > len(b) {
+			return nil, fmt.Errorf("a laced frame is truncated: %d > %d", size, len(b))
+		}
+		frames = append(frames, b[:size])
+		b = b[size:]
+	}
+	return append(frames, b), nil
+}
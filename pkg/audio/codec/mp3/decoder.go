@@ -0,0 +1,318 @@
+package mp3
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	gomp3 "github.com/hajimehoshi/go-mp3"
+	"github.com/xaionaro-go/audio/pkg/audio/codec"
+	"github.com/xaionaro-go/audio/pkg/audio/types"
+)
+
+const (
+	// Priority is lower than of the other decoders, because an MP3 stream
+	// without an ID3v2 tag is recognized only by a frame sync, which is
+	// less reliable than the magic numbers of the other formats.
+	Priority = 50
+
+	// firstFrameSearchLength is how many bytes after the ID3v2 tags are
+	// searched for the first frame (to skip garbage and padding).
+	firstFrameSearchLength = 8192
+)
+
+func init() {
+	codec.RegisterDecoderFactory(Priority, DecoderFactory{})
+}
+
+type DecoderFactory struct{}
+
+var _ codec.DecoderFactory = DecoderFactory{}
+
+func (DecoderFactory) Name() string {
+	return "mp3"
+}
+
+// Sniff checks for an ID3v2 tag or for a complete Layer III frame
+// followed by another one (or by the end of the data).
+func (DecoderFactory) Sniff(head []byte) bool {
+	if bytes.HasPrefix(head, []byte("ID3")) {
+		return true
+	}
+	idx, ok := findFirstFrame(head)
+	if !ok || idx != 0 {
+		return false
+	}
+	h, err := parseFrameHeader(head)
+	return err == nil && h.Size() <= len(head)
+}
+
+func (DecoderFactory) NewDecoder(r io.Reader) (codec.Decoder, error) {
+	return NewDecoder(r)
+}
+
+// findFirstFrame returns the offset of the first frame header in "b",
+// which is followed by another frame header (if "b" is long enough to
+// contain it).
+func findFirstFrame(b []byte) (int, bool) {
+	for idx := 0; idx+frameHeaderSize <= len(b); idx++ {
+		if b[idx] != 0xff {
+			continue
+		}
+		h, err := parseFrameHeader(b[idx:])
+		if err != nil {
+			continue
+		}
+		next := idx + h.Size()
+		if next+frameHeaderSize <= len(b) {
+			if _, err := parseFrameHeader(b[next:]); err != nil {
+				continue
+			}
+		}
+		return idx, true
+	}
+	return 0, false
+}
+
+// Decoder decodes MPEG-1/2/2.5 Layer III into PCMFormatS16LE.
+type Decoder struct {
+	mp3      *gomp3.Decoder
+	header   frameHeader
+	vbr      *VBRHeader
+	seekable bool
+	closer   io.Closer
+
+	stereo    []byte
+	stereoLen int
+	pending   []byte
+	readBytes uint64
+}
+
+var _ codec.Decoder = (*Decoder)(nil)
+
+// NewDecoder skips the ID3v2 tags and parses the first frame (including
+// the Xing/Info/VBRI header if it has one). Seeking is supported only if
+// "r" is an io.ReadSeeker.
+func NewDecoder(r io.Reader) (*Decoder, error) {
+	// the stream does not have to start at the beginning of "r" (e.g. if
+	// it is embedded into another file), so the offsets are relative to
+	// the current position
+	var offset int64
+	rs, seekable := r.(io.ReadSeeker)
+	if seekable {
+		start, err := rs.Seek(0, io.SeekCurrent)
+		if err != nil {
+			seekable = false
+		}
+		offset = start
+	}
+
+	window := make([]byte, firstFrameSearchLength)
+	for {
+		n, err := io.ReadFull(r, window[:id3v2HeaderSize])
+		if err != nil && n == 0 {
+			return nil, fmt.Errorf("unable to read the beginning of the stream: %w", err)
+		}
+		tagSize := id3v2TagSize(window[:n])
+		if tagSize == 0 {
+			m, err := io.ReadFull(r, window[n:])
+			if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF) {
+				return nil, fmt.Errorf("unable to read the first frame: %w", err)
+			}
+			window = window[:n+m]
+			break
+		}
+		if err := skip(r, tagSize-id3v2HeaderSize, seekable); err != nil {
+			return nil, fmt.Errorf("unable to skip the ID3v2 tag of size %d: %w", tagSize, err)
+		}
+		offset += tagSize
+	}
+
+	frameIdx, ok := findFirstFrame(window)
+	if !ok {
+		return nil, fmt.Errorf("unable to find an MPEG Layer III frame in the first %d bytes after the tags", len(window))
+	}
+	header, err := parseFrameHeader(window[frameIdx:])
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse the header of the first frame: %w", err)
+	}
+	vbr := parseVBRHeader(header, window[frameIdx:min(frameIdx+header.Size(), len(window))])
+	audioIdx := frameIdx
+	if vbr != nil {
+		// the VBR header frame is not an audio frame
+		audioIdx += header.Size()
+	}
+
+	var src io.Reader
+	if seekable {
+		audioStart := offset + int64(audioIdx)
+		if _, err := rs.Seek(audioStart, io.SeekStart); err != nil {
+			return nil, fmt.Errorf("unable to seek to the first audio frame at %d: %w", audioStart, err)
+		}
+		src = &offsetReadSeeker{ReadSeeker: rs, offset: audioStart}
+	} else {
+		src = io.MultiReader(bytes.NewReader(window[min(audioIdx, len(window)):]), r)
+	}
+
+	mp3Decoder, err := gomp3.NewDecoder(src)
+	if err != nil {
+		return nil, fmt.Errorf("unable to initialize the MP3 decoder: %w", err)
+	}
+	return &Decoder{
+		mp3:      mp3Decoder,
+		header:   header,
+		vbr:      vbr,
+		seekable: seekable,
+	}, nil
+}
+
+func skip(r io.Reader, n int64, seekable bool) error {
+	if seekable {
+		_, err := r.(io.Seeker).Seek(n, io.SeekCurrent)
+		return err
+	}
+	_, err := io.CopyN(io.Discard, r, n)
+	return err
+}
+
+// Open opens an MP3 file for decoding (with seeking enabled).
+func Open(filePath string) (*Decoder, error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open '%s': %w", filePath, err)
+	}
+	d, err := NewDecoder(f)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	d.closer = f
+	return d, nil
+}
+
+// VBRHeader returns the Xing/Info or VBRI header, or nil if the stream
+// has none.
+func (d *Decoder) VBRHeader() *VBRHeader {
+	return d.vbr
+}
+
+func (d *Decoder) Format() codec.Format {
+	return codec.Format{
+		Channels:   d.channels(),
+		SampleRate: types.SampleRate(d.header.SampleRate),
+		PCMFormat:  types.PCMFormatS16LE,
+	}
+}
+
+func (d *Decoder) channels() types.Channel {
+	if d.header.Mono {
+		return 1
+	}
+	return 2
+}
+
+func (d *Decoder) frameSize() uint64 {
+	return uint64(d.channels()) * 2
+}
+
+// Samples returns the amount of samples (per channel) in the stream, or
+// zero if it is unknown. It is taken from the Xing/VBRI header if the
+// stream has it, and is calculated by scanning the frames otherwise (only
+// if the stream is seekable).
+func (d *Decoder) Samples() uint64 {
+	if d.vbr != nil && d.vbr.Frames > 0 {
+		return uint64(d.vbr.Frames) * uint64(d.header.SamplesPerFrame())
+	}
+	if length := d.mp3.Length(); length > 0 {
+		// go-mp3 always produces stereo S16
+		return uint64(length) / 4
+	}
+	return 0
+}
+
+// Duration returns the duration of the stream, or zero if it is unknown.
+func (d *Decoder) Duration() time.Duration {
+	return d.samplesToDuration(d.Samples())
+}
+
+// Position returns the position of the next sample to be read.
+func (d *Decoder) Position() time.Duration {
+	return d.samplesToDuration(d.readBytes / d.frameSize())
+}
+
+func (d *Decoder) samplesToDuration(samples uint64) time.Duration {
+	return time.Duration(samples) * time.Second / time.Duration(d.header.SampleRate)
+}
+
+// SeekSample moves the position to the given sample (per channel).
+func (d *Decoder) SeekSample(sample uint64) error {
+	if !d.seekable {
+		return fmt.Errorf("the stream is not seekable")
+	}
+	total := uint64(d.mp3.Length()) / 4
+	if sample >= total {
+		return fmt.Errorf("sample %d is out of range [0, %d)", sample, total)
+	}
+	if _, err := d.mp3.Seek(int64(sample)*4, io.SeekStart); err != nil {
+		return fmt.Errorf("unable to seek to sample %d: %w", sample, err)
+	}
+	d.stereoLen = 0
+	d.pending = d.pending[:0]
+	d.readBytes = sample * d.frameSize()
+	return nil
+}
+
+func (d *Decoder) Read(b []byte) (int, error) {
+	if !d.header.Mono {
+		n, err := d.mp3.Read(b)
+		d.readBytes += uint64(n)
+		return n, err
+	}
+
+	// go-mp3 duplicates the mono channel, so we take every other sample
+	for len(d.pending) == 0 {
+		if d.stereo == nil {
+			d.stereo = make([]byte, 4096)
+		}
+		n, err := d.mp3.Read(d.stereo[d.stereoLen:])
+		d.stereoLen += n
+		complete := d.stereoLen / 4 * 4
+		for idx := 0; idx < complete; idx += 4 {
+			d.pending = append(d.pending, d.stereo[idx], d.stereo[idx+1])
+		}
+		d.stereoLen = copy(d.stereo, d.stereo[complete:d.stereoLen])
+		if err != nil && len(d.pending) == 0 {
+			return 0, err
+		}
+	}
+	n := copy(b, d.pending)
+	d.pending = d.pending[:copy(d.pending, d.pending[n:])]
+	d.readBytes += uint64(n)
+	return n, nil
+}
+
+// Close closes the file if the decoder was created by Open.
+func (d *Decoder) Close() error {
+	if d.closer == nil {
+		return nil
+	}
+	return d.closer.Close()
+}
+
+// offsetReadSeeker makes the position "offset" of the underlying
+// ReadSeeker to look like its beginning.
+type offsetReadSeeker struct {
+	io.ReadSeeker
+	offset int64
+}
+
+func (s *offsetReadSeeker) Seek(offset int64, whence int) (int64, error) {
+	if whence == io.SeekStart {
+		offset += s.offset
+	}
+	pos, err := s.ReadSeeker.Seek(offset, whence)
+	return pos - s.offset, err
+}
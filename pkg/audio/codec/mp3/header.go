@@ -0,0 +1,170 @@
+package mp3
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+const (
+	id3v2HeaderSize = 10
+	frameHeaderSize = 4
+)
+
+// id3v2TagSize returns the full size of the ID3v2 tag starting at "head"
+// (including the header and the footer), or zero if there is no tag.
+func id3v2TagSize(head []byte) int64 {
+	if len(head) < id3v2HeaderSize || !bytes.HasPrefix(head, []byte("ID3")) {
+		return 0
+	}
+	size := int64(head[6]&0x7f)<<21 | int64(head[7]&0x7f)<<14 | int64(head[8]&0x7f)<<7 | int64(head[9]&0x7f)
+	size += id3v2HeaderSize
+	if head[5]&0x10 != 0 {
+		// footer present
+		size += id3v2HeaderSize
+	}
+	return size
+}
+
+type mpegVersion uint8
+
+const (
+	mpegVersion2_5 = mpegVersion(0)
+	mpegVersion2   = mpegVersion(2)
+	mpegVersion1   = mpegVersion(3)
+)
+
+var bitRatesKbps = map[bool][16]int{
+	// MPEG-1 Layer III
+	true: {0, 32, 40, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320, 0},
+	// MPEG-2/2.5 Layer III
+	false: {0, 8, 16, 24, 32, 40, 48, 56, 64, 80, 96, 112, 128, 144, 160, 0},
+}
+
+var sampleRates = map[mpegVersion][3]int{
+	mpegVersion1:   {44100, 48000, 32000},
+	mpegVersion2:   {22050, 24000, 16000},
+	mpegVersion2_5: {11025, 12000, 8000},
+}
+
+// frameHeader is a parsed header of an MPEG audio Layer III frame.
+type frameHeader struct {
+	Version    mpegVersion
+	BitRate    int
+	SampleRate int
+	Padding    bool
+	Mono       bool
+}
+
+func parseFrameHeader(b []byte) (frameHeader, error) {
+	if len(b) < frameHeaderSize {
+		return frameHeader{}, io.ErrUnexpectedEOF
+	}
+	if b[0] != 0xff || b[1]&0xe0 != 0xe0 {
+		return frameHeader{}, fmt.Errorf("no frame sync: %X", b[:frameHeaderSize])
+	}
+	version := mpegVersion((b[1] >> 3) & 0x3)
+	if version == 1 {
+		return frameHeader{}, fmt.Errorf("reserved MPEG version")
+	}
+	if layer := (b[1] >> 1) & 0x3; layer != 1 {
+		return frameHeader{}, fmt.Errorf("only Layer III is supported, got layer code %d", layer)
+	}
+	bitRateIdx := b[2] >> 4
+	sampleRateIdx := (b[2] >> 2) & 0x3
+	if bitRateIdx == 0 || bitRateIdx == 15 {
+		return frameHeader{}, fmt.Errorf("unsupported bitrate index %d", bitRateIdx)
+	}
+	if sampleRateIdx == 3 {
+		return frameHeader{}, fmt.Errorf("reserved sample rate index")
+	}
+	return frameHeader{
+		Version:    version,
+		BitRate:    bitRatesKbps[version == mpegVersion1][bitRateIdx] * 1000,
+		SampleRate: sampleRates[version][sampleRateIdx],
+		Padding:    b[2]&0x2 != 0,
+		Mono:       b[3]>>6 == 3,
+	}, nil
+}
+
+// SamplesPerFrame returns the amount of samples (per channel) in the frame.
+func (h frameHeader) SamplesPerFrame() int {
+	if h.Version == mpegVersion1 {
+		return 1152
+	}
+	return 576
+}
+
+// Size returns the size of the frame including the header.
+func (h frameHeader) Size() int {
+	size := h.SamplesPerFrame() / 8 * h.BitRate / h.SampleRate
+	if h.Padding {
+		size++
+	}
+	return size
+}
+
+// sideInfoSize returns the size of the side information following the header.
+func (h frameHeader) sideInfoSize() int {
+	switch {
+	case h.Version == mpegVersion1 && h.Mono:
+		return 17
+	case h.Version == mpegVersion1:
+		return 32
+	case h.Mono:
+		return 9
+	default:
+		return 17
+	}
+}
+
+// VBRHeader is the content of a Xing/Info or VBRI header, which is stored
+// by encoders in the first frame (that contains no audio).
+type VBRHeader struct {
+	// Tag is "Xing", "Info" (a CBR stream) or "VBRI".
+	Tag string
+
+	// Frames is the amount of audio frames, or zero if unknown.
+	Frames uint32
+
+	// Bytes is the size of the audio frames, or zero if unknown.
+	Bytes uint32
+}
+
+const (
+	xingFlagFrames = 1 << iota
+	xingFlagBytes
+)
+
+// parseVBRHeader parses the Xing/Info or VBRI header of the frame "frame"
+// (starting with its header), and returns nil if there is none.
+func parseVBRHeader(h frameHeader, frame []byte) *VBRHeader {
+	if offset := frameHeaderSize + h.sideInfoSize(); len(frame) >= offset+8 {
+		tag := string(frame[offset : offset+4])
+		if tag == "Xing" || tag == "Info" {
+			vbr := &VBRHeader{Tag: tag}
+			flags := binary.BigEndian.Uint32(frame[offset+4:])
+			offset += 8
+			if flags&xingFlagFrames != 0 && len(frame) >= offset+4 {
+				vbr.Frames = binary.BigEndian.Uint32(frame[offset:])
+				offset += 4
+			}
+			if flags&xingFlagBytes != 0 && len(frame) >= offset+4 {
+				vbr.Bytes = binary.BigEndian.Uint32(frame[offset:])
+			}
+			return vbr
+		}
+	}
+
+	// VBRI is always located right after 32 bytes of the side information
+	const vbriOffset = frameHeaderSize + 32
+	if len(frame) >= vbriOffset+18 && string(frame[vbriOffset:vbriOffset+4]) == "VBRI" {
+		return &VBRHeader{
+			Tag:    "VBRI",
+			Bytes:  binary.BigEndian.Uint32(frame[vbriOffset+10:]),
+			Frames: binary.BigEndian.Uint32(frame[vbriOffset+14:]),
+		}
+	}
+	return nil
+}
@@ -0,0 +1,154 @@
+package mp3
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/xaionaro-go/audio/pkg/audio/codec"
+	"github.com/xaionaro-go/audio/pkg/audio/types"
+)
+
+// buildStream builds an MPEG-1 Layer III 128kbps 44100Hz stream of silent
+// frames (all the side information is zero).
+func buildStream(mono bool, frames int, id3 bool, xing bool) []byte {
+	header := []byte{0xff, 0xfb, 0x90, 0x00}
+	if mono {
+		header[3] = 0xc0
+	}
+	h, err := parseFrameHeader(header)
+	if err != nil {
+		panic(err)
+	}
+
+	var buf bytes.Buffer
+	if id3 {
+		// ID3v2.4 tag with 100 bytes of content and a footer
+		buf.Write([]byte{'I', 'D', '3', 4, 0, 0x10, 0, 0, 0, 100})
+		buf.Write(make([]byte, 100))
+		buf.Write([]byte{'3', 'D', 'I', 4, 0, 0x10, 0, 0, 0, 100})
+	}
+	if xing {
+		frame := make([]byte, h.Size())
+		copy(frame, header)
+		offset := frameHeaderSize + h.sideInfoSize()
+		copy(frame[offset:], "Xing")
+		binary.BigEndian.PutUint32(frame[offset+4:], xingFlagFrames)
+		binary.BigEndian.PutUint32(frame[offset+8:], uint32(frames))
+		buf.Write(frame)
+	}
+	for range frames {
+		frame := make([]byte, h.Size())
+		copy(frame, header)
+		buf.Write(frame)
+	}
+	return buf.Bytes()
+}
+
+func TestFrameHeader(t *testing.T) {
+	h, err := parseFrameHeader([]byte{0xff, 0xfb, 0x90, 0xc0})
+	require.NoError(t, err)
+	require.Equal(t, frameHeader{
+		Version:    mpegVersion1,
+		BitRate:    128000,
+		SampleRate: 44100,
+		Mono:       true,
+	}, h)
+	require.Equal(t, 417, h.Size())
+	require.Equal(t, 1152, h.SamplesPerFrame())
+
+	h, err = parseFrameHeader([]byte{0xff, 0xf3, 0x82, 0x00})
+	require.NoError(t, err)
+	require.Equal(t, mpegVersion2, h.Version)
+	require.Equal(t, 64000, h.BitRate)
+	require.Equal(t, 22050, h.SampleRate)
+	require.True(t, h.Padding)
+	require.Equal(t, 576, h.SamplesPerFrame())
+
+	_, err = parseFrameHeader([]byte{0xff, 0xfd, 0x90, 0x00}) // Layer II
+	require.Error(t, err)
+}
+
+func TestVBRI(t *testing.T) {
+	h, err := parseFrameHeader([]byte{0xff, 0xfb, 0x90, 0x00})
+	require.NoError(t, err)
+	frame := make([]byte, h.Size())
+	copy(frame[36:], "VBRI")
+	binary.BigEndian.PutUint32(frame[46:], 12345)
+	binary.BigEndian.PutUint32(frame[50:], 42)
+	require.Equal(t, &VBRHeader{Tag: "VBRI", Bytes: 12345, Frames: 42}, parseVBRHeader(h, frame))
+}
+
+func TestDecode(t *testing.T) {
+	for _, mono := range []bool{false, true} {
+		for _, xing := range []bool{false, true} {
+			stream := buildStream(mono, 20, true, xing)
+			channels := types.Channel(2)
+			if mono {
+				channels = 1
+			}
+			frameSize := int(channels) * 2
+
+			// seekable
+			d, err := NewDecoder(bytes.NewReader(stream))
+			require.NoError(t, err)
+			require.Equal(t, codec.Format{
+				Channels:   channels,
+				SampleRate: 44100,
+				PCMFormat:  types.PCMFormatS16LE,
+			}, d.Format())
+			require.Equal(t, xing, d.VBRHeader() != nil)
+			require.Equal(t, uint64(20*1152), d.Samples())
+			require.Equal(t, time.Duration(20*1152)*time.Second/44100, d.Duration())
+
+			pcm, err := io.ReadAll(d)
+			require.NoError(t, err)
+			require.Len(t, pcm, 20*1152*frameSize)
+			require.Equal(t, make([]byte, len(pcm)), pcm)
+			require.Equal(t, d.Duration(), d.Position())
+
+			require.NoError(t, d.SeekSample(1152*10+7))
+			require.Equal(t, time.Duration(1152*10+7)*time.Second/44100, d.Position())
+			pcm, err = io.ReadAll(d)
+			require.NoError(t, err)
+			require.Len(t, pcm, (1152*10-7)*frameSize)
+			require.Error(t, d.SeekSample(20*1152))
+
+			// embedded into another file
+			embedded := bytes.NewReader(append(bytes.Repeat([]byte{0xff}, 1000), stream...))
+			_, err = embedded.Seek(1000, io.SeekStart)
+			require.NoError(t, err)
+			d, err = NewDecoder(embedded)
+			require.NoError(t, err)
+			require.NoError(t, d.SeekSample(1152*10+7))
+			pcm, err = io.ReadAll(d)
+			require.NoError(t, err)
+			require.Len(t, pcm, (1152*10-7)*frameSize)
+
+			// not seekable, through the format detection
+			dec, err := codec.NewDecoder(bytes.NewBuffer(stream))
+			require.NoError(t, err)
+			d = dec.(*Decoder)
+			require.Equal(t, channels, d.Format().Channels)
+			require.Error(t, d.SeekSample(0))
+			if xing {
+				require.Equal(t, uint64(20*1152), d.Samples())
+			} else {
+				require.Zero(t, d.Samples())
+			}
+			pcm, err = io.ReadAll(d)
+			require.NoError(t, err)
+			require.Len(t, pcm, 20*1152*frameSize)
+		}
+	}
+}
+
+func TestSniff(t *testing.T) {
+	require.True(t, DecoderFactory{}.Sniff(buildStream(false, 3, false, false)))
+	require.True(t, DecoderFactory{}.Sniff(buildStream(false, 3, true, false)))
+	require.False(t, DecoderFactory{}.Sniff([]byte("OggS\x00\x02")))
+	require.False(t, DecoderFactory{}.Sniff([]byte{0xff, 0xfb, 0x90, 0x00, 0x00, 0x00}))
+}
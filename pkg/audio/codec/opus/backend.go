@@ -0,0 +1,28 @@
+package opus
+
+import (
+	"github.com/xaionaro-go/audio/pkg/audio/types"
+)
+
+// encoderBackend is an Opus encoder implementation, the PCM is in the
+// format of the EncoderConfig.
+type encoderBackend interface {
+	encode(pcm []byte, packet []byte) (int, error)
+	close() error
+}
+
+// decoderBackend is an Opus decoder implementation. "pcm" in all the
+// methods is in the format the backend was created for, and the returned
+// values are amounts of samples per channel.
+type decoderBackend interface {
+	decode(packet []byte, pcm []byte) (int, error)
+	decodeFEC(packet []byte, pcm []byte) error
+	decodePLC(pcm []byte) error
+	close() error
+}
+
+type backendFormat struct {
+	SampleRate types.SampleRate
+	Channels   types.Channel
+	PCMFormat  types.PCMFormat
+}
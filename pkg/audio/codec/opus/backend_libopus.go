@@ -0,0 +1,203 @@
+//go:build opus
+// +build opus
+
+package opus
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+
+	"github.com/xaionaro-go/audio/pkg/audio/types"
+	libopus "gopkg.in/hraban/opus.v2"
+)
+
+type libopusEncoder struct {
+	encoder   *libopus.Encoder
+	pcmFormat types.PCMFormat
+	pcm16     []int16
+	pcm32     []float32
+}
+
+func newEncoderBackend(cfg EncoderConfig) (encoderBackend, error) {
+	var application libopus.Application
+	switch cfg.Application {
+	case ApplicationVoIP:
+		application = libopus.AppVoIP
+	case ApplicationAudio:
+		application = libopus.AppAudio
+	case ApplicationRestrictedLowDelay:
+		application = libopus.AppRestrictedLowdelay
+	default:
+		return nil, fmt.Errorf("unsupported application: %v", cfg.Application)
+	}
+	encoder, err := libopus.NewEncoder(int(cfg.SampleRate), int(cfg.Channels), application)
+	if err != nil {
+		return nil, fmt.Errorf("unable to initialize the libopus encoder: %w", err)
+	}
+	if cfg.BitRate == 0 {
+		err = encoder.SetBitrateToAuto()
+	} else {
+		err = encoder.SetBitrate(int(cfg.BitRate))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to set the bitrate %d: %w", cfg.BitRate, err)
+	}
+	if err := encoder.SetComplexity(int(cfg.Complexity)); err != nil {
+		return nil, fmt.Errorf("unable to set the complexity %d: %w", cfg.Complexity, err)
+	}
+	if err := encoder.SetInBandFEC(cfg.FEC); err != nil {
+		return nil, fmt.Errorf("unable to set FEC to %v: %w", cfg.FEC, err)
+	}
+	if err := encoder.SetPacketLossPerc(int(cfg.PacketLossPercentage)); err != nil {
+		return nil, fmt.Errorf("unable to set the packet loss percentage %d: %w", cfg.PacketLossPercentage, err)
+	}
+	if err := encoder.SetDTX(cfg.DTX); err != nil {
+		return nil, fmt.Errorf("unable to set DTX to %v: %w", cfg.DTX, err)
+	}
+	return &libopusEncoder{
+		encoder:   encoder,
+		pcmFormat: cfg.PCMFormat,
+	}, nil
+}
+
+func (e *libopusEncoder) encode(pcm []byte, packet []byte) (int, error) {
+	switch e.pcmFormat {
+	case types.PCMFormatS16LE:
+		e.pcm16 = bytesToInt16(e.pcm16, pcm)
+		return e.encoder.Encode(e.pcm16, packet)
+	default:
+		e.pcm32 = bytesToFloat32(e.pcm32, pcm)
+		return e.encoder.EncodeFloat32(e.pcm32, packet)
+	}
+}
+
+func (e *libopusEncoder) close() error {
+	// the memory of the encoder is managed by Go
+	return nil
+}
+
+type libopusDecoder struct {
+	decoder  *libopus.Decoder
+	format   backendFormat
+	pcm16    []int16
+	pcm32    []float32
+	channels int
+}
+
+func newDecoderBackend(format backendFormat) (decoderBackend, error) {
+	decoder, err := libopus.NewDecoder(int(format.SampleRate), int(format.Channels))
+	if err != nil {
+		return nil, fmt.Errorf("unable to initialize the libopus decoder: %w", err)
+	}
+	return &libopusDecoder{
+		decoder:  decoder,
+		format:   format,
+		channels: int(format.Channels),
+	}, nil
+}
+
+func (d *libopusDecoder) samplesForBytes(pcm []byte) int {
+	return len(pcm) / int(d.format.PCMFormat.Size())
+}
+
+func (d *libopusDecoder) decode(packet []byte, pcm []byte) (int, error) {
+	size := d.samplesForBytes(pcm)
+	switch d.format.PCMFormat {
+	case types.PCMFormatS16LE:
+		d.pcm16 = resize(d.pcm16, size)
+		n, err := d.decoder.Decode(packet, d.pcm16)
+		if err != nil {
+			return 0, err
+		}
+		int16ToBytes(pcm, d.pcm16[:n*d.channels])
+		return n, nil
+	default:
+		d.pcm32 = resize(d.pcm32, size)
+		n, err := d.decoder.DecodeFloat32(packet, d.pcm32)
+		if err != nil {
+			return 0, err
+		}
+		float32ToBytes(pcm, d.pcm32[:n*d.channels])
+		return n, nil
+	}
+}
+
+func (d *libopusDecoder) decodeFEC(packet []byte, pcm []byte) error {
+	size := d.samplesForBytes(pcm)
+	switch d.format.PCMFormat {
+	case types.PCMFormatS16LE:
+		d.pcm16 = resize(d.pcm16, size)
+		if err := d.decoder.DecodeFEC(packet, d.pcm16); err != nil {
+			return err
+		}
+		int16ToBytes(pcm, d.pcm16)
+	default:
+		d.pcm32 = resize(d.pcm32, size)
+		if err := d.decoder.DecodeFECFloat32(packet, d.pcm32); err != nil {
+			return err
+		}
+		float32ToBytes(pcm, d.pcm32)
+	}
+	return nil
+}
+
+func (d *libopusDecoder) decodePLC(pcm []byte) error {
+	size := d.samplesForBytes(pcm)
+	switch d.format.PCMFormat {
+	case types.PCMFormatS16LE:
+		d.pcm16 = resize(d.pcm16, size)
+		if err := d.decoder.DecodePLC(d.pcm16); err != nil {
+			return err
+		}
+		int16ToBytes(pcm, d.pcm16)
+	default:
+		d.pcm32 = resize(d.pcm32, size)
+		if err := d.decoder.DecodePLCFloat32(d.pcm32); err != nil {
+			return err
+		}
+		float32ToBytes(pcm, d.pcm32)
+	}
+	return nil
+}
+
+func (d *libopusDecoder) close() error {
+	return nil
+}
+
+// resize returns a slice of exactly the given length and capacity
+// (libopus bindings use the capacity as the size of the buffer).
+func resize[T any](s []T, size int) []T {
+	if cap(s) < size {
+		s = make([]T, size)
+	}
+	return s[:size:size]
+}
+
+func bytesToInt16(dst []int16, src []byte) []int16 {
+	dst = resize(dst, len(src)/2)
+	for idx := range dst {
+		dst[idx] = int16(binary.LittleEndian.Uint16(src[idx*2:]))
+	}
+	return dst
+}
+
+func bytesToFloat32(dst []float32, src []byte) []float32 {
+	dst = resize(dst, len(src)/4)
+	for idx := range dst {
+		dst[idx] = math.Float32frombits(binary.LittleEndian.Uint32(src[idx*4:]))
+	}
+	return dst
+}
+
+func int16ToBytes(dst []byte, src []int16) {
+	for idx, v := range src {
+		binary.LittleEndian.PutUint16(dst[idx*2:], uint16(v))
+	}
+}
+
+func float32ToBytes(dst []byte, src []float32) {
+	for idx, v := range src {
+		binary.LittleEndian.PutUint32(dst[idx*4:], math.Float32bits(v))
+	}
+}
@@ -0,0 +1,16 @@
+//go:build !opus
+// +build !opus
+
+package opus
+
+import (
+	"fmt"
+)
+
+func newEncoderBackend(EncoderConfig) (encoderBackend, error) {
+	return nil, fmt.Errorf("built without tag 'opus'")
+}
+
+func newDecoderBackend(backendFormat) (decoderBackend, error) {
+	return nil, fmt.Errorf("built without tag 'opus'")
+}
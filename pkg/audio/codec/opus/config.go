@@ -0,0 +1,181 @@
+package opus
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/xaionaro-go/audio/pkg/audio/types"
+)
+
+// Application is the intended application of the encoder, it defines
+// the trade-offs made by the encoder.
+type Application uint
+
+const (
+	UndefinedApplication = Application(iota)
+	ApplicationVoIP
+	ApplicationAudio
+	ApplicationRestrictedLowDelay
+	EndOfApplication
+)
+
+func (a Application) String() string {
+	switch a {
+	case UndefinedApplication:
+		return "<undefined>"
+	case ApplicationVoIP:
+		return "voip"
+	case ApplicationAudio:
+		return "audio"
+	case ApplicationRestrictedLowDelay:
+		return "restricted-lowdelay"
+	default:
+		return fmt.Sprintf("<unexpected_value_%d>", uint(a))
+	}
+}
+
+const (
+	// SampleRateOgg is the sample rate of the granule positions of
+	// Ogg Opus, and the sample rate decoders of Ogg Opus produce.
+	SampleRateOgg = types.SampleRate(48000)
+
+	// MaxFrameDuration is the maximal duration of a single Opus packet.
+	MaxFrameDuration = 120 * time.Millisecond
+
+	// MaxPacketSize is the recommended size of the buffer for an encoded packet.
+	MaxPacketSize = 4000
+
+	DefaultFrameDuration = 20 * time.Millisecond
+	DefaultComplexity    = 10
+)
+
+// FrameDurations are the frame durations supported by the encoder.
+var FrameDurations = []time.Duration{
+	2500 * time.Microsecond,
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	20 * time.Millisecond,
+	40 * time.Millisecond,
+	60 * time.Millisecond,
+}
+
+// SampleRates are the sample rates supported by Opus.
+var SampleRates = []types.SampleRate{8000, 12000, 16000, 24000, 48000}
+
+type EncoderConfig struct {
+	SampleRate types.SampleRate
+	Channels   types.Channel
+
+	// PCMFormat is the format of the input, it is either
+	// PCMFormatS16LE or PCMFormatFloat32LE.
+	PCMFormat types.PCMFormat
+
+	Application Application
+
+	// BitRate is in bits per second, zero means automatic.
+	BitRate uint
+
+	// Complexity is from 0 (fastest) to 10 (best quality).
+	Complexity uint
+
+	// FrameDuration is one of FrameDurations.
+	FrameDuration time.Duration
+
+	// FEC enables the in-band forward error correction: each packet
+	// carries a low-bitrate copy of the previous one (see
+	// PacketDecoder.DecodeFEC). It is effective only if
+	// PacketLossPercentage is non-zero.
+	FEC                  bool
+	PacketLossPercentage uint
+
+	// DTX enables the discontinuous transmission: during silence
+	// the encoder produces tiny packets (or no packets at all).
+	DTX bool
+}
+
+// DefaultEncoderConfig returns a configuration suitable for voice.
+func DefaultEncoderConfig(
+	sampleRate types.SampleRate,
+	channels types.Channel,
+) EncoderConfig {
+	return EncoderConfig{
+		SampleRate:    sampleRate,
+		Channels:      channels,
+		PCMFormat:     types.PCMFormatS16LE,
+		Application:   ApplicationVoIP,
+		Complexity:    DefaultComplexity,
+		FrameDuration: DefaultFrameDuration,
+	}
+}
+
+func (cfg EncoderConfig) Validate() error {
+	if err := validateFormat(cfg.SampleRate, cfg.Channels, cfg.PCMFormat); err != nil {
+		return err
+	}
+	if cfg.Application == UndefinedApplication || cfg.Application >= EndOfApplication {
+		return fmt.Errorf("invalid application: %v", cfg.Application)
+	}
+	if cfg.Complexity > 10 {
+		return fmt.Errorf("the complexity is expected to be in range [0, 10], but is %d", cfg.Complexity)
+	}
+	if cfg.PacketLossPercentage > 100 {
+		return fmt.Errorf("the packet loss percentage is expected to be in range [0, 100], but is %d", cfg.PacketLossPercentage)
+	}
+	for _, d := range FrameDurations {
+		if cfg.FrameDuration == d {
+			return nil
+		}
+	}
+	return fmt.Errorf("unsupported frame duration %v, supported: %v", cfg.FrameDuration, FrameDurations)
+}
+
+func validateFormat(
+	sampleRate types.SampleRate,
+	channels types.Channel,
+	pcmFormat types.PCMFormat,
+) error {
+	if !isSupportedSampleRate(sampleRate) {
+		return fmt.Errorf("unsupported sample rate %d, supported: %v", sampleRate, SampleRates)
+	}
+	if channels != 1 && channels != 2 {
+		return fmt.Errorf("only mono and stereo are supported, but requested %d channels", channels)
+	}
+	switch pcmFormat {
+	case types.PCMFormatS16LE, types.PCMFormatFloat32LE:
+	default:
+		return fmt.Errorf("unsupported PCM format %v, only %v and %v are supported", pcmFormat, types.PCMFormatS16LE, types.PCMFormatFloat32LE)
+	}
+	return nil
+}
+
+func isSupportedSampleRate(sampleRate types.SampleRate) bool {
+	for _, r := range SampleRates {
+		if sampleRate == r {
+			return true
+		}
+	}
+	return false
+}
+
+// SamplesPerFrame returns the amount of samples (per channel) in a frame.
+func (cfg EncoderConfig) SamplesPerFrame() int {
+	return samplesForDuration(cfg.SampleRate, cfg.FrameDuration)
+}
+
+// ChunkSize returns the size of the PCM data of a single frame in bytes.
+func (cfg EncoderConfig) ChunkSize() uint {
+	return uint(cfg.SamplesPerFrame()) * uint(cfg.Channels) * uint(cfg.PCMFormat.Size())
+}
+
+// preSkip returns the amount of samples (at SampleRateOgg) to be dropped
+// from the beginning of the decoded stream (the encoder lookahead).
+func (cfg EncoderConfig) preSkip() uint16 {
+	if cfg.Application == ApplicationRestrictedLowDelay {
+		return 120
+	}
+	return 312
+}
+
+func samplesForDuration(sampleRate types.SampleRate, d time.Duration) int {
+	return int(uint64(sampleRate) * uint64(d) / uint64(time.Second))
+}
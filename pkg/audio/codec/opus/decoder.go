@@ -0,0 +1,161 @@
+package opus
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/xaionaro-go/audio/pkg/audio/codec"
+	"github.com/xaionaro-go/audio/pkg/audio/types"
+)
+
+const (
+	Priority = 100
+)
+
+func init() {
+	codec.RegisterDecoderFactory(Priority, DecoderFactory{})
+}
+
+type DecoderFactory struct{}
+
+var _ codec.DecoderFactory = DecoderFactory{}
+
+func (DecoderFactory) Name() string {
+	return "opus"
+}
+
+// Sniff checks if the first Ogg page contains an Opus identification header.
+func (DecoderFactory) Sniff(head []byte) bool {
+	if len(head) < oggPageHeaderSize || !bytes.HasPrefix(head, []byte("OggS")) {
+		return false
+	}
+	packetStart := oggPageHeaderSize + int(head[26])
+	return bytes.HasPrefix(head[min(packetStart, len(head)):], []byte(headMagic))
+}
+
+func (DecoderFactory) NewDecoder(r io.Reader) (codec.Decoder, error) {
+	return NewDecoder(r)
+}
+
+// Decoder decodes Ogg Opus into PCMFormatS16LE at SampleRateOgg. The
+// pre-skip and the end trimming are applied.
+type Decoder struct {
+	ogg           *OggReader
+	packetDecoder *PacketDecoder
+	closer        io.Closer
+
+	frameSize int
+	pcm       []byte
+	pending   []byte
+
+	// decoded is the granule position after the last decoded packet
+	decoded int64
+}
+
+var _ codec.Decoder = (*Decoder)(nil)
+
+// NewDecoder reads the headers of the stream; it requires the build tag 'opus'.
+func NewDecoder(r io.Reader) (*Decoder, error) {
+	ogg, err := NewOggReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read the Ogg Opus headers: %w", err)
+	}
+	channels := types.Channel(ogg.Head().Channels)
+	packetDecoder, err := NewPacketDecoder(SampleRateOgg, channels, types.PCMFormatS16LE)
+	if err != nil {
+		return nil, err
+	}
+	return &Decoder{
+		ogg:           ogg,
+		packetDecoder: packetDecoder,
+		frameSize:     int(channels) * int(types.PCMFormatS16LE.Size()),
+		pcm:           make([]byte, packetDecoder.MaxChunkSize()),
+	}, nil
+}
+
+// Open opens an Ogg Opus file for decoding.
+func Open(filePath string) (*Decoder, error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open '%s': %w", filePath, err)
+	}
+	d, err := NewDecoder(f)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	d.closer = f
+	return d, nil
+}
+
+func (d *Decoder) Head() Head {
+	return d.ogg.Head()
+}
+
+func (d *Decoder) Tags() Tags {
+	return d.ogg.Tags()
+}
+
+func (d *Decoder) Format() codec.Format {
+	return codec.Format{
+		Channels:   types.Channel(d.ogg.Head().Channels),
+		SampleRate: SampleRateOgg,
+		PCMFormat:  types.PCMFormatS16LE,
+	}
+}
+
+func (d *Decoder) Read(b []byte) (int, error) {
+	for len(d.pending) == 0 {
+		if err := d.decodePacket(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(b, d.pending)
+	d.pending = d.pending[n:]
+	return n, nil
+}
+
+func (d *Decoder) decodePacket() error {
+	packet, granule, err := d.ogg.ReadPacket()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return io.EOF
+		}
+		return fmt.Errorf("unable to read a packet: %w", err)
+	}
+	if len(packet) == 0 {
+		return nil
+	}
+	n, err := d.packetDecoder.Decode(packet, d.pcm)
+	if err != nil {
+		return err
+	}
+
+	start := d.decoded
+	d.decoded += int64(n / d.frameSize)
+	end := d.decoded
+	if d.ogg.IsEOS() && granule >= 0 {
+		end = min(end, granule)
+	}
+	start = max(start, int64(d.ogg.Head().PreSkip))
+	if end <= start {
+		return nil
+	}
+	base := d.decoded - int64(n/d.frameSize)
+	d.pending = d.pcm[(start-base)*int64(d.frameSize) : (end-base)*int64(d.frameSize)]
+	return nil
+}
+
+// Close closes the file if the decoder was created by Open.
+func (d *Decoder) Close() error {
+	err := d.packetDecoder.Close()
+	if d.closer != nil {
+		if closeErr := d.closer.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	return err
+}
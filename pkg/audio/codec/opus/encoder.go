@@ -0,0 +1,68 @@
+package opus
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/xaionaro-go/audio/pkg/audio/types"
+)
+
+// Encoder encodes fixed-size chunks of PCM (see ChunkSize) into raw Opus
+// packets, similar to how the noise suppressors process the audio.
+type Encoder struct {
+	backend encoderBackend
+	config  EncoderConfig
+}
+
+// NewEncoder returns an encoder; it requires the build tag 'opus' (and libopus).
+func NewEncoder(cfg EncoderConfig) (*Encoder, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid config: %w", err)
+	}
+	backend, err := newEncoderBackend(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &Encoder{
+		backend: backend,
+		config:  cfg,
+	}, nil
+}
+
+func (e *Encoder) Config() EncoderConfig {
+	return e.config
+}
+
+func (e *Encoder) Close() error {
+	return e.backend.close()
+}
+
+func (e *Encoder) Encoding(ctx context.Context) (types.Encoding, error) {
+	return types.EncodingPCM{
+		PCMFormat:  e.config.PCMFormat,
+		SampleRate: e.config.SampleRate,
+	}, nil
+}
+
+func (e *Encoder) Channels(ctx context.Context) (types.Channel, error) {
+	return e.config.Channels, nil
+}
+
+// ChunkSize returns the size of the PCM of a single frame in bytes.
+func (e *Encoder) ChunkSize() uint {
+	return e.config.ChunkSize()
+}
+
+// Encode encodes a single frame of PCM (of size ChunkSize) into "packet"
+// and returns the size of the packet. With DTX enabled, packets of size
+// 1 or 2 bytes do not need to be transmitted.
+func (e *Encoder) Encode(pcm []byte, packet []byte) (int, error) {
+	if len(pcm) != int(e.ChunkSize()) {
+		return 0, fmt.Errorf("the size of the input is not equal to ChunkSize: %d != %d", len(pcm), e.ChunkSize())
+	}
+	n, err := e.backend.encode(pcm, packet)
+	if err != nil {
+		return 0, fmt.Errorf("unable to encode a frame: %w", err)
+	}
+	return n, nil
+}
@@ -0,0 +1,118 @@
+package opus
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+)
+
+const (
+	headMagic = "OpusHead"
+	tagsMagic = "OpusTags"
+	headSize  = 19
+)
+
+// Head is the identification header of Ogg Opus (RFC 7845, section 5.1).
+// Only the channel mapping family 0 (mono or stereo) is supported.
+type Head struct {
+	Channels uint8
+
+	// PreSkip is the amount of samples (at 48kHz) to be discarded
+	// from the beginning of the decoded stream.
+	PreSkip uint16
+
+	// InputSampleRate is the sample rate of the original input
+	// (informational only).
+	InputSampleRate uint32
+
+	// OutputGain is the gain to be applied on decoding in dB (Q7.8).
+	OutputGain int16
+}
+
+func (h Head) MarshalBinary() ([]byte, error) {
+	b := make([]byte, 0, headSize)
+	b = append(b, headMagic...)
+	b = append(b, 1, h.Channels)
+	b = binary.LittleEndian.AppendUint16(b, h.PreSkip)
+	b = binary.LittleEndian.AppendUint32(b, h.InputSampleRate)
+	b = binary.LittleEndian.AppendUint16(b, uint16(h.OutputGain))
+	b = append(b, 0) // channel mapping family
+	return b, nil
+}
+
+func (h *Head) UnmarshalBinary(b []byte) error {
+	if len(b) < headSize || !bytes.HasPrefix(b, []byte(headMagic)) {
+		return fmt.Errorf("not an OpusHead packet")
+	}
+	if version := b[8]; version>>4 != 0 {
+		return fmt.Errorf("unsupported OpusHead version %d", version)
+	}
+	if family := b[18]; family != 0 {
+		return fmt.Errorf("unsupported channel mapping family %d", family)
+	}
+	*h = Head{
+		Channels:        b[9],
+		PreSkip:         binary.LittleEndian.Uint16(b[10:]),
+		InputSampleRate: binary.LittleEndian.Uint32(b[12:]),
+		OutputGain:      int16(binary.LittleEndian.Uint16(b[16:])),
+	}
+	if h.Channels != 1 && h.Channels != 2 {
+		return fmt.Errorf("invalid amount of channels for mapping family 0: %d", h.Channels)
+	}
+	return nil
+}
+
+// Tags is the comment header of Ogg Opus (RFC 7845, section 5.2).
+type Tags struct {
+	Vendor   string
+	Comments []string
+}
+
+func (t Tags) MarshalBinary() ([]byte, error) {
+	b := []byte(tagsMagic)
+	b = binary.LittleEndian.AppendUint32(b, uint32(len(t.Vendor)))
+	b = append(b, t.Vendor...)
+	b = binary.LittleEndian.AppendUint32(b, uint32(len(t.Comments)))
+	for _, c := range t.Comments {
+		b = binary.LittleEndian.AppendUint32(b, uint32(len(c)))
+		b = append(b, c...)
+	}
+	return b, nil
+}
+
+func (t *Tags) UnmarshalBinary(b []byte) error {
+	if !bytes.HasPrefix(b, []byte(tagsMagic)) {
+		return fmt.Errorf("not an OpusTags packet")
+	}
+	b = b[len(tagsMagic):]
+	readString := func() (string, error) {
+		if len(b) < 4 {
+			return "", fmt.Errorf("the OpusTags packet is truncated")
+		}
+		l := binary.LittleEndian.Uint32(b)
+		if uint64(len(b)-4) < uint64(l) {
+			return "", fmt.Errorf("the OpusTags packet is truncated")
+		}
+		s := string(b[4 : 4+l])
+		b = b[4+l:]
+		return s, nil
+	}
+	vendor, err := readString()
+	if err != nil {
+		return err
+	}
+	if len(b) < 4 {
+		return fmt.Errorf("the OpusTags packet is truncated")
+	}
+	count := binary.LittleEndian.Uint32(b)
+	b = b[4:]
+	*t = Tags{Vendor: vendor}
+	for range count {
+		c, err := readString()
+		if err != nil {
+			return err
+		}
+		t.Comments = append(t.Comments, c)
+	}
+	return nil
+}
@@ -0,0 +1,245 @@
+package opus
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+const (
+	oggPageHeaderSize = 27
+	oggMaxSegments    = 255
+	oggMaxSegmentSize = 255
+
+	oggFlagContinued = 0x01
+	oggFlagBOS       = 0x02
+	oggFlagEOS       = 0x04
+)
+
+// oggGranuleUnknown is the granule position of a page where no packet ends.
+const oggGranuleUnknown = -1
+
+var oggCRCTable = func() (table [256]uint32) {
+	const poly = 0x04c11db7
+	for idx := range table {
+		r := uint32(idx) << 24
+		for range 8 {
+			if r&0x80000000 != 0 {
+				r = r<<1 ^ poly
+			} else {
+				r <<= 1
+			}
+		}
+		table[idx] = r
+	}
+	return
+}()
+
+func oggCRC(crc uint32, b []byte) uint32 {
+	for _, c := range b {
+		crc = crc<<8 ^ oggCRCTable[byte(crc>>24)^c]
+	}
+	return crc
+}
+
+// oggPageWriter writes a logical Ogg bitstream, each packet starts a new page.
+type oggPageWriter struct {
+	w        io.Writer
+	serial   uint32
+	sequence uint32
+	buf      []byte
+}
+
+func newOggPageWriter(w io.Writer, serial uint32) *oggPageWriter {
+	return &oggPageWriter{
+		w:      w,
+		serial: serial,
+	}
+}
+
+func (w *oggPageWriter) writePacket(
+	packet []byte,
+	granule int64,
+	flags byte,
+) error {
+	for {
+		// a packet of size N*255 is terminated by a zero-length segment
+		segments := min(len(packet)/oggMaxSegmentSize+1, oggMaxSegments)
+		size := min(len(packet), segments*oggMaxSegmentSize)
+		complete := size == len(packet) && segments*oggMaxSegmentSize != size
+
+		pageGranule := granule
+		pageFlags := flags
+		if !complete {
+			pageGranule = oggGranuleUnknown
+			pageFlags &^= oggFlagEOS
+		}
+		if err := w.writePage(packet[:size], segments, pageGranule, pageFlags); err != nil {
+			return err
+		}
+		if complete {
+			return nil
+		}
+		packet = packet[size:]
+		flags = flags&^oggFlagBOS | oggFlagContinued
+	}
+}
+
+func (w *oggPageWriter) writePage(
+	data []byte,
+	segments int,
+	granule int64,
+	flags byte,
+) error {
+	w.buf = w.buf[:0]
+	w.buf = append(w.buf, "OggS"...)
+	w.buf = append(w.buf, 0, flags)
+	w.buf = binary.LittleEndian.AppendUint64(w.buf, uint64(granule))
+	w.buf = binary.LittleEndian.AppendUint32(w.buf, w.serial)
+	w.buf = binary.LittleEndian.AppendUint32(w.buf, w.sequence)
+	w.buf = binary.LittleEndian.AppendUint32(w.buf, 0) // CRC
+	w.buf = append(w.buf, byte(segments))
+	remaining := len(data)
+	for range segments {
+		segment := min(remaining, oggMaxSegmentSize)
+		w.buf = append(w.buf, byte(segment))
+		remaining -= segment
+	}
+	w.buf = append(w.buf, data...)
+	binary.LittleEndian.PutUint32(w.buf[22:], oggCRC(0, w.buf))
+	w.sequence++
+	if _, err := w.w.Write(w.buf); err != nil {
+		return fmt.Errorf("unable to write an Ogg page: %w", err)
+	}
+	return nil
+}
+
+type oggPage struct {
+	Flags    byte
+	Granule  int64
+	Serial   uint32
+	Sequence uint32
+	Segments []byte
+	Data     []byte
+}
+
+// oggPacketReader reads the packets of the first logical bitstream of
+// an Ogg stream (pages of other bitstreams are skipped).
+type oggPacketReader struct {
+	r       io.Reader
+	serial  uint32
+	started bool
+	page    oggPage
+	segment int
+	offset  int
+	eos     bool
+	packet  []byte
+}
+
+func newOggPacketReader(r io.Reader) *oggPacketReader {
+	return &oggPacketReader{r: r}
+}
+
+func (r *oggPacketReader) readPage() error {
+	for {
+		var header [oggPageHeaderSize]byte
+		if _, err := io.ReadFull(r.r, header[:]); err != nil {
+			return err
+		}
+		if !bytes.Equal(header[:4], []byte("OggS")) {
+			return fmt.Errorf("invalid Ogg page capture pattern: %X", header[:4])
+		}
+		if header[4] != 0 {
+			return fmt.Errorf("unsupported Ogg version %d", header[4])
+		}
+		page := oggPage{
+			Flags:    header[5],
+			Granule:  int64(binary.LittleEndian.Uint64(header[6:])),
+			Serial:   binary.LittleEndian.Uint32(header[14:]),
+			Sequence: binary.LittleEndian.Uint32(header[18:]),
+			Segments: make([]byte, header[26]),
+		}
+		if _, err := io.ReadFull(r.r, page.Segments); err != nil {
+			return fmt.Errorf("unable to read the segment table: %w", err)
+		}
+		var size int
+		for _, s := range page.Segments {
+			size += int(s)
+		}
+		page.Data = make([]byte, size)
+		if _, err := io.ReadFull(r.r, page.Data); err != nil {
+			return fmt.Errorf("unable to read the page data: %w", err)
+		}
+
+		crc := binary.LittleEndian.Uint32(header[22:])
+		binary.LittleEndian.PutUint32(header[22:], 0)
+		calculated := oggCRC(oggCRC(oggCRC(0, header[:]), page.Segments), page.Data)
+		if calculated != crc {
+			return fmt.Errorf("Ogg page %d checksum mismatch: %08X != %08X", page.Sequence, calculated, crc)
+		}
+
+		if !r.started {
+			r.started = true
+			r.serial = page.Serial
+		}
+		if page.Serial != r.serial {
+			continue
+		}
+		r.page = page
+		r.segment = 0
+		r.offset = 0
+		return nil
+	}
+}
+
+// readPacket returns the next packet and the granule position of the page
+// where the packet ends (if it is the last packet ending on the page,
+// otherwise oggGranuleUnknown). The returned slice is valid until the next call.
+func (r *oggPacketReader) readPacket() ([]byte, int64, error) {
+	r.packet = r.packet[:0]
+	for {
+		for r.segment >= len(r.page.Segments) {
+			if r.eos {
+				return nil, oggGranuleUnknown, io.EOF
+			}
+			if err := r.readPage(); err != nil {
+				if err == io.EOF && len(r.packet) > 0 {
+					return nil, oggGranuleUnknown, io.ErrUnexpectedEOF
+				}
+				return nil, oggGranuleUnknown, err
+			}
+			r.eos = r.page.Flags&oggFlagEOS != 0
+			if r.page.Flags&oggFlagContinued == 0 {
+				// a packet cannot be continued from a previous page
+				r.packet = r.packet[:0]
+			}
+		}
+		segment := int(r.page.Segments[r.segment])
+		r.packet = append(r.packet, r.page.Data[r.offset:r.offset+segment]...)
+		r.offset += segment
+		r.segment++
+		if segment == oggMaxSegmentSize {
+			continue
+		}
+		granule := int64(oggGranuleUnknown)
+		if r.isLastPacketOfPage() {
+			granule = r.page.Granule
+		}
+		return r.packet, granule, nil
+	}
+}
+
+func (r *oggPacketReader) isLastPacketOfPage() bool {
+	for _, s := range r.page.Segments[r.segment:] {
+		if s < oggMaxSegmentSize {
+			return false
+		}
+	}
+	return true
+}
+
+// isEOS returns true if the last returned packet is the last packet of the stream.
+func (r *oggPacketReader) isEOS() bool {
+	return r.eos && r.segment >= len(r.page.Segments)
+}
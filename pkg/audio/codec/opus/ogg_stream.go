@@ -0,0 +1,125 @@
+package opus
+
+import (
+	"fmt"
+	"io"
+	"math/rand/v2"
+)
+
+// OggWriter writes Opus packets into an Ogg Opus stream (RFC 7845).
+type OggWriter struct {
+	pages   *oggPageWriter
+	head    Head
+	granule int64
+
+	pending        []byte
+	pendingGranule int64
+	hasPending     bool
+}
+
+// NewOggWriter writes the identification and the comment headers.
+func NewOggWriter(w io.Writer, head Head, tags Tags) (*OggWriter, error) {
+	ogg := &OggWriter{
+		pages:   newOggPageWriter(w, rand.Uint32()),
+		head:    head,
+		granule: int64(head.PreSkip),
+	}
+	headPacket, err := head.MarshalBinary()
+	if err != nil {
+		return nil, fmt.Errorf("unable to serialize OpusHead: %w", err)
+	}
+	if err := ogg.pages.writePacket(headPacket, 0, oggFlagBOS); err != nil {
+		return nil, err
+	}
+	tagsPacket, err := tags.MarshalBinary()
+	if err != nil {
+		return nil, fmt.Errorf("unable to serialize OpusTags: %w", err)
+	}
+	if err := ogg.pages.writePacket(tagsPacket, 0, 0); err != nil {
+		return nil, err
+	}
+	return ogg, nil
+}
+
+// WritePacket writes a packet containing the given amount of samples
+// (per channel, at 48kHz). The amount may be less than the duration of
+// the packet only for the last packets (to trim the padding on decoding).
+func (w *OggWriter) WritePacket(packet []byte, samples int) error {
+	// the last packet is delayed to be able to set EOS on it in Close
+	if err := w.flushPending(0); err != nil {
+		return err
+	}
+	w.granule += int64(samples)
+	w.pending = append(w.pending[:0], packet...)
+	w.pendingGranule = w.granule
+	w.hasPending = true
+	return nil
+}
+
+func (w *OggWriter) flushPending(flags byte) error {
+	if !w.hasPending {
+		return nil
+	}
+	w.hasPending = false
+	return w.pages.writePacket(w.pending, w.pendingGranule, flags)
+}
+
+// Close writes the last packet marking the end of the stream. It does
+// not close the underlying writer.
+func (w *OggWriter) Close() error {
+	if !w.hasPending {
+		// an empty stream still has to be terminated
+		return w.pages.writePage(nil, 0, w.granule, oggFlagEOS)
+	}
+	return w.flushPending(oggFlagEOS)
+}
+
+// OggReader reads Opus packets from an Ogg Opus stream (RFC 7845).
+// Only the first logical bitstream is read.
+type OggReader struct {
+	packets *oggPacketReader
+	head    Head
+	tags    Tags
+}
+
+// NewOggReader reads the identification and the comment headers.
+func NewOggReader(r io.Reader) (*OggReader, error) {
+	ogg := &OggReader{
+		packets: newOggPacketReader(r),
+	}
+	packet, _, err := ogg.packets.readPacket()
+	if err != nil {
+		return nil, fmt.Errorf("unable to read the OpusHead packet: %w", err)
+	}
+	if err := ogg.head.UnmarshalBinary(packet); err != nil {
+		return nil, err
+	}
+	packet, _, err = ogg.packets.readPacket()
+	if err != nil {
+		return nil, fmt.Errorf("unable to read the OpusTags packet: %w", err)
+	}
+	if err := ogg.tags.UnmarshalBinary(packet); err != nil {
+		return nil, err
+	}
+	return ogg, nil
+}
+
+func (r *OggReader) Head() Head {
+	return r.head
+}
+
+func (r *OggReader) Tags() Tags {
+	return r.tags
+}
+
+// ReadPacket returns the next Opus packet and the granule position, which
+// is known (non-negative) only for the last packet completed on a page.
+// The returned slice is valid until the next call.
+func (r *OggReader) ReadPacket() ([]byte, int64, error) {
+	return r.packets.readPacket()
+}
+
+// IsEOS returns true if the last returned packet is the last one.
+func (r *OggReader) IsEOS() bool {
+	return r.packets.isEOS()
+}
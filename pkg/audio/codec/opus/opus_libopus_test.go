@@ -0,0 +1,127 @@
+//go:build opus
+// +build opus
+
+package opus
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/xaionaro-go/audio/pkg/audio/codec"
+	"github.com/xaionaro-go/audio/pkg/audio/types"
+)
+
+func sineS16LE(sampleRate types.SampleRate, channels types.Channel, samples int) []byte {
+	b := make([]byte, samples*int(channels)*2)
+	for idx := 0; idx < samples; idx++ {
+		v := int16(10000 * math.Sin(2*math.Pi*440*float64(idx)/float64(sampleRate)))
+		for ch := 0; ch < int(channels); ch++ {
+			binary.LittleEndian.PutUint16(b[(idx*int(channels)+ch)*2:], uint16(v))
+		}
+	}
+	return b
+}
+
+func rms(b []byte) float64 {
+	var sum float64
+	for idx := 0; idx+1 < len(b); idx += 2 {
+		v := float64(int16(binary.LittleEndian.Uint16(b[idx:])))
+		sum += v * v
+	}
+	return math.Sqrt(sum / float64(len(b)/2))
+}
+
+func TestWriterDecoder(t *testing.T) {
+	// a remainder shorter and longer than a frame minus the pre-skip, and
+	// an input of an exact multiple of the frame size
+	for _, samples := range []int{48000 + 123, 48000 + 900, 48000} {
+		t.Run(fmt.Sprintf("samples=%d", samples), func(t *testing.T) {
+			cfg := DefaultEncoderConfig(48000, 2)
+			cfg.Application = ApplicationAudio
+			cfg.BitRate = 64000
+			pcm := sineS16LE(48000, 2, samples)
+
+			var buf bytes.Buffer
+			w, err := NewWriter(&buf, cfg)
+			require.NoError(t, err)
+			_, err = w.Write(pcm[:1000])
+			require.NoError(t, err)
+			_, err = w.Write(pcm[1000:])
+			require.NoError(t, err)
+			require.NoError(t, w.Close())
+			require.Less(t, buf.Len(), len(pcm)/4)
+
+			d, err := codec.NewDecoder(&buf)
+			require.NoError(t, err)
+			require.Equal(t, codec.Format{Channels: 2, SampleRate: 48000, PCMFormat: types.PCMFormatS16LE}, d.Format())
+			decoded, err := io.ReadAll(d)
+			require.NoError(t, err)
+			require.Len(t, decoded, len(pcm))
+			require.InEpsilon(t, rms(pcm), rms(decoded), 0.2)
+
+			// the end of the input is delayed by the encoder lookahead
+			tail := int(cfg.preSkip()) * 2 * 2
+			require.InEpsilon(t, rms(pcm[len(pcm)-tail:]), rms(decoded[len(decoded)-tail:]), 0.3)
+		})
+	}
+}
+
+func TestPackets(t *testing.T) {
+	for _, frameDuration := range FrameDurations {
+		cfg := DefaultEncoderConfig(16000, 1)
+		cfg.FrameDuration = frameDuration
+		cfg.FEC = true
+		cfg.PacketLossPercentage = 10
+		cfg.DTX = true
+		e, err := NewEncoder(cfg)
+		require.NoError(t, err)
+
+		d, err := NewPacketDecoder(16000, 1, types.PCMFormatS16LE)
+		require.NoError(t, err)
+
+		pcm := sineS16LE(16000, 1, cfg.SamplesPerFrame())
+		packet := make([]byte, MaxPacketSize)
+		n, err := e.Encode(pcm, packet)
+		require.NoError(t, err)
+		_, err = e.Encode(pcm[1:], packet)
+		require.Error(t, err)
+
+		out := make([]byte, d.MaxChunkSize())
+		m, err := d.Decode(packet[:n], out)
+		require.NoError(t, err)
+		require.Equal(t, len(pcm), m)
+
+		lost := make([]byte, d.ChunkSizeForDuration(frameDuration))
+		require.NoError(t, d.DecodeFEC(packet[:n], lost))
+		require.NoError(t, d.DecodeLost(lost))
+
+		require.NoError(t, e.Close())
+		require.NoError(t, d.Close())
+	}
+}
+
+func TestFloat32(t *testing.T) {
+	cfg := DefaultEncoderConfig(48000, 2)
+	cfg.PCMFormat = types.PCMFormatFloat32LE
+	cfg.FrameDuration = 10 * time.Millisecond
+	e, err := NewEncoder(cfg)
+	require.NoError(t, err)
+	defer e.Close()
+	d, err := NewPacketDecoder(48000, 2, types.PCMFormatFloat32LE)
+	require.NoError(t, err)
+	defer d.Close()
+
+	packet := make([]byte, MaxPacketSize)
+	n, err := e.Encode(make([]byte, e.ChunkSize()), packet)
+	require.NoError(t, err)
+	out := make([]byte, d.MaxChunkSize())
+	m, err := d.Decode(packet[:n], out)
+	require.NoError(t, err)
+	require.Equal(t, int(e.ChunkSize()), m)
+}
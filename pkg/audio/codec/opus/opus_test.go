@@ -0,0 +1,112 @@
+package opus
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/xaionaro-go/audio/pkg/audio/types"
+)
+
+func TestOggPackets(t *testing.T) {
+	packets := [][]byte{
+		{},
+		bytes.Repeat([]byte{1}, 254),
+		bytes.Repeat([]byte{2}, 255),
+		bytes.Repeat([]byte{3}, 256),
+		bytes.Repeat([]byte{4}, 255*255),
+		bytes.Repeat([]byte{5}, 100000),
+		{6},
+	}
+
+	var buf bytes.Buffer
+	w, err := NewOggWriter(&buf, Head{Channels: 2, PreSkip: 312, InputSampleRate: 44100}, Tags{
+		Vendor:   "test",
+		Comments: []string{"TITLE=test"},
+	})
+	require.NoError(t, err)
+	for _, packet := range packets {
+		require.NoError(t, w.WritePacket(packet, 960))
+	}
+	require.NoError(t, w.Close())
+
+	require.True(t, DecoderFactory{}.Sniff(buf.Bytes()))
+
+	r, err := NewOggReader(bytes.NewReader(buf.Bytes()))
+	require.NoError(t, err)
+	require.Equal(t, Head{Channels: 2, PreSkip: 312, InputSampleRate: 44100}, r.Head())
+	require.Equal(t, Tags{Vendor: "test", Comments: []string{"TITLE=test"}}, r.Tags())
+	for idx, expected := range packets {
+		packet, granule, err := r.ReadPacket()
+		require.NoError(t, err, idx)
+		require.Equal(t, expected, packet, idx)
+		require.Equal(t, int64(312+960*(idx+1)), granule, idx)
+		require.Equal(t, idx == len(packets)-1, r.IsEOS(), idx)
+	}
+	_, _, err = r.ReadPacket()
+	require.ErrorIs(t, err, io.EOF)
+
+	// corrupted data
+	b := bytes.Clone(buf.Bytes())
+	b[len(b)-1] ^= 0xff
+	r, err = NewOggReader(bytes.NewReader(b))
+	require.NoError(t, err)
+	for {
+		_, _, err = r.ReadPacket()
+		if err != nil {
+			break
+		}
+	}
+	require.ErrorContains(t, err, "checksum mismatch")
+}
+
+func TestOggCRCWithVorbisFile(t *testing.T) {
+	f, err := os.Open("../../../../cmd/beep/resources/long_audio.ogg")
+	require.NoError(t, err)
+	defer f.Close()
+
+	r := newOggPacketReader(f)
+	var count int
+	for {
+		_, _, err := r.readPacket()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		count++
+	}
+	require.Greater(t, count, 3)
+
+	head := make([]byte, 4096)
+	_, err = f.ReadAt(head, 0)
+	require.NoError(t, err)
+	require.False(t, DecoderFactory{}.Sniff(head))
+}
+
+func TestEncoderConfig(t *testing.T) {
+	cfg := DefaultEncoderConfig(48000, 2)
+	require.NoError(t, cfg.Validate())
+	require.Equal(t, 960, cfg.SamplesPerFrame())
+	require.Equal(t, uint(960*2*2), cfg.ChunkSize())
+
+	cfg.FrameDuration = 2500 * time.Microsecond
+	cfg.SampleRate = 8000
+	cfg.Channels = 1
+	cfg.PCMFormat = types.PCMFormatFloat32LE
+	require.NoError(t, cfg.Validate())
+	require.Equal(t, uint(20*4), cfg.ChunkSize())
+
+	cfg.FrameDuration = 30 * time.Millisecond
+	require.Error(t, cfg.Validate())
+
+	cfg = DefaultEncoderConfig(44100, 2)
+	require.Error(t, cfg.Validate())
+	cfg = DefaultEncoderConfig(48000, 3)
+	require.Error(t, cfg.Validate())
+	cfg = DefaultEncoderConfig(48000, 2)
+	cfg.Complexity = 11
+	require.Error(t, cfg.Validate())
+}
@@ -0,0 +1,83 @@
+package opus
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/xaionaro-go/audio/pkg/audio/types"
+)
+
+// PacketDecoder decodes raw Opus packets (e.g. received from the network).
+type PacketDecoder struct {
+	backend decoderBackend
+	format  backendFormat
+}
+
+// NewPacketDecoder returns a decoder producing PCM of the given format (any
+// sample rate from SampleRates is allowed regardless of the sample rate
+// the packets were encoded with); it requires the build tag 'opus'.
+func NewPacketDecoder(
+	sampleRate types.SampleRate,
+	channels types.Channel,
+	pcmFormat types.PCMFormat,
+) (*PacketDecoder, error) {
+	if err := validateFormat(sampleRate, channels, pcmFormat); err != nil {
+		return nil, err
+	}
+	format := backendFormat{
+		SampleRate: sampleRate,
+		Channels:   channels,
+		PCMFormat:  pcmFormat,
+	}
+	backend, err := newDecoderBackend(format)
+	if err != nil {
+		return nil, err
+	}
+	return &PacketDecoder{
+		backend: backend,
+		format:  format,
+	}, nil
+}
+
+func (d *PacketDecoder) Close() error {
+	return d.backend.close()
+}
+
+// MaxChunkSize returns the size of the buffer enough to decode any packet.
+func (d *PacketDecoder) MaxChunkSize() uint {
+	return d.ChunkSizeForDuration(MaxFrameDuration)
+}
+
+// ChunkSizeForDuration returns the size of the PCM of the given duration in bytes.
+func (d *PacketDecoder) ChunkSizeForDuration(duration time.Duration) uint {
+	return uint(samplesForDuration(d.format.SampleRate, duration)) * uint(d.format.Channels) * uint(d.format.PCMFormat.Size())
+}
+
+// Decode decodes the packet into "pcm" and returns the amount of bytes written.
+func (d *PacketDecoder) Decode(packet []byte, pcm []byte) (int, error) {
+	samples, err := d.backend.decode(packet, pcm)
+	if err != nil {
+		return 0, fmt.Errorf("unable to decode a packet of size %d: %w", len(packet), err)
+	}
+	return samples * int(d.format.Channels) * int(d.format.PCMFormat.Size()), nil
+}
+
+// DecodeFEC recovers the lost packet preceding "packet" using the forward
+// error correction data in "packet" (it falls back to the packet loss
+// concealment if there is no such data). The size of "pcm" defines the
+// duration to be recovered, and it has to be a multiple of 2.5ms.
+func (d *PacketDecoder) DecodeFEC(packet []byte, pcm []byte) error {
+	if err := d.backend.decodeFEC(packet, pcm); err != nil {
+		return fmt.Errorf("unable to recover a lost packet of size %d: %w", len(pcm), err)
+	}
+	return nil
+}
+
+// DecodeLost fills "pcm" using the packet loss concealment. The size of
+// "pcm" defines the duration of the lost audio, and it has to be a multiple of 2.5ms.
+func (d *PacketDecoder) DecodeLost(pcm []byte) error {
+	if err := d.backend.decodePLC(pcm); err != nil {
+		return fmt.Errorf("unable to conceal a lost packet of size %d: %w", len(pcm), err)
+	}
+	return nil
+}
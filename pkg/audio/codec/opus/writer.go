@@ -0,0 +1,145 @@
+package opus
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/hashicorp/go-multierror"
+)
+
+// VendorString is written into the OpusTags of the created files.
+const VendorString = "github.com/xaionaro-go/audio"
+
+// Writer encodes PCM into an Ogg Opus stream.
+type Writer struct {
+	encoder          *Encoder
+	ogg              *OggWriter
+	closer           io.Closer
+	buf              []byte
+	packet           []byte
+	samplesPerPacket int
+	preSkip          int
+}
+
+var _ io.WriteCloser = (*Writer)(nil)
+
+// NewWriter writes the Ogg Opus headers and returns a writer of PCM in
+// the format of the config; it requires the build tag 'opus'.
+func NewWriter(w io.Writer, cfg EncoderConfig) (*Writer, error) {
+	encoder, err := NewEncoder(cfg)
+	if err != nil {
+		return nil, err
+	}
+	ogg, err := NewOggWriter(w, Head{
+		Channels:        uint8(cfg.Channels),
+		PreSkip:         cfg.preSkip(),
+		InputSampleRate: uint32(cfg.SampleRate),
+	}, Tags{Vendor: VendorString})
+	if err != nil {
+		encoder.Close()
+		return nil, fmt.Errorf("unable to write the headers: %w", err)
+	}
+	return &Writer{
+		encoder:          encoder,
+		ogg:              ogg,
+		buf:              make([]byte, 0, cfg.ChunkSize()),
+		packet:           make([]byte, MaxPacketSize),
+		samplesPerPacket: samplesForDuration(SampleRateOgg, cfg.FrameDuration),
+		preSkip:          int(cfg.preSkip()),
+	}, nil
+}
+
+// Create creates an Ogg Opus file.
+func Create(filePath string, cfg EncoderConfig) (*Writer, error) {
+	f, err := os.Create(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create '%s': %w", filePath, err)
+	}
+	w, err := NewWriter(f, cfg)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	w.closer = f
+	return w, nil
+}
+
+func (w *Writer) Encoder() *Encoder {
+	return w.encoder
+}
+
+func (w *Writer) Write(b []byte) (int, error) {
+	chunkSize := cap(w.buf)
+	var written int
+	for len(b) > 0 {
+		n := min(len(b), chunkSize-len(w.buf))
+		w.buf = append(w.buf, b[:n]...)
+		b = b[n:]
+		written += n
+		if len(w.buf) < chunkSize {
+			break
+		}
+		if err := w.encodeChunk(w.samplesPerPacket); err != nil {
+			return written, err
+		}
+	}
+	return written, nil
+}
+
+// flush encodes the remaining data and then silence until the decoded
+// stream covers the end of the input: the encoder output is delayed by
+// its lookahead (the pre-skip), so otherwise the last pre-skip samples
+// would be lost.
+func (w *Writer) flush() error {
+	if len(w.buf) == 0 && !w.ogg.hasPending {
+		return nil
+	}
+	// the remaining samples to be added to the granule position
+	samples := w.samplesPerPacket * len(w.buf) / cap(w.buf)
+	// the remaining samples to be decoded to reach the granule position
+	missing := w.preSkip + samples
+	for missing > 0 {
+		filled := len(w.buf)
+		w.buf = w.buf[:cap(w.buf)]
+		clear(w.buf[filled:])
+		packetSamples := min(samples, w.samplesPerPacket)
+		if err := w.encodeChunk(packetSamples); err != nil {
+			return err
+		}
+		samples -= packetSamples
+		missing -= w.samplesPerPacket
+	}
+	return nil
+}
+
+func (w *Writer) encodeChunk(samples int) error {
+	n, err := w.encoder.Encode(w.buf, w.packet)
+	if err != nil {
+		return err
+	}
+	w.buf = w.buf[:0]
+	return w.ogg.WritePacket(w.packet[:n], samples)
+}
+
+// Close encodes the remaining data (padded with silence, which is trimmed
+// on decoding), ends the stream and closes the file if the writer was
+// created by Create.
+func (w *Writer) Close() error {
+	var mErr *multierror.Error
+	if err := w.flush(); err != nil {
+		mErr = multierror.Append(mErr, err)
+	}
+	if err := w.ogg.Close(); err != nil {
+		mErr = multierror.Append(mErr, fmt.Errorf("unable to finalize the stream: %w", err))
+	}
+	if err := w.encoder.Close(); err != nil {
+		mErr = multierror.Append(mErr, fmt.Errorf("unable to close the encoder: %w", err))
+	}
+	if w.closer != nil {
+		if err := w.closer.Close(); err != nil {
+			mErr = multierror.Append(mErr, fmt.Errorf("unable to close the file: %w", err))
+		}
+	}
+	return mErr.ErrorOrNil()
+}
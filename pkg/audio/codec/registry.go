@@ -0,0 +1,128 @@
+package codec
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+)
+
+// SniffLength is the amount of bytes provided to DecoderFactory.Sniff.
+const SniffLength = 4096
+
+type decoderFactoryWithPriority struct {
+	Priority int
+	DecoderFactory
+}
+
+var decoderFactoryRegistry = map[reflect.Type]decoderFactoryWithPriority{}
+
+func RegisterDecoderFactory(
+	priority int,
+	decoderFactory DecoderFactory,
+) {
+	t := reflect.ValueOf(decoderFactory).Type()
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if _, ok := decoderFactoryRegistry[t]; ok {
+		panic(fmt.Errorf("there is already registered a factory of Decoder of type %v", t))
+	}
+	decoderFactoryRegistry[t] = decoderFactoryWithPriority{
+		Priority:       priority,
+		DecoderFactory: decoderFactory,
+	}
+}
+
+func DecoderFactories() []DecoderFactory {
+	var factoriesWithPriorities []decoderFactoryWithPriority
+	for _, factory := range decoderFactoryRegistry {
+		factoriesWithPriorities = append(factoriesWithPriorities, factory)
+	}
+	sort.Slice(factoriesWithPriorities, func(i, j int) bool {
+		return factoriesWithPriorities[i].Priority > factoriesWithPriorities[j].Priority
+	})
+
+	var factories []DecoderFactory
+	for _, factory := range factoriesWithPriorities {
+		factories = append(factories, factory.DecoderFactory)
+	}
+
+	return factories
+}
+
+// Sniff returns the factory of the decoder of the data starting with "head".
+func Sniff(head []byte) (DecoderFactory, error) {
+	if len(head) > SniffLength {
+		head = head[:SniffLength]
+	}
+	for _, factory := range DecoderFactories() {
+		if factory.Sniff(head) {
+			return factory, nil
+		}
+	}
+	return nil, fmt.Errorf("unable to detect the format (head: %X)", head[:min(len(head), 16)])
+}
+
+// NewDecoder detects the format of the data and returns a decoder for it.
+// If "r" is an io.ReadSeeker, it is passed to the decoder as is (so the
+// decoder may provide seeking and the duration).
+func NewDecoder(r io.Reader) (Decoder, error) {
+	head, r, err := readHead(r)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read the beginning of the data: %w", err)
+	}
+	factory, err := Sniff(head)
+	if err != nil {
+		return nil, err
+	}
+	decoder, err := factory.NewDecoder(r)
+	if err != nil {
+		return nil, fmt.Errorf("unable to initialize a %s decoder: %w", factory.Name(), err)
+	}
+	return decoder, nil
+}
+
+// peeker is implemented by readers that can return the upcoming data
+// without consuming it (like bufio.Reader).
+type peeker interface {
+	Peek(n int) ([]byte, error)
+}
+
+// readHead returns the first SniffLength bytes of the data and the
+// reader to read the data from the beginning.
+func readHead(r io.Reader) ([]byte, io.Reader, error) {
+	seeker, ok := r.(io.ReadSeeker)
+	if !ok {
+		bufReader := bufio.NewReaderSize(r, SniffLength)
+		head, err := bufReader.Peek(SniffLength)
+		if err != nil && !errors.Is(err, io.EOF) {
+			return nil, nil, err
+		}
+		return head, bufReader, nil
+	}
+
+	if peeker, ok := r.(peeker); ok {
+		head, err := peeker.Peek(SniffLength)
+		if err != nil && !errors.Is(err, io.EOF) {
+			return nil, nil, err
+		}
+		return head, r, nil
+	}
+
+	pos, err := seeker.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to get the position: %w", err)
+	}
+	head := make([]byte, SniffLength)
+	n, err := io.ReadFull(seeker, head)
+	if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return nil, nil, err
+	}
+	if _, err := seeker.Seek(pos, io.SeekStart); err != nil {
+		return nil, nil, fmt.Errorf("unable to seek back to %d: %w", pos, err)
+	}
+	return head[:n], seeker, nil
+}
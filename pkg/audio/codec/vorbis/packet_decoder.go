@@ -0,0 +1,69 @@
+package vorbis
+
+import (
+	"fmt"
+
+	"github.com/jfreymuth/vorbis"
+	"github.com/xaionaro-go/audio/pkg/audio/codec"
+	"github.com/xaionaro-go/audio/pkg/audio/types"
+)
+
+// PacketDecoder decodes raw Vorbis packets (e.g. demuxed from a container
+// other than Ogg) into PCMFormatFloat32LE.
+type PacketDecoder struct {
+	decoder  vorbis.Decoder
+	metadata map[string]string
+}
+
+// NewPacketDecoder reads the three Vorbis headers (identification,
+// comment and setup).
+func NewPacketDecoder(headers ...[]byte) (*PacketDecoder, error) {
+	if len(headers) != 3 {
+		return nil, fmt.Errorf("expected 3 Vorbis headers, but received %d", len(headers))
+	}
+	d := &PacketDecoder{}
+	for idx, header := range headers {
+		if err := d.decoder.ReadHeader(header); err != nil {
+			return nil, fmt.Errorf("unable to read Vorbis header #%d: %w", idx, err)
+		}
+	}
+	if !d.decoder.HeadersRead() {
+		return nil, fmt.Errorf("the Vorbis headers are incomplete")
+	}
+	d.metadata = parseComments(d.decoder.Comments)
+	return d, nil
+}
+
+func (d *PacketDecoder) Format() codec.Format {
+	return codec.Format{
+		Channels:   types.Channel(d.decoder.Channels()),
+		SampleRate: types.SampleRate(d.decoder.SampleRate()),
+		PCMFormat:  types.PCMFormatFloat32LE,
+	}
+}
+
+// Metadata returns the Vorbis comments, the keys are upper-case.
+func (d *PacketDecoder) Metadata() map[string]string {
+	return d.metadata
+}
+
+// MaxChunkSize returns the size of the buffer enough to decode any packet.
+func (d *PacketDecoder) MaxChunkSize() uint {
+	return uint(d.decoder.BufferSize()) * uint(types.PCMFormatFloat32LE.Size())
+}
+
+// Decode decodes the packet into "pcm" (which has to be at least
+// MaxChunkSize bytes long) and returns the amount of bytes written. The
+// first packet (and the first packet after Reset) yields no samples.
+func (d *PacketDecoder) Decode(packet []byte, pcm []byte) (int, error) {
+	samples, err := d.decoder.DecodeInto(packet, float32Slice(pcm))
+	if err != nil {
+		return 0, fmt.Errorf("unable to decode a packet of size %d: %w", len(packet), err)
+	}
+	return len(samples) * int(types.PCMFormatFloat32LE.Size()), nil
+}
+
+// Reset has to be called between decoding non-consecutive packets (e.g. after seeking).
+func (d *PacketDecoder) Reset() {
+	d.decoder.Clear()
+}
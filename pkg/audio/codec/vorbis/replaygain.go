@@ -0,0 +1,104 @@
+package vorbis
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+type ReplayGainMode uint
+
+const (
+	UndefinedReplayGainMode = ReplayGainMode(iota)
+	ReplayGainModeOff
+	ReplayGainModeTrack
+	ReplayGainModeAlbum
+	EndOfReplayGainMode
+)
+
+func (m ReplayGainMode) String() string {
+	switch m {
+	case UndefinedReplayGainMode:
+		return "<undefined>"
+	case ReplayGainModeOff:
+		return "off"
+	case ReplayGainModeTrack:
+		return "track"
+	case ReplayGainModeAlbum:
+		return "album"
+	default:
+		return fmt.Sprintf("<unexpected_value_%d>", uint(m))
+	}
+}
+
+// ReplayGain is the loudness normalization information from the
+// REPLAYGAIN_* comments. A zero peak means the peak is unknown.
+type ReplayGain struct {
+	TrackGainDB float64
+	TrackPeak   float64
+	HasTrack    bool
+
+	AlbumGainDB float64
+	AlbumPeak   float64
+	HasAlbum    bool
+}
+
+// ParseReplayGain parses the REPLAYGAIN_* values of the metadata (see Decoder.Metadata).
+func ParseReplayGain(metadata map[string]string) ReplayGain {
+	var rg ReplayGain
+	rg.TrackGainDB, rg.HasTrack = parseGain(metadata["REPLAYGAIN_TRACK_GAIN"])
+	rg.TrackPeak, _ = parsePeak(metadata["REPLAYGAIN_TRACK_PEAK"])
+	rg.AlbumGainDB, rg.HasAlbum = parseGain(metadata["REPLAYGAIN_ALBUM_GAIN"])
+	rg.AlbumPeak, _ = parsePeak(metadata["REPLAYGAIN_ALBUM_PEAK"])
+	return rg
+}
+
+// parseGain parses values like "-6.48 dB".
+func parseGain(s string) (float64, bool) {
+	s = strings.TrimSpace(s)
+	s = strings.TrimSpace(strings.TrimSuffix(strings.TrimSuffix(s, "dB"), "db"))
+	if s == "" {
+		return 0, false
+	}
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, false
+	}
+	return v, true
+}
+
+func parsePeak(s string) (float64, bool) {
+	v, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	if err != nil || v <= 0 {
+		return 0, false
+	}
+	return v, true
+}
+
+// Factor returns the amplitude multiplier for the given mode with the
+// pre-amplification in dB (applied only to tracks having ReplayGain
+// information). The factor is limited to not clip the peak. If the
+// information for the requested mode is absent, the other one is used.
+func (rg ReplayGain) Factor(mode ReplayGainMode, preampDB float64) float64 {
+	var gain, peak float64
+	switch mode {
+	case ReplayGainModeTrack, ReplayGainModeAlbum:
+	default:
+		return 1
+	}
+	useAlbum := (mode == ReplayGainModeAlbum && rg.HasAlbum) || !rg.HasTrack
+	switch {
+	case useAlbum && rg.HasAlbum:
+		gain, peak = rg.AlbumGainDB, rg.AlbumPeak
+	case rg.HasTrack:
+		gain, peak = rg.TrackGainDB, rg.TrackPeak
+	default:
+		return 1
+	}
+	factor := math.Pow(10, (gain+preampDB)/20)
+	if peak > 0 && factor*peak > 1 {
+		factor = 1 / peak
+	}
+	return factor
+}
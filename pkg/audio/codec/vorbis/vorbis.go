@@ -0,0 +1,169 @@
+package vorbis
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jfreymuth/oggvorbis"
+	"github.com/xaionaro-go/audio/pkg/audio/codec"
+	"github.com/xaionaro-go/audio/pkg/audio/types"
+)
+
+const (
+	Priority = 100
+)
+
+func init() {
+	codec.RegisterDecoderFactory(Priority, DecoderFactory{})
+}
+
+type DecoderFactory struct{}
+
+var _ codec.DecoderFactory = DecoderFactory{}
+
+func (DecoderFactory) Name() string {
+	return "vorbis"
+}
+
+// Sniff checks if the first Ogg page contains a Vorbis identification header.
+func (DecoderFactory) Sniff(head []byte) bool {
+	if len(head) < 27 || !bytes.HasPrefix(head, []byte("OggS")) {
+		return false
+	}
+	packetStart := 27 + int(head[26])
+	return bytes.HasPrefix(head[min(packetStart, len(head)):], []byte("\x01vorbis"))
+}
+
+func (DecoderFactory) NewDecoder(r io.Reader) (codec.Decoder, error) {
+	return NewDecoder(r)
+}
+
+// Decoder decodes Ogg Vorbis into PCMFormatFloat32LE.
+//
+// It is safe to call its methods concurrently with Read (e.g. to seek
+// while the decoder is being played).
+type Decoder struct {
+	reader        *oggvorbis.Reader
+	float32Reader readerFromFloat32Reader
+	locker        sync.Mutex
+	metadata      map[string]string
+	gainFactor    float32
+}
+
+var _ codec.Decoder = (*Decoder)(nil)
+
+func NewDecoder(r io.Reader) (*Decoder, error) {
+	oggReader, err := oggvorbis.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("unable to initialize a vorbis reader: %w", err)
+	}
+	return &Decoder{
+		reader:        oggReader,
+		float32Reader: newReaderFromFloat32Reader(oggReader),
+		metadata:      parseComments(oggReader.CommentHeader().Comments),
+		gainFactor:    1,
+	}, nil
+}
+
+// parseComments converts "KEY=value" comments into a map with upper-case
+// keys; multiple values of the same key are joined with "; ".
+func parseComments(comments []string) map[string]string {
+	m := map[string]string{}
+	for _, comment := range comments {
+		key, value, ok := strings.Cut(comment, "=")
+		if !ok {
+			continue
+		}
+		key = strings.ToUpper(key)
+		if prev, ok := m[key]; ok {
+			value = prev + "; " + value
+		}
+		m[key] = value
+	}
+	return m
+}
+
+// Metadata returns the Vorbis comments (e.g. "TITLE", "ARTIST",
+// "REPLAYGAIN_TRACK_GAIN"), the keys are upper-case.
+func (d *Decoder) Metadata() map[string]string {
+	return d.metadata
+}
+
+// ReplayGain returns the ReplayGain information from the comments.
+func (d *Decoder) ReplayGain() ReplayGain {
+	return ParseReplayGain(d.metadata)
+}
+
+// SetReplayGain enables applying the ReplayGain (from the comments) to the
+// decoded audio; ReplayGainModeOff disables it.
+func (d *Decoder) SetReplayGain(mode ReplayGainMode, preampDB float64) {
+	factor := d.ReplayGain().Factor(mode, preampDB)
+	d.locker.Lock()
+	defer d.locker.Unlock()
+	d.gainFactor = float32(factor)
+}
+
+// Duration returns the duration of the stream, or zero if it is unknown
+// (the length is known only if the input is an io.ReadSeeker).
+func (d *Decoder) Duration() time.Duration {
+	d.locker.Lock()
+	defer d.locker.Unlock()
+	return d.samplesToDuration(d.reader.Length())
+}
+
+// Position returns the position of the next sample to be read.
+func (d *Decoder) Position() time.Duration {
+	d.locker.Lock()
+	defer d.locker.Unlock()
+	return d.samplesToDuration(d.reader.Position())
+}
+
+func (d *Decoder) samplesToDuration(samples int64) time.Duration {
+	return time.Duration(samples) * time.Second / time.Duration(d.reader.SampleRate())
+}
+
+// SeekSample moves the position to the given sample (per channel); it
+// requires the input to be an io.ReadSeeker.
+func (d *Decoder) SeekSample(sample uint64) error {
+	d.locker.Lock()
+	defer d.locker.Unlock()
+	if err := d.reader.SetPosition(int64(sample)); err != nil {
+		return fmt.Errorf("unable to seek to sample %d: %w", sample, err)
+	}
+	return nil
+}
+
+// Seek moves the position to the given time; it requires the input to be
+// an io.ReadSeeker.
+func (d *Decoder) Seek(position time.Duration) error {
+	return d.SeekSample(uint64(position * time.Duration(d.reader.SampleRate()) / time.Second))
+}
+
+func (d *Decoder) Format() codec.Format {
+	return codec.Format{
+		Channels:   types.Channel(d.reader.Channels()),
+		SampleRate: types.SampleRate(d.reader.SampleRate()),
+		PCMFormat:  types.PCMFormatFloat32LE,
+	}
+}
+
+// Read reads the decoded PCM data (as bytes).
+func (d *Decoder) Read(b []byte) (int, error) {
+	d.locker.Lock()
+	defer d.locker.Unlock()
+	n, err := d.float32Reader.Read(b)
+	if d.gainFactor != 1 {
+		applyGain(float32Slice(b[:n]), d.gainFactor)
+	}
+	return n, err
+}
+
+func applyGain(samples []float32, factor float32) {
+	for idx, v := range samples {
+		samples[idx] = min(max(v*factor, -1), 1)
+	}
+}
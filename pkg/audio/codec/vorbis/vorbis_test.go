@@ -0,0 +1,82 @@
+package vorbis
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func readTestFile(t *testing.T) []byte {
+	b, err := os.ReadFile("../../../../cmd/beep/resources/long_audio.ogg")
+	require.NoError(t, err)
+	return b
+}
+
+func TestDecoderSeekDurationMetadata(t *testing.T) {
+	data := readTestFile(t)
+	d, err := NewDecoder(bytes.NewReader(data))
+	require.NoError(t, err)
+	require.Equal(t, "Processed by SoX", d.Metadata()["COMMENT"])
+
+	format := d.Format()
+	frameSize := int(format.Channels) * 4
+	all, err := io.ReadAll(d)
+	require.NoError(t, err)
+	samples := len(all) / frameSize
+	require.Equal(t, time.Duration(samples)*time.Second/time.Duration(format.SampleRate), d.Duration())
+	require.Equal(t, d.Duration(), d.Position())
+
+	sample := samples / 3
+	require.NoError(t, d.Seek(time.Duration(sample)*time.Second/time.Duration(format.SampleRate)))
+	require.InDelta(t, float64(sample), float64(d.Position()*time.Duration(format.SampleRate)/time.Second), 1)
+	rest, err := io.ReadAll(d)
+	require.NoError(t, err)
+	require.InDelta(t, len(all)-sample*frameSize, len(rest), float64(frameSize))
+
+	// not seekable
+	d, err = NewDecoder(bytes.NewBuffer(data))
+	require.NoError(t, err)
+	require.Zero(t, d.Duration())
+	require.Error(t, d.SeekSample(0))
+}
+
+func TestReplayGain(t *testing.T) {
+	rg := ParseReplayGain(map[string]string{
+		"REPLAYGAIN_TRACK_GAIN": "-6.0206 dB",
+		"REPLAYGAIN_TRACK_PEAK": "0.9",
+		"REPLAYGAIN_ALBUM_GAIN": "+3 dB",
+		"REPLAYGAIN_ALBUM_PEAK": "0.95",
+	})
+	require.True(t, rg.HasTrack)
+	require.True(t, rg.HasAlbum)
+	require.InDelta(t, 0.5, rg.Factor(ReplayGainModeTrack, 0), 1e-4)
+	require.InDelta(t, 1/0.95, rg.Factor(ReplayGainModeAlbum, 0), 1e-9)
+	require.Equal(t, 1.0, rg.Factor(ReplayGainModeOff, 0))
+
+	rg = ParseReplayGain(map[string]string{"REPLAYGAIN_TRACK_GAIN": "-6.0206 dB"})
+	require.InDelta(t, 0.5, rg.Factor(ReplayGainModeAlbum, 0), 1e-4)
+	require.InDelta(t, 1, rg.Factor(ReplayGainModeAlbum, 6.0206), 1e-4)
+	require.Equal(t, 1.0, ReplayGain{}.Factor(ReplayGainModeTrack, 10))
+
+	data := readTestFile(t)
+	d, err := NewDecoder(bytes.NewReader(data))
+	require.NoError(t, err)
+	original, err := io.ReadAll(d)
+	require.NoError(t, err)
+
+	d, err = NewDecoder(bytes.NewReader(data))
+	require.NoError(t, err)
+	d.metadata["REPLAYGAIN_TRACK_GAIN"] = "-6.0206 dB"
+	d.SetReplayGain(ReplayGainModeTrack, 0)
+	scaled, err := io.ReadAll(d)
+	require.NoError(t, err)
+	require.Equal(t, len(original), len(scaled))
+	o, s := float32Slice(original), float32Slice(scaled)
+	for idx := range o {
+		require.InDelta(t, o[idx]/2, s[idx], 1e-4)
+	}
+}
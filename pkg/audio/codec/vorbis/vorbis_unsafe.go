@@ -1,4 +1,4 @@
-package audio
+package vorbis
 
 import (
 	"io"
@@ -22,9 +22,12 @@ func newReaderFromFloat32Reader(r float32Reader) readerFromFloat32Reader {
 }
 
 func (r readerFromFloat32Reader) Read(b []byte) (int, error) {
-	ptr := unsafe.SliceData(b)
-	f := unsafe.Slice((*float32)(unsafe.Pointer(ptr)), len(b)/4)
-	n, err := r.float32Reader.Read(f)
+	n, err := r.float32Reader.Read(float32Slice(b))
 	n *= int(unsafe.Sizeof(float32(0)))
 	return n, err
 }
+
+func float32Slice(b []byte) []float32 {
+	ptr := unsafe.SliceData(b)
+	return unsafe.Slice((*float32)(unsafe.Pointer(ptr)), len(b)/4)
+}
@@ -0,0 +1,92 @@
+package wav
+
+import (
+	"math/bits"
+	"strings"
+
+	"github.com/xaionaro-go/audio/pkg/audio/types"
+)
+
+// ChannelMask is the dwChannelMask of WAVE_FORMAT_EXTENSIBLE: the
+// speaker positions of the channels (in the order of the bits).
+type ChannelMask uint32
+
+const (
+	SpeakerFrontLeft = ChannelMask(1 << iota)
+	SpeakerFrontRight
+	SpeakerFrontCenter
+	SpeakerLowFrequency
+	SpeakerBackLeft
+	SpeakerBackRight
+	SpeakerFrontLeftOfCenter
+	SpeakerFrontRightOfCenter
+	SpeakerBackCenter
+	SpeakerSideLeft
+	SpeakerSideRight
+	SpeakerTopCenter
+	SpeakerTopFrontLeft
+	SpeakerTopFrontCenter
+	SpeakerTopFrontRight
+	SpeakerTopBackLeft
+	SpeakerTopBackCenter
+	SpeakerTopBackRight
+	endOfSpeaker
+)
+
+const (
+	ChannelMaskMono   = SpeakerFrontCenter
+	ChannelMaskStereo = SpeakerFrontLeft | SpeakerFrontRight
+	ChannelMaskQuad   = SpeakerFrontLeft | SpeakerFrontRight | SpeakerBackLeft | SpeakerBackRight
+	ChannelMask5_1    = SpeakerFrontLeft | SpeakerFrontRight | SpeakerFrontCenter | SpeakerLowFrequency | SpeakerBackLeft | SpeakerBackRight
+	ChannelMask7_1    = ChannelMask5_1 | SpeakerSideLeft | SpeakerSideRight
+)
+
+var speakerNames = []string{
+	"FL", "FR", "FC", "LFE", "BL", "BR", "FLC", "FRC", "BC",
+	"SL", "SR", "TC", "TFL", "TFC", "TFR", "TBL", "TBC", "TBR",
+}
+
+// DefaultChannelMask returns the conventional speaker layout for the amount of channels.
+func DefaultChannelMask(channels types.Channel) ChannelMask {
+	switch channels {
+	case 1:
+		return ChannelMaskMono
+	case 2:
+		return ChannelMaskStereo
+	case 3:
+		return ChannelMaskStereo | SpeakerFrontCenter
+	case 4:
+		return ChannelMaskQuad
+	case 5:
+		return ChannelMaskQuad | SpeakerFrontCenter
+	case 6:
+		return ChannelMask5_1
+	case 7:
+		return ChannelMask5_1&^(SpeakerBackLeft|SpeakerBackRight) | SpeakerBackCenter | SpeakerSideLeft | SpeakerSideRight
+	case 8:
+		return ChannelMask7_1
+	default:
+		return 0
+	}
+}
+
+// Channels returns the amount of speakers in the mask.
+func (m ChannelMask) Channels() types.Channel {
+	return types.Channel(bits.OnesCount32(uint32(m)))
+}
+
+func (m ChannelMask) String() string {
+	if m == 0 {
+		return "<unset>"
+	}
+	var names []string
+	for idx := range speakerNames {
+		if m&(1<<idx) != 0 {
+			names = append(names, speakerNames[idx])
+		}
+	}
+	if m&^(endOfSpeaker-1) != 0 {
+		names = append(names, "<reserved>")
+	}
+	return strings.Join(names, "|")
+}
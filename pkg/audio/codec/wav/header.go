@@ -0,0 +1,294 @@
+package wav
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"time"
+
+	"github.com/xaionaro-go/audio/pkg/audio/codec"
+	"github.com/xaionaro-go/audio/pkg/audio/codec/adpcm"
+	"github.com/xaionaro-go/audio/pkg/audio/codec/internal/container"
+	"github.com/xaionaro-go/audio/pkg/audio/types"
+)
+
+type FormatTag uint16
+
+const (
+	FormatTagPCM        = FormatTag(0x0001)
+	FormatTagIEEEFloat  = FormatTag(0x0003)
+	FormatTagALaw       = FormatTag(0x0006)
+	FormatTagMuLaw      = FormatTag(0x0007)
+	FormatTagIMAADPCM   = FormatTag(0x0011)
+	FormatTagExtensible = FormatTag(0xFFFE)
+)
+
+func (t FormatTag) String() string {
+	switch t {
+	case FormatTagPCM:
+		return "pcm"
+	case FormatTagIEEEFloat:
+		return "ieee_float"
+	case FormatTagALaw:
+		return "alaw"
+	case FormatTagMuLaw:
+		return "mulaw"
+	case FormatTagIMAADPCM:
+		return "ima_adpcm"
+	case FormatTagExtensible:
+		return "extensible"
+	default:
+		return fmt.Sprintf("<unexpected_value_%d>", uint16(t))
+	}
+}
+
+// subFormatGUIDSuffix is the common suffix of KSDATAFORMAT_SUBTYPE_*
+// GUIDs, the first two bytes of which are the format tag.
+var subFormatGUIDSuffix = []byte{
+	0x00, 0x00, 0x00, 0x00, 0x10, 0x00, 0x80, 0x00, 0x00, 0xAA, 0x00, 0x38, 0x9B, 0x71,
+}
+
+// UnknownDataSize is the value of Header.DataSize if the size is not
+// known (e.g. the file is being recorded, or the recording crashed).
+const UnknownDataSize = container.UnknownDataSize
+
+const (
+	chunkHeaderSize   = 8
+	ds64BodySize      = 28
+	fmtBodySize       = 16
+	fmtBodySizeFloat  = 18
+	fmtBodySizeADPCM  = 20
+	fmtBodySizeExtens = 40
+	factBodySize      = 4
+	sizePlaceholder   = 0xFFFFFFFF
+
+	// DefaultSamplesPerBlock is the amount of samples per channel in an IMA
+	// ADPCM block of 256 bytes per channel.
+	DefaultSamplesPerBlock = 505
+)
+
+type Header struct {
+	Channels   types.Channel
+	SampleRate types.SampleRate
+	PCMFormat  types.PCMFormat
+
+	// ChannelMask is written only in the extensible format. If it is
+	// zero, DefaultChannelMask is used.
+	ChannelMask ChannelMask
+
+	// ValidBitsPerSample is the amount of meaningful bits in a sample; zero means all of them.
+	ValidBitsPerSample uint16
+
+	// SamplesPerBlock is the amount of samples per channel in a block of
+	// PCMFormatIMAADPCM (has to be 8*N+1); zero means DefaultSamplesPerBlock.
+	SamplesPerBlock uint16
+
+	DataSize uint64
+}
+
+// Format returns the format of the data as it is read by Reader (and
+// written to Writer): PCMFormatIMAADPCM is decoded into PCMFormatS16LE.
+func (h Header) Format() codec.Format {
+	pcmFormat := h.PCMFormat
+	if pcmFormat == types.PCMFormatIMAADPCM {
+		pcmFormat = types.PCMFormatS16LE
+	}
+	return codec.Format{
+		Channels:   h.Channels,
+		SampleRate: h.SampleRate,
+		PCMFormat:  pcmFormat,
+	}
+}
+
+// BlockAlign returns the size of a frame, or of a whole block for PCMFormatIMAADPCM.
+func (h Header) BlockAlign() uint32 {
+	if h.PCMFormat == types.PCMFormatIMAADPCM {
+		return uint32(adpcm.BlockAlignFor(int(h.samplesPerBlock()), int(h.Channels)))
+	}
+	return uint32(h.Channels) * h.PCMFormat.Size()
+}
+
+func (h Header) samplesPerBlock() uint16 {
+	if h.PCMFormat != types.PCMFormatIMAADPCM {
+		return 1
+	}
+	if h.SamplesPerBlock == 0 {
+		return DefaultSamplesPerBlock
+	}
+	return h.SamplesPerBlock
+}
+
+// Duration returns the duration of the data, or zero if it is unknown.
+func (h Header) Duration() time.Duration {
+	if h.DataSize == UnknownDataSize || h.BlockAlign() == 0 || h.SampleRate == 0 {
+		return 0
+	}
+	frames := h.DataSize / uint64(h.BlockAlign()) * uint64(h.samplesPerBlock())
+	return time.Duration(frames) * time.Second / time.Duration(h.SampleRate)
+}
+
+func formatTagFor(pcmFormat types.PCMFormat) (FormatTag, error) {
+	switch pcmFormat {
+	case types.PCMFormatU8, types.PCMFormatS16LE, types.PCMFormatS24LE, types.PCMFormatS32LE:
+		return FormatTagPCM, nil
+	case types.PCMFormatFloat32LE, types.PCMFormatFloat64LE:
+		return FormatTagIEEEFloat, nil
+	case types.PCMFormatALaw:
+		return FormatTagALaw, nil
+	case types.PCMFormatMuLaw:
+		return FormatTagMuLaw, nil
+	case types.PCMFormatIMAADPCM:
+		return FormatTagIMAADPCM, nil
+	default:
+		return 0, container.ErrUnsupportedPCMFormat(pcmFormat, "WAV")
+	}
+}
+
+func pcmFormatFor(formatTag FormatTag, bitsPerSample uint16) (types.PCMFormat, error) {
+	switch formatTag {
+	case FormatTagPCM:
+		switch bitsPerSample {
+		case 8:
+			return types.PCMFormatU8, nil
+		case 16:
+			return types.PCMFormatS16LE, nil
+		case 24:
+			return types.PCMFormatS24LE, nil
+		case 32:
+			return types.PCMFormatS32LE, nil
+		}
+	case FormatTagIEEEFloat:
+		switch bitsPerSample {
+		case 32:
+			return types.PCMFormatFloat32LE, nil
+		case 64:
+			return types.PCMFormatFloat64LE, nil
+		}
+	case FormatTagALaw:
+		if bitsPerSample == 8 {
+			return types.PCMFormatALaw, nil
+		}
+	case FormatTagMuLaw:
+		if bitsPerSample == 8 {
+			return types.PCMFormatMuLaw, nil
+		}
+	case FormatTagIMAADPCM:
+		if bitsPerSample == 4 {
+			return types.PCMFormatIMAADPCM, nil
+		}
+	default:
+		return types.UndefinedPCMFormat, fmt.Errorf("unsupported format tag: 0x%04X", uint16(formatTag))
+	}
+	return types.UndefinedPCMFormat, fmt.Errorf("unsupported bits per sample for format %v: %d", formatTag, bitsPerSample)
+}
+
+// isExtensible follows the Microsoft recommendation: the extensible
+// format is required for more than 2 channels or more than 16 bits.
+// The compressed formats are always written as is.
+func (h Header) isExtensible(formatTag FormatTag) bool {
+	if formatTag == FormatTagIMAADPCM {
+		return false
+	}
+	return h.Channels > 2 ||
+		(formatTag == FormatTagPCM && h.PCMFormat.Size() > 2) ||
+		h.ChannelMask != 0 ||
+		h.ValidBitsPerSample != 0
+}
+
+func (h Header) marshalFmt() ([]byte, error) {
+	if h.Channels == 0 || h.SampleRate == 0 {
+		return nil, fmt.Errorf("invalid header: %#+v", h)
+	}
+	formatTag, err := formatTagFor(h.PCMFormat)
+	if err != nil {
+		return nil, err
+	}
+	if formatTag == FormatTagIMAADPCM && h.samplesPerBlock()%8 != 1 {
+		return nil, fmt.Errorf("the amount of samples per IMA ADPCM block has to be 8*N+1, but it is %d", h.samplesPerBlock())
+	}
+	bitsPerSample := uint16(h.PCMFormat.BitsPerSample())
+	extensible := h.isExtensible(formatTag)
+
+	b := make([]byte, 0, fmtBodySizeExtens)
+	if extensible {
+		b = binary.LittleEndian.AppendUint16(b, uint16(FormatTagExtensible))
+	} else {
+		b = binary.LittleEndian.AppendUint16(b, uint16(formatTag))
+	}
+	b = binary.LittleEndian.AppendUint16(b, uint16(h.Channels))
+	b = binary.LittleEndian.AppendUint32(b, uint32(h.SampleRate))
+	b = binary.LittleEndian.AppendUint32(b, uint32(uint64(h.SampleRate)*uint64(h.BlockAlign())/uint64(h.samplesPerBlock())))
+	b = binary.LittleEndian.AppendUint16(b, uint16(h.BlockAlign()))
+	b = binary.LittleEndian.AppendUint16(b, bitsPerSample)
+	switch {
+	case extensible:
+		validBits := h.ValidBitsPerSample
+		if validBits == 0 {
+			validBits = bitsPerSample
+		}
+		channelMask := h.ChannelMask
+		if channelMask == 0 {
+			channelMask = DefaultChannelMask(h.Channels)
+		}
+		b = binary.LittleEndian.AppendUint16(b, fmtBodySizeExtens-fmtBodySizeFloat)
+		b = binary.LittleEndian.AppendUint16(b, validBits)
+		b = binary.LittleEndian.AppendUint32(b, uint32(channelMask))
+		b = binary.LittleEndian.AppendUint16(b, uint16(formatTag))
+		b = append(b, subFormatGUIDSuffix...)
+	case formatTag == FormatTagIMAADPCM:
+		b = binary.LittleEndian.AppendUint16(b, fmtBodySizeADPCM-fmtBodySizeFloat)
+		b = binary.LittleEndian.AppendUint16(b, h.samplesPerBlock())
+	case formatTag != FormatTagPCM:
+		b = binary.LittleEndian.AppendUint16(b, 0)
+	}
+	return b, nil
+}
+
+func (h *Header) unmarshalFmt(b []byte) error {
+	if len(b) < fmtBodySize {
+		return fmt.Errorf("the 'fmt ' chunk is too short: %d < %d", len(b), fmtBodySize)
+	}
+	formatTag := FormatTag(binary.LittleEndian.Uint16(b[0:]))
+	h.Channels = types.Channel(binary.LittleEndian.Uint16(b[2:]))
+	h.SampleRate = types.SampleRate(binary.LittleEndian.Uint32(b[4:]))
+	blockAlign := binary.LittleEndian.Uint16(b[12:])
+	bitsPerSample := binary.LittleEndian.Uint16(b[14:])
+	h.ChannelMask = 0
+	h.ValidBitsPerSample = 0
+	h.SamplesPerBlock = 0
+	if formatTag == FormatTagExtensible {
+		if len(b) < fmtBodySizeExtens {
+			return fmt.Errorf("the extensible 'fmt ' chunk is too short: %d < %d", len(b), fmtBodySizeExtens)
+		}
+		h.ValidBitsPerSample = binary.LittleEndian.Uint16(b[18:])
+		h.ChannelMask = ChannelMask(binary.LittleEndian.Uint32(b[20:]))
+		subFormat := b[24:40]
+		if !bytes.Equal(subFormat[2:], subFormatGUIDSuffix) {
+			return fmt.Errorf("unsupported sub-format GUID: %X", subFormat)
+		}
+		formatTag = FormatTag(binary.LittleEndian.Uint16(subFormat))
+		if h.ValidBitsPerSample == bitsPerSample {
+			h.ValidBitsPerSample = 0
+		}
+	}
+	var err error
+	h.PCMFormat, err = pcmFormatFor(formatTag, bitsPerSample)
+	if err != nil {
+		return err
+	}
+	if h.Channels == 0 || h.SampleRate == 0 {
+		return fmt.Errorf("invalid format: %d channels, sample rate %d", h.Channels, h.SampleRate)
+	}
+	if formatTag == FormatTagIMAADPCM {
+		h.SamplesPerBlock = uint16(adpcm.SamplesPerBlock(int(blockAlign), int(h.Channels)))
+		if len(b) >= fmtBodySizeADPCM {
+			if samplesPerBlock := binary.LittleEndian.Uint16(b[18:]); samplesPerBlock != h.SamplesPerBlock {
+				return fmt.Errorf("unexpected samples per block: %d != %d", samplesPerBlock, h.SamplesPerBlock)
+			}
+		}
+	}
+	if uint32(blockAlign) != h.BlockAlign() {
+		return fmt.Errorf("unexpected block align: %d != %d", blockAlign, h.BlockAlign())
+	}
+	return nil
+}
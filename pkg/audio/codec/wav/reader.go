@@ -0,0 +1,258 @@
+package wav
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/xaionaro-go/audio/pkg/audio/codec"
+	"github.com/xaionaro-go/audio/pkg/audio/codec/adpcm"
+	"github.com/xaionaro-go/audio/pkg/audio/codec/internal/container"
+	"github.com/xaionaro-go/audio/pkg/audio/types"
+)
+
+const (
+	Priority = 100
+)
+
+func init() {
+	codec.RegisterDecoderFactory(Priority, DecoderFactory{})
+}
+
+type DecoderFactory struct{}
+
+var _ codec.DecoderFactory = DecoderFactory{}
+
+func (DecoderFactory) Name() string {
+	return "wav"
+}
+
+func (DecoderFactory) Sniff(head []byte) bool {
+	if len(head) < 12 || string(head[8:12]) != "WAVE" {
+		return false
+	}
+	switch string(head[:4]) {
+	case "RIFF", "RF64", "BW64":
+		return true
+	}
+	return false
+}
+
+func (DecoderFactory) NewDecoder(r io.Reader) (codec.Decoder, error) {
+	return NewReader(r)
+}
+
+// layout is where the fields to be patched are located (relative to the
+// beginning of the file).
+type layout struct {
+	ds64Offset int64 // the offset of a "ds64" (or a reserving "JUNK") chunk, or -1
+	factOffset int64 // the offset of the body of a "fact" chunk, or -1
+	dataOffset int64 // the offset of the data (right after the "data" chunk header)
+	riffSize   uint64
+
+	// factSamples is the amount of samples per channel according to the "fact" chunk.
+	factSamples uint32
+}
+
+// Reader reads the PCM data from a WAV (or RF64) file.
+type Reader struct {
+	reader io.Reader
+	header Header
+	layout layout
+	data   container.DataReader
+
+	// for PCMFormatIMAADPCM:
+	block         []byte
+	samples       []int16
+	decodedBuf    []byte
+	decoded       []byte
+	framesDecoded uint64
+}
+
+var _ codec.Decoder = (*Reader)(nil)
+
+func NewReader(r io.Reader) (*Reader, error) {
+	wr := &Reader{
+		reader: r,
+	}
+	if err := wr.readHeader(); err != nil {
+		return nil, err
+	}
+	wr.data = container.DataReader{Reader: r, Remaining: wr.header.DataSize}
+	return wr, nil
+}
+
+func (r *Reader) readHeader() error {
+	var offset int64
+	readFull := func(b []byte) error {
+		n, err := io.ReadFull(r.reader, b)
+		offset += int64(n)
+		return err
+	}
+
+	var riffHeader [12]byte
+	if err := readFull(riffHeader[:]); err != nil {
+		return fmt.Errorf("unable to read the RIFF header: %w", err)
+	}
+	if !(DecoderFactory{}).Sniff(riffHeader[:]) {
+		return fmt.Errorf("not a WAV file: %q", riffHeader[:])
+	}
+	isRF64 := string(riffHeader[:4]) != "RIFF"
+	r.layout.riffSize = uint64(binary.LittleEndian.Uint32(riffHeader[4:]))
+
+	r.layout.ds64Offset = -1
+	r.layout.factOffset = -1
+	var (
+		ds64DataSize uint64
+		fmtIsRead    bool
+		chunkHeader  [chunkHeaderSize]byte
+	)
+	for {
+		chunkOffset := offset
+		if err := readFull(chunkHeader[:]); err != nil {
+			if errors.Is(err, io.EOF) {
+				return fmt.Errorf("there is no 'data' chunk")
+			}
+			return fmt.Errorf("unable to read a chunk header: %w", err)
+		}
+		chunkID := string(chunkHeader[:4])
+		chunkSize := binary.LittleEndian.Uint32(chunkHeader[4:])
+
+		switch chunkID {
+		case "data":
+			if !fmtIsRead {
+				return fmt.Errorf("the 'data' chunk precedes the 'fmt ' chunk")
+			}
+			r.layout.dataOffset = offset
+			switch {
+			case isRF64 && chunkSize == sizePlaceholder:
+				r.header.DataSize = ds64DataSize
+			case chunkSize == sizePlaceholder:
+				r.header.DataSize = UnknownDataSize
+			default:
+				r.header.DataSize = uint64(chunkSize)
+			}
+			return nil
+		case "fmt ", "ds64", "JUNK", "fact":
+			if chunkSize > 1<<16 {
+				return fmt.Errorf("the '%s' chunk is too large: %d", chunkID, chunkSize)
+			}
+			body := make([]byte, chunkSize+chunkSize%2)
+			if err := readFull(body); err != nil {
+				return fmt.Errorf("unable to read the '%s' chunk: %w", chunkID, err)
+			}
+			body = body[:chunkSize]
+			switch chunkID {
+			case "fmt ":
+				if err := r.header.unmarshalFmt(body); err != nil {
+					return err
+				}
+				fmtIsRead = true
+			case "ds64":
+				if len(body) < ds64BodySize {
+					return fmt.Errorf("the 'ds64' chunk is too short: %d < %d", len(body), ds64BodySize)
+				}
+				r.layout.riffSize = binary.LittleEndian.Uint64(body[0:])
+				ds64DataSize = binary.LittleEndian.Uint64(body[8:])
+				r.layout.ds64Offset = chunkOffset
+			case "fact":
+				if len(body) >= factBodySize {
+					r.layout.factOffset = chunkOffset + chunkHeaderSize
+					r.layout.factSamples = binary.LittleEndian.Uint32(body)
+				}
+			case "JUNK":
+				if chunkOffset == 12 && len(body) >= ds64BodySize && bytes.Count(body, []byte{0}) == len(body) {
+					r.layout.ds64Offset = chunkOffset
+				}
+			}
+		default:
+			skip := int64(chunkSize) + int64(chunkSize%2)
+			n, err := io.CopyN(io.Discard, r.reader, skip)
+			offset += n
+			if err != nil {
+				return fmt.Errorf("unable to skip the '%s' chunk: %w", chunkID, err)
+			}
+		}
+	}
+}
+
+// Header returns the header of the file. Header().DataSize is UnknownDataSize if the
+// file was not finalized (see RepairFile).
+func (r *Reader) Header() Header {
+	return r.header
+}
+
+func (r *Reader) Format() codec.Format {
+	return r.header.Format()
+}
+
+// Read reads the PCM data (in Format().PCMFormat, which differs from
+// Header().PCMFormat only for IMA ADPCM).
+func (r *Reader) Read(b []byte) (int, error) {
+	if r.header.PCMFormat == types.PCMFormatIMAADPCM {
+		return r.readADPCM(b)
+	}
+	return r.readRaw(b)
+}
+
+func (r *Reader) readRaw(b []byte) (int, error) {
+	return r.data.Read(b)
+}
+
+func (r *Reader) readADPCM(b []byte) (int, error) {
+	for len(r.decoded) == 0 {
+		if err := r.decodeBlock(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(b, r.decoded)
+	r.decoded = r.decoded[n:]
+	return n, nil
+}
+
+type readerFunc func([]byte) (int, error)
+
+func (fn readerFunc) Read(b []byte) (int, error) {
+	return fn(b)
+}
+
+func (r *Reader) decodeBlock() error {
+	channels := int(r.header.Channels)
+	blockAlign := int(r.header.BlockAlign())
+	if cap(r.block) < blockAlign {
+		r.block = make([]byte, blockAlign)
+		r.samples = make([]int16, int(r.header.samplesPerBlock())*channels)
+		r.decodedBuf = make([]byte, len(r.samples)*2)
+	}
+	n, err := io.ReadFull(readerFunc(r.readRaw), r.block[:blockAlign])
+	switch {
+	case err == nil:
+	case errors.Is(err, io.ErrUnexpectedEOF) && n >= 4*channels:
+		// a truncated last block: decode the complete groups of samples
+		n -= (n - 4*channels) % (4 * channels)
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		return io.EOF
+	default:
+		return err
+	}
+
+	frames, err := adpcm.DecodeBlock(r.samples, r.block[:n], channels)
+	if err != nil {
+		return fmt.Errorf("unable to decode an IMA ADPCM block: %w", err)
+	}
+	if r.layout.factSamples > 0 {
+		// the last block is padded (zero means the file was not finalized)
+		frames = int(min(uint64(frames), max(uint64(r.layout.factSamples), r.framesDecoded)-r.framesDecoded))
+		if frames == 0 {
+			return io.EOF
+		}
+	}
+	r.framesDecoded += uint64(frames)
+	r.decoded = r.decodedBuf[:frames*channels*2]
+	for idx, sample := range r.samples[:frames*channels] {
+		binary.LittleEndian.PutUint16(r.decoded[idx*2:], uint16(sample))
+	}
+	return nil
+}
@@ -0,0 +1,202 @@
+package wav
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/xaionaro-go/audio/pkg/audio/codec"
+	"github.com/xaionaro-go/audio/pkg/audio/types"
+)
+
+func TestRoundTrip(t *testing.T) {
+	for _, tc := range []struct {
+		header              Header
+		expectedChannelMask ChannelMask
+	}{
+		{Header{Channels: 1, SampleRate: 8000, PCMFormat: types.PCMFormatU8}, 0},
+		{Header{Channels: 2, SampleRate: 44100, PCMFormat: types.PCMFormatS16LE}, 0},
+		{Header{Channels: 2, SampleRate: 48000, PCMFormat: types.PCMFormatS24LE}, ChannelMaskStereo},
+		{Header{Channels: 1, SampleRate: 96000, PCMFormat: types.PCMFormatS32LE, ValidBitsPerSample: 24}, ChannelMaskMono},
+		{Header{Channels: 2, SampleRate: 48000, PCMFormat: types.PCMFormatFloat32LE}, 0},
+		{Header{Channels: 6, SampleRate: 48000, PCMFormat: types.PCMFormatFloat64LE}, ChannelMask5_1},
+		{Header{Channels: 4, SampleRate: 48000, PCMFormat: types.PCMFormatS16LE, ChannelMask: SpeakerFrontLeft | SpeakerFrontRight | SpeakerSideLeft | SpeakerSideRight}, SpeakerFrontLeft | SpeakerFrontRight | SpeakerSideLeft | SpeakerSideRight},
+		{Header{Channels: 1, SampleRate: 8000, PCMFormat: types.PCMFormatMuLaw}, 0},
+		{Header{Channels: 2, SampleRate: 8000, PCMFormat: types.PCMFormatALaw}, 0},
+	} {
+		t.Run(fmt.Sprintf("%v_%dch", tc.header.PCMFormat, tc.header.Channels), func(t *testing.T) {
+			filePath := filepath.Join(t.TempDir(), "test.wav")
+			w, err := Create(filePath, tc.header)
+			require.NoError(t, err)
+			data := make([]byte, int(tc.header.BlockAlign())*3)
+			for idx := range data {
+				data[idx] = byte(idx)
+			}
+			_, err = w.Write(data)
+			require.NoError(t, err)
+			require.NoError(t, w.Close())
+
+			f, err := os.Open(filePath)
+			require.NoError(t, err)
+			defer f.Close()
+			decoder, err := codec.NewDecoder(f)
+			require.NoError(t, err)
+			r := decoder.(*Reader)
+
+			expected := tc.header
+			expected.DataSize = uint64(len(data))
+			expected.ChannelMask = tc.expectedChannelMask
+			require.Equal(t, expected, r.Header())
+			read, err := io.ReadAll(r)
+			require.NoError(t, err)
+			require.Equal(t, data, read)
+		})
+	}
+}
+
+func TestIMAADPCM(t *testing.T) {
+	for _, channels := range []types.Channel{1, 2} {
+		t.Run(fmt.Sprintf("%dch", channels), func(t *testing.T) {
+			header := Header{Channels: channels, SampleRate: 8000, PCMFormat: types.PCMFormatIMAADPCM, SamplesPerBlock: 249}
+			require.Equal(t, types.PCMFormatS16LE, header.Format().PCMFormat)
+			require.Equal(t, uint32(128)*uint32(channels), header.BlockAlign())
+
+			filePath := filepath.Join(t.TempDir(), "test.wav")
+			w, err := Create(filePath, header)
+			require.NoError(t, err)
+			// 2.5 blocks
+			frames := 249*2 + 125
+			data := make([]byte, frames*int(channels)*2)
+			for idx := range frames * int(channels) {
+				binary.LittleEndian.PutUint16(data[idx*2:], uint16(int16(8000*math.Sin(float64(idx)/20))))
+			}
+			_, err = w.Write(data[:3])
+			require.NoError(t, err)
+			_, err = w.Write(data[3:])
+			require.NoError(t, err)
+			require.NoError(t, w.Close())
+
+			f, err := os.Open(filePath)
+			require.NoError(t, err)
+			defer f.Close()
+			decoder, err := codec.NewDecoder(f)
+			require.NoError(t, err)
+			r := decoder.(*Reader)
+			expected := header
+			expected.DataSize = 3 * uint64(header.BlockAlign())
+			require.Equal(t, expected, r.Header())
+			require.Equal(t, time.Duration(249*3)*time.Second/8000, r.Header().Duration())
+
+			// the padding of the last block is trimmed according to the "fact" chunk
+			read, err := io.ReadAll(r)
+			require.NoError(t, err)
+			require.Len(t, read, len(data))
+			for idx := 100; idx < len(data)/2; idx++ {
+				require.InDelta(t, int16(binary.LittleEndian.Uint16(data[idx*2:])), int16(binary.LittleEndian.Uint16(read[idx*2:])), 500, "sample %d", idx)
+			}
+		})
+	}
+}
+
+func TestHeaderLayout(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, Header{Channels: 2, SampleRate: 48000, PCMFormat: types.PCMFormatS16LE})
+	require.NoError(t, err)
+	b := buf.Bytes()
+	require.Equal(t, "RIFF", string(b[:4]))
+	require.Equal(t, "JUNK", string(b[12:16]))
+	require.Equal(t, "fmt ", string(b[48:52]))
+	require.Equal(t, uint32(16), binary.LittleEndian.Uint32(b[52:]))
+	require.Equal(t, uint16(FormatTagPCM), binary.LittleEndian.Uint16(b[56:]))
+	require.Equal(t, "data", string(b[72:76]))
+
+	// not seekable, so the sizes are left unknown (which is a valid streaming WAV)
+	_, err = w.Write([]byte{1, 2, 3, 4})
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	r, err := NewReader(bytes.NewReader(buf.Bytes()))
+	require.NoError(t, err)
+	require.Equal(t, UnknownDataSize, r.Header().DataSize)
+	read, err := io.ReadAll(r)
+	require.NoError(t, err)
+	require.Equal(t, []byte{1, 2, 3, 4}, read)
+}
+
+func TestRF64(t *testing.T) {
+	oldThreshold := rf64Threshold
+	rf64Threshold = 100
+	defer func() { rf64Threshold = oldThreshold }()
+
+	filePath := filepath.Join(t.TempDir(), "test.wav")
+	w, err := Create(filePath, Header{Channels: 1, SampleRate: 8000, PCMFormat: types.PCMFormatS16LE})
+	require.NoError(t, err)
+	data := bytes.Repeat([]byte{1, 2}, 100)
+	_, err = w.Write(data)
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	b, err := os.ReadFile(filePath)
+	require.NoError(t, err)
+	require.Equal(t, "RF64", string(b[:4]))
+	require.Equal(t, "ds64", string(b[12:16]))
+
+	r, err := NewReader(bytes.NewReader(b))
+	require.NoError(t, err)
+	require.Equal(t, uint64(len(data)), r.Header().DataSize)
+	read, err := io.ReadAll(r)
+	require.NoError(t, err)
+	require.Equal(t, data, read)
+}
+
+func TestRepairFile(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "test.wav")
+	f, err := os.Create(filePath)
+	require.NoError(t, err)
+	w, err := NewWriter(f, Header{Channels: 2, SampleRate: 8000, PCMFormat: types.PCMFormatS16LE})
+	require.NoError(t, err)
+	_, err = w.Write(bytes.Repeat([]byte{1, 2, 3, 4}, 10))
+	require.NoError(t, err)
+	require.NoError(t, w.Flush())
+	_, err = w.Write([]byte{5, 6, 7, 8, 9})
+	require.NoError(t, err)
+	// "crash": the file is not finalized, and the last frame is incomplete
+	require.NoError(t, f.Close())
+
+	r, err := NewReader(bytes.NewReader(mustReadFile(t, filePath)))
+	require.NoError(t, err)
+	require.Equal(t, uint64(40), r.Header().DataSize)
+
+	require.NoError(t, RepairFile(filePath))
+	b := mustReadFile(t, filePath)
+	r, err = NewReader(bytes.NewReader(b))
+	require.NoError(t, err)
+	require.Equal(t, uint64(44), r.Header().DataSize)
+	require.Equal(t, uint32(len(b)-8), binary.LittleEndian.Uint32(b[4:]))
+	read, err := io.ReadAll(r)
+	require.NoError(t, err)
+	require.Equal(t, append(bytes.Repeat([]byte{1, 2, 3, 4}, 10), 5, 6, 7, 8), read)
+
+	require.NoError(t, RepairFile(filePath))
+	require.Equal(t, b, mustReadFile(t, filePath))
+}
+
+func mustReadFile(t *testing.T, filePath string) []byte {
+	b, err := os.ReadFile(filePath)
+	require.NoError(t, err)
+	return b
+}
+
+func TestChannelMask(t *testing.T) {
+	require.Equal(t, "FL|FR|FC|LFE|BL|BR", ChannelMask5_1.String())
+	require.Equal(t, types.Channel(8), ChannelMask7_1.Channels())
+	for channels := types.Channel(1); channels <= 8; channels++ {
+		require.Equal(t, channels, DefaultChannelMask(channels).Channels())
+	}
+}
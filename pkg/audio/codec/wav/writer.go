@@ -0,0 +1,352 @@
+package wav
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"os"
+
+	"github.com/hashicorp/go-multierror"
+	"github.com/xaionaro-go/audio/pkg/audio/codec/adpcm"
+	"github.com/xaionaro-go/audio/pkg/audio/codec/internal/container"
+	"github.com/xaionaro-go/audio/pkg/audio/types"
+)
+
+// rf64Threshold is the RIFF size above which the file is converted to RF64.
+var rf64Threshold uint64 = math.MaxUint32 - 1
+
+// Writer writes a WAV file. The sizes in the header are written as
+// "unknown" first (which is also the convention for endless streams), and
+// are patched on Flush and Close if the underlying writer is an
+// io.WriteSeeker. If the writing was interrupted before that, the file
+// may be fixed with RepairFile.
+//
+// A "JUNK" chunk is reserved for a "ds64" chunk, so that the file is
+// converted to RF64 on Close if it exceeds 4GiB.
+//
+// If the format is PCMFormatIMAADPCM, Write accepts PCMFormatS16LE (see
+// Header.Format) and encodes it block by block; the last incomplete block
+// is padded on Close.
+type Writer struct {
+	writer      io.Writer
+	header      Header
+	layout      layout
+	startOffset int64
+	seekable    bool
+	dataSize    uint64
+	closer      io.Closer
+	closed      bool
+
+	// for PCMFormatIMAADPCM:
+	adpcmStates  []adpcm.State
+	pending      []byte
+	samples      []int16
+	block        []byte
+	framesFilled uint64
+}
+
+var _ io.WriteCloser = (*Writer)(nil)
+
+// NewWriter writes the header into "w" and returns a writer of the data.
+// Close does not close "w".
+func NewWriter(w io.Writer, header Header) (*Writer, error) {
+	fmtBody, err := header.marshalFmt()
+	if err != nil {
+		return nil, err
+	}
+	header.DataSize = UnknownDataSize
+	if header.PCMFormat == types.PCMFormatIMAADPCM {
+		header.SamplesPerBlock = header.samplesPerBlock()
+	}
+
+	b := make([]byte, 0, 12+chunkHeaderSize+ds64BodySize+chunkHeaderSize+len(fmtBody)+chunkHeaderSize+factBodySize+chunkHeaderSize)
+	b = append(b, "RIFF"...)
+	b = binary.LittleEndian.AppendUint32(b, sizePlaceholder)
+	b = append(b, "WAVE"...)
+	ds64Offset := int64(len(b))
+	b = append(b, "JUNK"...)
+	b = binary.LittleEndian.AppendUint32(b, ds64BodySize)
+	b = append(b, make([]byte, ds64BodySize)...)
+	b = append(b, "fmt "...)
+	b = binary.LittleEndian.AppendUint32(b, uint32(len(fmtBody)))
+	b = append(b, fmtBody...)
+	factOffset := int64(-1)
+	if !header.PCMFormat.IsLinear() {
+		// the "fact" chunk is required for the non-PCM formats
+		b = append(b, "fact"...)
+		b = binary.LittleEndian.AppendUint32(b, factBodySize)
+		factOffset = int64(len(b))
+		b = binary.LittleEndian.AppendUint32(b, 0)
+	}
+	b = append(b, "data"...)
+	b = binary.LittleEndian.AppendUint32(b, sizePlaceholder)
+
+	wr := &Writer{
+		writer: w,
+		header: header,
+		layout: layout{
+			ds64Offset: ds64Offset,
+			factOffset: factOffset,
+			dataOffset: int64(len(b)),
+		},
+	}
+	if header.PCMFormat == types.PCMFormatIMAADPCM {
+		wr.adpcmStates = make([]adpcm.State, header.Channels)
+		wr.block = make([]byte, header.BlockAlign())
+		wr.samples = make([]int16, 0, int(header.SamplesPerBlock)*int(header.Channels))
+	}
+	if seeker, ok := w.(io.Seeker); ok {
+		if offset, err := seeker.Seek(0, io.SeekCurrent); err == nil {
+			wr.startOffset = offset
+			wr.seekable = true
+		}
+	}
+	if err := container.WriteAll(w, b); err != nil {
+		return nil, fmt.Errorf("unable to write the header: %w", err)
+	}
+	return wr, nil
+}
+
+// Create creates the file and returns a writer, which closes the file on Close.
+func Create(filePath string, header Header) (*Writer, error) {
+	f, err := os.Create(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create '%s': %w", filePath, err)
+	}
+	w, err := NewWriter(f, header)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	w.closer = f
+	return w, nil
+}
+
+func (w *Writer) Header() Header {
+	return w.header
+}
+
+// DataSize returns the amount of bytes of the data chunk written so far.
+func (w *Writer) DataSize() uint64 {
+	return w.dataSize
+}
+
+func (w *Writer) Write(b []byte) (int, error) {
+	if w.closed {
+		return 0, fmt.Errorf("the writer is closed")
+	}
+	if w.adpcmStates != nil {
+		return w.writeADPCM(b)
+	}
+	n, err := w.writer.Write(b)
+	w.dataSize += uint64(n)
+	return n, err
+}
+
+func (w *Writer) writeADPCM(b []byte) (int, error) {
+	in := b
+	if len(w.pending) > 0 {
+		in = append(w.pending, b...)
+	}
+	blockSamples := cap(w.samples)
+	for ; len(in) >= 2; in = in[2:] {
+		w.samples = append(w.samples, int16(binary.LittleEndian.Uint16(in)))
+		if len(w.samples) == blockSamples {
+			if err := w.writeBlock(); err != nil {
+				return 0, err
+			}
+		}
+	}
+	w.pending = append(w.pending[:0], in...)
+	return len(b), nil
+}
+
+func (w *Writer) writeBlock() error {
+	adpcm.EncodeBlock(w.block, w.samples, int(w.header.Channels), w.adpcmStates)
+	w.framesFilled += uint64(len(w.samples) / int(w.header.Channels))
+	w.samples = w.samples[:0]
+	if err := container.WriteAll(w.writer, w.block); err != nil {
+		return fmt.Errorf("unable to write an IMA ADPCM block: %w", err)
+	}
+	w.dataSize += uint64(len(w.block))
+	return nil
+}
+
+// Flush patches the sizes in the header to match the data written so
+// far (if the underlying writer is seekable).
+func (w *Writer) Flush() error {
+	if !w.seekable {
+		return nil
+	}
+	ws := w.writer.(io.WriteSeeker)
+	if w.layout.factOffset >= 0 {
+		if err := patchFact(ws, w.startOffset, w.layout, w.frames()); err != nil {
+			return err
+		}
+	}
+	return patchSizes(ws, w.startOffset, w.layout, w.header.BlockAlign(), w.dataSize)
+}
+
+// frames returns the amount of samples per channel written so far.
+func (w *Writer) frames() uint64 {
+	if w.adpcmStates != nil {
+		return w.framesFilled
+	}
+	return w.dataSize / uint64(w.header.BlockAlign())
+}
+
+// Close writes the padding byte (if required) and patches the sizes in the header.
+func (w *Writer) Close() (_err error) {
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+	var mErr *multierror.Error
+	defer func() {
+		if w.closer != nil {
+			if err := w.closer.Close(); err != nil {
+				mErr = multierror.Append(mErr, err)
+			}
+		}
+		_err = mErr.ErrorOrNil()
+	}()
+
+	if len(w.samples) > 0 {
+		if err := w.writeBlock(); err != nil {
+			mErr = multierror.Append(mErr, err)
+			return
+		}
+	}
+	if w.dataSize%2 != 0 {
+		if err := container.WriteAll(w.writer, []byte{0}); err != nil {
+			mErr = multierror.Append(mErr, fmt.Errorf("unable to write the padding byte: %w", err))
+			return
+		}
+	}
+	if err := w.Flush(); err != nil {
+		mErr = multierror.Append(mErr, err)
+	}
+	return
+}
+
+// patchSizes writes the sizes corresponding to dataSize into the header
+// and moves the position to the end of the data.
+func patchSizes(
+	ws io.WriteSeeker,
+	startOffset int64,
+	l layout,
+	blockAlign uint32,
+	dataSize uint64,
+) error {
+	riffSize := uint64(l.dataOffset) - 8 + dataSize + dataSize%2
+	writeAt := func(offset int64, b []byte) error {
+		if _, err := ws.Seek(startOffset+offset, io.SeekStart); err != nil {
+			return fmt.Errorf("unable to seek: %w", err)
+		}
+		return container.WriteAll(ws, b)
+	}
+
+	var err error
+	if riffSize <= rf64Threshold {
+		err = writeAt(0, binary.LittleEndian.AppendUint32([]byte("RIFF"), uint32(riffSize)))
+		if err == nil {
+			err = writeAt(l.dataOffset-4, binary.LittleEndian.AppendUint32(nil, uint32(dataSize)))
+		}
+	} else {
+		if l.ds64Offset < 0 {
+			return fmt.Errorf("the data is too large for WAV (%d bytes), and there is no space reserved for the RF64 header", dataSize)
+		}
+		ds64 := append([]byte{}, "ds64"...)
+		ds64 = binary.LittleEndian.AppendUint32(ds64, ds64BodySize)
+		ds64 = binary.LittleEndian.AppendUint64(ds64, riffSize)
+		ds64 = binary.LittleEndian.AppendUint64(ds64, dataSize)
+		var sampleCount uint64
+		if blockAlign != 0 {
+			sampleCount = dataSize / uint64(blockAlign)
+		}
+		ds64 = binary.LittleEndian.AppendUint64(ds64, sampleCount)
+		ds64 = binary.LittleEndian.AppendUint32(ds64, 0) // table length
+		err = writeAt(0, binary.LittleEndian.AppendUint32([]byte("RF64"), sizePlaceholder))
+		if err == nil {
+			err = writeAt(l.ds64Offset, ds64)
+		}
+		if err == nil {
+			err = writeAt(l.dataOffset-4, binary.LittleEndian.AppendUint32(nil, sizePlaceholder))
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("unable to patch the header: %w", err)
+	}
+	if _, err := ws.Seek(startOffset+l.dataOffset+int64(dataSize+dataSize%2), io.SeekStart); err != nil {
+		return fmt.Errorf("unable to seek to the end of the data: %w", err)
+	}
+	return nil
+}
+
+// patchFact writes the amount of samples per channel into the "fact" chunk.
+func patchFact(ws io.WriteSeeker, startOffset int64, l layout, frames uint64) error {
+	if _, err := ws.Seek(startOffset+l.factOffset, io.SeekStart); err != nil {
+		return fmt.Errorf("unable to seek: %w", err)
+	}
+	if err := container.WriteAll(ws, binary.LittleEndian.AppendUint32(nil, uint32(min(frames, math.MaxUint32)))); err != nil {
+		return fmt.Errorf("unable to patch the 'fact' chunk: %w", err)
+	}
+	return nil
+}
+
+// RepairFile fixes the sizes in the header of a WAV file which was not
+// finalized (e.g. the recording process crashed): everything after the
+// beginning of the data is considered to be the data, except for an
+// incomplete trailing frame, which is cut off.
+func RepairFile(filePath string) (_err error) {
+	f, err := os.OpenFile(filePath, os.O_RDWR, 0)
+	if err != nil {
+		return fmt.Errorf("unable to open '%s': %w", filePath, err)
+	}
+	defer func() {
+		if err := f.Close(); err != nil && _err == nil {
+			_err = err
+		}
+	}()
+
+	r, err := NewReader(f)
+	if err != nil {
+		return fmt.Errorf("unable to parse the header of '%s': %w", filePath, err)
+	}
+	stat, err := f.Stat()
+	if err != nil {
+		return fmt.Errorf("unable to stat '%s': %w", filePath, err)
+	}
+	if stat.Size() < r.layout.dataOffset {
+		return fmt.Errorf("the file is shorter than its header")
+	}
+	if r.layout.riffSize+8 == uint64(stat.Size()) {
+		// the file is consistent (there might be other chunks after the data)
+		return nil
+	}
+	blockAlign := r.header.BlockAlign()
+	dataSize := uint64(stat.Size() - r.layout.dataOffset)
+	dataSize -= dataSize % uint64(blockAlign)
+
+	if r.layout.factOffset >= 0 {
+		frames := dataSize / uint64(blockAlign) * uint64(r.header.samplesPerBlock())
+		if err := patchFact(f, 0, r.layout, frames); err != nil {
+			return err
+		}
+	}
+	if err := patchSizes(f, 0, r.layout, blockAlign, dataSize); err != nil {
+		return err
+	}
+	end := r.layout.dataOffset + int64(dataSize)
+	if dataSize%2 != 0 {
+		if _, err := f.WriteAt([]byte{0}, end); err != nil {
+			return fmt.Errorf("unable to write the padding byte: %w", err)
+		}
+		end++
+	}
+	if err := f.Truncate(end); err != nil {
+		return fmt.Errorf("unable to truncate '%s': %w", filePath, err)
+	}
+	return nil
+}
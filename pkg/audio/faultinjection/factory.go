@@ -0,0 +1,53 @@
+package faultinjection
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/xaionaro-go/audio/pkg/audio/registry"
+	"github.com/xaionaro-go/audio/pkg/audio/types"
+)
+
+// PlayerPCMFactory wraps the players created by another factory, so
+// it could be registered via registry.RegisterPlayerFactory.
+type PlayerPCMFactory struct {
+	Backend  registry.PlayerPCMFactory
+	Schedule Schedule
+	Period   time.Duration
+}
+
+var _ registry.PlayerPCMFactory = (*PlayerPCMFactory)(nil)
+
+func (f *PlayerPCMFactory) NewPlayerPCM() (types.PlayerPCM, error) {
+	backend, err := f.Backend.NewPlayerPCM()
+	if err != nil {
+		return nil, fmt.Errorf("unable to initialize the backend %T: %w", f.Backend, err)
+	}
+	p := NewPlayerPCM(backend, f.Schedule)
+	if f.Period > 0 {
+		p.Period = f.Period
+	}
+	return p, nil
+}
+
+// RecorderPCMFactory wraps the recorders created by another factory, so
+// it could be registered via registry.RegisterRecorderFactory.
+type RecorderPCMFactory struct {
+	Backend  registry.RecorderPCMFactory
+	Schedule Schedule
+	Period   time.Duration
+}
+
+var _ registry.RecorderPCMFactory = (*RecorderPCMFactory)(nil)
+
+func (f *RecorderPCMFactory) NewRecorderPCM() (types.RecorderPCM, error) {
+	backend, err := f.Backend.NewRecorderPCM()
+	if err != nil {
+		return nil, fmt.Errorf("unable to initialize the backend %T: %w", f.Backend, err)
+	}
+	r := NewRecorderPCM(backend, f.Schedule)
+	if f.Period > 0 {
+		r.Period = f.Period
+	}
+	return r, nil
+}
@@ -0,0 +1,111 @@
+package faultinjection
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	ErrUnderrun     = errors.New("injected underrun")
+	ErrOverrun      = errors.New("injected overrun")
+	ErrPingFailure  = errors.New("injected ping failure")
+	ErrDisconnected = errors.New("injected disconnect")
+)
+
+type FaultKind uint
+
+const (
+	UndefinedFaultKind = FaultKind(iota)
+
+	// FaultKindUnderrun inserts a period of silence before the period
+	// (the data is delayed, not lost) and is reported as ErrUnderrun.
+	FaultKindUnderrun
+
+	// FaultKindOverrun discards the period and is reported as ErrOverrun.
+	FaultKindOverrun
+
+	// FaultKindDropPeriod silently discards the period.
+	FaultKindDropPeriod
+
+	// FaultKindDuplicatePeriod silently passes the period twice.
+	FaultKindDuplicatePeriod
+
+	// FaultKindClockDrift makes the stream drop (positive Fault.DriftPPM)
+	// or repeat (negative Fault.DriftPPM) frames from this period on,
+	// as if the device clock ran at a different speed.
+	FaultKindClockDrift
+
+	// FaultKindLatencySpike delays the period by Fault.Duration.
+	FaultKindLatencySpike
+
+	// FaultKindPingFailure makes Ping return ErrPingFailure.
+	FaultKindPingFailure
+
+	// FaultKindDisconnect breaks the stream with ErrDisconnected; after
+	// that the player/recorder fails Ping and refuses new streams.
+	FaultKindDisconnect
+
+	EndOfFaultKind
+)
+
+func (k FaultKind) String() string {
+	switch k {
+	case UndefinedFaultKind:
+		return "<undefined>"
+	case FaultKindUnderrun:
+		return "underrun"
+	case FaultKindOverrun:
+		return "overrun"
+	case FaultKindDropPeriod:
+		return "drop_period"
+	case FaultKindDuplicatePeriod:
+		return "duplicate_period"
+	case FaultKindClockDrift:
+		return "clock_drift"
+	case FaultKindLatencySpike:
+		return "latency_spike"
+	case FaultKindPingFailure:
+		return "ping_failure"
+	case FaultKindDisconnect:
+		return "disconnect"
+	default:
+		return fmt.Sprintf("<unexpected_value_%d>", uint(k))
+	}
+}
+
+type Fault struct {
+	Kind FaultKind
+
+	// Duration is used by FaultKindLatencySpike.
+	Duration time.Duration
+
+	// DriftPPM is used by FaultKindClockDrift.
+	DriftPPM float64
+}
+
+// Op is the kind of operation a fault is injected into.
+type Op uint
+
+const (
+	UndefinedOp = Op(iota)
+	OpPing
+	OpPlayPeriod
+	OpRecordPeriod
+	EndOfOp
+)
+
+func (op Op) String() string {
+	switch op {
+	case UndefinedOp:
+		return "<undefined>"
+	case OpPing:
+		return "ping"
+	case OpPlayPeriod:
+		return "play_period"
+	case OpRecordPeriod:
+		return "record_period"
+	default:
+		return fmt.Sprintf("<unexpected_value_%d>", uint(op))
+	}
+}
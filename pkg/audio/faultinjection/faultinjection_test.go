@@ -0,0 +1,149 @@
+package faultinjection
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/xaionaro-go/audio/pkg/audio"
+	"github.com/xaionaro-go/audio/pkg/audio/backends/virtual"
+	"github.com/xaionaro-go/audio/pkg/audio/types"
+)
+
+func newTestDevice(t *testing.T) *virtual.Device {
+	d, err := virtual.NewDevice(context.Background(), t.Name(), virtual.DeviceConfig{
+		SampleRate:      1000,
+		Channels:        1,
+		Period:          time.Millisecond,
+		BlockOnUnderrun: true,
+	})
+	require.NoError(t, err)
+	t.Cleanup(func() { d.Close() })
+	return d
+}
+
+func TestPlayerPCMFaults(t *testing.T) {
+	ctx := context.Background()
+	d := newTestDevice(t)
+
+	var recorded bytes.Buffer
+	_, err := virtual.NewRecorderPCMForDevice(d).RecordPCM(ctx, 1000, 1, types.PCMFormatU8, &recorded)
+	require.NoError(t, err)
+
+	player := NewPlayerPCM(virtual.NewPlayerPCMForDevice(d), ScriptedSchedule{
+		{Op: OpPlayPeriod, Index: 1, Fault: Fault{Kind: FaultKindDropPeriod}},
+		{Op: OpPlayPeriod, Index: 2, Fault: Fault{Kind: FaultKindDuplicatePeriod}},
+		{Op: OpPlayPeriod, Index: 3, Fault: Fault{Kind: FaultKindUnderrun}},
+	})
+	player.Period = 2 * time.Millisecond
+	stream, err := player.PlayPCM(ctx, 1000, 1, types.PCMFormatU8, time.Second, bytes.NewReader([]byte{
+		1, 2, 3, 4, 5, 6, 7, 8, 9,
+	}))
+	require.NoError(t, err)
+
+	require.NoError(t, d.Advance(20*time.Millisecond))
+	err = stream.Drain()
+	require.ErrorIs(t, err, ErrUnderrun)
+	require.Equal(t, []byte{
+		1, 2,
+		5, 6, 5, 6,
+		0x80, 0x80, 7, 8,
+		9,
+	}, bytes.TrimRight(recorded.Bytes(), "\x80"))
+	require.Equal(t, Stats{
+		Underruns:         1,
+		DroppedPeriods:    1,
+		DuplicatedPeriods: 1,
+	}, player.Stats())
+}
+
+func TestPlayerPCMDisconnect(t *testing.T) {
+	ctx := context.Background()
+	d := newTestDevice(t)
+
+	player := NewPlayerPCM(virtual.NewPlayerPCMForDevice(d), ScriptedSchedule{
+		{Op: OpPlayPeriod, Index: 2, Fault: Fault{Kind: FaultKindDisconnect}},
+	})
+	player.Period = time.Millisecond
+	require.NoError(t, player.Ping(ctx))
+	stream, err := player.PlayPCM(ctx, 1000, 1, types.PCMFormatU8, time.Second, bytes.NewReader(make([]byte, 10)))
+	require.NoError(t, err)
+
+	require.NoError(t, d.Advance(20*time.Millisecond))
+	require.ErrorIs(t, stream.Drain(), ErrDisconnected)
+	require.ErrorIs(t, player.Ping(ctx), ErrDisconnected)
+	_, err = player.PlayPCM(ctx, 1000, 1, types.PCMFormatU8, time.Second, bytes.NewReader(nil))
+	require.ErrorIs(t, err, ErrDisconnected)
+}
+
+func TestRecorderPCMFaults(t *testing.T) {
+	ctx := context.Background()
+	d := newTestDevice(t)
+
+	recorder := NewRecorderPCM(virtual.NewRecorderPCMForDevice(d), ScriptedSchedule{
+		{Op: OpRecordPeriod, Index: 1, Fault: Fault{Kind: FaultKindOverrun}},
+		{Op: OpRecordPeriod, Index: 2, Fault: Fault{Kind: FaultKindClockDrift, DriftPPM: -500000}},
+	})
+	recorder.Period = 2 * time.Millisecond
+	var recorded bytes.Buffer
+	recordStream, err := recorder.RecordPCM(ctx, 1000, 1, types.PCMFormatU8, &recorded)
+	require.NoError(t, err)
+
+	_, err = virtual.NewPlayerPCMForDevice(d).PlayPCM(ctx, 1000, 1, types.PCMFormatU8, time.Second, bytes.NewReader([]byte{
+		1, 2, 3, 4, 5, 6, 7, 8, 9,
+	}))
+	require.NoError(t, err)
+
+	require.NoError(t, d.Advance(9*time.Millisecond))
+	require.NoError(t, recordStream.Close())
+	require.ErrorIs(t, recordStream.(*RecordStream).Err(), ErrOverrun)
+	require.Equal(t, []byte{1, 2, 5, 6, 6, 7, 8, 8, 9}, recorded.Bytes())
+}
+
+type dummyPlayerFactory struct{}
+
+func (dummyPlayerFactory) NewPlayerPCM() (types.PlayerPCM, error) {
+	return audio.PlayerPCMDummy{}, nil
+}
+
+func TestPingFailureWithDummy(t *testing.T) {
+	ctx := context.Background()
+	f := &PlayerPCMFactory{
+		Backend: dummyPlayerFactory{},
+		Schedule: ScriptedSchedule{
+			{Op: OpPing, Index: 0, Fault: Fault{Kind: FaultKindPingFailure}},
+		},
+	}
+	player, err := f.NewPlayerPCM()
+	require.NoError(t, err)
+	require.ErrorIs(t, player.Ping(ctx), ErrPingFailure)
+	require.NoError(t, player.Ping(ctx))
+
+	stream, err := player.PlayPCM(ctx, 48000, 2, types.PCMFormatFloat32LE, time.Second, bytes.NewReader(nil))
+	require.NoError(t, err)
+	require.NoError(t, stream.Drain())
+}
+
+func TestRandomScheduleIsDeterministic(t *testing.T) {
+	probabilities := map[FaultKind]float64{
+		FaultKindDropPeriod:   0.3,
+		FaultKindLatencySpike: 0.2,
+		FaultKindPingFailure:  0.5,
+	}
+	a := NewRandomSchedule(1, probabilities)
+	b := NewRandomSchedule(1, probabilities)
+	var faultsCount int
+	for idx := uint64(0); idx < 100; idx++ {
+		for _, op := range []Op{OpPing, OpPlayPeriod} {
+			faultsA := a.FaultsAt(op, idx)
+			require.Equal(t, faultsA, b.FaultsAt(op, idx))
+			for _, f := range faultsA {
+				require.True(t, f.Kind.applicableTo(op), f.Kind)
+			}
+			faultsCount += len(faultsA)
+		}
+	}
+	require.NotZero(t, faultsCount)
+}
@@ -0,0 +1,266 @@
+package faultinjection
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/go-multierror"
+	"github.com/xaionaro-go/audio/pkg/audio/types"
+)
+
+const (
+	DefaultPeriod = 10 * time.Millisecond
+)
+
+type Stats struct {
+	Underruns         uint64
+	Overruns          uint64
+	DroppedPeriods    uint64
+	DuplicatedPeriods uint64
+	DroppedFrames     uint64
+	DuplicatedFrames  uint64
+	LatencySpikes     uint64
+	PingFailures      uint64
+	Disconnects       uint64
+}
+
+// injector is the state shared by a player/recorder and its streams.
+type injector struct {
+	Schedule Schedule
+	Period   time.Duration
+
+	locker       sync.Mutex
+	stats        Stats
+	pingCount    uint64
+	disconnected bool
+}
+
+func newInjector(schedule Schedule) *injector {
+	return &injector{
+		Schedule: schedule,
+		Period:   DefaultPeriod,
+	}
+}
+
+// Stats returns the counters of the injected faults.
+func (inj *injector) Stats() Stats {
+	inj.locker.Lock()
+	defer inj.locker.Unlock()
+	return inj.stats
+}
+
+// IsDisconnected returns true if a FaultKindDisconnect was injected.
+func (inj *injector) IsDisconnected() bool {
+	inj.locker.Lock()
+	defer inj.locker.Unlock()
+	return inj.disconnected
+}
+
+func (inj *injector) ping() error {
+	inj.locker.Lock()
+	idx := inj.pingCount
+	inj.pingCount++
+	disconnected := inj.disconnected
+	inj.locker.Unlock()
+	if disconnected {
+		return ErrDisconnected
+	}
+
+	for _, f := range inj.Schedule.FaultsAt(OpPing, idx) {
+		switch f.Kind {
+		case FaultKindPingFailure:
+			inj.locker.Lock()
+			inj.stats.PingFailures++
+			inj.locker.Unlock()
+			return ErrPingFailure
+		case FaultKindDisconnect:
+			inj.disconnect()
+			return ErrDisconnected
+		}
+	}
+	return nil
+}
+
+func (inj *injector) disconnect() {
+	inj.locker.Lock()
+	defer inj.locker.Unlock()
+	if !inj.disconnected {
+		inj.stats.Disconnects++
+	}
+	inj.disconnected = true
+}
+
+func (inj *injector) checkConnected() error {
+	if inj.IsDisconnected() {
+		return ErrDisconnected
+	}
+	return nil
+}
+
+func (inj *injector) newPeriodProcessor(
+	op Op,
+	sampleRate types.SampleRate,
+	channels types.Channel,
+	format types.PCMFormat,
+) (*periodProcessor, error) {
+	frameSize := int(channels) * int(format.Size())
+	if channels == 0 || format.Size() == 0 || format.Size() > 8 {
+		return nil, fmt.Errorf("unsupported stream parameters: %d channels, format %v", channels, format)
+	}
+	periodFrames := int(uint64(inj.Period) * uint64(sampleRate) / uint64(time.Second))
+	if periodFrames < 1 {
+		periodFrames = 1
+	}
+	var silence byte
+	if format == types.PCMFormatU8 {
+		silence = 0x80
+	}
+	return &periodProcessor{
+		injector:   inj,
+		op:         op,
+		frameSize:  frameSize,
+		periodSize: periodFrames * frameSize,
+		silence:    silence,
+	}, nil
+}
+
+// periodProcessor applies the scheduled faults to the periods of a single stream.
+type periodProcessor struct {
+	*injector
+	op         Op
+	frameSize  int
+	periodSize int
+	silence    byte
+
+	index        uint64
+	driftPPM     float64
+	driftAcc     float64
+	underrun     bool
+	overrun      bool
+	disconnected bool
+}
+
+// process appends the (possibly corrupted) period to "out".
+func (p *periodProcessor) process(
+	ctx context.Context,
+	out []byte,
+	period []byte,
+) ([]byte, error) {
+	if err := p.checkConnected(); err != nil {
+		p.setDisconnected()
+		return out, err
+	}
+
+	faults := p.Schedule.FaultsAt(p.op, p.index)
+	p.index++
+
+	repeats := 1
+	var spike time.Duration
+	for _, f := range faults {
+		switch f.Kind {
+		case FaultKindUnderrun:
+			p.count(func(s *Stats) {
+				s.Underruns++
+				p.underrun = true
+			})
+			for range period {
+				out = append(out, p.silence)
+			}
+		case FaultKindOverrun:
+			p.count(func(s *Stats) {
+				s.Overruns++
+				p.overrun = true
+			})
+			repeats = 0
+		case FaultKindDropPeriod:
+			p.count(func(s *Stats) { s.DroppedPeriods++ })
+			repeats = 0
+		case FaultKindDuplicatePeriod:
+			p.count(func(s *Stats) { s.DuplicatedPeriods++ })
+			if repeats > 0 {
+				repeats++
+			}
+		case FaultKindClockDrift:
+			p.driftPPM = f.DriftPPM
+		case FaultKindLatencySpike:
+			p.count(func(s *Stats) { s.LatencySpikes++ })
+			spike += f.Duration
+		case FaultKindDisconnect:
+			p.disconnect()
+			p.setDisconnected()
+			return out, ErrDisconnected
+		}
+	}
+
+	if spike > 0 {
+		t := time.NewTimer(spike)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return out, ctx.Err()
+		case <-t.C:
+		}
+	}
+
+	period = p.applyDrift(period)
+	for ; repeats > 0; repeats-- {
+		out = append(out, period...)
+	}
+	return out, nil
+}
+
+func (p *periodProcessor) applyDrift(period []byte) []byte {
+	if p.driftPPM == 0 {
+		return period
+	}
+	frames := len(period) / p.frameSize
+	p.driftAcc += float64(frames) * p.driftPPM / 1000000
+	for p.driftAcc >= 1 && len(period) >= p.frameSize {
+		period = period[:len(period)-p.frameSize]
+		p.driftAcc--
+		p.count(func(s *Stats) { s.DroppedFrames++ })
+	}
+	for p.driftAcc <= -1 && len(period) >= p.frameSize {
+		period = append(period[:len(period):len(period)], period[len(period)-p.frameSize:]...)
+		p.driftAcc++
+		p.count(func(s *Stats) { s.DuplicatedFrames++ })
+	}
+	return period
+}
+
+func (p *periodProcessor) setDisconnected() {
+	p.locker.Lock()
+	defer p.locker.Unlock()
+	p.disconnected = true
+}
+
+func (p *periodProcessor) count(fn func(*Stats)) {
+	p.locker.Lock()
+	defer p.locker.Unlock()
+	fn(&p.stats)
+}
+
+// result merges the error returned by the backend with the injected
+// conditions which real devices would have reported.
+func (p *periodProcessor) result(backendErr error) error {
+	p.locker.Lock()
+	defer p.locker.Unlock()
+
+	var mErr *multierror.Error
+	if backendErr != nil {
+		mErr = multierror.Append(mErr, backendErr)
+	}
+	if p.disconnected && (backendErr == nil || !errors.Is(backendErr, ErrDisconnected)) {
+		mErr = multierror.Append(mErr, ErrDisconnected)
+	}
+	if p.underrun {
+		mErr = multierror.Append(mErr, ErrUnderrun)
+	}
+	if p.overrun {
+		mErr = multierror.Append(mErr, ErrOverrun)
+	}
+	return mErr.ErrorOrNil()
+}
@@ -0,0 +1,84 @@
+package faultinjection
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/xaionaro-go/audio/pkg/audio/types"
+)
+
+// PlayerPCM is a decorator of a types.PlayerPCM which injects faults
+// according to the Schedule.
+type PlayerPCM struct {
+	Backend types.PlayerPCM
+	*injector
+}
+
+var _ types.PlayerPCM = (*PlayerPCM)(nil)
+
+func NewPlayerPCM(
+	backend types.PlayerPCM,
+	schedule Schedule,
+) *PlayerPCM {
+	return &PlayerPCM{
+		Backend:  backend,
+		injector: newInjector(schedule),
+	}
+}
+
+func (p *PlayerPCM) Close() error {
+	return p.Backend.Close()
+}
+
+func (p *PlayerPCM) Ping(ctx context.Context) error {
+	if err := p.injector.ping(); err != nil {
+		return err
+	}
+	return p.Backend.Ping(ctx)
+}
+
+func (p *PlayerPCM) PlayPCM(
+	ctx context.Context,
+	sampleRate types.SampleRate,
+	channels types.Channel,
+	format types.PCMFormat,
+	bufferSize time.Duration,
+	reader io.Reader,
+) (types.PlayStream, error) {
+	if err := p.checkConnected(); err != nil {
+		return nil, err
+	}
+	processor, err := p.newPeriodProcessor(OpPlayPeriod, sampleRate, channels, format)
+	if err != nil {
+		return nil, fmt.Errorf("unable to initialize the fault injector: %w", err)
+	}
+
+	stream, err := p.Backend.PlayPCM(
+		ctx,
+		sampleRate,
+		channels,
+		format,
+		bufferSize,
+		newReader(ctx, reader, processor),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &PlayStream{
+		PlayStream: stream,
+		processor:  processor,
+	}, nil
+}
+
+type PlayStream struct {
+	types.PlayStream
+	processor *periodProcessor
+}
+
+// Drain drains the backend stream and additionally reports the
+// injected underruns and disconnects.
+func (s *PlayStream) Drain() error {
+	return s.processor.result(s.PlayStream.Drain())
+}
@@ -0,0 +1,61 @@
+package faultinjection
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+)
+
+// reader applies the faults to the data a backend reads for playback.
+type reader struct {
+	ctx       context.Context
+	backend   io.Reader
+	processor *periodProcessor
+	periodBuf []byte
+	pending   bytes.Buffer
+	processed []byte
+	err       error
+}
+
+var _ io.Reader = (*reader)(nil)
+
+func newReader(
+	ctx context.Context,
+	backend io.Reader,
+	processor *periodProcessor,
+) *reader {
+	return &reader{
+		ctx:       ctx,
+		backend:   backend,
+		processor: processor,
+		periodBuf: make([]byte, processor.periodSize),
+	}
+}
+
+func (r *reader) Read(p []byte) (int, error) {
+	for r.pending.Len() == 0 {
+		if r.err != nil {
+			return 0, r.err
+		}
+
+		n, err := io.ReadFull(r.backend, r.periodBuf)
+		n -= n % r.processor.frameSize
+		if n > 0 {
+			var processErr error
+			r.processed, processErr = r.processor.process(r.ctx, r.processed[:0], r.periodBuf[:n])
+			r.pending.Write(r.processed)
+			if processErr != nil {
+				r.err = processErr
+				continue
+			}
+		}
+		if err != nil {
+			if errors.Is(err, io.ErrUnexpectedEOF) {
+				err = io.EOF
+			}
+			r.err = err
+		}
+	}
+	return r.pending.Read(p)
+}
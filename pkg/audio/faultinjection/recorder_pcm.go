@@ -0,0 +1,95 @@
+package faultinjection
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/hashicorp/go-multierror"
+	"github.com/xaionaro-go/audio/pkg/audio/types"
+)
+
+// RecorderPCM is a decorator of a types.RecorderPCM which injects faults
+// according to the Schedule.
+type RecorderPCM struct {
+	Backend types.RecorderPCM
+	*injector
+}
+
+var _ types.RecorderPCM = (*RecorderPCM)(nil)
+
+func NewRecorderPCM(
+	backend types.RecorderPCM,
+	schedule Schedule,
+) *RecorderPCM {
+	return &RecorderPCM{
+		Backend:  backend,
+		injector: newInjector(schedule),
+	}
+}
+
+func (r *RecorderPCM) Close() error {
+	return r.Backend.Close()
+}
+
+func (r *RecorderPCM) Ping(ctx context.Context) error {
+	if err := r.injector.ping(); err != nil {
+		return err
+	}
+	return r.Backend.Ping(ctx)
+}
+
+func (r *RecorderPCM) RecordPCM(
+	ctx context.Context,
+	sampleRate types.SampleRate,
+	channels types.Channel,
+	format types.PCMFormat,
+	writer io.Writer,
+) (types.RecordStream, error) {
+	if err := r.checkConnected(); err != nil {
+		return nil, err
+	}
+	processor, err := r.newPeriodProcessor(OpRecordPeriod, sampleRate, channels, format)
+	if err != nil {
+		return nil, fmt.Errorf("unable to initialize the fault injector: %w", err)
+	}
+
+	w := newWriter(ctx, writer, processor)
+	stream, err := r.Backend.RecordPCM(
+		ctx,
+		sampleRate,
+		channels,
+		format,
+		w,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &RecordStream{
+		RecordStream: stream,
+		writer:       w,
+	}, nil
+}
+
+type RecordStream struct {
+	types.RecordStream
+	writer *writer
+}
+
+// Err returns the injected overruns and disconnects.
+func (s *RecordStream) Err() error {
+	return s.writer.processor.result(nil)
+}
+
+// Close closes the backend stream and flushes the data buffered
+// for the incomplete period.
+func (s *RecordStream) Close() error {
+	var mErr *multierror.Error
+	if err := s.RecordStream.Close(); err != nil {
+		mErr = multierror.Append(mErr, err)
+	}
+	if err := s.writer.flush(); err != nil {
+		mErr = multierror.Append(mErr, fmt.Errorf("unable to flush: %w", err))
+	}
+	return mErr.ErrorOrNil()
+}
@@ -0,0 +1,96 @@
+package faultinjection
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+)
+
+type Schedule interface {
+	// FaultsAt returns the faults to inject into the operation.
+	//
+	// For OpPing the index is the number of the Ping call on the
+	// player/recorder, for OpPlayPeriod and OpRecordPeriod it is the
+	// number of the period within the stream.
+	FaultsAt(op Op, index uint64) []Fault
+}
+
+type ScheduledFault struct {
+	Op    Op
+	Index uint64
+	Fault
+}
+
+// ScriptedSchedule injects exactly the listed faults.
+type ScriptedSchedule []ScheduledFault
+
+var _ Schedule = ScriptedSchedule(nil)
+
+func (s ScriptedSchedule) FaultsAt(op Op, index uint64) []Fault {
+	var result []Fault
+	for _, f := range s {
+		if f.Op == op && f.Index == index {
+			result = append(result, f.Fault)
+		}
+	}
+	return result
+}
+
+// RandomSchedule injects every fault kind with the given probability
+// per operation. It is deterministic for the same seed and the same
+// order of operations.
+type RandomSchedule struct {
+	Probabilities map[FaultKind]float64
+	LatencySpike  time.Duration
+	DriftPPM      float64
+
+	locker sync.Mutex
+	rand   *rand.Rand
+}
+
+var _ Schedule = (*RandomSchedule)(nil)
+
+func NewRandomSchedule(
+	seed int64,
+	probabilities map[FaultKind]float64,
+) *RandomSchedule {
+	return &RandomSchedule{
+		Probabilities: probabilities,
+		LatencySpike:  100 * time.Millisecond,
+		DriftPPM:      1000,
+		rand:          rand.New(rand.NewSource(seed)),
+	}
+}
+
+func (s *RandomSchedule) FaultsAt(op Op, index uint64) []Fault {
+	s.locker.Lock()
+	defer s.locker.Unlock()
+
+	var result []Fault
+	for kind := UndefinedFaultKind + 1; kind < EndOfFaultKind; kind++ {
+		if !kind.applicableTo(op) {
+			continue
+		}
+		probability := s.Probabilities[kind]
+		if probability <= 0 || s.rand.Float64() >= probability {
+			continue
+		}
+		result = append(result, Fault{
+			Kind:     kind,
+			Duration: s.LatencySpike,
+			DriftPPM: s.DriftPPM,
+		})
+	}
+	return result
+}
+
+func (k FaultKind) applicableTo(op Op) bool {
+	switch k {
+	case FaultKindPingFailure:
+		return op == OpPing
+	case FaultKindDisconnect:
+		return true
+	default:
+		return op == OpPlayPeriod || op == OpRecordPeriod
+	}
+}
@@ -0,0 +1,90 @@
+package faultinjection
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// writer applies the faults to the data a backend records.
+type writer struct {
+	ctx       context.Context
+	backend   io.Writer
+	processor *periodProcessor
+
+	locker    sync.Mutex
+	buffer    []byte
+	processed []byte
+	err       error
+}
+
+var _ io.Writer = (*writer)(nil)
+
+func newWriter(
+	ctx context.Context,
+	backend io.Writer,
+	processor *periodProcessor,
+) *writer {
+	return &writer{
+		ctx:       ctx,
+		backend:   backend,
+		processor: processor,
+	}
+}
+
+func (w *writer) Write(p []byte) (int, error) {
+	w.locker.Lock()
+	defer w.locker.Unlock()
+	if w.err != nil {
+		return 0, w.err
+	}
+
+	w.buffer = append(w.buffer, p...)
+	periodSize := w.processor.periodSize
+	consumed := 0
+	for len(w.buffer)-consumed >= periodSize {
+		if err := w.writePeriod(w.buffer[consumed : consumed+periodSize]); err != nil {
+			w.err = err
+			return 0, err
+		}
+		consumed += periodSize
+	}
+	w.buffer = append(w.buffer[:0], w.buffer[consumed:]...)
+	return len(p), nil
+}
+
+func (w *writer) writePeriod(period []byte) error {
+	var processErr error
+	w.processed, processErr = w.processor.process(w.ctx, w.processed[:0], period)
+	if len(w.processed) > 0 {
+		n, err := w.backend.Write(w.processed)
+		if err != nil {
+			return fmt.Errorf("unable to write: %w", err)
+		}
+		if n != len(w.processed) {
+			return fmt.Errorf("invalid write length: %d != %d", n, len(w.processed))
+		}
+	}
+	return processErr
+}
+
+// flush passes through the remaining incomplete period.
+func (w *writer) flush() error {
+	w.locker.Lock()
+	defer w.locker.Unlock()
+	if w.err != nil {
+		return nil
+	}
+
+	n := len(w.buffer) - len(w.buffer)%w.processor.frameSize
+	if n == 0 {
+		return nil
+	}
+	err := w.writePeriod(w.buffer[:n])
+	w.buffer = w.buffer[:0]
+	if err != nil {
+		w.err = err
+	}
+	return err
+}
@@ -0,0 +1,148 @@
+package httpsource
+
+import (
+	"context"
+	"fmt"
+	"mime"
+	"strconv"
+	"strings"
+
+	"github.com/xaionaro-go/audio/pkg/audio"
+)
+
+type ContentKind uint
+
+const (
+	UndefinedContentKind = ContentKind(iota)
+
+	// ContentKindEncoded is an encoded (or containerized) content, the
+	// format of which is detected by package codec.
+	ContentKindEncoded
+
+	// ContentKindPCM is a headerless PCM content, the format of which is
+	// described by the Content-Type (like "audio/L16; rate=48000").
+	ContentKindPCM
+
+	EndOfContentKind
+)
+
+func (k ContentKind) String() string {
+	switch k {
+	case UndefinedContentKind:
+		return "<undefined>"
+	case ContentKindEncoded:
+		return "encoded"
+	case ContentKindPCM:
+		return "pcm"
+	default:
+		return fmt.Sprintf("<unexpected_value_%d>", uint(k))
+	}
+}
+
+// ContentInfo is the result of the content sniffing.
+type ContentInfo struct {
+	Kind ContentKind
+
+	// the fields below are set only for ContentKindPCM
+	SampleRate audio.SampleRate
+	Channels   audio.Channel
+	PCMFormat  audio.PCMFormat
+}
+
+// Sniff detects the raw PCM media types by the Content-Type header. Any
+// other content is reported as ContentKindEncoded, since it is
+// recognized by the magic bytes (see codec.Sniff) instead.
+func Sniff(contentType string) (ContentInfo, error) {
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err == nil {
+		switch strings.ToLower(mediaType) {
+		case "audio/l16", "audio/l24":
+			return sniffRawPCM(mediaType, params)
+		}
+	}
+	return ContentInfo{Kind: ContentKindEncoded}, nil
+}
+
+// sniffRawPCM parses the RFC 2586 / RFC 3190 media types (e.g. "audio/L16; rate=48000; channels=2").
+func sniffRawPCM(
+	mediaType string,
+	params map[string]string,
+) (ContentInfo, error) {
+	info := ContentInfo{
+		Kind:     ContentKindPCM,
+		Channels: 1,
+	}
+	switch strings.ToLower(mediaType) {
+	case "audio/l16":
+		info.PCMFormat = audio.PCMFormatS16BE
+	case "audio/l24":
+		info.PCMFormat = audio.PCMFormatS24BE
+	}
+	rate, err := strconv.ParseUint(params["rate"], 10, 32)
+	if err != nil {
+		return ContentInfo{}, fmt.Errorf("unable to parse the rate '%s' of '%s': %w", params["rate"], mediaType, err)
+	}
+	info.SampleRate = audio.SampleRate(rate)
+	if v, ok := params["channels"]; ok {
+		channels, err := strconv.ParseUint(v, 10, 32)
+		if err != nil {
+			return ContentInfo{}, fmt.Errorf("unable to parse the channels '%s' of '%s': %w", v, mediaType, err)
+		}
+		info.Channels = audio.Channel(channels)
+	}
+	return info, nil
+}
+
+// Play sniffs the content of the source and plays it with the suitable decoder.
+func Play(
+	ctx context.Context,
+	player *audio.Player,
+	src *Source,
+) (audio.PlayStream, error) {
+	info, err := Sniff(src.ContentType())
+	if err != nil {
+		return nil, err
+	}
+
+	switch info.Kind {
+	case ContentKindEncoded:
+		return player.PlayReader(ctx, src)
+	case ContentKindPCM:
+		return player.PlayPCM(ctx, info.SampleRate, info.Channels, info.PCMFormat, audio.BufferSize, src)
+	default:
+		return nil, fmt.Errorf("unsupported content kind: %v", info.Kind)
+	}
+}
+
+// PlayURL opens the URL with the default configuration and plays it.
+// The returned stream closes the source when closed.
+func PlayURL(
+	ctx context.Context,
+	player *audio.Player,
+	url string,
+) (audio.PlayStream, error) {
+	src, err := Open(ctx, url, DefaultConfig())
+	if err != nil {
+		return nil, fmt.Errorf("unable to open '%s': %w", url, err)
+	}
+	stream, err := Play(ctx, player, src)
+	if err != nil {
+		src.Close()
+		return nil, err
+	}
+	return &PlayStream{
+		PlayStream: stream,
+		Source:     src,
+	}, nil
+}
+
+type PlayStream struct {
+	audio.PlayStream
+	Source *Source
+}
+
+func (s *PlayStream) Close() error {
+	err := s.PlayStream.Close()
+	s.Source.Close()
+	return err
+}
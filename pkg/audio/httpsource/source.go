@@ -0,0 +1,401 @@
+// Package httpsource provides a buffered, seekable and self-reconnecting
+// reader of http(s) URLs, and playback of such URLs.
+package httpsource
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/facebookincubator/go-belt/tool/logger"
+	"github.com/xaionaro-go/observability"
+)
+
+const (
+	DefaultPrefetchSize = 1 << 20
+	DefaultMaxRetries   = 5
+	DefaultRetryDelay   = 500 * time.Millisecond
+	chunkSize           = 32 * 1024
+)
+
+type Config struct {
+	Client *http.Client
+
+	// PrefetchSize is the maximal amount of bytes to download ahead of the reader.
+	PrefetchSize int
+
+	// MaxRetries is the maximal amount of consecutive reconnection attempts
+	// on transient errors.
+	MaxRetries int
+
+	// RetryDelay is the delay before the first reconnection attempt; it
+	// grows linearly with the amount of consecutive attempts.
+	RetryDelay time.Duration
+}
+
+func DefaultConfig() Config {
+	return Config{
+		Client:       http.DefaultClient,
+		PrefetchSize: DefaultPrefetchSize,
+		MaxRetries:   DefaultMaxRetries,
+		RetryDelay:   DefaultRetryDelay,
+	}
+}
+
+// Source is an io.ReadSeekCloser of a remote resource. It prefetches
+// the data in background, reconnects (continuing via a Range request)
+// on transient errors and implements seeking via Range requests.
+type Source struct {
+	URL    string
+	Config Config
+
+	contentType   string
+	size          int64
+	acceptsRanges bool
+
+	locker      sync.Mutex
+	cond        *sync.Cond
+	body        io.ReadCloser
+	generation  uint64
+	buffer      []byte
+	readPos     int64
+	fetchPos    int64
+	retries     int
+	eof         bool
+	err         error
+	closed      bool
+	cancelFunc  context.CancelFunc
+	waitGroup   sync.WaitGroup
+	reconnected uint64
+}
+
+var _ io.ReadSeekCloser = (*Source)(nil)
+
+type transientError struct {
+	error
+}
+
+func (e transientError) Unwrap() error {
+	return e.error
+}
+
+// Open requests the URL and starts prefetching its content.
+func Open(
+	ctx context.Context,
+	url string,
+	cfg Config,
+) (*Source, error) {
+	if cfg.Client == nil {
+		cfg.Client = http.DefaultClient
+	}
+	if cfg.PrefetchSize <= 0 {
+		cfg.PrefetchSize = DefaultPrefetchSize
+	}
+	s := &Source{
+		URL:    url,
+		Config: cfg,
+		size:   -1,
+	}
+	s.cond = sync.NewCond(&s.locker)
+
+	resp, err := s.request(ctx, 0)
+	if err != nil {
+		return nil, err
+	}
+	s.body = resp.Body
+	s.contentType = resp.Header.Get("Content-Type")
+	s.acceptsRanges = resp.StatusCode == http.StatusPartialContent || strings.EqualFold(resp.Header.Get("Accept-Ranges"), "bytes")
+	if resp.ContentLength >= 0 {
+		s.size = resp.ContentLength
+	}
+
+	ctx, s.cancelFunc = context.WithCancel(ctx)
+	s.waitGroup.Add(1)
+	observability.Go(ctx, func(ctx context.Context) {
+		defer s.waitGroup.Done()
+		s.fetchLoop(ctx)
+	})
+	return s, nil
+}
+
+// ContentType returns the value of the Content-Type header of the response.
+func (s *Source) ContentType() string {
+	return s.contentType
+}
+
+// Size returns the size of the resource, or -1 if it is unknown.
+func (s *Source) Size() int64 {
+	return s.size
+}
+
+// AcceptsRanges returns true if the server supports Range requests
+// (and thus seeking is possible).
+func (s *Source) AcceptsRanges() bool {
+	return s.acceptsRanges
+}
+
+// Reconnections returns how many times the connection was re-established
+// due to transient errors.
+func (s *Source) Reconnections() uint64 {
+	s.locker.Lock()
+	defer s.locker.Unlock()
+	return s.reconnected
+}
+
+func (s *Source) request(
+	ctx context.Context,
+	offset int64,
+) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.URL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("unable to build a request: %w", err)
+	}
+	if offset > 0 {
+		req.Header.Set("Range", "bytes="+strconv.FormatInt(offset, 10)+"-")
+	}
+	resp, err := s.Config.Client.Do(req)
+	if err != nil {
+		return nil, transientError{fmt.Errorf("unable to request '%s': %w", s.URL, err)}
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusOK:
+		if offset > 0 {
+			// the server ignored the range, so skipping manually
+			if _, err := io.CopyN(io.Discard, resp.Body, offset); err != nil {
+				resp.Body.Close()
+				return nil, transientError{fmt.Errorf("unable to skip %d bytes: %w", offset, err)}
+			}
+		}
+		return resp, nil
+	case resp.StatusCode == http.StatusPartialContent:
+		if size := parseContentRangeSize(resp.Header.Get("Content-Range")); size >= 0 {
+			resp.ContentLength = size
+		}
+		return resp, nil
+	case resp.StatusCode == http.StatusRequestedRangeNotSatisfiable:
+		resp.Body.Close()
+		resp.Body = http.NoBody
+		return resp, nil
+	case resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests:
+		resp.Body.Close()
+		return nil, transientError{fmt.Errorf("received status %s", resp.Status)}
+	default:
+		resp.Body.Close()
+		return nil, fmt.Errorf("received status %s", resp.Status)
+	}
+}
+
+func parseContentRangeSize(contentRange string) int64 {
+	idx := strings.LastIndexByte(contentRange, '/')
+	if idx < 0 {
+		return -1
+	}
+	size, err := strconv.ParseInt(contentRange[idx+1:], 10, 64)
+	if err != nil {
+		return -1
+	}
+	return size
+}
+
+func (s *Source) fetchLoop(ctx context.Context) {
+	logger.Debugf(ctx, "fetchLoop")
+	defer func() { logger.Debugf(ctx, "/fetchLoop") }()
+
+	chunk := make([]byte, chunkSize)
+	for {
+		s.locker.Lock()
+		for !s.closed && (len(s.buffer) >= s.Config.PrefetchSize || s.eof || s.err != nil) {
+			s.cond.Wait()
+		}
+		if s.closed {
+			s.locker.Unlock()
+			return
+		}
+		generation := s.generation
+		fetchPos := s.fetchPos
+		body := s.body
+		retries := s.retries
+		s.locker.Unlock()
+
+		if body == nil {
+			if retries > 0 {
+				delay := s.Config.RetryDelay * time.Duration(retries)
+				logger.Debugf(ctx, "reconnecting to '%s' at %d in %v", s.URL, fetchPos, delay)
+				select {
+				case <-ctx.Done():
+					return
+				case <-time.After(delay):
+				}
+			}
+			resp, err := s.request(ctx, fetchPos)
+			s.locker.Lock()
+			if generation != s.generation {
+				s.locker.Unlock()
+				if resp != nil {
+					resp.Body.Close()
+				}
+				continue
+			}
+			if err != nil {
+				s.onErrorNoLock(err)
+			} else {
+				s.body = resp.Body
+				if retries > 0 {
+					s.reconnected++
+				}
+			}
+			s.cond.Broadcast()
+			s.locker.Unlock()
+			continue
+		}
+
+		n, err := body.Read(chunk)
+		s.locker.Lock()
+		if generation != s.generation {
+			s.locker.Unlock()
+			continue
+		}
+		if n > 0 {
+			s.buffer = append(s.buffer, chunk[:n]...)
+			s.fetchPos += int64(n)
+			s.retries = 0
+		}
+		switch {
+		case err == nil:
+		case errors.Is(err, io.EOF) && (s.size < 0 || s.fetchPos >= s.size):
+			s.eof = true
+		default:
+			if errors.Is(err, io.EOF) {
+				err = io.ErrUnexpectedEOF
+			}
+			body.Close()
+			s.body = nil
+			s.onErrorNoLock(transientError{fmt.Errorf("unable to read: %w", err)})
+		}
+		s.cond.Broadcast()
+		s.locker.Unlock()
+	}
+}
+
+func (s *Source) onErrorNoLock(err error) {
+	var tErr transientError
+	if !errors.As(err, &tErr) {
+		s.err = err
+		return
+	}
+	s.retries++
+	if s.retries > s.Config.MaxRetries {
+		s.err = fmt.Errorf("giving up after %d retries: %w", s.Config.MaxRetries, err)
+	}
+}
+
+// Read reads the prefetched data, waiting for it if necessary.
+func (s *Source) Read(p []byte) (int, error) {
+	s.locker.Lock()
+	defer s.locker.Unlock()
+	for len(s.buffer) == 0 && !s.eof && s.err == nil && !s.closed {
+		s.cond.Wait()
+	}
+	if s.closed {
+		return 0, fmt.Errorf("the source is closed")
+	}
+	if len(s.buffer) == 0 {
+		if s.err != nil {
+			return 0, s.err
+		}
+		return 0, io.EOF
+	}
+	n := copy(p, s.buffer)
+	s.buffer = s.buffer[n:]
+	s.readPos += int64(n)
+	s.cond.Broadcast()
+	return n, nil
+}
+
+// Peek returns the next n bytes without advancing the reader.
+func (s *Source) Peek(n int) ([]byte, error) {
+	s.locker.Lock()
+	defer s.locker.Unlock()
+	for len(s.buffer) < n && !s.eof && s.err == nil && !s.closed {
+		s.cond.Wait()
+	}
+	if len(s.buffer) < n {
+		err := s.err
+		if err == nil {
+			err = io.EOF
+		}
+		return append([]byte(nil), s.buffer...), err
+	}
+	return append([]byte(nil), s.buffer[:n]...), nil
+}
+
+// Seek sets the position for the next Read. Seeking outside of the
+// prefetched data issues a new Range request.
+func (s *Source) Seek(offset int64, whence int) (int64, error) {
+	s.locker.Lock()
+	defer s.locker.Unlock()
+
+	var pos int64
+	switch whence {
+	case io.SeekStart:
+		pos = offset
+	case io.SeekCurrent:
+		pos = s.readPos + offset
+	case io.SeekEnd:
+		if s.size < 0 {
+			return s.readPos, fmt.Errorf("the size of the resource is unknown")
+		}
+		pos = s.size + offset
+	default:
+		return s.readPos, fmt.Errorf("invalid whence: %d", whence)
+	}
+	if pos < 0 {
+		return s.readPos, fmt.Errorf("negative position: %d", pos)
+	}
+
+	if pos >= s.readPos && pos <= s.readPos+int64(len(s.buffer)) {
+		s.buffer = s.buffer[pos-s.readPos:]
+		s.readPos = pos
+		s.cond.Broadcast()
+		return pos, nil
+	}
+	if !s.acceptsRanges && pos != 0 {
+		return s.readPos, fmt.Errorf("the server does not support range requests")
+	}
+
+	s.generation++
+	if s.body != nil {
+		s.body.Close()
+		s.body = nil
+	}
+	s.buffer = nil
+	s.readPos = pos
+	s.fetchPos = pos
+	s.retries = 0
+	s.eof = false
+	s.err = nil
+	s.cond.Broadcast()
+	return pos, nil
+}
+
+func (s *Source) Close() error {
+	s.locker.Lock()
+	s.closed = true
+	if s.body != nil {
+		s.body.Close()
+		s.body = nil
+	}
+	s.cond.Broadcast()
+	s.locker.Unlock()
+
+	s.cancelFunc()
+	s.waitGroup.Wait()
+	return nil
+}
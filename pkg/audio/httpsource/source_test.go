@@ -0,0 +1,173 @@
+package httpsource
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/xaionaro-go/audio/pkg/audio"
+	"github.com/xaionaro-go/audio/pkg/audio/backends/virtual"
+	"github.com/xaionaro-go/audio/pkg/audio/codec/wav"
+)
+
+func testContent(size int) []byte {
+	b := make([]byte, size)
+	for idx := range b {
+		b[idx] = byte(idx * 7)
+	}
+	return b
+}
+
+func testConfig() Config {
+	cfg := DefaultConfig()
+	cfg.PrefetchSize = 1000
+	cfg.RetryDelay = time.Millisecond
+	return cfg
+}
+
+func TestSourceReadAndSeek(t *testing.T) {
+	content := testContent(100000)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "audio/ogg")
+		http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(content))
+	}))
+	defer srv.Close()
+
+	src, err := Open(context.Background(), srv.URL, testConfig())
+	require.NoError(t, err)
+	defer src.Close()
+	require.Equal(t, "audio/ogg", src.ContentType())
+	require.Equal(t, int64(len(content)), src.Size())
+	require.True(t, src.AcceptsRanges())
+
+	head, err := src.Peek(10)
+	require.NoError(t, err)
+	require.Equal(t, content[:10], head)
+
+	pos, err := src.Seek(50000, io.SeekStart)
+	require.NoError(t, err)
+	require.Equal(t, int64(50000), pos)
+	b := make([]byte, 100)
+	_, err = io.ReadFull(src, b)
+	require.NoError(t, err)
+	require.Equal(t, content[50000:50100], b)
+
+	pos, err = src.Seek(-10, io.SeekEnd)
+	require.NoError(t, err)
+	require.Equal(t, int64(len(content)-10), pos)
+	rest, err := io.ReadAll(src)
+	require.NoError(t, err)
+	require.Equal(t, content[len(content)-10:], rest)
+
+	_, err = src.Seek(0, io.SeekStart)
+	require.NoError(t, err)
+	all, err := io.ReadAll(src)
+	require.NoError(t, err)
+	require.Equal(t, content, all)
+}
+
+func TestSourceReconnect(t *testing.T) {
+	content := testContent(100000)
+	var requests atomic.Int64
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch requests.Add(1) {
+		case 1:
+			// abruptly drop the connection in the middle of the body
+			w.Header().Set("Accept-Ranges", "bytes")
+			w.Header().Set("Content-Length", "100000")
+			w.Write(content[:30000])
+			w.(http.Flusher).Flush()
+			panic(http.ErrAbortHandler)
+		case 2:
+			http.Error(w, "try again", http.StatusServiceUnavailable)
+		default:
+			http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(content))
+		}
+	}))
+	defer srv.Close()
+
+	src, err := Open(context.Background(), srv.URL, testConfig())
+	require.NoError(t, err)
+	defer src.Close()
+
+	all, err := io.ReadAll(src)
+	require.NoError(t, err)
+	require.Equal(t, content, all)
+	require.Equal(t, uint64(1), src.Reconnections())
+	require.Equal(t, int64(3), requests.Load())
+}
+
+func TestSourcePermanentError(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	defer srv.Close()
+	_, err := Open(context.Background(), srv.URL, testConfig())
+	require.Error(t, err)
+}
+
+func TestSniff(t *testing.T) {
+	info, err := Sniff("audio/L16; rate=8000; channels=2")
+	require.NoError(t, err)
+	require.Equal(t, ContentInfo{
+		Kind:       ContentKindPCM,
+		SampleRate: 8000,
+		Channels:   2,
+		PCMFormat:  audio.PCMFormatS16BE,
+	}, info)
+
+	for _, contentType := range []string{"audio/ogg", "audio/mpeg", "application/octet-stream", ""} {
+		info, err = Sniff(contentType)
+		require.NoError(t, err)
+		require.Equal(t, ContentKindEncoded, info.Kind, contentType)
+	}
+
+	_, err = Sniff("audio/L24; channels=2")
+	require.Error(t, err)
+}
+
+func TestPlayURL(t *testing.T) {
+	content := []byte{0x10, 0x00, 0x20, 0x00, 0xf0, 0x00}
+	require.Equal(t, content, playURL(t, "audio/L16;rate=1000", content, audio.PCMFormatS16BE))
+
+	// no usable Content-Type, so the format is detected by package codec
+	var wavFile bytes.Buffer
+	w, err := wav.NewWriter(&wavFile, wav.Header{Channels: 1, SampleRate: 1000, PCMFormat: audio.PCMFormatS16LE})
+	require.NoError(t, err)
+	_, err = w.Write(content)
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	require.Equal(t, content, playURL(t, "application/octet-stream", wavFile.Bytes(), audio.PCMFormatS16LE))
+}
+
+// playURL serves the content and returns what was played (as mono at 1000Hz).
+func playURL(t *testing.T, contentType string, content []byte, pcmFormat audio.PCMFormat) []byte {
+	ctx := context.Background()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", contentType)
+		http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(content))
+	}))
+	defer srv.Close()
+
+	d, err := virtual.NewDevice(ctx, t.Name()+"_"+contentType, virtual.DeviceConfig{
+		SampleRate:      1000,
+		Channels:        1,
+		BlockOnUnderrun: true,
+	})
+	require.NoError(t, err)
+	defer d.Close()
+	var recorded bytes.Buffer
+	_, err = virtual.NewRecorderPCMForDevice(d).RecordPCM(ctx, 1000, 1, pcmFormat, &recorded)
+	require.NoError(t, err)
+
+	stream, err := PlayURL(ctx, audio.NewPlayer(virtual.NewPlayerPCMForDevice(d)), srv.URL)
+	require.NoError(t, err)
+	defer stream.Close()
+	require.NoError(t, d.Advance(3*time.Millisecond))
+	require.NoError(t, stream.Drain())
+	return recorded.Bytes()
+}
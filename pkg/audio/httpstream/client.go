@@ -0,0 +1,110 @@
+package httpstream
+
+import (
+	"sync"
+)
+
+type client struct {
+	locker       sync.Mutex
+	buffer       []byte
+	maxBuffered  int
+	frameSize    int
+	dropPolicy   DropPolicy
+	droppedBytes uint64
+	notifyCh     chan struct{}
+	doneCh       chan struct{}
+	isDone       bool
+}
+
+func newClient(
+	maxBuffered int,
+	frameSize int,
+	dropPolicy DropPolicy,
+) *client {
+	maxBuffered -= maxBuffered % frameSize
+	if maxBuffered < frameSize {
+		maxBuffered = frameSize
+	}
+	return &client{
+		maxBuffered: maxBuffered,
+		frameSize:   frameSize,
+		dropPolicy:  dropPolicy,
+		notifyCh:    make(chan struct{}, 1),
+		doneCh:      make(chan struct{}),
+	}
+}
+
+// push enqueues whole frames for the client, applying the drop policy
+// if the client's buffer is full.
+func (c *client) push(frames []byte) {
+	c.locker.Lock()
+	defer c.locker.Unlock()
+	if c.isDone {
+		return
+	}
+
+	if overflow := len(c.buffer) + len(frames) - c.maxBuffered; overflow > 0 {
+		switch c.dropPolicy {
+		case DropPolicyDropNewest:
+			keep := len(frames) - overflow
+			keep -= keep % c.frameSize
+			if keep < 0 {
+				keep = 0
+			}
+			c.droppedBytes += uint64(len(frames) - keep)
+			frames = frames[:keep]
+		case DropPolicyDisconnect:
+			c.closeNoLock()
+			return
+		default:
+			if len(frames) > c.maxBuffered {
+				c.droppedBytes += uint64(len(frames) - c.maxBuffered)
+				frames = frames[len(frames)-c.maxBuffered:]
+				overflow = len(c.buffer)
+			}
+			overflow += (c.frameSize - overflow%c.frameSize) % c.frameSize
+			if overflow > len(c.buffer) {
+				overflow = len(c.buffer)
+			}
+			c.droppedBytes += uint64(overflow)
+			c.buffer = append(c.buffer[:0], c.buffer[overflow:]...)
+		}
+	}
+	if len(frames) == 0 {
+		return
+	}
+	c.buffer = append(c.buffer, frames...)
+	select {
+	case c.notifyCh <- struct{}{}:
+	default:
+	}
+}
+
+// pop moves the buffered data to "dst".
+func (c *client) pop(dst []byte) []byte {
+	c.locker.Lock()
+	defer c.locker.Unlock()
+	dst = append(dst, c.buffer...)
+	c.buffer = c.buffer[:0]
+	return dst
+}
+
+func (c *client) DroppedBytes() uint64 {
+	c.locker.Lock()
+	defer c.locker.Unlock()
+	return c.droppedBytes
+}
+
+func (c *client) close() {
+	c.locker.Lock()
+	defer c.locker.Unlock()
+	c.closeNoLock()
+}
+
+func (c *client) closeNoLock() {
+	if c.isDone {
+		return
+	}
+	c.isDone = true
+	close(c.doneCh)
+}
@@ -0,0 +1,51 @@
+package httpstream
+
+import (
+	"fmt"
+	"strings"
+)
+
+// DropPolicy defines what to do when a client is too slow to consume the stream.
+type DropPolicy uint
+
+const (
+	UndefinedDropPolicy = DropPolicy(iota)
+
+	// DropPolicyDropOldest discards the oldest buffered audio to fit
+	// the new one, so the client stays close to live.
+	DropPolicyDropOldest
+
+	// DropPolicyDropNewest discards the new audio until the client
+	// catches up, so the client gets gaps instead of jumps.
+	DropPolicyDropNewest
+
+	// DropPolicyDisconnect disconnects the client.
+	DropPolicyDisconnect
+
+	EndOfDropPolicy
+)
+
+func (p DropPolicy) String() string {
+	switch p {
+	case UndefinedDropPolicy:
+		return "<undefined>"
+	case DropPolicyDropOldest:
+		return "drop_oldest"
+	case DropPolicyDropNewest:
+		return "drop_newest"
+	case DropPolicyDisconnect:
+		return "disconnect"
+	default:
+		return fmt.Sprintf("<unexpected_value_%d>", uint(p))
+	}
+}
+
+func DropPolicyFromString(in string) DropPolicy {
+	in = strings.ToLower(in)
+	for p := UndefinedDropPolicy + 1; p < EndOfDropPolicy; p++ {
+		if p.String() == in {
+			return p
+		}
+	}
+	return UndefinedDropPolicy
+}
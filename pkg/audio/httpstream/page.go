@@ -0,0 +1,38 @@
+package httpstream
+
+import (
+	"html/template"
+	"net/http"
+)
+
+var pageTemplate = template.Must(template.New("page").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>{{.Title}}</title></head>
+<body>
+<h1>{{.Title}}</h1>
+<audio controls autoplay preload="none" src="{{.StreamURL}}"></audio>
+</body>
+</html>
+`))
+
+// PageHandler serves a minimal HTML page with an audio element playing streamURL.
+func PageHandler(title string, streamURL string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		pageTemplate.Execute(w, struct {
+			Title     string
+			StreamURL string
+		}{
+			Title:     title,
+			StreamURL: streamURL,
+		})
+	})
+}
+
+// Handler returns a handler serving the HTML page at "/" and the stream at "/stream.wav".
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("/stream.wav", s)
+	mux.Handle("/{$}", PageHandler("live audio", "stream.wav"))
+	return mux
+}
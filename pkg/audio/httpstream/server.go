@@ -0,0 +1,188 @@
+// Package httpstream serves live recorded audio over HTTP as an endless WAV stream.
+package httpstream
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/facebookincubator/go-belt/tool/logger"
+	"github.com/xaionaro-go/audio/pkg/audio"
+)
+
+const (
+	DefaultClientBufferDuration = 2 * time.Second
+	DefaultDropPolicy           = DropPolicyDropOldest
+)
+
+// Server is an io.Writer of PCM data (to be passed to a recorder) and
+// an http.Handler which streams this data to every connected client.
+type Server struct {
+	SampleRate audio.SampleRate
+	Channels   audio.Channel
+	PCMFormat  audio.PCMFormat
+
+	ClientBufferDuration time.Duration
+	DropPolicy           DropPolicy
+
+	header    []byte
+	frameSize int
+
+	locker  sync.Mutex
+	clients map[*client]struct{}
+	pending []byte
+	closed  bool
+}
+
+var (
+	_ io.Writer    = (*Server)(nil)
+	_ http.Handler = (*Server)(nil)
+)
+
+func NewServer(
+	sampleRate audio.SampleRate,
+	channels audio.Channel,
+	pcmFormat audio.PCMFormat,
+) (*Server, error) {
+	header, err := streamingWAVHeader(sampleRate, channels, pcmFormat)
+	if err != nil {
+		return nil, err
+	}
+	return &Server{
+		SampleRate:           sampleRate,
+		Channels:             channels,
+		PCMFormat:            pcmFormat,
+		ClientBufferDuration: DefaultClientBufferDuration,
+		DropPolicy:           DefaultDropPolicy,
+		header:               header,
+		frameSize:            int(channels) * int(pcmFormat.Size()),
+		clients:              map[*client]struct{}{},
+	}, nil
+}
+
+// Record starts recording from the recorder into the server.
+func (s *Server) Record(
+	ctx context.Context,
+	recorder *audio.Recorder,
+) (audio.RecordStream, error) {
+	return recorder.RecordPCM(ctx, s.SampleRate, s.Channels, s.PCMFormat, s)
+}
+
+// Write distributes the PCM data among the connected clients. An
+// incomplete trailing frame is kept until the next Write.
+func (s *Server) Write(p []byte) (int, error) {
+	s.locker.Lock()
+	defer s.locker.Unlock()
+	if s.closed {
+		return 0, fmt.Errorf("the server is closed")
+	}
+
+	data := p
+	if len(s.pending) > 0 {
+		s.pending = append(s.pending, p...)
+		data = s.pending
+	}
+	complete := len(data) - len(data)%s.frameSize
+	if complete > 0 {
+		for c := range s.clients {
+			c.push(data[:complete])
+		}
+	}
+	s.pending = append(s.pending[:0], data[complete:]...)
+	return len(p), nil
+}
+
+// ClientsCount returns the amount of currently connected clients.
+func (s *Server) ClientsCount() int {
+	s.locker.Lock()
+	defer s.locker.Unlock()
+	return len(s.clients)
+}
+
+func (s *Server) addClient() (*client, error) {
+	s.locker.Lock()
+	defer s.locker.Unlock()
+	if s.closed {
+		return nil, fmt.Errorf("the server is closed")
+	}
+	bufferSize := int(audio.EncodingPCM{
+		PCMFormat:  s.PCMFormat,
+		SampleRate: s.SampleRate,
+	}.BytesForDuration(s.ClientBufferDuration)) * int(s.Channels)
+	c := newClient(bufferSize, s.frameSize, s.DropPolicy)
+	s.clients[c] = struct{}{}
+	return c, nil
+}
+
+func (s *Server) removeClient(c *client) {
+	s.locker.Lock()
+	defer s.locker.Unlock()
+	delete(s.clients, c)
+	c.close()
+}
+
+// ServeHTTP streams the audio to the client until either side disconnects.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	c, err := s.addClient()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	defer s.removeClient(c)
+	logger.Debugf(ctx, "client %s connected", r.RemoteAddr)
+	defer func() {
+		logger.Debugf(ctx, "client %s disconnected (dropped: %d bytes)", r.RemoteAddr, c.DroppedBytes())
+	}()
+
+	h := w.Header()
+	h.Set("Content-Type", "audio/wav")
+	h.Set("Cache-Control", "no-cache, no-store")
+	w.WriteHeader(http.StatusOK)
+	if r.Method == http.MethodHead {
+		return
+	}
+	flusher, _ := w.(http.Flusher)
+	if _, err := w.Write(s.header); err != nil {
+		return
+	}
+	if flusher != nil {
+		flusher.Flush()
+	}
+
+	var buf []byte
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-c.doneCh:
+			return
+		case <-c.notifyCh:
+		}
+		buf = c.pop(buf[:0])
+		if len(buf) == 0 {
+			continue
+		}
+		if _, err := w.Write(buf); err != nil {
+			logger.Debugf(ctx, "unable to write to client %s: %v", r.RemoteAddr, err)
+			return
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+}
+
+// Close disconnects all the clients and makes further Write calls fail.
+func (s *Server) Close() error {
+	s.locker.Lock()
+	defer s.locker.Unlock()
+	s.closed = true
+	for c := range s.clients {
+		c.close()
+	}
+	return nil
+}
@@ -0,0 +1,119 @@
+package httpstream
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/xaionaro-go/audio/pkg/audio"
+	"github.com/xaionaro-go/audio/pkg/audio/backends/virtual"
+)
+
+func TestServer(t *testing.T) {
+	ctx := context.Background()
+	d, err := virtual.NewDevice(ctx, t.Name(), virtual.DeviceConfig{
+		SampleRate:      1000,
+		Channels:        1,
+		BlockOnUnderrun: true,
+	})
+	require.NoError(t, err)
+	defer d.Close()
+
+	s, err := NewServer(1000, 1, audio.PCMFormatS16LE)
+	require.NoError(t, err)
+	defer s.Close()
+	recordStream, err := s.Record(ctx, audio.NewRecorder(virtual.NewRecorderPCMForDevice(d)))
+	require.NoError(t, err)
+	defer recordStream.Close()
+
+	httpServer := httptest.NewServer(s.Handler())
+	defer httpServer.Close()
+
+	resp, err := http.Get(httpServer.URL + "/")
+	require.NoError(t, err)
+	page, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	require.NoError(t, err)
+	require.Contains(t, string(page), `<audio controls autoplay preload="none" src="stream.wav">`)
+
+	var clients []io.ReadCloser
+	for range 2 {
+		resp, err := http.Get(httpServer.URL + "/stream.wav")
+		require.NoError(t, err)
+		require.Equal(t, "audio/wav", resp.Header.Get("Content-Type"))
+		defer resp.Body.Close()
+		header := make([]byte, 44)
+		_, err = io.ReadFull(resp.Body, header)
+		require.NoError(t, err)
+		require.Equal(t, "RIFF", string(header[:4]))
+		require.Equal(t, uint16(1), binary.LittleEndian.Uint16(header[20:]))
+		require.Equal(t, uint32(1000), binary.LittleEndian.Uint32(header[24:]))
+		require.Equal(t, uint16(16), binary.LittleEndian.Uint16(header[34:]))
+		clients = append(clients, resp.Body)
+	}
+	require.Eventually(t, func() bool { return s.ClientsCount() == 2 }, time.Second, time.Millisecond)
+
+	input := []byte{0x00, 0x10, 0x00, 0x20, 0x00, 0xf0}
+	_, err = audio.NewPlayer(virtual.NewPlayerPCMForDevice(d)).PlayPCM(ctx, 1000, 1, audio.PCMFormatS16LE, time.Second, bytes.NewReader(input))
+	require.NoError(t, err)
+	require.NoError(t, d.Advance(3*time.Millisecond))
+
+	for _, c := range clients {
+		received := make([]byte, len(input))
+		_, err = io.ReadFull(c, received)
+		require.NoError(t, err)
+		require.Equal(t, input, received)
+	}
+}
+
+func TestServerWriteKeepsFramesWhole(t *testing.T) {
+	s, err := NewServer(1000, 2, audio.PCMFormatS16LE)
+	require.NoError(t, err)
+	c, err := s.addClient()
+	require.NoError(t, err)
+
+	_, err = s.Write([]byte{1, 2, 3})
+	require.NoError(t, err)
+	require.Empty(t, c.pop(nil))
+	_, err = s.Write([]byte{4, 5, 6})
+	require.NoError(t, err)
+	require.Equal(t, []byte{1, 2, 3, 4}, c.pop(nil))
+	_, err = s.Write([]byte{7, 8})
+	require.NoError(t, err)
+	require.Equal(t, []byte{5, 6, 7, 8}, c.pop(nil))
+}
+
+func TestClientDropPolicy(t *testing.T) {
+	t.Run(DropPolicyDropOldest.String(), func(t *testing.T) {
+		c := newClient(4, 2, DropPolicyDropOldest)
+		c.push([]byte{1, 1, 2, 2})
+		c.push([]byte{3, 3})
+		require.Equal(t, []byte{2, 2, 3, 3}, c.pop(nil))
+		c.push([]byte{4, 4, 5, 5, 6, 6})
+		require.Equal(t, []byte{5, 5, 6, 6}, c.pop(nil))
+		require.Equal(t, uint64(4), c.DroppedBytes())
+	})
+	t.Run(DropPolicyDropNewest.String(), func(t *testing.T) {
+		c := newClient(4, 2, DropPolicyDropNewest)
+		c.push([]byte{1, 1, 2, 2})
+		c.push([]byte{3, 3})
+		require.Equal(t, []byte{1, 1, 2, 2}, c.pop(nil))
+		require.Equal(t, uint64(2), c.DroppedBytes())
+	})
+	t.Run(DropPolicyDisconnect.String(), func(t *testing.T) {
+		c := newClient(4, 2, DropPolicyDisconnect)
+		c.push([]byte{1, 1, 2, 2, 3, 3})
+		select {
+		case <-c.doneCh:
+		default:
+			t.Fatal("the client is expected to be disconnected")
+		}
+	})
+	require.Equal(t, DropPolicyDropNewest, DropPolicyFromString("DROP_NEWEST"))
+}
@@ -0,0 +1,51 @@
+package httpstream
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+
+	"github.com/xaionaro-go/audio/pkg/audio/types"
+)
+
+const (
+	wavFormatTagPCM       = 1
+	wavFormatTagIEEEFloat = 3
+)
+
+// streamingWAVHeader returns a WAV header for a stream of unknown
+// length: the sizes are set to the maximal values, which is the
+// convention understood by browsers and media players.
+func streamingWAVHeader(
+	sampleRate types.SampleRate,
+	channels types.Channel,
+	format types.PCMFormat,
+) ([]byte, error) {
+	var formatTag uint16
+	switch format {
+	case types.PCMFormatU8, types.PCMFormatS16LE, types.PCMFormatS24LE, types.PCMFormatS32LE:
+		formatTag = wavFormatTagPCM
+	case types.PCMFormatFloat32LE, types.PCMFormatFloat64LE:
+		formatTag = wavFormatTagIEEEFloat
+	default:
+		return nil, fmt.Errorf("PCM format %v cannot be represented in WAV", format)
+	}
+	sampleSize := format.Size()
+	blockAlign := uint32(channels) * sampleSize
+
+	h := make([]byte, 0, 44)
+	h = append(h, "RIFF"...)
+	h = binary.LittleEndian.AppendUint32(h, math.MaxUint32)
+	h = append(h, "WAVE"...)
+	h = append(h, "fmt "...)
+	h = binary.LittleEndian.AppendUint32(h, 16)
+	h = binary.LittleEndian.AppendUint16(h, formatTag)
+	h = binary.LittleEndian.AppendUint16(h, uint16(channels))
+	h = binary.LittleEndian.AppendUint32(h, uint32(sampleRate))
+	h = binary.LittleEndian.AppendUint32(h, uint32(sampleRate)*blockAlign)
+	h = binary.LittleEndian.AppendUint16(h, uint16(blockAlign))
+	h = binary.LittleEndian.AppendUint16(h, uint16(sampleSize*8))
+	h = append(h, "data"...)
+	h = binary.LittleEndian.AppendUint32(h, math.MaxUint32)
+	return h, nil
+}
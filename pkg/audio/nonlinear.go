@@ -0,0 +1,137 @@
+package audio
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/xaionaro-go/audio/pkg/audio/codec/adpcm"
+	"github.com/xaionaro-go/audio/pkg/audio/codec/g711"
+)
+
+// newLinearReader decodes a non-linear format (see PCMFormat.IsLinear)
+// into PCMFormatS16LE, since the backends support only linear formats.
+func newLinearReader(r io.Reader, pcmFormat PCMFormat, channels Channel) io.Reader {
+	switch pcmFormat {
+	case PCMFormatMuLaw:
+		return &g711Reader{reader: r, decode: g711.DecodeMuLaw}
+	case PCMFormatALaw:
+		return &g711Reader{reader: r, decode: g711.DecodeALaw}
+	case PCMFormatIMAADPCM:
+		return adpcm.NewReader(r, int(channels))
+	default:
+		return r
+	}
+}
+
+type g711Reader struct {
+	reader io.Reader
+	decode func(byte) int16
+	buf    []byte
+}
+
+func (r *g711Reader) Read(b []byte) (int, error) {
+	size := len(b) / 2
+	if size == 0 {
+		return 0, io.ErrShortBuffer
+	}
+	if cap(r.buf) < size {
+		r.buf = make([]byte, size)
+	}
+	n, err := r.reader.Read(r.buf[:size])
+	for idx, v := range r.buf[:n] {
+		binary.LittleEndian.PutUint16(b[idx*2:], uint16(r.decode(v)))
+	}
+	return n * 2, err
+}
+
+// newNonlinearWriter encodes PCMFormatS16LE into a non-linear format
+// (see PCMFormat.IsLinear). Close must be called after the last Write
+// to flush the encoder; it does not close "w".
+func newNonlinearWriter(w io.Writer, pcmFormat PCMFormat, channels Channel) *nonlinearWriter {
+	nw := &nonlinearWriter{writer: w}
+	switch pcmFormat {
+	case PCMFormatMuLaw:
+		nw.encode = encodeG711(g711.EncodeMuLaw)
+	case PCMFormatALaw:
+		nw.encode = encodeG711(g711.EncodeALaw)
+	case PCMFormatIMAADPCM:
+		encoder := adpcm.NewStreamEncoder(int(channels))
+		nw.encode = encoder.Encode
+		nw.flush = encoder.Flush
+	default:
+		panic(fmt.Errorf("%v is a linear format", pcmFormat))
+	}
+	return nw
+}
+
+func encodeG711(encode func(int16) byte) func([]byte, []int16) int {
+	return func(dst []byte, samples []int16) int {
+		for idx, sample := range samples {
+			dst[idx] = encode(sample)
+		}
+		return len(samples)
+	}
+}
+
+type nonlinearWriter struct {
+	writer  io.Writer
+	encode  func(dst []byte, samples []int16) int
+	flush   func(dst []byte) int
+	locker  sync.Mutex
+	closed  bool
+	pending []byte
+	samples []int16
+	buf     []byte
+}
+
+var _ io.WriteCloser = (*nonlinearWriter)(nil)
+
+func (w *nonlinearWriter) Write(b []byte) (int, error) {
+	w.locker.Lock()
+	defer w.locker.Unlock()
+	if w.closed {
+		return 0, io.ErrClosedPipe
+	}
+	in := b
+	if len(w.pending) > 0 {
+		in = append(w.pending, b...)
+	}
+	samples := len(in) / 2
+	if cap(w.samples) < samples {
+		w.samples = make([]int16, samples)
+		w.buf = make([]byte, samples)
+	}
+	for idx := range samples {
+		w.samples[idx] = int16(binary.LittleEndian.Uint16(in[idx*2:]))
+	}
+	w.pending = append(w.pending[:0], in[samples*2:]...)
+	n := w.encode(w.buf, w.samples[:samples])
+	if _, err := w.writer.Write(w.buf[:n]); err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
+
+// Close writes the data kept by the encoder (if any).
+func (w *nonlinearWriter) Close() error {
+	w.locker.Lock()
+	defer w.locker.Unlock()
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+	if w.flush == nil {
+		return nil
+	}
+	var buf [1]byte
+	n := w.flush(buf[:])
+	if n == 0 {
+		return nil
+	}
+	if _, err := w.writer.Write(buf[:n]); err != nil {
+		return fmt.Errorf("unable to write the flushed data: %w", err)
+	}
+	return nil
+}
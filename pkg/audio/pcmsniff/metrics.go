@@ -0,0 +1,207 @@
+package pcmsniff
+
+import (
+	"encoding/binary"
+	"math"
+
+	"github.com/mjibson/go-dsp/fft"
+	"github.com/xaionaro-go/audio/pkg/audio/types"
+)
+
+const (
+	// minFrames is the minimal amount of frames for a candidate to be analyzed.
+	minFrames = 64
+
+	fftWindowSize = 512
+	maxFFTWindows = 64
+
+	// validMagnitude is the maximal magnitude of a valid float sample.
+	validMagnitude = 1.5
+
+	int24Penalty = 0.99
+)
+
+func float64Slice(b []byte) []float64 {
+	result := make([]float64, len(b)/8)
+	for idx := range result {
+		result[idx] = math.Float64frombits(binary.LittleEndian.Uint64(b[idx*8:]))
+	}
+	return result
+}
+
+func analyzeSamples(samples []float64, channels int, pcmFormat types.PCMFormat) Candidate {
+	var c Candidate
+
+	// the invalid samples are zeroed, so that they do not dominate the other metrics
+	var valid, concentrated int
+	for idx, v := range samples {
+		if math.IsNaN(v) || math.Abs(v) > validMagnitude {
+			samples[idx] = 0
+			continue
+		}
+		valid++
+		if math.Abs(v) < 0.5 {
+			concentrated++
+		}
+	}
+	c.Validity = float64(valid) / float64(len(samples))
+	c.Concentration = float64(concentrated) / float64(len(samples))
+
+	perChannel := deinterleave(samples, channels)
+	c.Smoothness = 1
+	for _, channel := range perChannel {
+		c.Smoothness = min(c.Smoothness, autocorrelation(channel))
+		c.SpectralFlatness += spectralFlatness(channel) / float64(channels)
+	}
+	if channels > 1 {
+		var pairs int
+		for i := range perChannel {
+			for j := i + 1; j < len(perChannel); j++ {
+				c.ChannelCorrelation += math.Abs(correlation(perChannel[i], perChannel[j]))
+				pairs++
+			}
+		}
+		c.ChannelCorrelation /= float64(pairs)
+	}
+	c.LowBitsSmoothness = lowBitsSmoothness(perChannel, pcmFormat)
+
+	c.Score = c.Validity * (0.45*max(c.Smoothness, 0) + 0.35*(1-c.SpectralFlatness) + 0.2*c.Concentration) * (1 - c.LowBitsSmoothness)
+	if (pcmFormat == types.PCMFormatS32LE || pcmFormat == types.PCMFormatS32BE) && fitsInt24(samples) {
+		// the same data is also a valid S24_32 (which is more likely then)
+		c.Score *= int24Penalty
+	}
+	return c
+}
+
+func deinterleave(samples []float64, channels int) [][]float64 {
+	frames := len(samples) / channels
+	result := make([][]float64, channels)
+	for ch := range result {
+		result[ch] = make([]float64, frames)
+		for idx := range frames {
+			result[ch][idx] = samples[idx*channels+ch]
+		}
+	}
+	return result
+}
+
+func mean(x []float64) float64 {
+	var sum float64
+	for _, v := range x {
+		sum += v
+	}
+	return sum / float64(len(x))
+}
+
+// autocorrelation returns the lag-1 autocorrelation; a constant signal
+// (e.g. silence) is considered smooth.
+func autocorrelation(x []float64) float64 {
+	m := mean(x)
+	var num, den float64
+	for idx, v := range x {
+		d := v - m
+		den += d * d
+		if idx > 0 {
+			num += d * (x[idx-1] - m)
+		}
+	}
+	if den == 0 {
+		return 1
+	}
+	return num / den
+}
+
+func correlation(x, y []float64) float64 {
+	mx, my := mean(x), mean(y)
+	var num, dx, dy float64
+	for idx := range x {
+		a, b := x[idx]-mx, y[idx]-my
+		num += a * b
+		dx += a * a
+		dy += b * b
+	}
+	if dx == 0 || dy == 0 {
+		return 0
+	}
+	return num / math.Sqrt(dx*dy)
+}
+
+// spectralFlatness returns the spectral flatness averaged over the
+// windows (the silent windows are skipped; zero if all of them are silent).
+func spectralFlatness(x []float64) float64 {
+	var (
+		sum     float64
+		windows int
+	)
+	window := make([]float64, fftWindowSize)
+	for offset := 0; offset+fftWindowSize <= len(x) && windows < maxFFTWindows; offset += fftWindowSize {
+		m := mean(x[offset : offset+fftWindowSize])
+		for idx := range window {
+			// the Hann window
+			w := 0.5 - 0.5*math.Cos(2*math.Pi*float64(idx)/float64(fftWindowSize-1))
+			window[idx] = (x[offset+idx] - m) * w
+		}
+		spectrum := fft.FFTReal(window)
+		var logSum, powerSum float64
+		bins := spectrum[1 : fftWindowSize/2]
+		for _, v := range bins {
+			power := real(v)*real(v) + imag(v)*imag(v) + 1e-20
+			logSum += math.Log(power)
+			powerSum += power
+		}
+		arithmeticMean := powerSum / float64(len(bins))
+		if arithmeticMean < 1e-12 {
+			continue
+		}
+		sum += math.Exp(logSum/float64(len(bins))) / arithmeticMean
+		windows++
+	}
+	if windows == 0 {
+		return 0
+	}
+	return sum / float64(windows)
+}
+
+// lowBitsSmoothness splits the integer samples into the higher and lower
+// halves, and returns the minimal smoothness of the lower halves if the
+// higher halves are not (almost) silent.
+func lowBitsSmoothness(perChannel [][]float64, pcmFormat types.PCMFormat) float64 {
+	var bits uint
+	switch pcmFormat {
+	case types.PCMFormatS16LE, types.PCMFormatS16BE, types.PCMFormatU16LE, types.PCMFormatU16BE:
+		bits = 16
+	case types.PCMFormatS32LE, types.PCMFormatS32BE:
+		bits = 32
+	default:
+		return 0
+	}
+	half := bits / 2
+	scale := math.Exp2(float64(bits - 1))
+	result := 1.0
+	for _, channel := range perChannel {
+		hi := make([]float64, len(channel))
+		lo := make([]float64, len(channel))
+		var hiEnergy float64
+		for idx, v := range channel {
+			i := int64(math.Round(v * scale))
+			hi[idx] = float64(i >> half)
+			lo[idx] = float64(int64(uint64(i)<<(64-half)) >> (64 - half))
+			hiEnergy += hi[idx] * hi[idx]
+		}
+		if math.Sqrt(hiEnergy/float64(len(hi))) < 4 {
+			return 0
+		}
+		result = min(result, autocorrelation(lo))
+	}
+	return max(result, 0)
+}
+
+// fitsInt24 returns true if all the S32 samples are sign-extended 24-bit values.
+func fitsInt24(samples []float64) bool {
+	for _, v := range samples {
+		if math.Abs(v) >= 1.0/256 {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,161 @@
+// Package pcmsniff guesses the format of headerless PCM data.
+//
+// Every candidate interpretation (a PCM format and an amount of channels)
+// is decoded and scored by how much it looks like audio: the samples of
+// real audio are correlated with their neighbours, their spectrum is far
+// from flat (unlike noise), and their values are concentrated around zero
+// (and are finite, for floats). A wrong sample size, endianness or
+// signedness turns the audio into noise-like or off-centre values.
+package pcmsniff
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/xaionaro-go/audio/pkg/audio/resampler"
+	"github.com/xaionaro-go/audio/pkg/audio/types"
+)
+
+// Config defines the candidates to be checked.
+type Config struct {
+	// PCMFormats are the candidate formats; on equal scores the earlier
+	// formats are preferred.
+	PCMFormats []types.PCMFormat
+	Channels   []types.Channel
+
+	// MaxBytes is the amount of bytes from the beginning of the data to
+	// be analyzed (zero means all of them).
+	MaxBytes int
+}
+
+// DefaultConfig returns the linear formats in the order of how common
+// they are, and up to 2 channels.
+func DefaultConfig() Config {
+	return Config{
+		PCMFormats: []types.PCMFormat{
+			types.PCMFormatS16LE,
+			types.PCMFormatFloat32LE,
+			types.PCMFormatS32LE,
+			types.PCMFormatS24LE,
+			types.PCMFormatU8,
+			types.PCMFormatS24_32LE,
+			types.PCMFormatS16BE,
+			types.PCMFormatFloat32BE,
+			types.PCMFormatS32BE,
+			types.PCMFormatS24BE,
+			types.PCMFormatS8,
+			types.PCMFormatFloat64LE,
+			types.PCMFormatFloat64BE,
+			types.PCMFormatU16LE,
+			types.PCMFormatU16BE,
+		},
+		Channels: []types.Channel{1, 2},
+		MaxBytes: 1 << 20,
+	}
+}
+
+// Candidate is an interpretation of the data with its score and the
+// metrics the score is based on.
+type Candidate struct {
+	PCMFormat types.PCMFormat
+	Channels  types.Channel
+
+	// Score is in [0, 1], the higher the more likely.
+	Score float64
+
+	// Smoothness is the lag-1 autocorrelation of the samples (the
+	// minimum over the channels); close to 1 for audio, around 0 for noise.
+	Smoothness float64
+
+	// SpectralFlatness is the ratio of the geometric and arithmetic means
+	// of the power spectrum (averaged over the channels); close to 1 for noise.
+	SpectralFlatness float64
+
+	// ChannelCorrelation is the mean absolute correlation between the
+	// channels (zero for mono).
+	ChannelCorrelation float64
+
+	// Concentration is the share of the samples with the magnitude
+	// below a half of the full scale.
+	Concentration float64
+
+	// Validity is the share of the samples which are finite and within
+	// the full scale (less than 1 only for the float formats).
+	Validity float64
+
+	// LowBitsSmoothness is the smoothness of the lower halves of the
+	// integer samples, if the higher halves are not silent; it is high
+	// if the samples are actually pairs of smaller samples.
+	LowBitsSmoothness float64
+}
+
+func (c Candidate) String() string {
+	return fmt.Sprintf("%v %dch (score %.3f)", c.PCMFormat, c.Channels, c.Score)
+}
+
+// Analyze scores all the candidates from the config and returns them
+// sorted by the score (the most likely first).
+func Analyze(data []byte, cfg Config) ([]Candidate, error) {
+	if cfg.MaxBytes > 0 && len(data) > cfg.MaxBytes {
+		data = data[:cfg.MaxBytes]
+	}
+	var candidates []Candidate
+	for _, pcmFormat := range cfg.PCMFormats {
+		if !pcmFormat.IsLinear() {
+			return nil, fmt.Errorf("non-linear format %v is not supported", pcmFormat)
+		}
+		for _, channels := range cfg.Channels {
+			frameSize := int(pcmFormat.Size()) * int(channels)
+			frames := len(data) / frameSize
+			if frames < minFrames {
+				continue
+			}
+			samples, err := decode(data[:frames*frameSize], pcmFormat, channels)
+			if err != nil {
+				return nil, err
+			}
+			candidate := analyzeSamples(samples, int(channels), pcmFormat)
+			candidate.PCMFormat = pcmFormat
+			candidate.Channels = channels
+			candidates = append(candidates, candidate)
+		}
+	}
+	if len(candidates) == 0 {
+		return nil, fmt.Errorf("not enough data: %d bytes", len(data))
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].Score > candidates[j].Score
+	})
+	return candidates, nil
+}
+
+// Sniff returns the most likely interpretation of the data using DefaultConfig.
+func Sniff(data []byte) (Candidate, error) {
+	candidates, err := Analyze(data, DefaultConfig())
+	if err != nil {
+		return Candidate{}, err
+	}
+	return candidates[0], nil
+}
+
+// decode converts the data into interleaved float64 samples.
+func decode(data []byte, pcmFormat types.PCMFormat, channels types.Channel) ([]float64, error) {
+	format := resampler.Format{
+		Channels:   channels,
+		SampleRate: 48000,
+		PCMFormat:  pcmFormat,
+	}
+	outFormat := format
+	outFormat.PCMFormat = types.PCMFormatFloat64LE
+	r, err := resampler.NewResampler(format, bytes.NewReader(data), outFormat)
+	if err != nil {
+		return nil, fmt.Errorf("unable to initialize a converter from %v: %w", pcmFormat, err)
+	}
+	converted, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("unable to convert from %v: %w", pcmFormat, err)
+	}
+	return float64Slice(converted), nil
+}
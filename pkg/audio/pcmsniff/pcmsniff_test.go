@@ -0,0 +1,63 @@
+package pcmsniff
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/xaionaro-go/audio/pkg/audio/resampler"
+	"github.com/xaionaro-go/audio/pkg/audio/types"
+)
+
+// synthesize generates a few seconds of music-like audio in the given format.
+func synthesize(t *testing.T, pcmFormat types.PCMFormat, channels types.Channel) []byte {
+	rng := rand.New(rand.NewSource(0))
+	const frames = 24000
+	b := make([]byte, frames*int(channels)*8)
+	for idx := range frames {
+		for ch := range int(channels) {
+			tm := float64(idx) / 48000
+			v := 0.3*math.Sin(2*math.Pi*220*tm*float64(ch+1)) +
+				0.15*math.Sin(2*math.Pi*1330*tm+float64(ch)) +
+				0.01*rng.NormFloat64()
+			binary.LittleEndian.PutUint64(b[(idx*int(channels)+ch)*8:], math.Float64bits(v))
+		}
+	}
+	format := resampler.Format{Channels: channels, SampleRate: 48000, PCMFormat: types.PCMFormatFloat64LE}
+	outFormat := format
+	outFormat.PCMFormat = pcmFormat
+	r, err := resampler.NewResampler(format, bytes.NewReader(b), outFormat)
+	require.NoError(t, err)
+	result, err := io.ReadAll(r)
+	require.NoError(t, err)
+	return result
+}
+
+func TestAnalyze(t *testing.T) {
+	for _, pcmFormat := range DefaultConfig().PCMFormats {
+		for _, channels := range []types.Channel{1, 2} {
+			t.Run(fmt.Sprintf("%v_%dch", pcmFormat, channels), func(t *testing.T) {
+				candidates, err := Analyze(synthesize(t, pcmFormat, channels), DefaultConfig())
+				require.NoError(t, err)
+				require.Equal(t, pcmFormat, candidates[0].PCMFormat, "%v", candidates[:3])
+				require.Equal(t, channels, candidates[0].Channels, "%v", candidates[:3])
+			})
+		}
+	}
+}
+
+func TestSniff(t *testing.T) {
+	c, err := Sniff(synthesize(t, types.PCMFormatS16LE, 2))
+	require.NoError(t, err)
+	require.Equal(t, types.PCMFormatS16LE, c.PCMFormat)
+	require.Equal(t, types.Channel(2), c.Channels)
+	require.Greater(t, c.ChannelCorrelation, 0.0)
+
+	_, err = Sniff([]byte{1, 2, 3})
+	require.Error(t, err)
+}
@@ -1,17 +1,31 @@
 package planar
 
 import (
+	"errors"
 	"fmt"
 	"io"
 
 	"github.com/xaionaro-go/audio/pkg/audio"
 )
 
+// maxConsecutiveEmptyReads is the amount of (0, nil) reads from the
+// backend after which PlanarizeReader.Read gives up with io.ErrNoProgress.
+const maxConsecutiveEmptyReads = 100
+
 type PlanarizeReader struct {
 	Backend    io.Reader
 	Channels   audio.Channel
 	SampleSize uint
 	Buffer     []byte
+
+	// PCMFormat defines the silence to complete the last frame with if
+	// the data ends with an incomplete frame (zeros if it is not set).
+	PCMFormat audio.PCMFormat
+
+	// pending is the amount of bytes of an incomplete frame in the
+	// beginning of Buffer.
+	pending int
+	err     error
 }
 
 var _ io.Reader = (*PlanarizeReader)(nil)
@@ -19,9 +33,10 @@ var _ io.Reader = (*PlanarizeReader)(nil)
 func NewPlanarizeReader(
 	backend io.Reader,
 	channels audio.Channel,
-	sampleSize uint,
+	pcmFormat audio.PCMFormat,
 	bufferSize uint,
 ) *PlanarizeReader {
+	sampleSize := uint(pcmFormat.Size())
 	if bufferSize%(sampleSize*uint(channels)) != 0 {
 		panic(fmt.Errorf("buffer size in not a multiple of sampleSize*channels: %d %% %d*%d != 0", bufferSize, sampleSize, uint(channels)))
 	}
@@ -30,33 +45,78 @@ func NewPlanarizeReader(
 		Channels:   channels,
 		SampleSize: sampleSize,
 		Buffer:     make([]byte, bufferSize),
+		PCMFormat:  pcmFormat,
 	}
 }
 
+// Read planarizes whole frames only: an incomplete frame read from the
+// backend is kept until the next call, and if the data ends with an
+// incomplete frame, it is completed with silence.
+// It returns (0, nil) only if "p" is shorter than a frame.
 func (r *PlanarizeReader) Read(p []byte) (int, error) {
 	shortestMessageSize := int(r.Channels) * int(r.SampleSize)
 	if len(p) < shortestMessageSize {
-		return 0, fmt.Errorf("the provided output buffer is too short: %d < %d", len(p), shortestMessageSize)
+		return 0, nil
 	}
-	requestLength := (len(p) / shortestMessageSize) * shortestMessageSize
-	if requestLength > len(r.Buffer) {
-		requestLength = len(r.Buffer)
+	requestLength := min(len(p), len(r.Buffer)) / shortestMessageSize * shortestMessageSize
+	if requestLength == 0 {
+		return 0, fmt.Errorf("the buffer is too short: %d < %d", len(r.Buffer), shortestMessageSize)
 	}
 
-	n, err := r.Backend.Read(r.Buffer[:requestLength])
-	if err != nil {
-		return n, fmt.Errorf("unable to read from the backend: %w", err)
-	}
-	if n > requestLength {
-		return n, fmt.Errorf("received more bytes than requested: %d > %d", n, requestLength)
-	}
+	for emptyReads := 0; ; {
+		if r.err != nil {
+			err := r.err
+			if !errors.Is(err, io.EOF) {
+				r.err = nil
+			}
+			return 0, err
+		}
+
+		read, err := r.Backend.Read(r.Buffer[r.pending:requestLength])
+		if read > requestLength-r.pending {
+			return 0, fmt.Errorf("received more bytes than requested: %d > %d", read, requestLength-r.pending)
+		}
+		n := r.pending + read
+		if err != nil {
+			r.err = fmt.Errorf("unable to read from the backend: %w", err)
+			if errors.Is(err, io.EOF) && n%shortestMessageSize != 0 {
+				n = r.padFrame(n)
+			}
+		}
 
-	err = Planarize(r.Channels, r.SampleSize, p[:n], r.Buffer[:n])
-	if err != nil {
-		return n, fmt.Errorf("unable to planarize: %w", err)
+		messageLength := n / shortestMessageSize * shortestMessageSize
+		if messageLength == 0 {
+			r.pending = n
+			if read == 0 && err == nil {
+				emptyReads++
+				if emptyReads >= maxConsecutiveEmptyReads {
+					return 0, io.ErrNoProgress
+				}
+			}
+			continue
+		}
+		err = Planarize(r.Channels, r.SampleSize, p[:messageLength], r.Buffer[:messageLength])
+		r.pending = copy(r.Buffer, r.Buffer[messageLength:n])
+		if err != nil {
+			return 0, fmt.Errorf("unable to planarize: %w", err)
+		}
+		return messageLength, nil
 	}
+}
 
-	return n, nil
+// padFrame completes the incomplete frame in the end of the first "n"
+// bytes of Buffer with silence, and returns the new length.
+func (r *PlanarizeReader) padFrame(n int) int {
+	shortestMessageSize := int(r.Channels) * int(r.SampleSize)
+	silence := r.PCMFormat.Silence()
+	end := (n + shortestMessageSize - 1) / shortestMessageSize * shortestMessageSize
+	for idx := n; idx < end; idx++ {
+		r.Buffer[idx] = 0
+		if len(silence) == int(r.SampleSize) {
+			r.Buffer[idx] = silence[idx%int(r.SampleSize)]
+		}
+	}
+	return end
 }
 
 func Planarize(channels audio.Channel, sampleSize uint, output, input []byte) error {
@@ -6,8 +6,10 @@ import (
 	"errors"
 	"io"
 	"testing"
+	"testing/iotest"
 
 	"github.com/stretchr/testify/require"
+	"github.com/xaionaro-go/audio/pkg/audio"
 )
 
 func TestPlanarizeAndUnplanarize(t *testing.T) {
@@ -35,9 +37,72 @@ func TestUnplanarizeReaderAndPlanarizeReader(t *testing.T) {
 	b := must(hex.DecodeString("000102030405060708090A0B0C0D0E0F101112131415161718191A1B1C1D1E1F"))
 	orig := bytes.NewReader(b)
 	unplanared := NewUnplanarizeReader(orig, 2, 4, 65536)
-	planared := NewPlanarizeReader(unplanared, 2, 4, 65536)
+	planared := NewPlanarizeReader(unplanared, 2, audio.PCMFormatS32LE, 65536)
 
 	r, err := io.ReadAll(planared)
 	require.True(t, errors.Is(err, io.EOF), err)
 	require.Equal(t, b, r)
 }
+
+func TestPlanarizeReaderPartialFrames(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		in       string
+		lastPair string
+	}{
+		{name: "IncompleteFrame", in: "1011", lastPair: "1011 0080"},
+		{name: "IncompleteSample", in: "10", lastPair: "1080 0080"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			b := must(hex.DecodeString(clean("00010203 04050607 08090A0B 0C0D0E0F " + tc.in)))
+			// the backend returns one byte at a time, the last frame is incomplete
+			planared := NewPlanarizeReader(iotest.OneByteReader(bytes.NewReader(b)), 2, audio.PCMFormatU16LE, 8)
+
+			n, err := planared.Read(make([]byte, 3))
+			require.NoError(t, err)
+			require.Zero(t, n)
+
+			var result []byte
+			buf := make([]byte, 8)
+			for {
+				n, err := planared.Read(buf)
+				result = append(result, buf[:n]...)
+				if err != nil {
+					require.ErrorIs(t, err, io.EOF)
+					break
+				}
+				require.Equal(t, 4, n)
+			}
+			// the incomplete frame is completed with silence (0x8000 in U16LE)
+			expected := must(hex.DecodeString(clean("0001 0203 0405 0607 0809 0A0B 0C0D 0E0F " + tc.lastPair)))
+			require.Equal(t, expected, result)
+			_, err = planared.Read(buf)
+			require.ErrorIs(t, err, io.EOF)
+		})
+	}
+}
+
+type noProgressReader struct{}
+
+func (noProgressReader) Read([]byte) (int, error) {
+	return 0, nil
+}
+
+func TestPlanarizeReaderNoProgress(t *testing.T) {
+	// e.g. a non-blocking pipe with an incomplete frame in it
+	planared := NewPlanarizeReader(io.MultiReader(bytes.NewReader([]byte{1, 2, 3}), noProgressReader{}), 2, audio.PCMFormatS16LE, 8)
+	n, err := planared.Read(make([]byte, 8))
+	require.ErrorIs(t, err, io.ErrNoProgress)
+	require.Zero(t, n)
+}
+
+func TestPlanarizeReaderError(t *testing.T) {
+	someErr := errors.New("some error")
+	planared := NewPlanarizeReader(io.MultiReader(bytes.NewReader([]byte{1, 2, 3, 4, 5}), iotest.ErrReader(someErr)), 2, audio.PCMFormatS16LE, 8)
+	buf := make([]byte, 8)
+	n, err := planared.Read(buf)
+	require.NoError(t, err)
+	require.Equal(t, 4, n)
+	_, err = planared.Read(buf)
+	require.ErrorIs(t, err, someErr)
+}
@@ -0,0 +1,39 @@
+package audio
+
+import (
+	"time"
+
+	"github.com/xaionaro-go/audio/pkg/audio/codec/vorbis"
+)
+
+// VorbisPlayStream is a PlayStream of Ogg Vorbis (see Player.PlayVorbis).
+type VorbisPlayStream struct {
+	PlayStream
+	Decoder *vorbis.Decoder
+}
+
+// Duration returns the total duration, or zero if it is unknown.
+func (s *VorbisPlayStream) Duration() time.Duration {
+	return s.Decoder.Duration()
+}
+
+// Position returns the position of the decoder, which is ahead of
+// the actually played audio by the amount of buffered data.
+func (s *VorbisPlayStream) Position() time.Duration {
+	return s.Decoder.Position()
+}
+
+func (s *VorbisPlayStream) Seek(position time.Duration) error {
+	return s.Decoder.Seek(position)
+}
+
+// Metadata returns the Vorbis comments (with upper-case keys).
+func (s *VorbisPlayStream) Metadata() map[string]string {
+	return s.Decoder.Metadata()
+}
+
+// SetReplayGain enables (or disables with ReplayGainModeOff) applying the
+// ReplayGain from the comments; it takes effect on the not yet decoded audio.
+func (s *VorbisPlayStream) SetReplayGain(mode vorbis.ReplayGainMode, preampDB float64) {
+	s.Decoder.SetReplayGain(mode, preampDB)
+}
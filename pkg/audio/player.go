@@ -4,12 +4,16 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"os"
 	"sync"
 	"time"
 
 	"github.com/facebookincubator/go-belt/tool/logger"
 	"github.com/hashicorp/go-multierror"
-	"github.com/jfreymuth/oggvorbis"
+	"github.com/xaionaro-go/audio/pkg/audio/codec"
+	// registering all the decoders for PlayReader and PlayFile
+	_ "github.com/xaionaro-go/audio/pkg/audio/codec/all"
+	"github.com/xaionaro-go/audio/pkg/audio/codec/vorbis"
 	"github.com/xaionaro-go/audio/pkg/audio/registry"
 )
 
@@ -77,23 +81,71 @@ func NewPlayerAuto(
 	}
 }
 
-// TODO: split this away; player for every format should be in a separate package
+// PlayVorbis plays Ogg Vorbis. Seeking and the duration are available
+// only if "rawReader" is an io.ReadSeeker.
 func (a *Player) PlayVorbis(
 	ctx context.Context,
 	rawReader io.Reader,
+) (*VorbisPlayStream, error) {
+	decoder, err := vorbis.NewDecoder(rawReader)
+	if err != nil {
+		return nil, err
+	}
+	stream, err := a.PlayDecoder(ctx, decoder)
+	if err != nil {
+		return nil, err
+	}
+	return &VorbisPlayStream{
+		PlayStream: stream,
+		Decoder:    decoder,
+	}, nil
+}
+
+// PlayReader detects the format of the data (see package codec) and plays it.
+func (a *Player) PlayReader(
+	ctx context.Context,
+	reader io.Reader,
+) (PlayStream, error) {
+	decoder, err := codec.NewDecoder(reader)
+	if err != nil {
+		return nil, fmt.Errorf("unable to initialize a decoder: %w", err)
+	}
+	return a.PlayDecoder(ctx, decoder)
+}
+
+// PlayFile plays the file in any of the formats registered in package codec.
+// The file is closed when the returned stream is closed.
+func (a *Player) PlayFile(
+	ctx context.Context,
+	filePath string,
 ) (PlayStream, error) {
-	oggReader, err := oggvorbis.NewReader(rawReader)
+	f, err := os.Open(filePath)
 	if err != nil {
-		return nil, fmt.Errorf("unable to initialize a vorbis reader: %w", err)
+		return nil, fmt.Errorf("unable to open '%s': %w", filePath, err)
 	}
+	stream, err := a.PlayReader(ctx, f)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &playStreamWithCloser{
+		PlayStream: stream,
+		Closer:     f,
+	}, nil
+}
 
-	stream, err := a.PlayerPCM.PlayPCM(
+func (a *Player) PlayDecoder(
+	ctx context.Context,
+	decoder codec.Decoder,
+) (PlayStream, error) {
+	format := decoder.Format()
+	stream, err := a.PlayPCM(
 		ctx,
-		SampleRate(oggReader.SampleRate()),
-		Channel(oggReader.Channels()),
-		PCMFormatFloat32LE,
+		format.SampleRate,
+		format.Channels,
+		format.PCMFormat,
 		BufferSize,
-		newReaderFromFloat32Reader(oggReader),
+		decoder,
 	)
 	if err != nil {
 		return nil, fmt.Errorf("unable to playback as PCM: %w", err)
@@ -101,6 +153,22 @@ func (a *Player) PlayVorbis(
 	return stream, nil
 }
 
+type playStreamWithCloser struct {
+	PlayStream
+	io.Closer
+}
+
+func (s *playStreamWithCloser) Close() error {
+	var mErr *multierror.Error
+	if err := s.PlayStream.Close(); err != nil {
+		mErr = multierror.Append(mErr, err)
+	}
+	if err := s.Closer.Close(); err != nil {
+		mErr = multierror.Append(mErr, err)
+	}
+	return mErr.ErrorOrNil()
+}
+
 func (a *Player) PlayPCM(
 	ctx context.Context,
 	sampleRate SampleRate,
@@ -109,6 +177,10 @@ func (a *Player) PlayPCM(
 	bufferSize time.Duration,
 	pcmReader io.Reader,
 ) (PlayStream, error) {
+	if !pcmFormat.IsLinear() {
+		pcmReader = newLinearReader(pcmReader, pcmFormat, channels)
+		pcmFormat = PCMFormatS16LE
+	}
 	return a.PlayerPCM.PlayPCM(
 		ctx,
 		sampleRate,
@@ -80,11 +80,47 @@ func (a *Recorder) RecordPCM(
 	pcmFormat PCMFormat,
 	pcmWriter io.Writer,
 ) (RecordStream, error) {
-	return a.RecorderPCM.RecordPCM(
+	if pcmFormat.IsLinear() {
+		return a.RecorderPCM.RecordPCM(
+			ctx,
+			sampleRate,
+			channels,
+			pcmFormat,
+			pcmWriter,
+		)
+	}
+
+	nonlinearWriter := newNonlinearWriter(pcmWriter, pcmFormat, channels)
+	stream, err := a.RecorderPCM.RecordPCM(
 		ctx,
 		sampleRate,
 		channels,
-		pcmFormat,
-		pcmWriter,
+		PCMFormatS16LE,
+		nonlinearWriter,
 	)
+	if err != nil {
+		return nil, err
+	}
+	return &recordStreamWithCloser{
+		RecordStream: stream,
+		Closer:       nonlinearWriter,
+	}, nil
+}
+
+// recordStreamWithCloser closes the Closer after the stream, i.e. when
+// nothing is written into it anymore.
+type recordStreamWithCloser struct {
+	RecordStream
+	io.Closer
+}
+
+func (s *recordStreamWithCloser) Close() error {
+	var mErr *multierror.Error
+	if err := s.RecordStream.Close(); err != nil {
+		mErr = multierror.Append(mErr, err)
+	}
+	if err := s.Closer.Close(); err != nil {
+		mErr = multierror.Append(mErr, err)
+	}
+	return mErr.ErrorOrNil()
 }
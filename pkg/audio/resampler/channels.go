@@ -0,0 +1,288 @@
+package resampler
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+
+	"github.com/xaionaro-go/audio/pkg/audio/types"
+)
+
+// ChannelMatrix defines how the channels are mixed: the output channel "o"
+// is the sum of the input channels "i" multiplied by ChannelMatrix[o][i].
+//
+// The channel order of the presets is the one of WAV/SMPTE: FL, FR, FC,
+// LFE, BL, BR (5.1), then SL, SR (7.1).
+type ChannelMatrix [][]float64
+
+// OutputChannels returns the amount of the rows.
+func (m ChannelMatrix) OutputChannels() types.Channel {
+	return types.Channel(len(m))
+}
+
+// InputChannels returns the amount of the columns.
+func (m ChannelMatrix) InputChannels() types.Channel {
+	if len(m) == 0 {
+		return 0
+	}
+	return types.Channel(len(m[0]))
+}
+
+// Validate returns an error if the matrix is empty or not rectangular.
+func (m ChannelMatrix) Validate() error {
+	if len(m) == 0 || len(m[0]) == 0 {
+		return fmt.Errorf("the channel matrix is empty")
+	}
+	for idx, row := range m {
+		if len(row) != len(m[0]) {
+			return fmt.Errorf("row %d of the channel matrix has %d columns instead of %d", idx, len(row), len(m[0]))
+		}
+	}
+	return nil
+}
+
+// Normalized returns a copy of the matrix with every row scaled down (if
+// needed) to make the sum of the absolute gains at most 1, so that the
+// mixing never clips.
+func (m ChannelMatrix) Normalized() ChannelMatrix {
+	result := make(ChannelMatrix, len(m))
+	for o, row := range m {
+		var sum float64
+		for _, gain := range row {
+			sum += math.Abs(gain)
+		}
+		result[o] = make([]float64, len(row))
+		for i, gain := range row {
+			if sum > 1 {
+				gain /= sum
+			}
+			result[o][i] = gain
+		}
+	}
+	return result
+}
+
+func newChannelMatrix(in, out types.Channel) ChannelMatrix {
+	m := make(ChannelMatrix, out)
+	for o := range m {
+		m[o] = make([]float64, in)
+	}
+	return m
+}
+
+// ChannelMatrixIdentity returns the matrix which keeps the channels as is.
+func ChannelMatrixIdentity(channels types.Channel) ChannelMatrix {
+	m := newChannelMatrix(channels, channels)
+	for idx := range m {
+		m[idx][idx] = 1
+	}
+	return m
+}
+
+// ChannelMatrixSelect returns the matrix which makes the output channel
+// "o" a copy of the input channel channels[o] (counting from zero). It
+// covers selecting, swapping and duplicating channels; e.g. (8, 3) picks
+// the fourth channel of 8, and (2, 1, 0) swaps the stereo channels.
+func ChannelMatrixSelect(in types.Channel, channels ...int) (ChannelMatrix, error) {
+	if len(channels) == 0 {
+		return nil, fmt.Errorf("no channels selected")
+	}
+	m := newChannelMatrix(in, types.Channel(len(channels)))
+	for o, i := range channels {
+		if i < 0 || i >= int(in) {
+			return nil, fmt.Errorf("channel %d is out of range [0, %d)", i, in)
+		}
+		m[o][i] = 1
+	}
+	return m, nil
+}
+
+// ChannelMatrixUpmixStereo returns the matrix which puts stereo into the
+// front channels of 5.1 or 7.1 (the other channels are silent).
+func ChannelMatrixUpmixStereo(out types.Channel) (ChannelMatrix, error) {
+	if out != 6 && out != 8 {
+		return nil, fmt.Errorf("upmixing stereo is supported only to 6 or 8 channels, but requested %d", out)
+	}
+	m := newChannelMatrix(2, out)
+	m[0][0] = 1
+	m[1][1] = 1
+	return m, nil
+}
+
+// DownmixWeights are the gains of the non-front channels when downmixing
+// to stereo.
+type DownmixWeights struct {
+	Center   float64
+	LFE      float64
+	Surround float64
+}
+
+// DefaultDownmixWeights returns the ITU-R BS.775 weights (the LFE is dropped).
+func DefaultDownmixWeights() DownmixWeights {
+	return DownmixWeights{
+		Center:   math.Sqrt2 / 2,
+		LFE:      0,
+		Surround: math.Sqrt2 / 2,
+	}
+}
+
+// ChannelMatrixDownmix returns the matrix which downmixes 5.1 or 7.1 to
+// stereo. The result may clip; see Normalized.
+func ChannelMatrixDownmix(in types.Channel, weights DownmixWeights) (ChannelMatrix, error) {
+	if in != 6 && in != 8 {
+		return nil, fmt.Errorf("downmixing is supported only from 6 or 8 channels, but requested %d", in)
+	}
+	m := newChannelMatrix(in, 2)
+	for side := range 2 {
+		m[side][side] = 1
+		m[side][2] = weights.Center
+		m[side][3] = weights.LFE
+		for surround := 4 + side; surround < int(in); surround += 2 {
+			m[side][surround] = weights.Surround
+		}
+	}
+	return m, nil
+}
+
+// DefaultChannelMatrix returns the matrix used if none is given: identity,
+// duplicating mono, averaging into mono, upmixing stereo into 5.1/7.1 and
+// (normalized) downmixing of 5.1/7.1 into stereo.
+func DefaultChannelMatrix(in, out types.Channel) (ChannelMatrix, error) {
+	switch {
+	case in == 0 || out == 0:
+		return nil, fmt.Errorf("zero channels: %d -> %d", in, out)
+	case in == out:
+		return ChannelMatrixIdentity(in), nil
+	case in == 1 || out == 1:
+		m := newChannelMatrix(in, out)
+		for o := range m {
+			for i := range m[o] {
+				m[o][i] = 1 / float64(in)
+			}
+		}
+		return m, nil
+	case in == 2 && (out == 6 || out == 8):
+		return ChannelMatrixUpmixStereo(out)
+	case (in == 6 || in == 8) && out == 2:
+		m, err := ChannelMatrixDownmix(in, DefaultDownmixWeights())
+		if err != nil {
+			return nil, err
+		}
+		return m.Normalized(), nil
+	default:
+		return nil, fmt.Errorf("do not know how to convert %d channels to %d", in, out)
+	}
+}
+
+// initChannelMixer wraps the input into a channelMixer if a matrix is
+// given or the channels cannot be converted by averaging or duplicating.
+func (r *Resampler) initChannelMixer() error {
+	in, out := r.inFormat.Channels, r.outFormat.Channels
+	matrix := r.config.ChannelMatrix
+	if matrix == nil {
+		if in == out || in == 1 || out == 1 {
+			return nil
+		}
+		var err error
+		matrix, err = DefaultChannelMatrix(in, out)
+		if err != nil {
+			return err
+		}
+	}
+	if err := matrix.Validate(); err != nil {
+		return err
+	}
+	if matrix.InputChannels() != in || matrix.OutputChannels() != out {
+		return fmt.Errorf("the channel matrix is %dx%d, but expected %dx%d", matrix.OutputChannels(), matrix.InputChannels(), out, in)
+	}
+	r.inReader = newChannelMixer(r.inReader, r.inFormat.PCMFormat, matrix)
+	r.inFormat.Channels = out
+	r.inFormat.PCMFormat = types.PCMFormatFloat64LE
+	return nil
+}
+
+// channelMixer converts the channels of the input frames according to
+// the matrix, and outputs them in PCMFormatFloat64LE.
+type channelMixer struct {
+	reader    io.Reader
+	pcmFormat types.PCMFormat
+	matrix    ChannelMatrix
+	err       error
+
+	inBuf   []byte
+	partial int
+	outBuf  []byte
+	pending []byte
+	frame   []float64
+}
+
+func newChannelMixer(r io.Reader, pcmFormat types.PCMFormat, matrix ChannelMatrix) *channelMixer {
+	return &channelMixer{
+		reader:    r,
+		pcmFormat: pcmFormat,
+		matrix:    matrix,
+		frame:     make([]float64, matrix.InputChannels()),
+	}
+}
+
+func (m *channelMixer) Read(p []byte) (int, error) {
+	inFrameSize := int(m.pcmFormat.Size()) * len(m.frame)
+	outFrameSize := 8 * len(m.matrix)
+	for emptyReads := 0; len(m.pending) == 0; {
+		if m.err != nil {
+			// the error is not sticky, as the input may be resumed (see Writer)
+			err := m.err
+			m.err = nil
+			return 0, err
+		}
+		size := max(len(p)/outFrameSize, 1) * inFrameSize
+		if cap(m.inBuf) < size {
+			inBuf := make([]byte, size)
+			copy(inBuf, m.inBuf[:m.partial])
+			m.inBuf = inBuf
+		}
+		n, err := m.reader.Read(m.inBuf[m.partial:size])
+		if n == 0 && err == nil {
+			emptyReads++
+			if emptyReads >= maxConsecutiveEmptyReads {
+				return 0, io.ErrNoProgress
+			}
+			continue
+		}
+		emptyReads = 0
+		n += m.partial
+		if errors.Is(err, io.EOF) {
+			m.inBuf = padFrame(m.inBuf[:n], m.pcmFormat, inFrameSize)
+			n = len(m.inBuf)
+		}
+		frames := n / inFrameSize
+		if cap(m.outBuf) < frames*outFrameSize {
+			m.outBuf = make([]byte, frames*outFrameSize)
+		}
+		m.pending = m.outBuf[:frames*outFrameSize]
+		for idx := range frames {
+			m.mixFrame(m.pending[idx*outFrameSize:], m.inBuf[idx*inFrameSize:])
+		}
+		m.partial = copy(m.inBuf, m.inBuf[frames*inFrameSize:n])
+		m.err = err
+	}
+	n := copy(p, m.pending)
+	m.pending = m.pending[n:]
+	return n, nil
+}
+
+func (m *channelMixer) mixFrame(dst, src []byte) {
+	sampleSize := int(m.pcmFormat.Size())
+	for i := range m.frame {
+		m.frame[i] = getFloat64(m.pcmFormat, src[i*sampleSize:])
+	}
+	for o, row := range m.matrix {
+		var sum float64
+		for i, gain := range row {
+			sum += gain * m.frame[i]
+		}
+		binary.LittleEndian.PutUint64(dst[o*8:], math.Float64bits(sum))
+	}
+}
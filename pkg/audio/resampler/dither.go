@@ -0,0 +1,92 @@
+package resampler
+
+import (
+	"fmt"
+	"math"
+	"math/rand/v2"
+)
+
+// Dither defines how the samples are quantized when the bit depth is reduced.
+type Dither int
+
+const (
+	UndefinedDither = Dither(iota)
+
+	// DitherNone just rounds the samples.
+	DitherNone
+
+	// DitherTPDF adds a noise with the triangular probability density
+	// function (±1 LSB), which decorrelates the quantization error from
+	// the signal.
+	DitherTPDF
+
+	// DitherNoiseShaped is DitherTPDF with the quantization error fed back
+	// through a filter, which moves the noise towards the high
+	// frequencies (where it is less audible).
+	DitherNoiseShaped
+	EndOfDither
+)
+
+func (d Dither) String() string {
+	switch d {
+	case UndefinedDither:
+		return "<undefined>"
+	case DitherNone:
+		return "none"
+	case DitherTPDF:
+		return "tpdf"
+	case DitherNoiseShaped:
+		return "noise_shaped"
+	default:
+		return fmt.Sprintf("<unexpected_value_%d>", int(d))
+	}
+}
+
+// noiseShapingFilter is the 3-tap error feedback filter by Wannamaker.
+var noiseShapingFilter = [...]float64{1.623, -0.982, 0.109}
+
+// quantizer rounds the samples to the resolution of the output format
+// with dither.
+type quantizer struct {
+	dither   Dither
+	bits     uint
+	minValue float64
+	maxValue float64
+	rng      *rand.Rand
+
+	// errors contains the last quantization errors (in LSB) of each channel.
+	errors [][len(noiseShapingFilter)]float64
+}
+
+func newQuantizer(dither Dither, bits uint, channels int) *quantizer {
+	return &quantizer{
+		dither:   dither,
+		bits:     bits,
+		minValue: -math.Ldexp(1, int(bits)-1),
+		maxValue: math.Ldexp(1, int(bits)-1) - 1,
+		rng:      rand.New(rand.NewPCG(0, 0)),
+		errors:   make([][len(noiseShapingFilter)]float64, channels),
+	}
+}
+
+// quantize returns "v" rounded to the resolution (and saturated to the range).
+func (q *quantizer) quantize(ch int, v float64) float64 {
+	if math.IsNaN(v) {
+		return v
+	}
+	target := math.Ldexp(v, int(q.bits)-1)
+	errors := &q.errors[ch]
+	if q.dither == DitherNoiseShaped {
+		for idx, coef := range noiseShapingFilter {
+			target -= coef * errors[idx]
+		}
+	}
+	quantized := math.Round(target + q.rng.Float64() - q.rng.Float64())
+	quantized = min(max(quantized, q.minValue), q.maxValue)
+	if q.dither == DitherNoiseShaped {
+		copy(errors[1:], errors[:])
+		// limited to keep the feedback stable on clipping
+		errors[0] = min(max(quantized-target, -2), 2)
+	}
+	return math.Ldexp(quantized, 1-int(q.bits))
+}
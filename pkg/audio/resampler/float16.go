@@ -0,0 +1,67 @@
+package resampler
+
+import (
+	"math"
+)
+
+// float16frombits converts an IEEE 754 half-precision float into float32.
+func float16frombits(h uint16) float32 {
+	sign := uint32(h>>15) << 31
+	exponent := uint32(h>>10) & 0x1f
+	mantissa := uint32(h) & 0x3ff
+	switch exponent {
+	case 0:
+		// zero or subnormal
+		v := float32(mantissa) / (1 << 24)
+		if sign != 0 {
+			v = -v
+		}
+		return v
+	case 0x1f:
+		// infinity or NaN
+		return math.Float32frombits(sign | 0x7f800000 | mantissa<<13)
+	default:
+		return math.Float32frombits(sign | (exponent+127-15)<<23 | mantissa<<13)
+	}
+}
+
+// float16bits converts float32 into an IEEE 754 half-precision float
+// (rounding to the nearest, ties to even).
+func float16bits(f float32) uint16 {
+	b := math.Float32bits(f)
+	sign := uint16(b>>16) & 0x8000
+	exponent := int32(b>>23)&0xff - 127 + 15
+	mantissa := b & 0x7fffff
+	switch {
+	case int32(b>>23)&0xff == 0xff:
+		// infinity or NaN
+		if mantissa != 0 {
+			return sign | 0x7e00
+		}
+		return sign | 0x7c00
+	case exponent >= 0x1f:
+		return sign | 0x7c00
+	case exponent <= 0:
+		if exponent < -10 {
+			return sign
+		}
+		// subnormal
+		mantissa |= 0x800000
+		shift := uint32(14 - exponent)
+		half := uint32(1) << (shift - 1)
+		rounded := mantissa >> shift
+		rest := mantissa & (1<<shift - 1)
+		if rest > half || (rest == half && rounded&1 != 0) {
+			rounded++
+		}
+		return sign | uint16(rounded)
+	default:
+		rounded := uint32(exponent)<<10 | mantissa>>13
+		rest := mantissa & 0x1fff
+		if rest > 0x1000 || (rest == 0x1000 && rounded&1 != 0) {
+			// may overflow into the exponent, which is correct (up to infinity)
+			rounded++
+		}
+		return sign | uint16(rounded)
+	}
+}
@@ -0,0 +1,181 @@
+package resampler
+
+import (
+	"encoding/binary"
+	"math"
+
+	"github.com/xaionaro-go/audio/pkg/audio/types"
+)
+
+// decodeSamples converts the samples into float64 like getFloat64 does,
+// but the common formats are converted by specialized loops (without the
+// switch on the format per sample, and without bounds checks).
+func decodeSamples(f types.PCMFormat, dst []float64, src []byte) {
+	switch f {
+	case types.PCMFormatU8:
+		src = src[:len(dst)]
+		for idx, v := range src {
+			dst[idx] = (float64(v) - 128) / 128
+		}
+	case types.PCMFormatS16LE:
+		src = src[:len(dst)*2]
+		for idx := range dst {
+			dst[idx] = float64(int16(binary.LittleEndian.Uint16(src[idx*2:]))) / 32768
+		}
+	case types.PCMFormatS24LE:
+		src = src[:len(dst)*3]
+		for idx := range dst {
+			s := src[idx*3 : idx*3+3]
+			dst[idx] = float64(int32(uint32(s[0])<<8|uint32(s[1])<<16|uint32(s[2])<<24)>>8) / 8388608
+		}
+	case types.PCMFormatS32LE:
+		src = src[:len(dst)*4]
+		for idx := range dst {
+			dst[idx] = float64(int32(binary.LittleEndian.Uint32(src[idx*4:]))) / 2147483648
+		}
+	case types.PCMFormatFloat32LE:
+		src = src[:len(dst)*4]
+		for idx := range dst {
+			dst[idx] = float64(math.Float32frombits(binary.LittleEndian.Uint32(src[idx*4:])))
+		}
+	case types.PCMFormatFloat64LE:
+		src = src[:len(dst)*8]
+		for idx := range dst {
+			dst[idx] = math.Float64frombits(binary.LittleEndian.Uint64(src[idx*8:]))
+		}
+	default:
+		size := int(f.Size())
+		for idx := range dst {
+			dst[idx] = getFloat64(f, src[idx*size:])
+		}
+	}
+}
+
+// encodeSamples converts float64 samples like setFloat64 does (see
+// decodeSamples), and returns the amount of the clipped samples.
+func encodeSamples(f types.PCMFormat, dst []byte, src []float64) int {
+	var clipped int
+	switch f {
+	case types.PCMFormatU8:
+		dst = dst[:len(src)]
+		for idx, v := range src {
+			s, c := roundSaturate(v*128, math.MinInt8, math.MaxInt8)
+			dst[idx] = byte(int8(s)) ^ 0x80
+			clipped += c
+		}
+	case types.PCMFormatS16LE:
+		for len(src) > 0 && len(dst) >= 2 {
+			s, c := roundSaturate(src[0]*32768, math.MinInt16, math.MaxInt16)
+			binary.LittleEndian.PutUint16(dst, uint16(s))
+			clipped += c
+			src, dst = src[1:], dst[2:]
+		}
+	case types.PCMFormatS24LE:
+		for len(src) > 0 && len(dst) >= 3 {
+			s, c := roundSaturate(src[0]*8388608, -8388608, 8388607)
+			dst[0] = byte(s)
+			dst[1] = byte(s >> 8)
+			dst[2] = byte(s >> 16)
+			clipped += c
+			src, dst = src[1:], dst[3:]
+		}
+	case types.PCMFormatS32LE:
+		for len(src) > 0 && len(dst) >= 4 {
+			s, c := roundSaturate(src[0]*2147483648, math.MinInt32, math.MaxInt32)
+			binary.LittleEndian.PutUint32(dst, uint32(s))
+			clipped += c
+			src, dst = src[1:], dst[4:]
+		}
+	case types.PCMFormatFloat32LE:
+		for len(src) > 0 && len(dst) >= 4 {
+			binary.LittleEndian.PutUint32(dst, math.Float32bits(float32(src[0])))
+			src, dst = src[1:], dst[4:]
+		}
+	case types.PCMFormatFloat64LE:
+		for len(src) > 0 && len(dst) >= 8 {
+			binary.LittleEndian.PutUint64(dst, math.Float64bits(src[0]))
+			src, dst = src[1:], dst[8:]
+		}
+	default:
+		size := int(f.Size())
+		bits := integerBits(f)
+		for idx, v := range src {
+			if bits > 0 && isClipped(v, bits) {
+				clipped++
+			}
+			setFloat64(f, dst[idx*size:], v)
+		}
+	}
+	return clipped
+}
+
+// roundSaturate rounds "x" half away from zero (like math.Round) and
+// limits it to [lo, hi] (which must fit into 32 bits); it also returns 1
+// if the value was limited. NaN becomes zero.
+//
+// It is written to avoid branches on clipped or noisy signals: with
+// go1.27 on amd64 (go build -gcflags=-S), the loop of
+// convertFloat32LEToS16LE has no jumps except the loop condition (the
+// float min/max compile into MINSD, the rounding into SETCC and the
+// saturation into CMOVQ). The code is scalar: one sample per iteration.
+func roundSaturate(x float64, lo, hi int64) (int64, int) {
+	// keeping the value in the range of int64 (while still out of [lo, hi])
+	x = min(max(x, float64(lo-1)), float64(hi+1))
+	r := int64(x)
+	frac := x - float64(r)
+	if frac >= 0.5 {
+		r++
+	}
+	if frac <= -0.5 {
+		r--
+	}
+	if x != x {
+		r = 0
+	}
+	s := min(max(r, lo), hi)
+	var clipped int
+	if s != r {
+		clipped = 1
+	}
+	return s, clipped
+}
+
+// directKernel converts the samples from one format to another without
+// the intermediate float64 samples, and returns the amount of the
+// clipped samples. It is used if the conversion is just a change of
+// the format (see Resampler.readDirect). The kernels are plain Go loops,
+// there is no assembly (SIMD) implementation.
+type directKernel func(dst, src []byte) int
+
+type formatPair struct {
+	In  types.PCMFormat
+	Out types.PCMFormat
+}
+
+var directKernels = map[formatPair]directKernel{
+	{types.PCMFormatFloat32LE, types.PCMFormatS16LE}: convertFloat32LEToS16LE,
+	{types.PCMFormatS16LE, types.PCMFormatFloat32LE}: convertS16LEToFloat32LE,
+}
+
+func convertFloat32LEToS16LE(dst, src []byte) int {
+	var clipped int
+	for len(src) >= 4 && len(dst) >= 2 {
+		// scaling of a float32 by a power of two is exact in float64
+		v := float64(math.Float32frombits(binary.LittleEndian.Uint32(src))) * 32768
+		s, c := roundSaturate(v, math.MinInt16, math.MaxInt16)
+		binary.LittleEndian.PutUint16(dst, uint16(s))
+		clipped += c
+		src, dst = src[4:], dst[2:]
+	}
+	return clipped
+}
+
+func convertS16LEToFloat32LE(dst, src []byte) int {
+	for len(src) >= 2 && len(dst) >= 4 {
+		// exact: any int16 divided by 32768 is representable as float32
+		v := float32(int16(binary.LittleEndian.Uint16(src))) * (1.0 / 32768)
+		binary.LittleEndian.PutUint32(dst, math.Float32bits(v))
+		src, dst = src[2:], dst[4:]
+	}
+	return 0
+}
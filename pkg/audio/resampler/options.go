@@ -0,0 +1,110 @@
+package resampler
+
+import (
+	"fmt"
+)
+
+// Quality defines the algorithm of the sample rate conversion.
+type Quality int
+
+const (
+	UndefinedQuality = Quality(iota)
+
+	// QualityNearest drops or repeats samples; it is the cheapest, but
+	// produces strong aliasing (when downsampling) and imaging (when upsampling).
+	QualityNearest
+
+	// QualityFast, QualityMedium and QualityHigh use a polyphase
+	// windowed-sinc filter of increasing length (and CPU cost).
+	QualityFast
+	QualityMedium
+	QualityHigh
+	EndOfQuality
+)
+
+func (q Quality) String() string {
+	switch q {
+	case UndefinedQuality:
+		return "<undefined>"
+	case QualityNearest:
+		return "nearest"
+	case QualityFast:
+		return "fast"
+	case QualityMedium:
+		return "medium"
+	case QualityHigh:
+		return "high"
+	default:
+		return fmt.Sprintf("<unexpected_value_%d>", int(q))
+	}
+}
+
+// Config is the configuration of a Resampler.
+type Config struct {
+	Quality Quality
+
+	// ChannelMatrix overrides DefaultChannelMatrix.
+	ChannelMatrix ChannelMatrix
+
+	// VariableRatio enables SetRatio (the windowed-sinc conversion is
+	// used then even if the sample rates are equal; QualityNearest is
+	// replaced by QualityMedium).
+	VariableRatio bool
+
+	// Dither is applied if the output is an integer format of a lower
+	// resolution than the input (or the input is a float format).
+	Dither Dither
+}
+
+// DefaultConfig returns the configuration used if no options are given.
+func DefaultConfig() Config {
+	return Config{
+		Quality: QualityNearest,
+		Dither:  DitherNone,
+	}
+}
+
+type Option interface {
+	apply(*Config)
+}
+
+type Options []Option
+
+// Config returns DefaultConfig with the options applied.
+func (opts Options) Config() Config {
+	cfg := DefaultConfig()
+	for _, opt := range opts {
+		opt.apply(&cfg)
+	}
+	return cfg
+}
+
+// OptionQuality selects the algorithm of the sample rate conversion.
+type OptionQuality Quality
+
+func (opt OptionQuality) apply(cfg *Config) {
+	cfg.Quality = Quality(opt)
+}
+
+// OptionChannelMatrix sets the mixing of the input channels into the
+// output channels.
+type OptionChannelMatrix ChannelMatrix
+
+func (opt OptionChannelMatrix) apply(cfg *Config) {
+	cfg.ChannelMatrix = ChannelMatrix(opt)
+}
+
+// OptionDither sets the dither used when the bit depth is reduced.
+type OptionDither Dither
+
+func (opt OptionDither) apply(cfg *Config) {
+	cfg.Dither = Dither(opt)
+}
+
+// OptionVariableRatio enables the adjustment of the conversion ratio at
+// runtime, see Resampler.SetRatio.
+type OptionVariableRatio bool
+
+func (opt OptionVariableRatio) apply(cfg *Config) {
+	cfg.VariableRatio = bool(opt)
+}
@@ -2,12 +2,16 @@ package resampler
 
 import (
 	"encoding/binary"
+	"errors"
 	"fmt"
 	"io"
 	"math"
 	"sync"
+	"sync/atomic"
 
 	"github.com/xaionaro-go/audio/pkg/audio"
+	"github.com/xaionaro-go/audio/pkg/audio/codec/adpcm"
+	"github.com/xaionaro-go/audio/pkg/audio/codec/g711"
 	"github.com/xaionaro-go/audio/pkg/audio/types"
 )
 
@@ -26,6 +30,7 @@ type precalculated struct {
 	outSampleSize   uint
 	inNumAvg        uint
 	outNumRepeat    uint
+	numChannels     uint
 	outDistanceStep uint64
 }
 
@@ -37,13 +42,56 @@ type Resampler struct {
 	outDistance uint64
 	locker      sync.Mutex
 	buffer      []byte
+	inValues    []float64
+	outValues   []float64
+	config      Config
 	precalculated
+
+	// sinc is set if the sample rate is converted by a windowed-sinc filter.
+	sinc *sincState
+
+	// direct is set if the conversion is just a change of the format,
+	// which has a specialized kernel.
+	direct directKernel
+
+	// ratio is the float64 bits of the ratio set by SetRatio.
+	ratio atomic.Uint64
+
+	// quantizer is set if the output is dithered.
+	quantizer      *quantizer
+	outBits        uint
+	clippedSamples atomic.Uint64
+
+	// adpcmEncoder is set if the output is PCMFormatIMAADPCM, then the
+	// resampling itself is done into S16LE stored in adpcmBuffer.
+	adpcmEncoder *adpcm.StreamEncoder
+	adpcmBuffer  []byte
+	adpcmSamples []int16
 }
 
 func getFloat64(f types.PCMFormat, p []byte) float64 {
 	switch f {
 	case types.PCMFormatU8:
 		return (float64(p[0]) - 128) / 128
+	case types.PCMFormatS8:
+		return float64(int8(p[0])) / 128
+	case types.PCMFormatU16LE:
+		return (float64(binary.LittleEndian.Uint16(p)) - 32768) / 32768
+	case types.PCMFormatU16BE:
+		return (float64(binary.BigEndian.Uint16(p)) - 32768) / 32768
+	case types.PCMFormatU24LE:
+		return (float64(uint32(p[0])|uint32(p[1])<<8|uint32(p[2])<<16) - 8388608) / 8388608
+	case types.PCMFormatU24BE:
+		return (float64(uint32(p[2])|uint32(p[1])<<8|uint32(p[0])<<16) - 8388608) / 8388608
+	case types.PCMFormatS24_32LE:
+		// the most significant byte is ignored
+		return float64(int32(binary.LittleEndian.Uint32(p)<<8)>>8) / 8388608
+	case types.PCMFormatS24_32BE:
+		return float64(int32(binary.BigEndian.Uint32(p)<<8)>>8) / 8388608
+	case types.PCMFormatFloat16LE:
+		return float64(float16frombits(binary.LittleEndian.Uint16(p)))
+	case types.PCMFormatFloat16BE:
+		return float64(float16frombits(binary.BigEndian.Uint16(p)))
 	case types.PCMFormatS16LE:
 		return float64(int16(binary.LittleEndian.Uint16(p))) / 32768
 	case types.PCMFormatS16BE:
@@ -76,6 +124,10 @@ func getFloat64(f types.PCMFormat, p []byte) float64 {
 		return math.Float64frombits(binary.LittleEndian.Uint64(p))
 	case types.PCMFormatFloat64BE:
 		return math.Float64frombits(binary.BigEndian.Uint64(p))
+	case types.PCMFormatMuLaw:
+		return float64(g711.DecodeMuLaw(p[0])) / 32768
+	case types.PCMFormatALaw:
+		return float64(g711.DecodeALaw(p[0])) / 32768
 	default:
 		panic(fmt.Sprintf("unknown format: %v", f))
 	}
@@ -84,41 +136,53 @@ func getFloat64(f types.PCMFormat, p []byte) float64 {
 func setFloat64(f types.PCMFormat, p []byte, v float64) {
 	switch f {
 	case types.PCMFormatU8:
-		p[0] = byte(math.Round(v*128 + 128))
+		p[0] = byte(toInt(v, 8) + 128)
+	case types.PCMFormatS8:
+		p[0] = byte(int8(toInt(v, 8)))
+	case types.PCMFormatU16LE:
+		binary.LittleEndian.PutUint16(p, uint16(int32(toInt16(v))+32768))
+	case types.PCMFormatU16BE:
+		binary.BigEndian.PutUint16(p, uint16(int32(toInt16(v))+32768))
+	case types.PCMFormatU24LE:
+		val := uint32(toInt24(v) + 8388608)
+		p[0] = byte(val)
+		p[1] = byte(val >> 8)
+		p[2] = byte(val >> 16)
+	case types.PCMFormatU24BE:
+		val := uint32(toInt24(v) + 8388608)
+		p[0] = byte(val >> 16)
+		p[1] = byte(val >> 8)
+		p[2] = byte(val)
+	case types.PCMFormatS24_32LE:
+		binary.LittleEndian.PutUint32(p, uint32(toInt24(v)))
+	case types.PCMFormatS24_32BE:
+		binary.BigEndian.PutUint32(p, uint32(toInt24(v)))
+	case types.PCMFormatFloat16LE:
+		binary.LittleEndian.PutUint16(p, float16bits(float32(v)))
+	case types.PCMFormatFloat16BE:
+		binary.BigEndian.PutUint16(p, float16bits(float32(v)))
 	case types.PCMFormatS16LE:
-		binary.LittleEndian.PutUint16(p, uint16(int16(math.Round(v*32768))))
+		binary.LittleEndian.PutUint16(p, uint16(toInt16(v)))
 	case types.PCMFormatS16BE:
-		binary.BigEndian.PutUint16(p, uint16(int16(math.Round(v*32768))))
+		binary.BigEndian.PutUint16(p, uint16(toInt16(v)))
 	case types.PCMFormatS24LE:
-		val := int32(math.Round(v * 8388608))
-		if val > 8388607 {
-			val = 8388607
-		}
-		if val < -8388608 {
-			val = -8388608
-		}
+		val := toInt24(v)
 		p[0] = byte(val)
 		p[1] = byte(val >> 8)
 		p[2] = byte(val >> 16)
 	case types.PCMFormatS24BE:
-		val := int32(math.Round(v * 8388608))
-		if val > 8388607 {
-			val = 8388607
-		}
-		if val < -8388608 {
-			val = -8388608
-		}
+		val := toInt24(v)
 		p[0] = byte(val >> 16)
 		p[1] = byte(val >> 8)
 		p[2] = byte(val)
 	case types.PCMFormatS32LE:
-		binary.LittleEndian.PutUint32(p, uint32(int32(math.Round(v*2147483648))))
+		binary.LittleEndian.PutUint32(p, uint32(int32(toInt(v, 32))))
 	case types.PCMFormatS32BE:
-		binary.BigEndian.PutUint32(p, uint32(int32(math.Round(v*2147483648))))
+		binary.BigEndian.PutUint32(p, uint32(int32(toInt(v, 32))))
 	case types.PCMFormatS64LE:
-		binary.LittleEndian.PutUint64(p, uint64(int64(math.Round(v*9223372036854775808))))
+		binary.LittleEndian.PutUint64(p, uint64(toInt(v, 64)))
 	case types.PCMFormatS64BE:
-		binary.BigEndian.PutUint64(p, uint64(int64(math.Round(v*9223372036854775808))))
+		binary.BigEndian.PutUint64(p, uint64(toInt(v, 64)))
 	case types.PCMFormatFloat32LE:
 		binary.LittleEndian.PutUint32(p, math.Float32bits(float32(v)))
 	case types.PCMFormatFloat32BE:
@@ -127,22 +191,105 @@ func setFloat64(f types.PCMFormat, p []byte, v float64) {
 		binary.LittleEndian.PutUint64(p, math.Float64bits(v))
 	case types.PCMFormatFloat64BE:
 		binary.BigEndian.PutUint64(p, math.Float64bits(v))
+	case types.PCMFormatMuLaw:
+		p[0] = g711.EncodeMuLaw(toInt16(v))
+	case types.PCMFormatALaw:
+		p[0] = g711.EncodeALaw(toInt16(v))
 	default:
 		panic(fmt.Sprintf("unknown format: %v", f))
 	}
 }
 
+// toInt scales "v" to a signed integer of the given amount of bits,
+// saturating on overflow (NaN becomes zero).
+func toInt(v float64, bits uint) int64 {
+	limit := float64(uint64(1) << (bits - 1))
+	scaled := math.Round(v * limit)
+	switch {
+	case math.IsNaN(scaled):
+		return 0
+	case scaled >= limit:
+		return math.MaxInt64 >> (64 - bits)
+	case scaled < -limit:
+		return math.MinInt64 >> (64 - bits)
+	}
+	return int64(scaled)
+}
+
+// isClipped returns true if "v" is out of the range of a signed integer
+// of the given amount of bits.
+func isClipped(v float64, bits uint) bool {
+	limit := float64(uint64(1) << (bits - 1))
+	scaled := math.Round(v * limit)
+	return scaled >= limit || scaled < -limit
+}
+
+func toInt16(v float64) int16 {
+	return int16(toInt(v, 16))
+}
+
+func toInt24(v float64) int32 {
+	return int32(toInt(v, 24))
+}
+
+// integerBits returns the resolution of a linear integer format (zero
+// for the other formats).
+func integerBits(f types.PCMFormat) uint {
+	switch f {
+	case types.PCMFormatU8, types.PCMFormatS8:
+		return 8
+	case types.PCMFormatS16LE, types.PCMFormatS16BE, types.PCMFormatU16LE, types.PCMFormatU16BE:
+		return 16
+	case types.PCMFormatS24LE, types.PCMFormatS24BE, types.PCMFormatU24LE, types.PCMFormatU24BE,
+		types.PCMFormatS24_32LE, types.PCMFormatS24_32BE:
+		return 24
+	case types.PCMFormatS32LE, types.PCMFormatS32BE:
+		return 32
+	case types.PCMFormatS64LE, types.PCMFormatS64BE:
+		return 64
+	default:
+		return 0
+	}
+}
+
+// resolutionBits returns the resolution of the samples of the format:
+// the same as integerBits, except that G.711 is decoded into 16 bits.
+func resolutionBits(f types.PCMFormat) uint {
+	switch f {
+	case types.PCMFormatMuLaw, types.PCMFormatALaw:
+		return 16
+	default:
+		return integerBits(f)
+	}
+}
+
 var _ io.Reader = (*Resampler)(nil)
 
+// maxConsecutiveEmptyReads is the amount of (0, nil) reads from the input
+// after which the input is considered stuck (see io.ErrNoProgress).
+const maxConsecutiveEmptyReads = 100
+
 func NewResampler(
 	inFormat Format,
 	inReader io.Reader,
 	outFormat Format,
+	opts ...Option,
 ) (*Resampler, error) {
+	// IMA ADPCM is stateful, so it is decoded/encoded as a whole stream
+	// around the resampling of S16LE
+	if inFormat.PCMFormat == types.PCMFormatIMAADPCM {
+		inReader = adpcm.NewReader(inReader, int(inFormat.Channels))
+		inFormat.PCMFormat = types.PCMFormatS16LE
+	}
 	r := &Resampler{
 		inReader:  inReader,
 		inFormat:  inFormat,
 		outFormat: outFormat,
+		config:    Options(opts).Config(),
+	}
+	if outFormat.PCMFormat == types.PCMFormatIMAADPCM {
+		r.adpcmEncoder = adpcm.NewStreamEncoder(int(outFormat.Channels))
+		r.outFormat.PCMFormat = types.PCMFormatS16LE
 	}
 	err := r.init()
 	if err != nil {
@@ -152,12 +299,22 @@ func NewResampler(
 }
 
 func (r *Resampler) init() error {
+	// the channel mixer replaces the input format, so the resolution of
+	// the input is taken before
+	inBits := resolutionBits(r.inFormat.PCMFormat)
+	if err := r.initChannelMixer(); err != nil {
+		return err
+	}
+
 	r.inSampleSize = uint(r.inFormat.PCMFormat.Size())
 	r.outSampleSize = uint(r.outFormat.PCMFormat.Size())
 
 	r.inNumAvg = 1
 	r.outNumRepeat = 1
-	if r.inFormat.Channels != r.outFormat.Channels {
+	r.numChannels = 1
+	if r.inFormat.Channels == r.outFormat.Channels {
+		r.numChannels = uint(r.inFormat.Channels)
+	} else {
 		switch {
 		case r.inFormat.Channels == 1:
 			r.outNumRepeat = uint(r.outFormat.Channels)
@@ -174,14 +331,126 @@ func (r *Resampler) init() error {
 	r.inDistance = 0
 	r.outDistance = 0
 
+	r.outBits = integerBits(r.outFormat.PCMFormat)
+	switch r.config.Dither {
+	case DitherNone:
+	case DitherTPDF, DitherNoiseShaped:
+		if r.outBits > 0 && (inBits == 0 || inBits > r.outBits) {
+			r.quantizer = newQuantizer(r.config.Dither, r.outBits, int(r.outFormat.Channels))
+		}
+	default:
+		return fmt.Errorf("unknown dither: %v", r.config.Dither)
+	}
+
+	quality := r.config.Quality
+	if r.config.VariableRatio {
+		if quality == QualityNearest {
+			quality = QualityMedium
+		}
+		r.ratio.Store(math.Float64bits(1))
+	}
+	if r.inFormat.SampleRate != r.outFormat.SampleRate || r.config.VariableRatio {
+		switch quality {
+		case QualityNearest:
+		case QualityFast, QualityMedium, QualityHigh:
+			r.initSinc(sincPresets[quality])
+		default:
+			return fmt.Errorf("unknown quality: %v", quality)
+		}
+	}
+
+	if r.sinc == nil && r.quantizer == nil &&
+		r.inFormat.SampleRate == r.outFormat.SampleRate &&
+		r.inFormat.Channels == r.outFormat.Channels {
+		r.direct = directKernels[formatPair{In: r.inFormat.PCMFormat, Out: r.outFormat.PCMFormat}]
+	}
+
 	return nil
 }
 
+// ClippedSamples returns the amount of the output samples which were out
+// of the range of the (integer) output format, and thus were saturated.
+func (r *Resampler) ClippedSamples() uint64 {
+	return r.clippedSamples.Load()
+}
+
+// writeSamples encodes the interleaved output samples (which are
+// modified if dithered).
+func (r *Resampler) writeSamples(p []byte, values []float64) {
+	var clipped int
+	if r.quantizer != nil {
+		channels := int(r.outFormat.Channels)
+		for idx, v := range values {
+			if isClipped(v, r.outBits) {
+				clipped++
+			}
+			values[idx] = r.quantizer.quantize(idx%channels, v)
+		}
+		encodeSamples(r.outFormat.PCMFormat, p, values)
+	} else {
+		clipped = encodeSamples(r.outFormat.PCMFormat, p, values)
+	}
+	if clipped > 0 {
+		r.clippedSamples.Add(uint64(clipped))
+	}
+}
+
+// resize returns a slice of the given length, reusing the buffer if possible.
+func resize(buf []float64, length int) []float64 {
+	if cap(buf) < length {
+		return make([]float64, length)
+	}
+	return buf[:length]
+}
+
 func (r *Resampler) Read(p []byte) (int, error) {
 	r.locker.Lock()
 	defer r.locker.Unlock()
+	if r.adpcmEncoder != nil {
+		return r.readADPCM(p)
+	}
+	return r.read(p)
+}
 
-	maxOutChunks := uint64(len(p)) / uint64(r.outSampleSize) / uint64(r.outNumRepeat)
+func (r *Resampler) readADPCM(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	// each output byte contains two samples
+	size := len(p) * 4
+	if cap(r.adpcmBuffer) < size {
+		r.adpcmBuffer = make([]byte, size)
+		r.adpcmSamples = make([]int16, size/2)
+	}
+	for {
+		n, err := r.read(r.adpcmBuffer[:size])
+		samples := r.adpcmSamples[:n/2]
+		for idx := range samples {
+			samples[idx] = int16(binary.LittleEndian.Uint16(r.adpcmBuffer[idx*2:]))
+		}
+		written := r.adpcmEncoder.Encode(p, samples)
+		if err != nil {
+			if errors.Is(err, io.EOF) && written < len(p) {
+				written += r.adpcmEncoder.Flush(p[written:])
+			}
+			return written, err
+		}
+		if written > 0 || n == 0 {
+			return written, nil
+		}
+	}
+}
+
+func (r *Resampler) read(p []byte) (int, error) {
+	switch {
+	case r.sinc != nil:
+		return r.readSinc(p)
+	case r.direct != nil:
+		return r.readDirect(p)
+	}
+	inChunkSize := uint64(r.inSampleSize) * uint64(r.inNumAvg) * uint64(r.numChannels)
+	outChunkSize := uint64(r.outSampleSize) * uint64(r.outNumRepeat) * uint64(r.numChannels)
+	maxOutChunks := uint64(len(p)) / outChunkSize
 	if maxOutChunks == 0 {
 		return 0, nil
 	}
@@ -190,53 +459,142 @@ func (r *Resampler) Read(p []byte) (int, error) {
 	if chunksToRead == 0 {
 		chunksToRead = 1
 	}
-	bytesToRead := uint64(chunksToRead) * uint64(r.inSampleSize) * uint64(r.inNumAvg)
-	if cap(r.buffer) < int(bytesToRead) {
-		r.buffer = make([]byte, bytesToRead)
-	} else {
-		r.buffer = r.buffer[:bytesToRead]
-	}
-	n, err := r.inReader.Read(r.buffer)
-	r.buffer = r.buffer[:n]
+	bytesToRead := chunksToRead * inChunkSize
+	inSamplesPerChunk := uint64(r.inNumAvg) * uint64(r.numChannels)
+	outSamplesPerChunk := uint64(r.outNumRepeat) * uint64(r.numChannels)
+	r.outValues = resize(r.outValues, int(maxOutChunks*outSamplesPerChunk))
 
-	if n > 0 && n%int(r.inSampleSize*r.inNumAvg) != 0 {
-		return 0, fmt.Errorf("read a number of bytes (%d) that is not a multiple of %d", n, r.inSampleSize*r.inNumAvg)
-	}
-	chunksRead := uint64(n) / uint64(r.inSampleSize) / uint64(r.inNumAvg)
+	for {
+		var err error
+		if len(r.buffer) < int(inChunkSize) {
+			err = r.readInput(int(bytesToRead), int(inChunkSize))
+		}
+		chunksRead := uint64(len(r.buffer)) / inChunkSize
+		r.inValues = resize(r.inValues, int(chunksRead*inSamplesPerChunk))
+		decodeSamples(r.inFormat.PCMFormat, r.inValues, r.buffer)
+		in, out := r.inValues, r.outValues[:0]
+
+		dstChunkIdx := uint64(0)
+		srcChunkIdx := uint64(0)
+		if r.inFormat.SampleRate == r.outFormat.SampleRate && r.inNumAvg == 1 && r.outNumRepeat == 1 {
+			// a plain format conversion
+			chunks := min(chunksRead, maxOutChunks)
+			out = in[:chunks*inSamplesPerChunk]
+			srcChunkIdx, dstChunkIdx = chunks, chunks
+			r.inDistance += chunks * distanceStep
+			r.outDistance += chunks * r.outDistanceStep
+		}
+		for srcChunkIdx < chunksRead && dstChunkIdx < maxOutChunks {
+			// If we are too far ahead in input distance, skip input samples
+			for r.inDistance < r.outDistance && srcChunkIdx < chunksRead {
+				srcChunkIdx++
+				r.inDistance += distanceStep
+			}
+			if srcChunkIdx >= chunksRead {
+				break
+			}
+
+			// Write output sample (possibly repeated)
+			chunk := in[srcChunkIdx*inSamplesPerChunk : (srcChunkIdx+1)*inSamplesPerChunk]
+			for dstChunkIdx < maxOutChunks && r.outDistance <= r.inDistance {
+				for ch := uint64(0); ch < uint64(r.numChannels); ch++ {
+					// Read input sample
+					var sum float64
+					for _, v := range chunk[ch*uint64(r.inNumAvg) : (ch+1)*uint64(r.inNumAvg)] {
+						sum += v
+					}
+					val := sum / float64(r.inNumAvg)
+					for repeatIdx := uint64(0); repeatIdx < uint64(r.outNumRepeat); repeatIdx++ {
+						out = append(out, val)
+					}
+				}
+				dstChunkIdx++
+				r.outDistance += r.outDistanceStep
+			}
 
-	dstChunkIdx := uint64(0)
-	srcChunkIdx := uint64(0)
-	for srcChunkIdx < chunksRead && dstChunkIdx < maxOutChunks {
-		// If we are too far ahead in input distance, skip input samples
-		for r.inDistance < r.outDistance && srcChunkIdx < chunksRead {
 			srcChunkIdx++
 			r.inDistance += distanceStep
 		}
-		if srcChunkIdx >= chunksRead {
-			break
+
+		r.writeSamples(p[:dstChunkIdx*outChunkSize], out)
+
+		// keeping the unconsumed data (including an incomplete frame) for the next call
+		r.buffer = r.buffer[:copy(r.buffer, r.buffer[srcChunkIdx*inChunkSize:])]
+		if errors.Is(err, io.EOF) && len(r.buffer) > 0 {
+			err = nil
+		}
+		if dstChunkIdx > 0 || err != nil {
+			return int(dstChunkIdx * outChunkSize), err
 		}
+	}
+}
 
-		// Read input sample
-		idxSrc := srcChunkIdx * uint64(r.inSampleSize) * uint64(r.inNumAvg)
-		var sum float64
-		for channelIdx := uint64(0); channelIdx < uint64(r.inNumAvg); channelIdx++ {
-			sum += getFloat64(r.inFormat.PCMFormat, r.buffer[idxSrc+channelIdx*uint64(r.inSampleSize):])
+// readDirect converts the whole frames with the direct kernel.
+func (r *Resampler) readDirect(p []byte) (int, error) {
+	inFrameSize := int(r.inSampleSize) * int(r.inFormat.Channels)
+	outFrameSize := int(r.outSampleSize) * int(r.outFormat.Channels)
+	maxFrames := len(p) / outFrameSize
+	if maxFrames == 0 {
+		return 0, nil
+	}
+	for {
+		var err error
+		if len(r.buffer) < inFrameSize {
+			err = r.readInput(maxFrames*inFrameSize, inFrameSize)
+		}
+		frames := min(len(r.buffer)/inFrameSize, maxFrames)
+		if clipped := r.direct(p[:frames*outFrameSize], r.buffer[:frames*inFrameSize]); clipped > 0 {
+			r.clippedSamples.Add(uint64(clipped))
 		}
-		val := sum / float64(r.inNumAvg)
 
-		// Write output sample (possibly repeated)
-		for dstChunkIdx < maxOutChunks && r.outDistance <= r.inDistance {
-			for repeatIdx := uint64(0); repeatIdx < uint64(r.outNumRepeat); repeatIdx++ {
-				idxDst := (dstChunkIdx*uint64(r.outNumRepeat) + repeatIdx) * uint64(r.outSampleSize)
-				setFloat64(r.outFormat.PCMFormat, p[idxDst:], val)
-			}
-			dstChunkIdx++
-			r.outDistance += r.outDistanceStep
+		// keeping the unconsumed data (including an incomplete frame) for the next call
+		r.buffer = r.buffer[:copy(r.buffer, r.buffer[frames*inFrameSize:])]
+		if errors.Is(err, io.EOF) && len(r.buffer) > 0 {
+			err = nil
+		}
+		if frames > 0 || err != nil {
+			return frames * outFrameSize, err
 		}
+	}
+}
 
-		srcChunkIdx++
-		r.inDistance += distanceStep
+// readInput reads up to "size" bytes into the buffer (after the data left
+// from the previous calls); at EOF an incomplete last frame is completed
+// with silence. Like bufio.Reader, it gives up with io.ErrNoProgress if
+// the input returns no data and no error too many times in a row.
+func (r *Resampler) readInput(size int, frameSize int) error {
+	if cap(r.buffer) < size {
+		buf := make([]byte, len(r.buffer), size)
+		copy(buf, r.buffer)
+		r.buffer = buf
+	}
+	if len(r.buffer) >= size {
+		return nil
 	}
+	for emptyReads := 0; emptyReads < maxConsecutiveEmptyReads; emptyReads++ {
+		n, err := r.inReader.Read(r.buffer[len(r.buffer):size])
+		r.buffer = r.buffer[:len(r.buffer)+n]
+		if errors.Is(err, io.EOF) {
+			r.buffer = padFrame(r.buffer, r.inFormat.PCMFormat, frameSize)
+		}
+		if n > 0 || err != nil {
+			return err
+		}
+	}
+	return io.ErrNoProgress
+}
 
-	return int(dstChunkIdx * uint64(r.outSampleSize) * uint64(r.outNumRepeat)), err
+// padFrame completes the incomplete frame at the end of "b" with silence.
+func padFrame(b []byte, pcmFormat types.PCMFormat, frameSize int) []byte {
+	if len(b)%frameSize == 0 {
+		return b
+	}
+	sampleSize := int(pcmFormat.Size())
+	// the incomplete sample is completed with zeros
+	b = append(b, make([]byte, (sampleSize-len(b)%sampleSize)%sampleSize)...)
+	for len(b)%frameSize != 0 {
+		b = append(b, make([]byte, sampleSize)...)
+		setFloat64(pcmFormat, b[len(b)-sampleSize:], 0)
+	}
+	return b
 }
@@ -3,8 +3,14 @@ package resampler
 import (
 	"bytes"
 	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
 	"math"
+	"math/rand/v2"
 	"testing"
+	"testing/iotest"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -140,4 +146,1014 @@ func TestResampler(t *testing.T) {
 		assert.Equal(t, byte(150), out[0])
 		assert.Equal(t, byte(100), out[1]) // (50+150)/2 = 100
 	})
+
+	t.Run("Conversion_MuLaw_to_ALaw_Mono", func(t *testing.T) {
+		inFmt := Format{
+			Channels:   1,
+			SampleRate: 8000,
+			PCMFormat:  types.PCMFormatMuLaw,
+		}
+		outFmt := Format{
+			Channels:   1,
+			SampleRate: 8000,
+			PCMFormat:  types.PCMFormatALaw,
+		}
+		// zero, the maximal positive value and the maximal negative value
+		r, err := NewResampler(inFmt, bytes.NewReader([]byte{0xFF, 0x80, 0x00}), outFmt)
+		require.NoError(t, err)
+
+		out := make([]byte, 3)
+		n, err := r.Read(out)
+		assert.NoError(t, err)
+		assert.Equal(t, 3, n)
+		assert.Equal(t, []byte{0xD5, 0xAA, 0x2A}, out)
+	})
+
+	t.Run("IMAADPCM_RoundTrip", func(t *testing.T) {
+		s16Fmt := Format{
+			Channels:   1,
+			SampleRate: 8000,
+			PCMFormat:  types.PCMFormatS16LE,
+		}
+		adpcmFmt := s16Fmt
+		adpcmFmt.PCMFormat = types.PCMFormatIMAADPCM
+
+		data := make([]byte, 2*801)
+		for i := 0; i < 801; i++ {
+			binary.LittleEndian.PutUint16(data[i*2:], uint16(int16(8000*math.Sin(float64(i)/10))))
+		}
+		encoder, err := NewResampler(s16Fmt, bytes.NewReader(data), adpcmFmt)
+		require.NoError(t, err)
+		encoded, err := io.ReadAll(encoder)
+		require.NoError(t, err)
+		// the odd sample is padded
+		require.Len(t, encoded, 401)
+
+		decoder, err := NewResampler(adpcmFmt, bytes.NewReader(encoded), s16Fmt)
+		require.NoError(t, err)
+		decoded, err := io.ReadAll(decoder)
+		require.NoError(t, err)
+		require.Len(t, decoded, 2*802)
+		for i := 100; i < 801; i++ {
+			assert.InDelta(t, int16(binary.LittleEndian.Uint16(data[i*2:])), int16(binary.LittleEndian.Uint16(decoded[i*2:])), 500, "sample %d", i)
+		}
+	})
+
+	t.Run("Conversion_AllFormats_RoundTrip", func(t *testing.T) {
+		values := []int16{0, 16384, -16384, 32000, -32768, 1234}
+		data := make([]byte, len(values)*2)
+		for i, v := range values {
+			binary.LittleEndian.PutUint16(data[i*2:], uint16(v))
+		}
+		s16Fmt := Format{
+			Channels:   1,
+			SampleRate: 8000,
+			PCMFormat:  types.PCMFormatS16LE,
+		}
+		for pcmFormat := types.UndefinedPCMFormat + 1; pcmFormat < types.EndOfPCMFormat; pcmFormat++ {
+			if !pcmFormat.IsLinear() {
+				continue
+			}
+			t.Run(pcmFormat.String(), func(t *testing.T) {
+				outFmt := s16Fmt
+				outFmt.PCMFormat = pcmFormat
+				r, err := NewResampler(s16Fmt, bytes.NewReader(data), outFmt)
+				require.NoError(t, err)
+				converted, err := io.ReadAll(r)
+				require.NoError(t, err)
+				require.Len(t, converted, len(values)*int(pcmFormat.Size()))
+
+				r, err = NewResampler(outFmt, bytes.NewReader(converted), s16Fmt)
+				require.NoError(t, err)
+				out, err := io.ReadAll(r)
+				require.NoError(t, err)
+				require.Len(t, out, len(data))
+				tolerance := 0.0
+				switch pcmFormat {
+				case types.PCMFormatU8, types.PCMFormatS8:
+					tolerance = 256
+				case types.PCMFormatFloat16LE, types.PCMFormatFloat16BE:
+					tolerance = 16
+				}
+				for i, v := range values {
+					require.InDelta(t, v, int16(binary.LittleEndian.Uint16(out[i*2:])), tolerance, "sample %d", i)
+				}
+			})
+		}
+	})
+
+	t.Run("Conversion_S24_32LE", func(t *testing.T) {
+		inFmt := Format{
+			Channels:   1,
+			SampleRate: 8000,
+			PCMFormat:  types.PCMFormatS24_32LE,
+		}
+		outFmt := inFmt
+		outFmt.PCMFormat = types.PCMFormatS24LE
+		// the padding byte is ignored
+		r, err := NewResampler(inFmt, bytes.NewReader([]byte{0x01, 0x00, 0x80, 0xAB, 0xFF, 0xFF, 0x7F, 0x00}), outFmt)
+		require.NoError(t, err)
+		out, err := io.ReadAll(r)
+		require.NoError(t, err)
+		require.Equal(t, []byte{0x01, 0x00, 0x80, 0xFF, 0xFF, 0x7F}, out)
+	})
+}
+
+func TestFloat16(t *testing.T) {
+	for _, tc := range []struct {
+		bits  uint16
+		value float32
+	}{
+		{0x0000, 0},
+		{0x3C00, 1},
+		{0xC000, -2},
+		{0x3555, 0.333251953125},
+		{0x7BFF, 65504},
+		{0x0001, 1.0 / (1 << 24)},
+		{0x7C00, float32(math.Inf(1))},
+	} {
+		assert.Equal(t, tc.value, float16frombits(tc.bits), "0x%04X", tc.bits)
+		assert.Equal(t, tc.bits, float16bits(tc.value), "%v", tc.value)
+	}
+	assert.Equal(t, uint16(0x7C00), float16bits(1e6))
+	assert.True(t, math.IsNaN(float64(float16frombits(float16bits(float32(math.NaN()))))))
+	for bits := uint16(0); bits < 0x7C00; bits++ {
+		require.Equal(t, bits, float16bits(float16frombits(bits)))
+	}
+}
+
+func float64Sine(frames int, channels int, freq, sampleRate float64, amplitude float64) []byte {
+	b := make([]byte, frames*channels*8)
+	for idx := range frames {
+		// the other channels are silent
+		binary.LittleEndian.PutUint64(b[idx*channels*8:], math.Float64bits(amplitude*math.Sin(2*math.Pi*freq*float64(idx)/sampleRate)))
+	}
+	return b
+}
+
+func readFloat64s(t *testing.T, r io.Reader) []float64 {
+	b, err := io.ReadAll(r)
+	require.NoError(t, err)
+	result := make([]float64, len(b)/8)
+	for idx := range result {
+		result[idx] = math.Float64frombits(binary.LittleEndian.Uint64(b[idx*8:]))
+	}
+	return result
+}
+
+func rms(x []float64) float64 {
+	var sum float64
+	for _, v := range x {
+		sum += v * v
+	}
+	return math.Sqrt(sum / float64(len(x)))
+}
+
+func TestSinc(t *testing.T) {
+	formatAt := func(sampleRate types.SampleRate, channels types.Channel) Format {
+		return Format{Channels: channels, SampleRate: sampleRate, PCMFormat: types.PCMFormatFloat64LE}
+	}
+
+	t.Run("Downsampling_AntiAliasing", func(t *testing.T) {
+		// 10kHz is above the Nyquist frequency of 16kHz
+		in := float64Sine(4800, 1, 10000, 48000, 0.5)
+		r, err := NewResampler(formatAt(48000, 1), bytes.NewReader(in), formatAt(16000, 1))
+		require.NoError(t, err)
+		aliased := rms(readFloat64s(t, r))
+		require.Greater(t, aliased, 0.2)
+
+		for _, quality := range []Quality{QualityFast, QualityMedium, QualityHigh} {
+			t.Run(quality.String(), func(t *testing.T) {
+				r, err := NewResampler(formatAt(48000, 1), bytes.NewReader(in), formatAt(16000, 1), OptionQuality(quality))
+				require.NoError(t, err)
+				out := readFloat64s(t, r)
+				require.Len(t, out, 1600)
+				require.Less(t, rms(out[100:1500]), 0.01)
+			})
+		}
+	})
+
+	t.Run("Upsampling", func(t *testing.T) {
+		in := float64Sine(1600, 1, 1000, 16000, 0.5)
+		for _, quality := range []Quality{QualityFast, QualityMedium, QualityHigh} {
+			t.Run(quality.String(), func(t *testing.T) {
+				r, err := NewResampler(formatAt(16000, 1), bytes.NewReader(in), formatAt(48000, 1), OptionQuality(quality))
+				require.NoError(t, err)
+				out := readFloat64s(t, r)
+				require.Len(t, out, 4800)
+				// no group delay: the output is aligned with the input
+				for idx := 300; idx < 4500; idx++ {
+					require.InDelta(t, 0.5*math.Sin(2*math.Pi*1000*float64(idx)/48000), out[idx], 0.005, "sample %d", idx)
+				}
+			})
+		}
+	})
+
+	t.Run("Stereo_44100_to_48000_Chunked", func(t *testing.T) {
+		in := float64Sine(4410, 2, 440, 44100, 0.5)
+		r, err := NewResampler(formatAt(44100, 2), bytes.NewReader(in), formatAt(48000, 2), OptionQuality(QualityMedium))
+		require.NoError(t, err)
+		expected := readFloat64s(t, r)
+		require.Len(t, expected, 4800*2)
+		for idx := 100; idx < 4700; idx++ {
+			require.InDelta(t, 0.5*math.Sin(2*math.Pi*440*float64(idx)/48000), expected[idx*2], 0.001, "sample %d", idx)
+			require.Zero(t, expected[idx*2+1])
+		}
+
+		// the state is carried across the Read calls
+		r, err = NewResampler(formatAt(44100, 2), bytes.NewReader(in), formatAt(48000, 2), OptionQuality(QualityMedium))
+		require.NoError(t, err)
+		var out []byte
+		buf := make([]byte, 16*7)
+		for {
+			n, err := r.Read(buf)
+			out = append(out, buf[:n]...)
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			require.NoError(t, err)
+		}
+		require.Equal(t, expected, readFloat64s(t, bytes.NewReader(out)))
+	})
+
+	t.Run("Mono_to_Stereo_S16LE", func(t *testing.T) {
+		in := make([]byte, 2000)
+		r, err := NewResampler(
+			Format{Channels: 1, SampleRate: 8000, PCMFormat: types.PCMFormatS16LE}, bytes.NewReader(in),
+			Format{Channels: 2, SampleRate: 16000, PCMFormat: types.PCMFormatS16LE},
+			OptionQuality(QualityFast),
+		)
+		require.NoError(t, err)
+		out, err := io.ReadAll(r)
+		require.NoError(t, err)
+		require.Equal(t, make([]byte, 8000), out)
+	})
+}
+
+func TestQualityString(t *testing.T) {
+	for q := UndefinedQuality; q <= EndOfQuality; q++ {
+		require.NotEmpty(t, q.String())
+	}
+	require.Equal(t, "high", QualityHigh.String())
+}
+
+func TestChannelMatrix(t *testing.T) {
+	s16Format := func(channels types.Channel) Format {
+		return Format{Channels: channels, SampleRate: 48000, PCMFormat: types.PCMFormatS16LE}
+	}
+	s16 := func(samples ...int16) []byte {
+		b := make([]byte, len(samples)*2)
+		for idx, v := range samples {
+			binary.LittleEndian.PutUint16(b[idx*2:], uint16(v))
+		}
+		return b
+	}
+
+	t.Run("Select_Channel3_of_8", func(t *testing.T) {
+		m, err := ChannelMatrixSelect(8, 3)
+		require.NoError(t, err)
+		in := s16(0, 1, 2, 3, 4, 5, 6, 7, 10, 11, 12, 13, 14, 15, 16, 17)
+		// one byte at a time: the frames are reassembled
+		r, err := NewResampler(s16Format(8), iotest.OneByteReader(bytes.NewReader(in)), s16Format(1), OptionChannelMatrix(m))
+		require.NoError(t, err)
+		out, err := io.ReadAll(r)
+		require.NoError(t, err)
+		require.Equal(t, s16(3, 13), out)
+	})
+
+	t.Run("Swap_and_Duplicate", func(t *testing.T) {
+		m, err := ChannelMatrixSelect(2, 1, 0, 0)
+		require.NoError(t, err)
+		r, err := NewResampler(s16Format(2), bytes.NewReader(s16(100, 200, -300, 400)), s16Format(3), OptionChannelMatrix(m))
+		require.NoError(t, err)
+		out, err := io.ReadAll(r)
+		require.NoError(t, err)
+		require.Equal(t, s16(200, 100, 100, 400, -300, -300), out)
+	})
+
+	t.Run("Downmix_5_1", func(t *testing.T) {
+		r, err := NewResampler(s16Format(6), bytes.NewReader(s16(1000, 2000, 3000, 4000, 5000, 6000)), s16Format(2))
+		require.NoError(t, err)
+		out, err := io.ReadAll(r)
+		require.NoError(t, err)
+		c := math.Sqrt2 / 2
+		norm := 1 + 2*c
+		require.Equal(t, s16(
+			int16(math.Round((1000+c*3000+c*5000)/norm)),
+			int16(math.Round((2000+c*3000+c*6000)/norm)),
+		), out)
+	})
+
+	t.Run("Downmix_7_1_Weights", func(t *testing.T) {
+		m, err := ChannelMatrixDownmix(8, DownmixWeights{Center: 0.5, LFE: 0.25, Surround: 1})
+		require.NoError(t, err)
+		require.Equal(t, ChannelMatrix{
+			{1, 0, 0.5, 0.25, 1, 0, 1, 0},
+			{0, 1, 0.5, 0.25, 0, 1, 0, 1},
+		}, m)
+		require.Equal(t, []float64{1.0 / 3.75, 0, 0.5 / 3.75, 0.25 / 3.75, 1 / 3.75, 0, 1 / 3.75, 0}, m.Normalized()[0])
+	})
+
+	t.Run("Upmix_Stereo_with_Resampling", func(t *testing.T) {
+		r, err := NewResampler(
+			s16Format(2), bytes.NewReader(s16(1000, -1000, 1000, -1000)),
+			Format{Channels: 6, SampleRate: 96000, PCMFormat: types.PCMFormatS16LE},
+		)
+		require.NoError(t, err)
+		out, err := io.ReadAll(r)
+		require.NoError(t, err)
+		require.Equal(t, s16(1000, -1000, 0, 0, 0, 0), out[:12])
+		require.Len(t, out, 12*3)
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		_, err := NewResampler(s16Format(3), bytes.NewReader(nil), s16Format(2))
+		require.Error(t, err)
+		_, err = NewResampler(s16Format(2), bytes.NewReader(nil), s16Format(2), OptionChannelMatrix(ChannelMatrix{{1, 0}}))
+		require.Error(t, err)
+		_, err = NewResampler(s16Format(2), bytes.NewReader(nil), s16Format(1), OptionChannelMatrix(ChannelMatrix{{1}}))
+		require.Error(t, err)
+		_, err = ChannelMatrixSelect(2, 2)
+		require.Error(t, err)
+		require.Error(t, ChannelMatrix{{1, 0}, {1}}.Validate())
+	})
+}
+
+func TestSaturation(t *testing.T) {
+	in := make([]byte, 0, 5*8)
+	for _, v := range []float64{1.5, -1.5, 1.0, math.NaN(), 0.5} {
+		in = binary.LittleEndian.AppendUint64(in, math.Float64bits(v))
+	}
+	for _, tc := range []struct {
+		pcmFormat types.PCMFormat
+		expected  []int64
+	}{
+		{types.PCMFormatU8, []int64{255, 0, 255, 128, 192}},
+		{types.PCMFormatS8, []int64{127, -128, 127, 0, 64}},
+		{types.PCMFormatS16LE, []int64{math.MaxInt16, math.MinInt16, math.MaxInt16, 0, 16384}},
+		{types.PCMFormatS32LE, []int64{math.MaxInt32, math.MinInt32, math.MaxInt32, 0, 1 << 30}},
+		{types.PCMFormatS64LE, []int64{math.MaxInt64, math.MinInt64, math.MaxInt64, 0, 1 << 62}},
+	} {
+		t.Run(tc.pcmFormat.String(), func(t *testing.T) {
+			r, err := NewResampler(
+				Format{Channels: 1, SampleRate: 48000, PCMFormat: types.PCMFormatFloat64LE}, bytes.NewReader(in),
+				Format{Channels: 1, SampleRate: 48000, PCMFormat: tc.pcmFormat},
+			)
+			require.NoError(t, err)
+			out, err := io.ReadAll(r)
+			require.NoError(t, err)
+			size := int(tc.pcmFormat.Size())
+			for idx, expected := range tc.expected {
+				var actual int64
+				switch size {
+				case 1:
+					actual = int64(out[idx])
+					if tc.pcmFormat == types.PCMFormatS8 {
+						actual = int64(int8(out[idx]))
+					}
+				case 2:
+					actual = int64(int16(binary.LittleEndian.Uint16(out[idx*2:])))
+				case 4:
+					actual = int64(int32(binary.LittleEndian.Uint32(out[idx*4:])))
+				case 8:
+					actual = int64(binary.LittleEndian.Uint64(out[idx*8:]))
+				}
+				require.Equal(t, expected, actual, "sample %d", idx)
+			}
+			require.Equal(t, uint64(3), r.ClippedSamples())
+		})
+	}
+}
+
+func TestDither(t *testing.T) {
+	const frames = 20000
+	// a DC of a quarter of the S16 LSB plus a tone below a half of the LSB
+	in := make([]float64, frames)
+	inBytes := make([]byte, 0, frames*8)
+	for idx := range in {
+		in[idx] = (0.25 + 0.2*math.Sin(2*math.Pi*float64(idx)/100)) / 32768
+		inBytes = binary.LittleEndian.AppendUint64(inBytes, math.Float64bits(in[idx]))
+	}
+	quantize := func(dither Dither) []float64 {
+		r, err := NewResampler(
+			Format{Channels: 1, SampleRate: 48000, PCMFormat: types.PCMFormatFloat64LE}, bytes.NewReader(inBytes),
+			Format{Channels: 1, SampleRate: 48000, PCMFormat: types.PCMFormatS16LE},
+			OptionDither(dither),
+		)
+		require.NoError(t, err)
+		out, err := io.ReadAll(r)
+		require.NoError(t, err)
+		require.Len(t, out, frames*2)
+		errors := make([]float64, frames)
+		for idx := range errors {
+			errors[idx] = float64(int16(binary.LittleEndian.Uint16(out[idx*2:]))) - in[idx]*32768
+		}
+		return errors
+	}
+	// the power of the error below ~1/16 of the sample rate
+	lowFrequencyPower := func(errors []float64) float64 {
+		var power float64
+		for idx := 16; idx < len(errors); idx++ {
+			avg := mean(errors[idx-16 : idx])
+			power += avg * avg
+		}
+		return power / float64(len(errors)-16)
+	}
+
+	noDither := quantize(DitherNone)
+	tpdf := quantize(DitherTPDF)
+	noiseShaped := quantize(DitherNoiseShaped)
+
+	// without dither the signal is lost, with dither it is preserved on average
+	require.InDelta(t, -0.25, mean(noDither), 0.05)
+	require.InDelta(t, 0, mean(tpdf), 0.05)
+	require.InDelta(t, 0, mean(noiseShaped), 0.05)
+	require.Less(t, lowFrequencyPower(noiseShaped), lowFrequencyPower(tpdf)/4)
+
+	// dither is not applied if the bit depth is not reduced
+	s16 := make([]byte, 200)
+	for idx := range s16 {
+		s16[idx] = byte(idx * 7)
+	}
+	r, err := NewResampler(
+		Format{Channels: 2, SampleRate: 48000, PCMFormat: types.PCMFormatS16LE}, bytes.NewReader(s16),
+		Format{Channels: 2, SampleRate: 48000, PCMFormat: types.PCMFormatS16BE},
+		OptionDither(DitherTPDF),
+	)
+	require.NoError(t, err)
+	out, err := io.ReadAll(r)
+	require.NoError(t, err)
+	for idx := 0; idx < len(s16); idx += 2 {
+		require.Equal(t, s16[idx:idx+2], []byte{out[idx+1], out[idx]})
+	}
+
+	// the same if the channels are mixed, or the input is G.711
+	convert := func(inFormat Format, outFormat Format, opts ...Option) []byte {
+		r, err := NewResampler(inFormat, bytes.NewReader(s16), outFormat, opts...)
+		require.NoError(t, err)
+		out, err := io.ReadAll(r)
+		require.NoError(t, err)
+		return out
+	}
+	for _, inFormat := range []Format{
+		{Channels: 2, SampleRate: 48000, PCMFormat: types.PCMFormatS16LE},
+		{Channels: 2, SampleRate: 48000, PCMFormat: types.PCMFormatMuLaw},
+		{Channels: 2, SampleRate: 48000, PCMFormat: types.PCMFormatALaw},
+	} {
+		outFormat := Format{Channels: 2, SampleRate: 48000, PCMFormat: types.PCMFormatS16LE}
+		matrix := OptionChannelMatrix(ChannelMatrix{{0, 1}, {1, 0}})
+		require.Equal(t,
+			convert(inFormat, outFormat, matrix, OptionDither(DitherNone)),
+			convert(inFormat, outFormat, matrix, OptionDither(DitherTPDF)),
+			inFormat.PCMFormat,
+		)
+		require.Equal(t,
+			convert(inFormat, outFormat, OptionDither(DitherNone)),
+			convert(inFormat, outFormat, OptionDither(DitherNoiseShaped)),
+			inFormat.PCMFormat,
+		)
+	}
+}
+
+func mean(x []float64) float64 {
+	var sum float64
+	for _, v := range x {
+		sum += v
+	}
+	return sum / float64(len(x))
+}
+
+func TestVariableRatio(t *testing.T) {
+	format := Format{Channels: 1, SampleRate: 48000, PCMFormat: types.PCMFormatFloat64LE}
+	in := float64Sine(48000, 1, 440, 48000, 0.5)
+
+	t.Run("Invalid", func(t *testing.T) {
+		r, err := NewResampler(format, bytes.NewReader(in), format)
+		require.NoError(t, err)
+		require.Error(t, r.SetRatio(1.001))
+		require.Equal(t, 1.0, r.Ratio())
+
+		r, err = NewResampler(format, bytes.NewReader(in), format, OptionVariableRatio(true))
+		require.NoError(t, err)
+		require.Error(t, r.SetRatio(1.5))
+		require.Error(t, r.SetRatio(math.NaN()))
+		require.NoError(t, r.SetRatio(1.001))
+		require.Equal(t, 1.001, r.Ratio())
+	})
+
+	t.Run("Nominal", func(t *testing.T) {
+		r, err := NewResampler(format, bytes.NewReader(in), format, OptionVariableRatio(true))
+		require.NoError(t, err)
+		out := readFloat64s(t, r)
+		require.Len(t, out, 48000)
+		for idx := 100; idx < 47900; idx++ {
+			require.InDelta(t, 0.5*math.Sin(2*math.Pi*440*float64(idx)/48000), out[idx], 0.001, "sample %d", idx)
+		}
+	})
+
+	t.Run("Adjusted_Smoothly", func(t *testing.T) {
+		r, err := NewResampler(format, bytes.NewReader(in), format, OptionVariableRatio(true))
+		require.NoError(t, err)
+		var out []float64
+		buf := make([]byte, 8*1000)
+		for {
+			if len(out) >= 10000 && r.Ratio() == 1 {
+				require.NoError(t, r.SetRatio(1.01))
+				// 38000 input frames are left (approximately)
+				require.InDelta(t, 10000, len(out), 1000)
+			}
+			n, err := r.Read(buf)
+			out = append(out, readFloat64s(t, bytes.NewReader(buf[:n]))...)
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			require.NoError(t, err)
+		}
+		// the rest of the input is consumed at the higher ratio
+		require.InDelta(t, 48000+380, len(out), 30)
+		// no clicks: the second difference is bounded by the one of the sine
+		for idx := 100; idx < len(out)-100; idx++ {
+			require.Less(t, math.Abs(out[idx]-2*out[idx-1]+out[idx-2]), 0.002, "sample %d", idx)
+		}
+	})
+}
+
+func TestRatioController(t *testing.T) {
+	const (
+		targetFill = 4800.0
+		inputRate  = 48000 * 1.002
+		outputRate = 48000.0
+		dt         = 100 * time.Millisecond
+	)
+	// the buffer is in front of the resampler, and the input clock is faster
+	c := NewRatioController(DefaultRatioControllerConfig(targetFill))
+	fill, ratio := targetFill, 1.0
+	for range 10 * 60 * 10 {
+		fill += (inputRate - outputRate/ratio) * dt.Seconds()
+		ratio = c.Update(fill, dt)
+	}
+	require.InDelta(t, targetFill, fill, targetFill*0.1)
+	require.InDelta(t, 1/1.002, ratio, 0.0002)
+}
+
+func TestWriter(t *testing.T) {
+	in := make([]byte, 6*4*3000)
+	for idx := 0; idx < len(in)/2; idx++ {
+		binary.LittleEndian.PutUint16(in[idx*2:], uint16(int16(10000*math.Sin(float64(idx)/30))))
+	}
+	for _, tc := range []struct {
+		name      string
+		inFormat  Format
+		outFormat Format
+		opts      []Option
+	}{
+		{
+			name:      "Nearest_Stereo_44100_to_48000",
+			inFormat:  Format{Channels: 2, SampleRate: 44100, PCMFormat: types.PCMFormatS16LE},
+			outFormat: Format{Channels: 2, SampleRate: 48000, PCMFormat: types.PCMFormatFloat32LE},
+		},
+		{
+			name:      "Sinc_Mono_48000_to_16000",
+			inFormat:  Format{Channels: 1, SampleRate: 48000, PCMFormat: types.PCMFormatS16LE},
+			outFormat: Format{Channels: 1, SampleRate: 16000, PCMFormat: types.PCMFormatS16LE},
+			opts:      []Option{OptionQuality(QualityHigh), OptionDither(DitherNone)},
+		},
+		{
+			name:      "Downmix_5_1_with_Sinc",
+			inFormat:  Format{Channels: 6, SampleRate: 48000, PCMFormat: types.PCMFormatS32LE},
+			outFormat: Format{Channels: 2, SampleRate: 44100, PCMFormat: types.PCMFormatS24LE},
+			opts:      []Option{OptionQuality(QualityFast)},
+		},
+		{
+			name:      "IMAADPCM",
+			inFormat:  Format{Channels: 2, SampleRate: 8000, PCMFormat: types.PCMFormatS16LE},
+			outFormat: Format{Channels: 2, SampleRate: 8000, PCMFormat: types.PCMFormatIMAADPCM},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			r, err := NewResampler(tc.inFormat, bytes.NewReader(in), tc.outFormat, tc.opts...)
+			require.NoError(t, err)
+			expected, err := io.ReadAll(r)
+			require.NoError(t, err)
+
+			var out bytes.Buffer
+			w, err := NewWriter(tc.inFormat, &out, tc.outFormat, tc.opts...)
+			require.NoError(t, err)
+			// the chunks are not aligned to the frames
+			for offset := 0; offset < len(in); offset += 1001 {
+				n, err := w.Write(in[offset:min(offset+1001, len(in))])
+				require.NoError(t, err)
+				require.Equal(t, min(1001, len(in)-offset), n)
+			}
+			require.NoError(t, w.Close())
+			require.NoError(t, w.Close())
+			require.Equal(t, expected, out.Bytes())
+
+			_, err = w.Write(in[:4])
+			require.Error(t, err)
+
+			// the data which was not written is written by the next calls
+			flaky := &flakyWriter{}
+			requireFlakyError := func(err error) {
+				if !errors.Is(err, io.ErrShortWrite) {
+					require.ErrorIs(t, err, errFlakyWriter)
+				}
+			}
+			w, err = NewWriter(tc.inFormat, flaky, tc.outFormat, tc.opts...)
+			require.NoError(t, err)
+			for offset := 0; offset < len(in); offset += 1001 {
+				n, err := w.Write(in[offset:min(offset+1001, len(in))])
+				require.Equal(t, min(1001, len(in)-offset), n)
+				if err != nil {
+					requireFlakyError(err)
+				}
+			}
+			for attempt := 0; ; attempt++ {
+				require.Less(t, attempt, 100)
+				err := w.Close()
+				if err == nil {
+					break
+				}
+				requireFlakyError(err)
+			}
+			require.NoError(t, w.Close())
+			require.Equal(t, expected, flaky.Bytes())
+		})
+	}
+
+	t.Run("OutputError", func(t *testing.T) {
+		format := Format{Channels: 1, SampleRate: 8000, PCMFormat: types.PCMFormatS16LE}
+		w, err := NewWriter(format, failingWriter{}, format)
+		require.NoError(t, err)
+		// the input is consumed anyway, so it must not be written again
+		n, err := w.Write(in[:100])
+		require.Error(t, err)
+		require.Equal(t, 100, n)
+	})
+}
+
+type failingWriter struct{}
+
+func (failingWriter) Write([]byte) (int, error) {
+	return 0, errors.New("unable to write")
+}
+
+var errFlakyWriter = errors.New("flaky writer")
+
+// flakyWriter fails every third write, and writes only a half of the
+// data in every other third write.
+type flakyWriter struct {
+	bytes.Buffer
+	calls int
+}
+
+func (w *flakyWriter) Write(b []byte) (int, error) {
+	w.calls++
+	switch w.calls % 3 {
+	case 1:
+		return 0, errFlakyWriter
+	case 2:
+		return w.Buffer.Write(b[:len(b)/2])
+	default:
+		return w.Buffer.Write(b)
+	}
+}
+
+func TestPartialFrames(t *testing.T) {
+	in := make([]byte, 4*1000)
+	for idx := 0; idx < len(in)/2; idx++ {
+		binary.LittleEndian.PutUint16(in[idx*2:], uint16(int16(10000*math.Sin(float64(idx)/30))))
+	}
+	inFormat := Format{Channels: 2, SampleRate: 48000, PCMFormat: types.PCMFormatS16LE}
+	for _, tc := range []struct {
+		name      string
+		outFormat Format
+		opts      []Option
+	}{
+		{"Nearest_SameRate", Format{Channels: 2, SampleRate: 48000, PCMFormat: types.PCMFormatS16BE}, nil},
+		{"Nearest_Upsampling", Format{Channels: 2, SampleRate: 96000, PCMFormat: types.PCMFormatS16LE}, nil},
+		{"Sinc_Downsampling", Format{Channels: 2, SampleRate: 16000, PCMFormat: types.PCMFormatS16LE}, []Option{OptionQuality(QualityFast)}},
+		{"Matrix", Format{Channels: 3, SampleRate: 48000, PCMFormat: types.PCMFormatS16LE}, []Option{OptionChannelMatrix(ChannelMatrix{{1, 0}, {0, 1}, {0.5, 0.5}})}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			r, err := NewResampler(inFormat, bytes.NewReader(in), tc.outFormat, tc.opts...)
+			require.NoError(t, err)
+			expected, err := io.ReadAll(r)
+			require.NoError(t, err)
+
+			for name, reader := range map[string]io.Reader{
+				"OneByte": iotest.OneByteReader(bytes.NewReader(in)),
+				"Half":    iotest.HalfReader(bytes.NewReader(in)),
+				"DataErr": iotest.DataErrReader(bytes.NewReader(in)),
+			} {
+				r, err := NewResampler(inFormat, reader, tc.outFormat, tc.opts...)
+				require.NoError(t, err)
+				var out []byte
+				buf := make([]byte, 4*100)
+				for {
+					n, err := r.Read(buf)
+					out = append(out, buf[:n]...)
+					if errors.Is(err, io.EOF) {
+						break
+					}
+					require.NoError(t, err)
+					require.NotZero(t, n, name)
+				}
+				require.Equal(t, expected, out, name)
+			}
+
+			// e.g. a non-blocking pipe with an incomplete frame in it
+			r, err = NewResampler(inFormat, io.MultiReader(bytes.NewReader(in[:3]), noProgressReader{}), tc.outFormat, tc.opts...)
+			require.NoError(t, err)
+			n, err := r.Read(make([]byte, 4*100))
+			require.ErrorIs(t, err, io.ErrNoProgress)
+			require.Zero(t, n)
+		})
+	}
+
+	t.Run("IncompleteLastFrame", func(t *testing.T) {
+		// the last frame misses the right channel, which becomes silent
+		r, err := NewResampler(inFormat, bytes.NewReader([]byte{1, 0, 2, 0, 3, 0}), inFormat)
+		require.NoError(t, err)
+		n, err := r.Read(make([]byte, 3))
+		require.NoError(t, err)
+		require.Zero(t, n)
+		out, err := io.ReadAll(r)
+		require.NoError(t, err)
+		require.Equal(t, []byte{1, 0, 2, 0, 3, 0, 0, 0}, out)
+
+		// the silence of an unsigned format is not zero
+		u8Format := Format{Channels: 2, SampleRate: 8000, PCMFormat: types.PCMFormatU8}
+		r, err = NewResampler(u8Format, bytes.NewReader([]byte{1, 2, 3}), u8Format)
+		require.NoError(t, err)
+		out, err = io.ReadAll(r)
+		require.NoError(t, err)
+		require.Equal(t, []byte{1, 2, 3, 128}, out)
+	})
+}
+
+// loopReader endlessly repeats the data.
+type loopReader struct {
+	data   []byte
+	offset int
+}
+
+func (r *loopReader) Read(p []byte) (int, error) {
+	n := copy(p, r.data[r.offset:])
+	r.offset = (r.offset + n) % len(r.data)
+	return n, nil
+}
+
+// noProgressReader never returns any data, nor an error (like a
+// non-blocking pipe with no data in it).
+type noProgressReader struct{}
+
+func (noProgressReader) Read([]byte) (int, error) {
+	return 0, nil
+}
+
+// legacyReader is the conversion loop of the resampler before the batch
+// kernels (sample by sample through getFloat64/setFloat64), kept as the
+// baseline for BenchmarkResampler. It supports only the same amount of
+// channels on both sides.
+type legacyReader struct {
+	inFormat        Format
+	outFormat       Format
+	reader          io.Reader
+	buffer          []byte
+	inDistance      uint64
+	outDistance     uint64
+	outDistanceStep uint64
+}
+
+func newLegacyReader(inFormat Format, reader io.Reader, outFormat Format) *legacyReader {
+	return &legacyReader{
+		inFormat:        inFormat,
+		outFormat:       outFormat,
+		reader:          reader,
+		outDistanceStep: uint64(float64(distanceStep) * float64(inFormat.SampleRate) / float64(outFormat.SampleRate)),
+	}
+}
+
+func (r *legacyReader) Read(p []byte) (int, error) {
+	inSampleSize := uint64(r.inFormat.PCMFormat.Size())
+	outSampleSize := uint64(r.outFormat.PCMFormat.Size())
+	maxOutSamples := uint64(len(p)) / outSampleSize
+	samplesToRead := max(maxOutSamples*uint64(r.inFormat.SampleRate)/uint64(r.outFormat.SampleRate), 1)
+	if cap(r.buffer) < int(samplesToRead*inSampleSize) {
+		r.buffer = make([]byte, samplesToRead*inSampleSize)
+	}
+	n, err := r.reader.Read(r.buffer[:samplesToRead*inSampleSize])
+	samplesRead := uint64(n) / inSampleSize
+
+	var srcIdx, dstIdx uint64
+	for srcIdx < samplesRead && dstIdx < maxOutSamples {
+		for r.inDistance < r.outDistance && srcIdx < samplesRead {
+			srcIdx++
+			r.inDistance += distanceStep
+		}
+		if srcIdx >= samplesRead {
+			break
+		}
+		val := getFloat64(r.inFormat.PCMFormat, r.buffer[srcIdx*inSampleSize:])
+		for dstIdx < maxOutSamples && r.outDistance <= r.inDistance {
+			setFloat64(r.outFormat.PCMFormat, p[dstIdx*outSampleSize:], val)
+			dstIdx++
+			r.outDistance += r.outDistanceStep
+		}
+		srcIdx++
+		r.inDistance += distanceStep
+	}
+	return int(dstIdx * outSampleSize), err
+}
+
+// BenchmarkResampler compares Resampler.Read (at 48kHz with 8 channels)
+// with the sample by sample conversion it used before (see legacyReader).
+func BenchmarkResampler(b *testing.B) {
+	const channels = 8
+	for _, tc := range []struct {
+		name    string
+		in, out types.PCMFormat
+		outRate types.SampleRate
+		opts    []Option
+	}{
+		{"F32LE_to_S16LE", types.PCMFormatFloat32LE, types.PCMFormatS16LE, 48000, nil},
+		{"S16LE_to_F32LE", types.PCMFormatS16LE, types.PCMFormatFloat32LE, 48000, nil},
+		{"S24LE_to_S32LE", types.PCMFormatS24LE, types.PCMFormatS32LE, 48000, nil},
+		{"F32LE_to_S16LE_44100", types.PCMFormatFloat32LE, types.PCMFormatS16LE, 44100, nil},
+		{"F32LE_to_S16LE_44100_Sinc", types.PCMFormatFloat32LE, types.PCMFormatS16LE, 44100, []Option{OptionQuality(QualityFast)}},
+	} {
+		inFormat := Format{Channels: channels, SampleRate: 48000, PCMFormat: tc.in}
+		outFormat := Format{Channels: channels, SampleRate: tc.outRate, PCMFormat: tc.out}
+		// a second of a noisy signal
+		data := make([]byte, 48000*channels*int(tc.in.Size()))
+		rng := rand.New(rand.NewPCG(1, 2))
+		for idx := range 48000 * channels {
+			setFloat64(tc.in, data[idx*int(tc.in.Size()):], rng.Float64()*2-1)
+		}
+		run := func(b *testing.B, r io.Reader) {
+			buf := make([]byte, 4800*channels*int(tc.out.Size()))
+			b.SetBytes(int64(len(buf)))
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := r.Read(buf); err != nil {
+					b.Fatal(err)
+				}
+			}
+		}
+		if tc.opts == nil {
+			b.Run(tc.name+"/Legacy", func(b *testing.B) {
+				run(b, newLegacyReader(inFormat, &loopReader{data: data}, outFormat))
+			})
+		}
+		b.Run(tc.name+"/Resampler", func(b *testing.B) {
+			r, err := NewResampler(inFormat, &loopReader{data: data}, outFormat, tc.opts...)
+			require.NoError(b, err)
+			run(b, r)
+		})
+	}
+}
+
+func TestKernels(t *testing.T) {
+	values := []float64{
+		0, 0.5, -0.5, 1, -1, 1.5, -1.5, 0.999, -0.999, 1e-6, math.NaN(), math.Inf(1), math.Inf(-1),
+		// the rounding of the halves
+		0.5 / 128, -128.5 / 128, 127.5 / 128,
+		0.5 / 32768, -0.5 / 32768, 2.5 / 32768, 32767.5 / 32768, -32768.5 / 32768, math.Nextafter(0.5, 0) / 32768,
+		0.5 / 8388608, 8388607.5 / 8388608, 0.5 / 2147483648, -2147483648.5 / 2147483648,
+	}
+	for _, f := range []types.PCMFormat{
+		types.PCMFormatU8,
+		types.PCMFormatS8,
+		types.PCMFormatS16LE,
+		types.PCMFormatS16BE,
+		types.PCMFormatS24LE,
+		types.PCMFormatS24_32LE,
+		types.PCMFormatS32LE,
+		types.PCMFormatFloat16LE,
+		types.PCMFormatFloat32LE,
+		types.PCMFormatFloat64LE,
+	} {
+		t.Run(f.String(), func(t *testing.T) {
+			size := int(f.Size())
+			expected := make([]byte, len(values)*size)
+			var expectedClipped int
+			bits := integerBits(f)
+			for idx, v := range values {
+				setFloat64(f, expected[idx*size:], v)
+				if bits > 0 && isClipped(v, bits) {
+					expectedClipped++
+				}
+			}
+			encoded := make([]byte, len(expected))
+			clipped := encodeSamples(f, encoded, values)
+			require.Equal(t, expected, encoded)
+			require.Equal(t, expectedClipped, clipped)
+
+			decoded := make([]float64, len(values))
+			decodeSamples(f, decoded, encoded)
+			for idx := range values {
+				expected := getFloat64(f, encoded[idx*size:])
+				if math.IsNaN(expected) {
+					require.True(t, math.IsNaN(decoded[idx]))
+					continue
+				}
+				require.Equal(t, expected, decoded[idx], "sample %d", idx)
+			}
+		})
+	}
+
+	for pair, kernel := range directKernels {
+		t.Run(fmt.Sprintf("%s_to_%s", pair.In, pair.Out), func(t *testing.T) {
+			rng := rand.New(rand.NewPCG(1, 2))
+			inSize, outSize := int(pair.In.Size()), int(pair.Out.Size())
+			in := make([]byte, 4096*inSize)
+			for idx := range in {
+				in[idx] = byte(rng.Uint32())
+			}
+			for idx, v := range values {
+				setFloat64(pair.In, in[idx*inSize:], v)
+			}
+			if pair.In == types.PCMFormatFloat32LE {
+				// the whole range, not just random bit patterns
+				for idx := len(values); idx < 2048; idx++ {
+					setFloat64(pair.In, in[idx*inSize:], (rng.Float64()*2-1)*1.01)
+				}
+			}
+
+			samples := len(in) / inSize
+			expected := make([]byte, samples*outSize)
+			var expectedClipped int
+			bits := integerBits(pair.Out)
+			for idx := range samples {
+				v := getFloat64(pair.In, in[idx*inSize:])
+				setFloat64(pair.Out, expected[idx*outSize:], v)
+				if bits > 0 && isClipped(v, bits) {
+					expectedClipped++
+				}
+			}
+			out := make([]byte, len(expected))
+			require.Equal(t, expectedClipped, kernel(out, in))
+			require.Equal(t, expected, out)
+
+			// the same through the Resampler
+			inFormat := Format{Channels: 2, SampleRate: 48000, PCMFormat: pair.In}
+			outFormat := Format{Channels: 2, SampleRate: 48000, PCMFormat: pair.Out}
+			r, err := NewResampler(inFormat, bytes.NewReader(in), outFormat)
+			require.NoError(t, err)
+			require.NotNil(t, r.direct)
+			out, err = io.ReadAll(r)
+			require.NoError(t, err)
+			require.Equal(t, expected, out)
+			require.Equal(t, uint64(expectedClipped), r.ClippedSamples())
+		})
+	}
+}
+
+// BenchmarkConversion compares the sample by sample conversion (via
+// getFloat64/setFloat64) with the batch kernels (and the direct kernels
+// where available).
+func BenchmarkConversion(b *testing.B) {
+	const samples = 4800 * 8
+	for _, tc := range []struct {
+		in, out types.PCMFormat
+	}{
+		{types.PCMFormatFloat32LE, types.PCMFormatS16LE},
+		{types.PCMFormatS16LE, types.PCMFormatFloat32LE},
+		{types.PCMFormatS24LE, types.PCMFormatS32LE},
+	} {
+		in := make([]byte, samples*int(tc.in.Size()))
+		for idx := range samples {
+			setFloat64(tc.in, in[idx*int(tc.in.Size()):], math.Sin(float64(idx)/10))
+		}
+		out := make([]byte, samples*int(tc.out.Size()))
+		values := make([]float64, samples)
+		name := fmt.Sprintf("%s_to_%s", tc.in, tc.out)
+		b.Run(name+"/Generic", func(b *testing.B) {
+			b.SetBytes(int64(len(out)))
+			for i := 0; i < b.N; i++ {
+				for idx := range values {
+					values[idx] = getFloat64(tc.in, in[idx*int(tc.in.Size()):])
+				}
+				for idx, v := range values {
+					setFloat64(tc.out, out[idx*int(tc.out.Size()):], v)
+				}
+			}
+		})
+		b.Run(name+"/Batch", func(b *testing.B) {
+			b.SetBytes(int64(len(out)))
+			for i := 0; i < b.N; i++ {
+				decodeSamples(tc.in, values, in)
+				encodeSamples(tc.out, out, values)
+			}
+		})
+		if kernel, ok := directKernels[formatPair{In: tc.in, Out: tc.out}]; ok {
+			b.Run(name+"/Direct", func(b *testing.B) {
+				b.SetBytes(int64(len(out)))
+				for i := 0; i < b.N; i++ {
+					kernel(out, in)
+				}
+			})
+		}
+	}
 }
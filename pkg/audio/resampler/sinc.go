@@ -0,0 +1,303 @@
+package resampler
+
+import (
+	"errors"
+	"io"
+	"math"
+)
+
+type sincParams struct {
+	// zeroCrossings is the amount of zero crossings of the sinc on each side.
+	zeroCrossings int
+
+	// cutoff is the cutoff frequency relative to the Nyquist frequency
+	// (of the lower of the two sample rates).
+	cutoff float64
+
+	// kaiserBeta is the shape parameter of the Kaiser window.
+	kaiserBeta float64
+
+	// phases is the amount of the precalculated fractional offsets
+	// (the filter is linearly interpolated between them).
+	phases int
+}
+
+var sincPresets = map[Quality]sincParams{
+	QualityFast:   {zeroCrossings: 8, cutoff: 0.85, kaiserBeta: 5, phases: 128},
+	QualityMedium: {zeroCrossings: 16, cutoff: 0.91, kaiserBeta: 7, phases: 256},
+	QualityHigh:   {zeroCrossings: 32, cutoff: 0.95, kaiserBeta: 10, phases: 512},
+}
+
+// sincFilter is a polyphase windowed-sinc low-pass filter.
+type sincFilter struct {
+	// halfTaps is the amount of the taps on each side of the
+	// interpolated point, in input samples.
+	halfTaps int
+	phases   int
+
+	// table contains (phases+1) rows of 2*halfTaps coefficients; row "p"
+	// is the filter for the fractional offset p/phases.
+	table []float64
+}
+
+// newSincFilter builds the filter for the given ratio of the output
+// sample rate to the input sample rate.
+func newSincFilter(params sincParams, ratio float64) *sincFilter {
+	scale := params.cutoff * min(ratio, 1)
+	halfTaps := int(math.Ceil(float64(params.zeroCrossings) / scale))
+	taps := 2 * halfTaps
+	f := &sincFilter{
+		halfTaps: halfTaps,
+		phases:   params.phases,
+		table:    make([]float64, (params.phases+1)*taps),
+	}
+	kaiserNorm := besselI0(params.kaiserBeta)
+	for p := 0; p <= params.phases; p++ {
+		frac := float64(p) / float64(params.phases)
+		row := f.table[p*taps : (p+1)*taps]
+		var sum float64
+		for k := range row {
+			t := float64(k-halfTaps+1) - frac
+			x := t * scale / float64(params.zeroCrossings)
+			if x <= -1 || x >= 1 {
+				continue
+			}
+			window := besselI0(params.kaiserBeta*math.Sqrt(1-x*x)) / kaiserNorm
+			row[k] = scale * sinc(scale*t) * window
+			sum += row[k]
+		}
+		// unity gain for DC at every phase
+		for k := range row {
+			row[k] /= sum
+		}
+	}
+	return f
+}
+
+// coefficients returns the filter for the given fractional offset in [0, 1).
+func (f *sincFilter) coefficients(frac float64, result []float64) []float64 {
+	taps := 2 * f.halfTaps
+	pos := frac * float64(f.phases)
+	p := int(pos)
+	a := pos - float64(p)
+	row0 := f.table[p*taps : (p+1)*taps]
+	row1 := f.table[(p+1)*taps : (p+2)*taps]
+	result = result[:taps]
+	for k := range result {
+		result[k] = row0[k] + a*(row1[k]-row0[k])
+	}
+	return result
+}
+
+func sinc(x float64) float64 {
+	if x == 0 {
+		return 1
+	}
+	return math.Sin(math.Pi*x) / (math.Pi * x)
+}
+
+// besselI0 is the zeroth order modified Bessel function of the first kind.
+func besselI0(x float64) float64 {
+	sum, term := 1.0, 1.0
+	for k := 1; term > sum*1e-16; k++ {
+		v := x / (2 * float64(k))
+		term *= v * v
+		sum += term
+	}
+	return sum
+}
+
+// sincState is the state of the windowed-sinc conversion carried
+// across the Read calls.
+type sincState struct {
+	filter *sincFilter
+
+	// channels is the amount of the filtered channels: the input channels
+	// are averaged into one if the output is mono, and the only input
+	// channel is repeated if the input is mono.
+	channels int
+
+	// history contains the interleaved input frames (converted to float64),
+	// starting at the frame number "base"; the frames before the first one
+	// are zeros.
+	history []float64
+	base    int64
+
+	// inFrames is the amount of input frames read so far.
+	inFrames int64
+	eof      bool
+
+	// pos+frac/den is the position of the next output frame in input frames.
+	pos  int64
+	frac uint64
+	step uint64
+	den  uint64
+
+	// variable is set in the variable ratio mode; then the fractional
+	// position is variableFrac, and it is advanced by variableStep (which
+	// follows nominalStep divided by the ratio set by SetRatio).
+	variable     bool
+	variableFrac float64
+	variableStep float64
+	nominalStep  float64
+
+	coefs []float64
+}
+
+func (r *Resampler) initSinc(params sincParams) {
+	inRate, outRate := uint64(r.inFormat.SampleRate), uint64(r.outFormat.SampleRate)
+	g := gcd(inRate, outRate)
+	filterRatio := float64(outRate) / float64(inRate)
+	if r.config.VariableRatio {
+		// leaving the room for the lowest ratio
+		filterRatio *= 1 - MaxRatioDeviation
+	}
+	filter := newSincFilter(params, filterRatio)
+	channels := int(r.inFormat.Channels)
+	if r.inFormat.Channels == 1 || r.outFormat.Channels == 1 {
+		channels = 1
+	}
+	s := &sincState{
+		filter:   filter,
+		channels: channels,
+		// the zeros before the beginning of the stream
+		history: make([]float64, (filter.halfTaps-1)*channels),
+		base:    -int64(filter.halfTaps - 1),
+		step:    inRate / g,
+		den:     outRate / g,
+		coefs:   make([]float64, 2*filter.halfTaps),
+
+		variable:     r.config.VariableRatio,
+		nominalStep:  float64(inRate) / float64(outRate),
+		variableStep: float64(inRate) / float64(outRate),
+	}
+	r.sinc = s
+}
+
+func gcd(a, b uint64) uint64 {
+	for b != 0 {
+		a, b = b, a%b
+	}
+	return a
+}
+
+func (r *Resampler) readSinc(p []byte) (int, error) {
+	s := r.sinc
+	outFrameSize := int(r.outSampleSize) * int(r.outFormat.Channels)
+	maxFrames := len(p) / outFrameSize
+	if maxFrames == 0 {
+		return 0, nil
+	}
+	outChannels := int(r.outFormat.Channels)
+	r.outValues = resize(r.outValues, maxFrames*outChannels)
+	var frames int
+	for frames < maxFrames {
+		if s.eof && s.pos >= s.inFrames {
+			if frames == 0 {
+				return 0, io.EOF
+			}
+			break
+		}
+		if s.pos+int64(s.filter.halfTaps) >= s.base+int64(len(s.history)/s.channels) {
+			if frames > 0 {
+				break
+			}
+			if err := r.fillSinc(maxFrames); err != nil {
+				return 0, err
+			}
+			continue
+		}
+		r.sincFrame(r.outValues[frames*outChannels : (frames+1)*outChannels])
+		frames++
+	}
+	r.writeSamples(p[:frames*outFrameSize], r.outValues[:frames*outChannels])
+	return frames * outFrameSize, nil
+}
+
+// fillSinc reads more input frames into the history.
+func (r *Resampler) fillSinc(outFrames int) error {
+	s := r.sinc
+	halfTaps := int64(s.filter.halfTaps)
+
+	// dropping the frames which are not needed anymore
+	if drop := s.pos - halfTaps + 1 - s.base; drop > 0 {
+		n := copy(s.history, s.history[drop*int64(s.channels):])
+		s.history = s.history[:n]
+		s.base += drop
+	}
+
+	inFrameSize := int(r.inSampleSize) * int(r.inFormat.Channels)
+	framesToRead := int(uint64(outFrames)*s.step/s.den) + 1
+	if s.variable {
+		framesToRead = int(float64(outFrames)*s.variableStep) + 1
+	}
+	err := r.readInput(framesToRead*inFrameSize, inFrameSize)
+	frames := len(r.buffer) / inFrameSize
+	r.appendSincFrames(r.buffer[:frames*inFrameSize])
+	s.inFrames += int64(frames)
+	// keeping an incomplete frame for the next call
+	r.buffer = r.buffer[:copy(r.buffer, r.buffer[frames*inFrameSize:])]
+	if err != nil {
+		if !errors.Is(err, io.EOF) {
+			return err
+		}
+		// the zeros after the end of the stream
+		s.eof = true
+		s.history = append(s.history, make([]float64, int(halfTaps)*s.channels)...)
+	}
+	return nil
+}
+
+func (r *Resampler) appendSincFrames(b []byte) {
+	s := r.sinc
+	inChannels := int(r.inFormat.Channels)
+	if s.channels == inChannels {
+		length := len(s.history)
+		s.history = append(s.history, make([]float64, len(b)/int(r.inSampleSize))...)
+		decodeSamples(r.inFormat.PCMFormat, s.history[length:], b)
+		return
+	}
+	r.inValues = resize(r.inValues, len(b)/int(r.inSampleSize))
+	decodeSamples(r.inFormat.PCMFormat, r.inValues, b)
+	for offset := 0; offset < len(r.inValues); offset += inChannels {
+		var sum float64
+		for _, v := range r.inValues[offset : offset+inChannels] {
+			sum += v
+		}
+		s.history = append(s.history, sum/float64(inChannels))
+	}
+}
+
+// sincFrame calculates the next output frame.
+func (r *Resampler) sincFrame(out []float64) {
+	s := r.sinc
+	frac := float64(s.frac) / float64(s.den)
+	if s.variable {
+		frac = s.variableFrac
+	}
+	coefs := s.filter.coefficients(frac, s.coefs)
+	start := int(s.pos-int64(s.filter.halfTaps)+1-s.base) * s.channels
+	for ch := range s.channels {
+		var sum float64
+		idx := start + ch
+		for _, c := range coefs {
+			sum += c * s.history[idx]
+			idx += s.channels
+		}
+		if s.channels == len(out) {
+			out[ch] = sum
+			continue
+		}
+		for outCh := range out {
+			out[outCh] = sum
+		}
+	}
+
+	if s.variable {
+		r.advanceVariable()
+		return
+	}
+	s.frac += s.step
+	s.pos += int64(s.frac / s.den)
+	s.frac %= s.den
+}
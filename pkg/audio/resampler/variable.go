@@ -0,0 +1,126 @@
+package resampler
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+const (
+	// MaxRatioDeviation is the maximal deviation of the ratio set by
+	// SetRatio from 1.
+	MaxRatioDeviation = 0.05
+
+	// ratioSmoothingFrames is the time constant (in output frames) of the
+	// transition to a new ratio.
+	ratioSmoothingFrames = 1024
+)
+
+// SetRatio adjusts the conversion ratio relative to the nominal one
+// (defined by the sample rates): e.g. 1.001 produces 0.1% more output
+// frames per input frame. The transition is smooth, so it does not cause
+// clicks. It may be called concurrently with Read, but requires
+// OptionVariableRatio.
+func (r *Resampler) SetRatio(ratio float64) error {
+	if !r.config.VariableRatio {
+		return fmt.Errorf("the variable ratio mode is not enabled")
+	}
+	if math.IsNaN(ratio) || math.Abs(ratio-1) > MaxRatioDeviation {
+		return fmt.Errorf("the ratio %v is out of range [%v, %v]", ratio, 1-MaxRatioDeviation, 1+MaxRatioDeviation)
+	}
+	r.ratio.Store(math.Float64bits(ratio))
+	return nil
+}
+
+// Ratio returns the ratio set by SetRatio (1 by default).
+func (r *Resampler) Ratio() float64 {
+	if !r.config.VariableRatio {
+		return 1
+	}
+	return math.Float64frombits(r.ratio.Load())
+}
+
+func (r *Resampler) advanceVariable() {
+	s := r.sinc
+	targetStep := s.nominalStep / r.Ratio()
+	s.variableStep += (targetStep - s.variableStep) / ratioSmoothingFrames
+	s.variableFrac += s.variableStep
+	whole := math.Floor(s.variableFrac)
+	s.pos += int64(whole)
+	s.variableFrac -= whole
+}
+
+// RatioControllerConfig is the configuration of a RatioController.
+type RatioControllerConfig struct {
+	// TargetFill is the fill level of the buffer to be maintained (in
+	// any units, e.g. bytes).
+	TargetFill float64
+
+	// ProportionalGain is the ratio correction per the fill error
+	// relative to TargetFill.
+	ProportionalGain float64
+
+	// IntegralGain is the ratio correction per the relative fill error
+	// accumulated over a second.
+	IntegralGain float64
+
+	// Smoothing is the time constant of the averaging of the fill level
+	// (which is usually jittery due to the I/O in chunks).
+	Smoothing time.Duration
+
+	// MaxDeviation limits the deviation of the ratio from 1.
+	MaxDeviation float64
+}
+
+// DefaultRatioControllerConfig returns a configuration suitable for
+// compensating the clock drift between two audio devices.
+func DefaultRatioControllerConfig(targetFill float64) RatioControllerConfig {
+	return RatioControllerConfig{
+		TargetFill:       targetFill,
+		ProportionalGain: 0.005,
+		IntegralGain:     0.0005,
+		Smoothing:        time.Second,
+		MaxDeviation:     0.01,
+	}
+}
+
+// RatioController is a PI controller deriving the ratio for SetRatio from
+// the fill level of a buffer adjacent to the resampler. It works for a
+// buffer on either side: if the buffer grows, then the ratio is lowered
+// (producing less output, or consuming more input, per frame).
+type RatioController struct {
+	config       RatioControllerConfig
+	smoothedFill float64
+	integral     float64
+	initialized  bool
+}
+
+func NewRatioController(cfg RatioControllerConfig) *RatioController {
+	return &RatioController{
+		config: cfg,
+	}
+}
+
+// Update accounts the current fill level, measured "elapsed" after the
+// previous one, and returns the new ratio.
+func (c *RatioController) Update(fill float64, elapsed time.Duration) float64 {
+	cfg := c.config
+	if !c.initialized {
+		c.smoothedFill = fill
+		c.initialized = true
+	} else if cfg.Smoothing > 0 {
+		alpha := float64(elapsed) / float64(cfg.Smoothing+elapsed)
+		c.smoothedFill += (fill - c.smoothedFill) * alpha
+	} else {
+		c.smoothedFill = fill
+	}
+
+	fillError := (c.smoothedFill - cfg.TargetFill) / cfg.TargetFill
+	correction := cfg.ProportionalGain*fillError + c.integral
+	// anti-windup: the integral is not accumulated while saturated
+	if math.Abs(correction) < cfg.MaxDeviation {
+		c.integral += cfg.IntegralGain * fillError * elapsed.Seconds()
+	}
+	correction = cfg.ProportionalGain*fillError + c.integral
+	return 1 - min(max(correction, -cfg.MaxDeviation), cfg.MaxDeviation)
+}
@@ -0,0 +1,163 @@
+package resampler
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/xaionaro-go/audio/pkg/audio/types"
+)
+
+// errNeedMoreInput is returned by pushReader if all the written data
+// was consumed.
+var errNeedMoreInput = errors.New("need more input")
+
+// pushReader provides the data written into a Writer to its Resampler
+// (which keeps incomplete frames until the rest is written).
+type pushReader struct {
+	data   []byte
+	offset int
+	closed bool
+}
+
+func (r *pushReader) Read(p []byte) (int, error) {
+	if r.offset == len(r.data) {
+		if r.closed {
+			return 0, io.EOF
+		}
+		return 0, errNeedMoreInput
+	}
+	n := copy(p, r.data[r.offset:])
+	r.offset += n
+	return n, nil
+}
+
+// compact drops the consumed data.
+func (r *pushReader) compact() {
+	r.data = r.data[:copy(r.data, r.data[r.offset:])]
+	r.offset = 0
+}
+
+// Writer is the push counterpart of Resampler: it converts the data
+// written into it and writes the result into the output writer. It is
+// useful with RecorderPCM.RecordPCM, which pushes the recorded audio.
+type Writer struct {
+	locker    sync.Mutex
+	resampler *Resampler
+	input     *pushReader
+	output    io.Writer
+	buf       []byte
+	closed    bool
+
+	// unwritten is the converted data which the output did not accept
+	unwritten []byte
+}
+
+var _ io.WriteCloser = (*Writer)(nil)
+
+// NewWriter returns a Writer converting from inFormat to outFormat; the
+// options are the same as of NewResampler.
+func NewWriter(
+	inFormat Format,
+	output io.Writer,
+	outFormat Format,
+	opts ...Option,
+) (*Writer, error) {
+	input := &pushReader{}
+	r, err := NewResampler(inFormat, input, outFormat, opts...)
+	if err != nil {
+		return nil, err
+	}
+	outFrameSize := int(outFormat.PCMFormat.Size()) * int(outFormat.Channels)
+	if outFormat.PCMFormat == types.PCMFormatIMAADPCM {
+		outFrameSize = int(outFormat.Channels)
+	}
+	return &Writer{
+		resampler: r,
+		input:     input,
+		output:    output,
+		buf:       make([]byte, outFrameSize*4096),
+	}, nil
+}
+
+// Resampler returns the underlying Resampler (e.g. for SetRatio).
+func (w *Writer) Resampler() *Resampler {
+	return w.resampler
+}
+
+// Write converts the data and writes the result; incomplete frames are
+// kept until the next Write (or completed with silence on Close).
+//
+// "p" is consumed even if writing the result fails (the converted data
+// which was not written is kept and written first by the next Write or
+// Close), so it should not be written again.
+func (w *Writer) Write(p []byte) (int, error) {
+	w.locker.Lock()
+	defer w.locker.Unlock()
+	if w.input.closed {
+		return 0, fmt.Errorf("the writer is closed")
+	}
+	w.input.data = append(w.input.data, p...)
+	if err := w.drain(); err != nil {
+		return len(p), err
+	}
+	return len(p), nil
+}
+
+// Close writes the rest of the converted data (including the tail of
+// the filter). It does not close the output writer. If writing fails,
+// Close may be called again to retry.
+func (w *Writer) Close() error {
+	w.locker.Lock()
+	defer w.locker.Unlock()
+	if w.closed {
+		return nil
+	}
+	w.input.closed = true
+	if err := w.drain(); err != nil {
+		return err
+	}
+	w.closed = true
+	return nil
+}
+
+func (w *Writer) drain() error {
+	if len(w.unwritten) > 0 {
+		if err := w.write(w.unwritten); err != nil {
+			return err
+		}
+	}
+	for {
+		n, err := w.resampler.Read(w.buf)
+		if n > 0 {
+			if err := w.write(w.buf[:n]); err != nil {
+				return err
+			}
+		}
+		switch {
+		case err == nil:
+		case errors.Is(err, errNeedMoreInput):
+			w.input.compact()
+			return nil
+		case errors.Is(err, io.EOF):
+			return nil
+		default:
+			return fmt.Errorf("unable to convert: %w", err)
+		}
+	}
+}
+
+// write writes "b" to the output, keeping the part which was not
+// written in w.unwritten.
+func (w *Writer) write(b []byte) error {
+	n, err := w.output.Write(b)
+	if err == nil && n < len(b) {
+		err = io.ErrShortWrite
+	}
+	w.unwritten = append(w.unwritten[:0], b[n:]...)
+	if err != nil {
+		return fmt.Errorf("unable to write: %w", err)
+	}
+	return nil
+}
@@ -27,6 +27,18 @@ const (
 	PCMFormatFloat64BE = types.PCMFormatFloat64BE
 	PCMFormatS64LE     = types.PCMFormatS64LE
 	PCMFormatS64BE     = types.PCMFormatS64BE
+	PCMFormatMuLaw     = types.PCMFormatMuLaw
+	PCMFormatALaw      = types.PCMFormatALaw
+	PCMFormatIMAADPCM  = types.PCMFormatIMAADPCM
+	PCMFormatS8        = types.PCMFormatS8
+	PCMFormatU16LE     = types.PCMFormatU16LE
+	PCMFormatU16BE     = types.PCMFormatU16BE
+	PCMFormatU24LE     = types.PCMFormatU24LE
+	PCMFormatU24BE     = types.PCMFormatU24BE
+	PCMFormatS24_32LE  = types.PCMFormatS24_32LE
+	PCMFormatS24_32BE  = types.PCMFormatS24_32BE
+	PCMFormatFloat16LE = types.PCMFormatFloat16LE
+	PCMFormatFloat16BE = types.PCMFormatFloat16BE
 )
 
 type Encoding = types.Encoding
@@ -39,6 +39,30 @@ const (
 	PCMFormatFloat64BE
 	PCMFormatS64LE
 	PCMFormatS64BE
+
+	// PCMFormatMuLaw and PCMFormatALaw are 8-bit G.711 companded samples.
+	PCMFormatMuLaw
+	PCMFormatALaw
+
+	// PCMFormatIMAADPCM is a raw IMA ADPCM stream: 4 bits per sample (see
+	// BitsPerSample), two interleaved samples per byte (low nibble first).
+	PCMFormatIMAADPCM
+
+	PCMFormatS8
+	PCMFormatU16LE
+	PCMFormatU16BE
+	PCMFormatU24LE
+	PCMFormatU24BE
+
+	// PCMFormatS24_32LE and PCMFormatS24_32BE are 24-bit samples in the
+	// least significant bits of 32-bit containers (S24_LE in ALSA terms).
+	PCMFormatS24_32LE
+	PCMFormatS24_32BE
+
+	// PCMFormatFloat16LE and PCMFormatFloat16BE are IEEE 754 half-precision floats.
+	PCMFormatFloat16LE
+	PCMFormatFloat16BE
+
 	EndOfPCMFormat
 )
 
@@ -46,13 +70,16 @@ func (f PCMFormat) Size() uint32 {
 	switch f {
 	case UndefinedPCMFormat:
 		return math.MaxUint32
-	case PCMFormatU8:
+	case PCMFormatU8, PCMFormatS8, PCMFormatMuLaw, PCMFormatALaw:
 		return 1
-	case PCMFormatS16LE, PCMFormatS16BE:
+	case PCMFormatIMAADPCM:
+		// the minimal amount of whole bytes containing a sample
+		return 1
+	case PCMFormatS16LE, PCMFormatS16BE, PCMFormatU16LE, PCMFormatU16BE, PCMFormatFloat16LE, PCMFormatFloat16BE:
 		return 2
-	case PCMFormatS24LE, PCMFormatS24BE:
+	case PCMFormatS24LE, PCMFormatS24BE, PCMFormatU24LE, PCMFormatU24BE:
 		return 3
-	case PCMFormatFloat32LE, PCMFormatFloat32BE, PCMFormatS32LE, PCMFormatS32BE:
+	case PCMFormatFloat32LE, PCMFormatFloat32BE, PCMFormatS32LE, PCMFormatS32BE, PCMFormatS24_32LE, PCMFormatS24_32BE:
 		return 4
 	case PCMFormatFloat64LE, PCMFormatFloat64BE, PCMFormatS64LE, PCMFormatS64BE:
 		return 8
@@ -61,14 +88,108 @@ func (f PCMFormat) Size() uint32 {
 	}
 }
 
+// BitsPerSample returns the size of a sample in bits, which is not a
+// multiple of 8 only for PCMFormatIMAADPCM.
+func (f PCMFormat) BitsPerSample() uint32 {
+	if f == PCMFormatIMAADPCM {
+		return 4
+	}
+	return f.Size() * 8
+}
+
+// IsLinear returns false for the companded and compressed formats
+// (G.711 and IMA ADPCM), which the audio backends do not support directly.
+func (f PCMFormat) IsLinear() bool {
+	switch f {
+	case PCMFormatMuLaw, PCMFormatALaw, PCMFormatIMAADPCM:
+		return false
+	default:
+		return true
+	}
+}
+
+// Silence returns the encoding of a sample of silence (Size() bytes),
+// which is not all zeros for the unsigned and the G.711 formats.
+func (f PCMFormat) Silence() []byte {
+	switch f {
+	case PCMFormatU8:
+		return []byte{0x80}
+	case PCMFormatU16LE:
+		return []byte{0x00, 0x80}
+	case PCMFormatU16BE:
+		return []byte{0x80, 0x00}
+	case PCMFormatU24LE:
+		return []byte{0x00, 0x00, 0x80}
+	case PCMFormatU24BE:
+		return []byte{0x80, 0x00, 0x00}
+	case PCMFormatMuLaw:
+		return []byte{0xFF}
+	case PCMFormatALaw:
+		return []byte{0xD5}
+	case UndefinedPCMFormat:
+		return nil
+	default:
+		if f >= EndOfPCMFormat {
+			return nil
+		}
+		return make([]byte, f.Size())
+	}
+}
+
 func (f PCMFormat) String() string {
 	switch f {
 	case UndefinedPCMFormat:
 		return "<undefined>"
+	case PCMFormatU8:
+		return "u8"
+	case PCMFormatS8:
+		return "s8"
 	case PCMFormatS16LE:
 		return "s16le"
+	case PCMFormatS16BE:
+		return "s16be"
+	case PCMFormatU16LE:
+		return "u16le"
+	case PCMFormatU16BE:
+		return "u16be"
+	case PCMFormatS24LE:
+		return "s24le"
+	case PCMFormatS24BE:
+		return "s24be"
+	case PCMFormatU24LE:
+		return "u24le"
+	case PCMFormatU24BE:
+		return "u24be"
+	case PCMFormatS24_32LE:
+		return "s24_32le"
+	case PCMFormatS24_32BE:
+		return "s24_32be"
+	case PCMFormatS32LE:
+		return "s32le"
+	case PCMFormatS32BE:
+		return "s32be"
+	case PCMFormatS64LE:
+		return "s64le"
+	case PCMFormatS64BE:
+		return "s64be"
+	case PCMFormatFloat16LE:
+		return "f16le"
+	case PCMFormatFloat16BE:
+		return "f16be"
 	case PCMFormatFloat32LE:
 		return "f32le"
+	case PCMFormatFloat32BE:
+		return "f32be"
+	case PCMFormatFloat64LE:
+		return "f64le"
+	case PCMFormatFloat64BE:
+		return "f64be"
+	case PCMFormatMuLaw:
+		return "mulaw"
+	case PCMFormatALaw:
+		return "alaw"
+	case PCMFormatIMAADPCM:
+		return "ima_adpcm"
 	default:
 		return fmt.Sprintf("<unexpected_value_%d>", f)
 	}
@@ -104,9 +225,9 @@ func (pcm EncodingPCM) BytesPerSample() uint {
 }
 
 func (pcm EncodingPCM) BytesForSecond() uint {
-	return uint(pcm.BytesPerSample()) * uint(pcm.SampleRate)
+	return uint(pcm.PCMFormat.BitsPerSample()) * uint(pcm.SampleRate) / 8
 }
 
 func (pcm EncodingPCM) BytesForDuration(d time.Duration) uint64 {
-	return (uint64(pcm.SampleRate) * uint64(d.Microseconds()) / 1000000) * uint64(pcm.BytesPerSample())
+	return (uint64(pcm.SampleRate) * uint64(d.Microseconds()) / 1000000) * uint64(pcm.PCMFormat.BitsPerSample()) / 8
 }
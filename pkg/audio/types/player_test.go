@@ -0,0 +1,24 @@
+package types
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPCMFormatString(t *testing.T) {
+	names := map[string]struct{}{}
+	for f := UndefinedPCMFormat + 1; f < EndOfPCMFormat; f++ {
+		name := f.String()
+		require.NotContains(t, name, "<", "format %d has no name", uint(f))
+		require.NotContains(t, names, name)
+		names[name] = struct{}{}
+		require.Equal(t, f, PCMFormatFromString(name))
+		require.NotEqual(t, uint32(0), f.Size())
+		require.Len(t, f.Silence(), int(f.Size()))
+	}
+	require.Equal(t, PCMFormatS24_32LE, PCMFormatFromString("S24_32LE"))
+	require.Equal(t, UndefinedPCMFormat, PCMFormatFromString("s23le"))
+	require.Equal(t, uint32(4), PCMFormatS24_32BE.Size())
+	require.Equal(t, uint32(2), PCMFormatFloat16LE.Size())
+}